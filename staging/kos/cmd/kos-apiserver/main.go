@@ -0,0 +1,38 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	genericapiserver "k8s.io/apiserver/pkg/server"
+
+	"k8s.io/examples/staging/kos/pkg/cmd/server"
+)
+
+func main() {
+	flag.Parse()
+
+	stopCh := genericapiserver.SetupSignalHandler()
+	options := server.NewNetworkAPIServerOptions(os.Stdout, os.Stderr)
+	cmd := server.NewCommandStartNetworkAPIServer(options, stopCh)
+	if err := cmd.Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command kos-cni-agent is a CNI plugin implementing the spec's 1.0.0
+// ADD/DEL/CHECK/VERSION commands. Like cmd/kos-cni-attachment it manages a
+// NetworkAttachment per pod rather than driving a networkfabric.Interface
+// directly, but unlike that plugin it creates the container's network
+// device itself -- a veth pair, moved into the container's network
+// namespace and configured there with vishvananda/netlink (see
+// pkg/cni/veth.go) -- instead of waiting for a controller to report an
+// already-existing device. Once the device is up, ADD hands its host-side
+// name to a ConnectionAgent over a local Unix domain socket (see
+// pkg/controllers/connectionagent/agentserver.go and the netconf's
+// "agentSocket" field) so the agent's bookkeeping treats it the same as
+// an interface it created itself; DEL reverses the handoff so the agent
+// tears it down through its fabric.
+//
+// This lets a kubelet configured with this plugin reconcile container
+// lifecycle with NetworkAttachment lifecycle end to end, without a
+// separate out-of-band step to create attachments or an out-of-band
+// device.
+package main
@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	"k8s.io/examples/staging/kos/pkg/cni"
+	"k8s.io/examples/staging/kos/pkg/cniattachment"
+
+	koscsv1a1 "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/typed/network/v1alpha1"
+)
+
+// hostIfcName derives this attachment's Network Interface name in the
+// host network namespace from containerID, the same way
+// cmd/kos-cni/ifc.go's hostIfcName does: deterministically, so DEL can
+// name the device without this plugin keeping state of its own between
+// invocations. The result is truncated to fit Linux's 15-byte IFNAMSIZ
+// limit.
+func hostIfcName(containerID string) string {
+	sum := sha256.Sum256([]byte(containerID))
+	return fmt.Sprintf("kos%x", sum)[:15]
+}
+
+// cmdAdd creates a veth pair for att, moves its container end into
+// netnsPath renamed to ifName, and hands the host end's name to the
+// ConnectionAgent at conf.AgentSocket.
+func cmdAdd(subnetsIfc koscsv1a1.SubnetInterface, conf cni.NetConf, att *netv1a1.NetworkAttachment, namespace, name, containerID, netnsPath, ifName string) error {
+	subnet, err := subnetsIfc.Get(conf.Subnet, k8smetav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get Subnet %s/%s: %s", namespace, conf.Subnet, err.Error())
+	}
+	mac, err := net.ParseMAC(att.Status.MACAddress)
+	if err != nil {
+		return fmt.Errorf("invalid MAC address %q on NetworkAttachment %s/%s: %s", att.Status.MACAddress, namespace, name, err.Error())
+	}
+	addrs, err := guestAddrs(subnet, att)
+	if err != nil {
+		return err
+	}
+
+	hostName := hostIfcName(containerID)
+	if err := cni.CreateVeth(hostName, netnsPath, ifName, mac, addrs); err != nil {
+		return err
+	}
+	if err := cni.Attach(conf.AgentSocket, namespace, name, hostName); err != nil {
+		cni.DeleteHostVeth(hostName)
+		return fmt.Errorf("failed to hand %q off to agent socket %s: %s", hostName, conf.AgentSocket, err.Error())
+	}
+
+	result, err := cniattachment.BuildResult(conf.CNIVersion, att, ifName, netnsPath)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(os.Stdout).Encode(result)
+}
+
+// guestAddrs combines att's assigned addresses with their Subnet's prefix
+// lengths: NetworkAttachmentStatus.IPv4/IPv6 are bare addresses, with no
+// prefix length of their own.
+func guestAddrs(subnet *netv1a1.Subnet, att *netv1a1.NetworkAttachment) ([]net.IPNet, error) {
+	var addrs []net.IPNet
+	v4, err := withPrefixOf(att.Status.IPv4, subnet.Spec.IPv4)
+	if err != nil {
+		return nil, err
+	}
+	if v4 != nil {
+		addrs = append(addrs, *v4)
+	}
+	if att.Status.IPv6 != "" {
+		v6, err := withPrefixOf(att.Status.IPv6, subnet.Spec.IPv6)
+		if err != nil {
+			return nil, err
+		}
+		if v6 != nil {
+			addrs = append(addrs, *v6)
+		}
+	}
+	return addrs, nil
+}
+
+func withPrefixOf(addr, subnetCIDR string) (*net.IPNet, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid address %q", addr)
+	}
+	_, subnetIPNet, err := net.ParseCIDR(subnetCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet CIDR %q: %s", subnetCIDR, err.Error())
+	}
+	return &net.IPNet{IP: ip, Mask: subnetIPNet.Mask}, nil
+}
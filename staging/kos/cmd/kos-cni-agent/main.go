@@ -0,0 +1,148 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/client-go/tools/clientcmd"
+
+	"k8s.io/examples/staging/kos/pkg/cni"
+	"k8s.io/examples/staging/kos/pkg/cniattachment"
+
+	koscs "k8s.io/examples/staging/kos/pkg/client/clientset/versioned"
+)
+
+func main() {
+	if err := run(); err != nil {
+		cniattachment.EmitError(err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	command := os.Getenv("CNI_COMMAND")
+	if command == "VERSION" {
+		return json.NewEncoder(os.Stdout).Encode(struct {
+			CNIVersion        string   `json:"cniVersion"`
+			SupportedVersions []string `json:"supportedVersions"`
+		}{CNIVersion: "1.0.0", SupportedVersions: cniattachment.SupportedVersions})
+	}
+
+	containerID := os.Getenv("CNI_CONTAINERID")
+	ifName := os.Getenv("CNI_IFNAME")
+	netnsPath := os.Getenv("CNI_NETNS")
+	podNamespace, podName, podInfraContainerID := cniattachment.ParseCNIArgs(os.Getenv("CNI_ARGS"))
+	if containerID == "" {
+		return fmt.Errorf("CNI_CONTAINERID is not set")
+	}
+	if podInfraContainerID == "" {
+		podInfraContainerID = containerID
+	}
+
+	stdin, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read netconf from stdin: %s", err.Error())
+	}
+	var conf cni.NetConf
+	if err := json.Unmarshal(stdin, &conf); err != nil {
+		return fmt.Errorf("failed to parse netconf: %s", err.Error())
+	}
+	if conf.AgentSocket == "" {
+		return fmt.Errorf(`netconf is missing "agentSocket"`)
+	}
+	namespace := conf.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	timeout := cniattachment.DefaultTimeout
+	if conf.Timeout != "" {
+		timeout, err = time.ParseDuration(conf.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %s", conf.Timeout, err.Error())
+		}
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", conf.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to build kube client config: %s", err.Error())
+	}
+	kcs, err := koscs.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to build clientset: %s", err.Error())
+	}
+	attsIfc := kcs.NetworkV1alpha1().NetworkAttachments(namespace)
+	name := cniattachment.AttachmentName(podNamespace, podName, podInfraContainerID)
+
+	switch command {
+	case "ADD":
+		if netnsPath == "" || ifName == "" {
+			return fmt.Errorf("CNI_NETNS and CNI_IFNAME must both be set for ADD")
+		}
+		if conf.Subnet == "" {
+			return fmt.Errorf(`netconf is missing "subnet"`)
+		}
+		glog.V(2).Infof("kos-cni-agent ADD: pod %s/%s, container %s, attachment %s/%s", podNamespace, podName, containerID, namespace, name)
+		if _, err := cniattachment.EnsureAttachment(attsIfc, namespace, name, nodeName(), conf.Subnet, conf.PostCreateExec, conf.PostDeleteExec); err != nil {
+			return err
+		}
+		att, err := cniattachment.WaitForReady(attsIfc, namespace, name, timeout)
+		if err != nil {
+			return err
+		}
+		return cmdAdd(kcs.NetworkV1alpha1().Subnets(namespace), conf, att, namespace, name, containerID, netnsPath, ifName)
+
+	case "DEL":
+		glog.V(2).Infof("kos-cni-agent DEL: pod %s/%s, container %s, attachment %s/%s", podNamespace, podName, containerID, namespace, name)
+		if err := cni.Detach(conf.AgentSocket, namespace, name); err != nil {
+			// DEL must succeed even if the agent can no longer be reached
+			// (e.g. it has already been torn down): the NetworkAttachment
+			// is still the source of truth and its deletion below is what
+			// matters to the spec's caller.
+			glog.Warningf("kos-cni-agent DEL: failed to detach from agent socket %s: %s", conf.AgentSocket, err.Error())
+		}
+		return cniattachment.DeleteAndWait(attsIfc, namespace, name, timeout)
+
+	case "CHECK":
+		_, err := cniattachment.WaitForReady(attsIfc, namespace, name, 0)
+		return err
+
+	default:
+		return fmt.Errorf("unknown CNI_COMMAND %q", command)
+	}
+}
+
+// nodeName identifies the node the attachment is being created on, from
+// KUBERNETES_NODE_NAME (set by the kubelet plugin invocation environment
+// in deployments that wire it through) or, failing that, the local
+// hostname.
+func nodeName() string {
+	if n := os.Getenv("KUBERNETES_NODE_NAME"); n != "" {
+		return n
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
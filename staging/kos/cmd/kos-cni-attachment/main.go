@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command kos-cni-attachment is a CNI plugin that manages a
+// NetworkAttachment per CNI ADD/DEL and translates its Status into a CNI
+// Result, instead of driving a networkfabric.Interface directly the way
+// kos-cni does. It exists so kubelet/podman can use this module directly
+// against a cluster already running the connection agent (or any other
+// controller that implements NetworkAttachments), without a custom agent
+// of their own. See pkg/cniattachment for the translation logic.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/client-go/tools/clientcmd"
+
+	"k8s.io/examples/staging/kos/pkg/cniattachment"
+
+	koscs "k8s.io/examples/staging/kos/pkg/client/clientset/versioned"
+)
+
+func main() {
+	if err := run(); err != nil {
+		cniattachment.EmitError(err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	command := os.Getenv("CNI_COMMAND")
+	if command == "VERSION" {
+		return json.NewEncoder(os.Stdout).Encode(struct {
+			CNIVersion        string   `json:"cniVersion"`
+			SupportedVersions []string `json:"supportedVersions"`
+		}{CNIVersion: "1.0.0", SupportedVersions: cniattachment.SupportedVersions})
+	}
+
+	containerID := os.Getenv("CNI_CONTAINERID")
+	ifName := os.Getenv("CNI_IFNAME")
+	netnsPath := os.Getenv("CNI_NETNS")
+	podNamespace, podName, podInfraContainerID := cniattachment.ParseCNIArgs(os.Getenv("CNI_ARGS"))
+	// GC and STATUS act cluster/namespace-wide, not on a single container,
+	// so the CNI spec does not require the runtime to set CNI_CONTAINERID
+	// for them the way it does for ADD/DEL/CHECK.
+	if containerID == "" && command != "GC" && command != "STATUS" {
+		return fmt.Errorf("CNI_CONTAINERID is not set")
+	}
+	if podInfraContainerID == "" {
+		podInfraContainerID = containerID
+	}
+
+	stdin, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read netconf from stdin: %s", err.Error())
+	}
+	var conf cniattachment.NetConf
+	if err := json.Unmarshal(stdin, &conf); err != nil {
+		return fmt.Errorf("failed to parse netconf: %s", err.Error())
+	}
+	namespace := conf.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	timeout := cniattachment.DefaultTimeout
+	if conf.Timeout != "" {
+		timeout, err = time.ParseDuration(conf.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %s", conf.Timeout, err.Error())
+		}
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", conf.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to build kube client config: %s", err.Error())
+	}
+	kcs, err := koscs.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to build clientset: %s", err.Error())
+	}
+	attsIfc := kcs.NetworkV1alpha1().NetworkAttachments(namespace)
+	name := cniattachment.AttachmentName(podNamespace, podName, podInfraContainerID)
+
+	switch command {
+	case "ADD":
+		if conf.Subnet == "" {
+			return fmt.Errorf(`netconf is missing "subnet"`)
+		}
+		glog.V(2).Infof("kos-cni-attachment ADD: pod %s/%s, container %s, attachment %s/%s", podNamespace, podName, containerID, namespace, name)
+		if _, err := cniattachment.EnsureAttachment(attsIfc, namespace, name, nodeName(), conf.Subnet, conf.PostCreateExec, conf.PostDeleteExec); err != nil {
+			return err
+		}
+		att, err := cniattachment.WaitForReady(attsIfc, namespace, name, timeout)
+		if err != nil {
+			return err
+		}
+		result, err := cniattachment.BuildResult(conf.CNIVersion, att, ifName, netnsPath)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(result)
+
+	case "DEL":
+		glog.V(2).Infof("kos-cni-attachment DEL: pod %s/%s, container %s, attachment %s/%s", podNamespace, podName, containerID, namespace, name)
+		return cniattachment.DeleteAndWait(attsIfc, namespace, name, timeout)
+
+	case "CHECK":
+		_, err := cniattachment.WaitForReady(attsIfc, namespace, name, 0)
+		return err
+
+	case "STATUS":
+		return cniattachment.Status(attsIfc)
+
+	case "GC":
+		var gcArgs cniattachment.GCArgs
+		if err := json.Unmarshal(stdin, &gcArgs); err != nil {
+			return fmt.Errorf("failed to parse GC args: %s", err.Error())
+		}
+		keep := make(map[string]struct{}, len(gcArgs.Attachments))
+		for _, a := range gcArgs.Attachments {
+			keep[cniattachment.AttachmentName("", "", a.ContainerID)] = struct{}{}
+		}
+		return cniattachment.GC(attsIfc, namespace, keep)
+
+	default:
+		return fmt.Errorf("unknown CNI_COMMAND %q", command)
+	}
+}
+
+// nodeName identifies the node the attachment is being created on, from
+// KUBERNETES_NODE_NAME (set by the kubelet plugin invocation environment
+// in deployments that wire it through) or, failing that, the local
+// hostname.
+func nodeName() string {
+	if n := os.Getenv("KUBERNETES_NODE_NAME"); n != "" {
+		return n
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command kos-cni is a CNI (https://github.com/containernetworking/cni)
+// plugin, implementing the spec's 0.4.0 ADD/DEL/CHECK/VERSION commands,
+// that creates and destroys Network Interfaces through
+// k8s.io/examples/staging/kos/pkg/networkfabric.Interface instead of
+// talking to a ConnectionAgent. It exists so that a KOS fabric can be
+// driven as a secondary network by a CNI meta-plugin (e.g. Multus)
+// alongside a cluster's primary pod network, without a ConnectionAgent or
+// the NetworkAttachment API in the loop.
+//
+// The plugin's netconf (read from stdin, per the CNI spec) carries a
+// "fabric" field naming a registered networkfabric factory (see
+// k8s.io/examples/staging/kos/pkg/networkfabric.NewFabric) and a
+// "fabricConfig" object holding that factory's Config, plus the "mac" and
+// "ip" this Network Interface should use and the "segmentID" it belongs
+// to; unlike most CNI plugins this one does not delegate to a separate
+// IPAM plugin, since in KOS these values are already decided upstream (by
+// a Subnet/NetworkAttachment controller, or by whatever is driving this
+// plugin directly).
+//
+// ADD calls CreateLocalIfc to create the Network Interface's Linux device
+// in the host network namespace, then moves it into the container's
+// network namespace, renames it to the requested CNI_IFNAME, and sets its
+// MAC, IP and up state there. DEL calls DeleteLocalIfc, by the same
+// deterministic host-side name ADD used; this works even though the
+// device has since been renamed and moved to another namespace, because
+// the fabric looks the Network Interface up by the name it was created
+// under, not by where the underlying Linux device currently lives. CHECK
+// calls ListLocalIfcs and confirms that name is still present.
+//
+// Moving and configuring the device inside the container's namespace is
+// done by shelling out to the "ip" CLI with its "-n <netns>" form, the
+// same way the ovs fabric shells out to ovs-vsctl/ovs-ofctl; this requires
+// an iproute2 new enough to accept a namespace path (rather than only a
+// name registered under /var/run/netns) for "-n", which is true of every
+// distribution this has been tried on but is not guaranteed by any spec.
+//
+// GC, the spec's mechanism for reconciling a plugin's state against a
+// list of container IDs the runtime still considers valid, is not
+// implemented: this plugin keeps no state of its own, beyond what
+// networkfabric.Interface already tracks, to reconcile against.
+package main
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/examples/staging/kos/pkg/networkfabric/ovsdb"
+	"k8s.io/examples/staging/kos/pkg/networkfabric/vxlan"
+)
+
+// fabricConfigDecoders maps a networkfabric factory name to a function
+// that decodes a netConf's FabricConfig into that factory's Config type.
+// networkfabric.Factory takes an interface{}, so something has to know
+// the concrete type to unmarshal into; this is that something, kept here
+// rather than in networkfabric itself since it is specific to what this
+// binary lets its netconf express.
+//
+// "ovs" is the only fabric missing here: it (see
+// ../../pkg/networkfabric/ovs/doc.go) has no Config type or registered
+// factory yet, so there is nothing for this binary to decode into or
+// invoke for it.
+var fabricConfigDecoders = map[string]func(json.RawMessage) (interface{}, error){
+	ovsdb.FactoryName: decodeOVSDBConfig,
+	vxlan.FactoryName: decodeVXLANConfig,
+}
+
+func decodeOVSDBConfig(raw json.RawMessage) (interface{}, error) {
+	var cfg ovsdb.Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid fabricConfig for fabric %q: %s", ovsdb.FactoryName, err.Error())
+	}
+	return cfg, nil
+}
+
+func decodeVXLANConfig(raw json.RawMessage) (interface{}, error) {
+	var cfg vxlan.Config
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid fabricConfig for fabric %q: %s", vxlan.FactoryName, err.Error())
+	}
+	return cfg, nil
+}
+
+// decodeFabricConfig decodes raw into the Config type of the fabric named
+// fabricName, using whichever decoder in fabricConfigDecoders is
+// registered under that name.
+func decodeFabricConfig(fabricName string, raw json.RawMessage) (interface{}, error) {
+	decode, found := fabricConfigDecoders[fabricName]
+	if !found {
+		return nil, fmt.Errorf("no fabricConfig decoder registered for fabric %q", fabricName)
+	}
+	return decode(raw)
+}
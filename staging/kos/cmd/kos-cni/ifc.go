@@ -0,0 +1,166 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"os/exec"
+
+	"k8s.io/examples/staging/kos/pkg/networkfabric"
+)
+
+// hostIfcName derives this Network Interface's name in the host network
+// namespace, and the name networkfabric.Interface identifies it by for
+// the lifetime of the container, from containerID. It is deterministic so
+// that DEL, given only the same CNI_CONTAINERID ADD saw, can name the
+// Network Interface to delete without this plugin keeping any state of
+// its own between invocations. The result is truncated to fit Linux's
+// 15-byte IFNAMSIZ limit.
+func hostIfcName(containerID string) string {
+	sum := sha256.Sum256([]byte(containerID))
+	return fmt.Sprintf("kos%x", sum)[:15]
+}
+
+// cmdAdd creates conf's Network Interface on the host and moves it into
+// the container's network namespace, renamed to ifName.
+func cmdAdd(conf *netConf, containerID, netnsPath, ifName string) (*result, error) {
+	mac, err := net.ParseMAC(conf.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mac %q: %s", conf.MAC, err.Error())
+	}
+	ip, ipNet, err := net.ParseCIDR(conf.IP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ip %q: %s", conf.IP, err.Error())
+	}
+	prefixLen, _ := ipNet.Mask.Size()
+
+	fabricConfig, err := decodeFabricConfig(conf.Fabric, conf.FabricConfig)
+	if err != nil {
+		return nil, err
+	}
+	fabric, err := networkfabric.NewFabric(conf.Fabric, fabricConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate fabric %q: %s", conf.Fabric, err.Error())
+	}
+
+	hostName := hostIfcName(containerID)
+	ifc := networkfabric.LocalNetIfc{
+		Name:      hostName,
+		SegmentID: conf.SegmentID,
+		GuestMAC:  mac,
+		GuestIPs:  []net.IP{ip},
+	}
+	if err := fabric.CreateLocalIfc(ifc); err != nil {
+		return nil, fmt.Errorf("failed to create local Network Interface %q: %s", hostName, err.Error())
+	}
+
+	if err := moveAndConfigureInNetns(hostName, netnsPath, ifName, mac, ip, prefixLen); err != nil {
+		fabric.DeleteLocalIfc(ifc)
+		return nil, err
+	}
+
+	return &result{
+		CNIVersion: conf.CNIVersion,
+		Interfaces: []ifcResult{{Name: ifName, Mac: mac.String(), Sandbox: netnsPath}},
+		IPs:        []ipResult{{Address: conf.IP, Interface: intPtr(0)}},
+	}, nil
+}
+
+// cmdDel deletes the Network Interface ADD created for containerID, if it
+// still exists. Per the CNI spec, DEL must succeed even if the container's
+// network namespace is already gone, so this does not touch netnsPath at
+// all; deleting the Network Interface through the fabric is enough,
+// regardless of where its device currently lives.
+func cmdDel(conf *netConf, containerID string) error {
+	fabricConfig, err := decodeFabricConfig(conf.Fabric, conf.FabricConfig)
+	if err != nil {
+		return err
+	}
+	fabric, err := networkfabric.NewFabric(conf.Fabric, fabricConfig)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate fabric %q: %s", conf.Fabric, err.Error())
+	}
+	hostName := hostIfcName(containerID)
+	if err := fabric.DeleteLocalIfc(networkfabric.LocalNetIfc{Name: hostName}); err != nil {
+		return fmt.Errorf("failed to delete local Network Interface %q: %s", hostName, err.Error())
+	}
+	return nil
+}
+
+// cmdCheck confirms the Network Interface ADD created for containerID is
+// still known to the fabric.
+func cmdCheck(conf *netConf, containerID string) error {
+	fabricConfig, err := decodeFabricConfig(conf.Fabric, conf.FabricConfig)
+	if err != nil {
+		return err
+	}
+	fabric, err := networkfabric.NewFabric(conf.Fabric, fabricConfig)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate fabric %q: %s", conf.Fabric, err.Error())
+	}
+	ifcs, err := fabric.ListLocalIfcs()
+	if err != nil {
+		return fmt.Errorf("failed to list local Network Interfaces: %s", err.Error())
+	}
+	hostName := hostIfcName(containerID)
+	for _, ifc := range ifcs {
+		if ifc.Name == hostName {
+			return nil
+		}
+	}
+	return fmt.Errorf("local Network Interface %q not found", hostName)
+}
+
+// moveAndConfigureInNetns moves the host device named hostName into the
+// network namespace at netnsPath, renames it to ifName, and sets its MAC,
+// address and up state there, by shelling out to "ip" the same way the
+// ovs fabric shells out to ovs-vsctl/ovs-ofctl.
+func moveAndConfigureInNetns(hostName, netnsPath, ifName string, mac net.HardwareAddr, ip net.IP, prefixLen int) error {
+	if err := runIP("link", "set", "dev", hostName, "netns", netnsPath); err != nil {
+		return fmt.Errorf("failed to move %q into namespace %q: %s", hostName, netnsPath, err.Error())
+	}
+	if err := runIPInNetns(netnsPath, "link", "set", "dev", hostName, "name", ifName); err != nil {
+		return fmt.Errorf("failed to rename %q to %q in namespace %q: %s", hostName, ifName, netnsPath, err.Error())
+	}
+	if err := runIPInNetns(netnsPath, "link", "set", "dev", ifName, "address", mac.String()); err != nil {
+		return fmt.Errorf("failed to set MAC of %q in namespace %q: %s", ifName, netnsPath, err.Error())
+	}
+	addr := fmt.Sprintf("%s/%d", ip.String(), prefixLen)
+	if err := runIPInNetns(netnsPath, "addr", "add", addr, "dev", ifName); err != nil {
+		return fmt.Errorf("failed to set address of %q in namespace %q: %s", ifName, netnsPath, err.Error())
+	}
+	if err := runIPInNetns(netnsPath, "link", "set", "dev", ifName, "up"); err != nil {
+		return fmt.Errorf("failed to bring up %q in namespace %q: %s", ifName, netnsPath, err.Error())
+	}
+	return nil
+}
+
+func runIP(args ...string) error {
+	out, err := exec.Command("ip", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ip %v: %s: %s", args, err.Error(), string(out))
+	}
+	return nil
+}
+
+func runIPInNetns(netnsPath string, args ...string) error {
+	return runIP(append([]string{"-n", netnsPath}, args...)...)
+}
+
+func intPtr(i int) *int { return &i }
@@ -0,0 +1,123 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+
+	// Importing a fabric package registers its Factory (see
+	// networkfabric.RegisterFabricFactory) under FactoryName; ovsdb and
+	// vxlan have one today.
+	_ "k8s.io/examples/staging/kos/pkg/networkfabric/ovsdb"
+	_ "k8s.io/examples/staging/kos/pkg/networkfabric/vxlan"
+)
+
+func main() {
+	if err := run(); err != nil {
+		emitError(err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	command := os.Getenv("CNI_COMMAND")
+	containerID := os.Getenv("CNI_CONTAINERID")
+	netnsPath := os.Getenv("CNI_NETNS")
+	ifName := os.Getenv("CNI_IFNAME")
+	args := parseCNIArgs(os.Getenv("CNI_ARGS"))
+
+	if command == "VERSION" {
+		fmt.Println(`{"cniVersion": "0.4.0", "supportedVersions": ["0.3.1", "0.4.0"]}`)
+		return nil
+	}
+
+	stdin, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read netconf from stdin: %s", err.Error())
+	}
+	var conf netConf
+	if err := json.Unmarshal(stdin, &conf); err != nil {
+		return fmt.Errorf("failed to parse netconf: %s", err.Error())
+	}
+
+	if containerID == "" {
+		return fmt.Errorf("CNI_CONTAINERID is not set")
+	}
+
+	switch command {
+	case "ADD":
+		if netnsPath == "" || ifName == "" {
+			return fmt.Errorf("CNI_NETNS and CNI_IFNAME must both be set for ADD")
+		}
+		glog.V(2).Infof("kos-cni ADD: pod %s/%s, container %s, fabric %q", args.K8sPodNamespace, args.K8sPodName, containerID, conf.Fabric)
+		res, err := cmdAdd(&conf, containerID, netnsPath, ifName)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(os.Stdout).Encode(res)
+
+	case "DEL":
+		glog.V(2).Infof("kos-cni DEL: pod %s/%s, container %s", args.K8sPodNamespace, args.K8sPodName, containerID)
+		return cmdDel(&conf, containerID)
+
+	case "CHECK":
+		return cmdCheck(&conf, containerID)
+
+	case "GC":
+		// Nothing to reconcile: this plugin keeps no state of its own
+		// beyond what the fabric already tracks. See doc.go.
+		return nil
+
+	default:
+		return fmt.Errorf("unknown CNI_COMMAND %q", command)
+	}
+}
+
+// parseCNIArgs parses CNI_ARGS, a ';'-separated list of KEY=VALUE pairs;
+// keys this plugin doesn't recognize are ignored.
+func parseCNIArgs(raw string) cniArgs {
+	var args cniArgs
+	for _, pair := range strings.Split(raw, ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "K8S_POD_NAMESPACE":
+			args.K8sPodNamespace = kv[1]
+		case "K8S_POD_NAME":
+			args.K8sPodName = kv[1]
+		}
+	}
+	return args
+}
+
+// emitError prints err to stdout as a CNI spec error result; the spec
+// requires errors to go to stdout, not stderr.
+func emitError(err error) {
+	json.NewEncoder(os.Stdout).Encode(cniError{
+		CNIVersion: "0.4.0",
+		Code:       genericErrorCode,
+		Msg:        err.Error(),
+	})
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "encoding/json"
+
+// netConf is this plugin's netconf, the JSON document the CNI spec says is
+// passed on stdin. The fields common to every CNI plugin are mixed in
+// alongside the ones specific to kos-cni.
+type netConf struct {
+	CNIVersion string `json:"cniVersion"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+
+	// Fabric is the name a networkfabric Factory was registered under
+	// (see networkfabric.RegisterFabricFactory); "ovsdb" is the only one
+	// with a real implementation today.
+	Fabric string `json:"fabric"`
+
+	// FabricConfig is decoded into Fabric's own Config type by
+	// decodeFabricConfig; its shape is entirely up to the named fabric.
+	FabricConfig json.RawMessage `json:"fabricConfig"`
+
+	// SegmentID is the Network Interface's tunneled segment, passed
+	// straight through to networkfabric.LocalNetIfc.
+	SegmentID uint32 `json:"segmentID"`
+
+	// MAC is the Network Interface's guest MAC address.
+	MAC string `json:"mac"`
+
+	// IP is the Network Interface's single guest address, in CIDR form
+	// (e.g. "10.0.0.5/24"); the prefix length is used to configure the
+	// address inside the container's namespace, and is not part of
+	// networkfabric.LocalNetIfc itself.
+	IP string `json:"ip"`
+}
+
+// cniArgs holds the subset of CNI_ARGS this plugin reads. CNI_ARGS is a
+// ';'-separated list of KEY=VALUE pairs; unrecognized keys are ignored.
+type cniArgs struct {
+	K8sPodNamespace string
+	K8sPodName      string
+}
+
+// result is this plugin's CNI spec 0.4.0 "success" result, printed to
+// stdout by ADD and CHECK.
+type result struct {
+	CNIVersion string      `json:"cniVersion"`
+	Interfaces []ifcResult `json:"interfaces,omitempty"`
+	IPs        []ipResult  `json:"ips,omitempty"`
+}
+
+type ifcResult struct {
+	Name    string `json:"name"`
+	Mac     string `json:"mac,omitempty"`
+	Sandbox string `json:"sandbox,omitempty"`
+}
+
+type ipResult struct {
+	Address   string `json:"address"`
+	Interface *int   `json:"interface,omitempty"`
+}
+
+// cniError is the CNI spec's error result, printed to stdout (not stderr)
+// with a non-zero exit code. Code 100 is this plugin's only error code,
+// chosen from the range the spec reserves for plugin-specific errors
+// (codes 0-99 are reserved for the spec's own well-known errors).
+type cniError struct {
+	CNIVersion string `json:"cniVersion"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+	Details    string `json:"details,omitempty"`
+}
+
+const genericErrorCode = 100
@@ -17,6 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	fuzz "github.com/google/gofuzz"
@@ -137,49 +141,150 @@ func (writes WriteSet) UnionMax(others WriteSet) WriteSet {
 	return ans
 }
 
-// Timestamp records a time and is not truncated when marshalled.  A
-// Timestamp does not record a location but is unambiguous; it is the
-// number of nanoseconds since Jan 1, 1970 began in Greenwich, UK.
+// MaxWrite returns the greatest ServerTime among the set's writes, the
+// zero Timestamp if the set is empty. Pass it as Tick's prev argument to
+// record a new write that causally follows every write already in the
+// set.
+func (writes WriteSet) MaxWrite() Timestamp {
+	var max Timestamp
+	for _, wr := range writes {
+		max = max.Max(wr.ServerTime)
+	}
+	return max
+}
+
+// Select returns the subset of writes named by sections, in the order
+// sections lists them, omitting any name writes has no entry for. Pass
+// the value of a "sections=" query parameter to let a caller fetch or
+// watch only the ExtendedObjectMeta entries it cares about (e.g.
+// status.impl) instead of reconciling on every section's writes.
+func (writes WriteSet) Select(sections ...string) WriteSet {
+	ans := make(WriteSet, 0, len(sections))
+	for _, section := range sections {
+		if wr, found := writes.GetWrite(section); found {
+			ans = append(ans, wr)
+		}
+	}
+	return ans
+}
+
+// StaleSections returns the subset of others whose ServerTime is not
+// causally after the receiver's write to the same section -- the
+// sections where accepting others in place of the receiver would lose a
+// write rather than advance it. A PATCH registrar can call this with the
+// stored WriteSet as the receiver and the write the request is attempting
+// as others, and reject the request (409, naming the sections this
+// returns) instead of relying on the object's single ResourceVersion,
+// which would also conflict with concurrent writers of unrelated
+// sections.
+func (writes WriteSet) StaleSections(others WriteSet) WriteSet {
+	var ans WriteSet
+	for _, owr := range others {
+		wr, found := writes.GetWrite(owr.Section)
+		if found && !wr.ServerTime.Before(owr.ServerTime) {
+			ans = append(ans, owr)
+		}
+	}
+	return ans
+}
+
+// Timestamp is a Hybrid Logical Clock value: the (physical time, logical
+// counter, writing node) triple recorded for a write. HLCs compare
+// lexicographically on those three fields, so causally related writes
+// recorded by apiservers with skewed wall clocks still compare correctly;
+// see Tick. A zero Timestamp denotes "no write recorded". It marshals as
+// the string "<rfc3339nano>@<logical>#<nodeID>"; see UnmarshalJSON for
+// the pre-HLC representation it also accepts.
 type Timestamp struct {
-	// Nano is that number.
-	Nano int64 `json:"nano" protobuf:"varint,1,name=nano"`
+	// PhysicalNano is, ordinarily, the writer's wall-clock time in
+	// nanoseconds since Jan 1, 1970 began in Greenwich, UK. Tick can
+	// instead carry forward a causally prior value's PhysicalNano, when
+	// that is later than the writer's own wall clock.
+	PhysicalNano int64 `json:"-" protobuf:"varint,1,name=physicalNano"`
+
+	// Logical disambiguates writes that share the same PhysicalNano. It
+	// is reset to 0 whenever PhysicalNano advances and otherwise
+	// increments; see Tick.
+	Logical uint32 `json:"-" protobuf:"varint,2,name=logical"`
+
+	// NodeID identifies the writer that produced this value, so that
+	// writes with equal (PhysicalNano, Logical) from distinct writers
+	// still compare deterministically. Empty for values that predate
+	// HLCs; see UnmarshalJSON.
+	NodeID string `json:"-" protobuf:"bytes,3,name=nodeID"`
 }
 
-// NewTime returns a wrapped instance of the provided time
+// NewTime returns a wrapped instance of the provided time, with no
+// logical counter or node ID. Useful for tests and display; writers
+// recording a new write should use Tick instead, so that the result is
+// ordered correctly with respect to prior writes.
 func NewTimestamp(time time.Time) Timestamp {
 	// Time::UnixNano() is unambiguous
-	return Timestamp{time.UnixNano()}
+	return Timestamp{PhysicalNano: time.UnixNano()}
 }
 
 // Date returns the Timestamp corresponding to the supplied parameters
-// by wrapping time.Date.
+// by wrapping time.Date. Like NewTimestamp, it carries no logical
+// counter or node ID.
 func Date(year int, month time.Month, day, hour, min, sec, nsec int, loc *time.Location) Timestamp {
-	return Timestamp{time.Date(year, month, day, hour, min, sec, nsec, loc).UnixNano()}
+	return Timestamp{PhysicalNano: time.Date(year, month, day, hour, min, sec, nsec, loc).UnixNano()}
 }
 
-// Now returns the current local time.
+// Now returns the current local time, with no logical counter or node
+// ID. Like NewTimestamp, prefer Tick when recording a new write.
 func Now() Timestamp {
-	return Timestamp{time.Now().UnixNano()}
+	return Timestamp{PhysicalNano: time.Now().UnixNano()}
+}
+
+// Tick produces the HLC value for a new write by nodeID, given prev, the
+// greatest HLC value already recorded for the object being written (the
+// zero Timestamp if none). It implements the standard HLC update rule:
+// the new physical component is the later of prev's and the local wall
+// clock, and the logical component increments only when the wall clock
+// did not advance past prev's physical component.
+func Tick(prev Timestamp, nodeID string) Timestamp {
+	return tick(prev, time.Now().UnixNano(), nodeID)
+}
+
+func tick(prev Timestamp, physicalNow int64, nodeID string) Timestamp {
+	np := prev.PhysicalNano
+	if physicalNow > np {
+		np = physicalNow
+	}
+	var nl uint32
+	if np == prev.PhysicalNano {
+		nl = prev.Logical + 1
+	}
+	return Timestamp{PhysicalNano: np, Logical: nl, NodeID: nodeID}
 }
 
 // IsZero returns true if the value is zero.
 func (ts Timestamp) IsZero() bool {
-	return ts.Nano == 0
+	return ts.PhysicalNano == 0 && ts.Logical == 0 && ts.NodeID == ""
 }
 
-// Sub returns the difference between the two timestamps
+// Sub returns the difference between the two timestamps' physical
+// components; the logical counter and node ID do not represent a
+// duration.
 func (ts Timestamp) Sub(us Timestamp) time.Duration {
-	return time.Duration(ts.Nano - us.Nano)
+	return time.Duration(ts.PhysicalNano - us.PhysicalNano)
 }
 
-// Before reports whether the time instant t is before u.
+// Before reports whether ts is ordered before us, comparing
+// (PhysicalNano, Logical, NodeID) lexicographically.
 func (ts Timestamp) Before(us Timestamp) bool {
-	return ts.Nano < us.Nano
+	if ts.PhysicalNano != us.PhysicalNano {
+		return ts.PhysicalNano < us.PhysicalNano
+	}
+	if ts.Logical != us.Logical {
+		return ts.Logical < us.Logical
+	}
+	return ts.NodeID < us.NodeID
 }
 
-// Equal reports whether the time instant t is equal to u.
+// Equal reports whether ts and us are the same HLC value.
 func (ts Timestamp) Equal(us Timestamp) bool {
-	return ts.Nano == us.Nano
+	return ts.PhysicalNano == us.PhysicalNano && ts.Logical == us.Logical && ts.NodeID == us.NodeID
 }
 
 // Min returns the earlier of the two, receiver if tie
@@ -199,9 +304,10 @@ func (ts Timestamp) Max(us Timestamp) Timestamp {
 }
 
 // Unix returns the local time corresponding to the given Unix time
-// by wrapping time.Unix.
+// by wrapping time.Unix. Like NewTimestamp, it carries no logical
+// counter or node ID.
 func Unix(sec int64, nsec int64) Timestamp {
-	return Timestamp{time.Unix(sec, nsec).UnixNano()}
+	return Timestamp{PhysicalNano: time.Unix(sec, nsec).UnixNano()}
 }
 
 // Fuzz satisfies fuzz.Interface.
@@ -210,23 +316,69 @@ func (ts *Timestamp) Fuzz(c fuzz.Continue) {
 		return
 	}
 	// Allow for about 1000 years of randomness.
-	ts.Nano = time.Unix(c.Rand.Int63n(1000*365*24*60*60), c.Rand.Int63n(1000000000)).UnixNano()
+	ts.PhysicalNano = time.Unix(c.Rand.Int63n(1000*365*24*60*60), c.Rand.Int63n(1000000000)).UnixNano()
+	ts.Logical = uint32(c.RandUint64())
+	ts.NodeID = c.RandString()
 }
 
 var _ fuzz.Interface = &Timestamp{}
 
-// String formats the timestamp after shifting into UTC
+// String formats the timestamp as "<rfc3339nano>@<logical>#<nodeID>",
+// shifting the physical component into UTC.
 func (ts Timestamp) String() string {
 	utc := ts.Time().In(time.UTC)
-	return utc.Format(MetaTimestampFormat)
+	return fmt.Sprintf("%s@%d#%s", utc.Format(MetaTimestampFormat), ts.Logical, ts.NodeID)
 }
 
-// MetaTimestampFormat is the format used by Timestamp::String()
+// MetaTimestampFormat is the format used by Timestamp::String() for the
+// physical component.
 const MetaTimestampFormat = time.RFC3339Nano
 
-// Time converts to a time.Time
+// Time converts ts's physical component to a time.Time.
 func (ts Timestamp) Time() time.Time {
-	return time.Unix(0, ts.Nano)
+	return time.Unix(0, ts.PhysicalNano)
+}
+
+// MarshalJSON marshals ts as "<rfc3339nano>@<logical>#<nodeID>".
+func (ts Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ts.String())
+}
+
+// UnmarshalJSON parses the "<rfc3339nano>@<logical>#<nodeID>" form
+// produced by MarshalJSON. For compatibility with data written before
+// this type became an HLC, it also accepts that form's {"nano": <int64>}
+// shape, reading it as PhysicalNano=nano, Logical=0, NodeID="".
+func (ts *Timestamp) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return ts.unmarshalString(s)
+	}
+	var legacy struct {
+		Nano int64 `json:"nano"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("invalid Timestamp %s: %s", data, err.Error())
+	}
+	*ts = Timestamp{PhysicalNano: legacy.Nano}
+	return nil
+}
+
+func (ts *Timestamp) unmarshalString(s string) error {
+	atIdx := strings.IndexByte(s, '@')
+	hashIdx := strings.IndexByte(s, '#')
+	if atIdx < 0 || hashIdx < atIdx {
+		return fmt.Errorf("invalid Timestamp %q: want <rfc3339nano>@<logical>#<nodeID>", s)
+	}
+	t, err := time.Parse(MetaTimestampFormat, s[:atIdx])
+	if err != nil {
+		return fmt.Errorf("invalid Timestamp %q: %s", s, err.Error())
+	}
+	logical, err := strconv.ParseUint(s[atIdx+1:hashIdx], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid Timestamp %q: %s", s, err.Error())
+	}
+	*ts = Timestamp{PhysicalNano: t.UnixNano(), Logical: uint32(logical), NodeID: s[hashIdx+1:]}
+	return nil
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -260,11 +412,16 @@ type NetworkAttachmentSpec struct {
 	// has executed the results of the execution are reported through the
 	// NetworkAttachmentStatus PostCreateExecReport field.
 	// The connection agent is configured with a set of allowed programs
-	// to invoke. If a non-allowed program is requested then the result
+	// to invoke, and this is also enforced at admission time against the
+	// ExecPolicy bound to the attachment's namespace (see ExecPolicy and
+	// pkg/registry/network/networkattachment's ExecPolicyGetter), so a
+	// request naming a disallowed program is rejected rather than merely
+	// reported. If a non-allowed program is requested then the result
 	// will report an error.  Each argument is subjected to a very
 	// restricted form of variable expansion.  The only allowed syntax
 	// is `${variableName}` and the only variables are `ifname`, `ipv4`,
-	// and `mac`.
+	// and `mac`, unless the bound ExecPolicy's AllowedVariables says
+	// otherwise.
 	// PostCreateExec is immutable: attempts to update it will fail.
 	// +optional
 	// +patchStrategy=replace
@@ -283,6 +440,58 @@ type NetworkAttachmentSpec struct {
 	// +optional
 	// +patchStrategy=replace
 	PostDeleteExec []string `json:"postDeleteExec,omitempty" protobuf:"bytes,4,opt,name=postDeleteExec" patchStrategy:"replace"`
+
+	// RequestedIPv4 pins this attachment to a specific IPv4 address
+	// inside its Subnet, instead of letting the IPAM path pick one. The
+	// IPAM path locks exactly this address and reports an error in
+	// Status.Errors.IPAM -- rather than falling back to allocating a
+	// different one -- if it lies outside every one of the Subnet's
+	// ranges, collides with a range's Gateway, or is already locked.
+	// +optional
+	RequestedIPv4 string `json:"requestedIPv4,omitempty" protobuf:"bytes,5,opt,name=requestedIPv4"`
+
+	// RequestedIPv6 is RequestedIPv4's IPv6 counterpart, honored the
+	// same way against the Subnet's IPv6 ranges.
+	// +optional
+	RequestedIPv6 string `json:"requestedIPv6,omitempty" protobuf:"bytes,6,opt,name=requestedIPv6"`
+
+	// Routes, if non-empty, override the owning Subnet's Spec.Routes for
+	// this attachment; the agent copies whichever applies into
+	// Status.Routes at bind time.
+	// +optional
+	// +patchStrategy=replace
+	Routes []Route `json:"routes,omitempty" protobuf:"bytes,7,rep,name=routes" patchStrategy:"replace"`
+
+	// DNS, if non-zero, overrides the owning Subnet's Spec.DNS for this
+	// attachment; the agent copies whichever applies into Status.DNS at
+	// bind time.
+	// +optional
+	DNS DNSConfig `json:"dns,omitempty" protobuf:"bytes,8,opt,name=dns"`
+
+	// PreDeleteExec is a command to exec inside the attachment host's
+	// connection agent container before the attachment's Linux network
+	// interface is deleted. Setting it causes the PreDeleteExecFinalizer
+	// to be added at delete time, so that deletion blocks until the
+	// connection agent has run the command, recorded the result in
+	// Status.PreDeleteExecReport, and removed the finalizer. The same
+	// restrictions and variable expansions as for PostCreateExec are
+	// applied.
+	// PreDeleteExec is immutable: attempts to update it will fail.
+	// +optional
+	// +patchStrategy=replace
+	PreDeleteExec []string `json:"preDeleteExec,omitempty" protobuf:"bytes,9,rep,name=preDeleteExec" patchStrategy:"replace"`
+
+	// RescheduleOnNodeLoss, if true, tells pkg/controllers/remediation that
+	// this attachment should be deleted (rather than merely stranded, with
+	// its IP address released and a Host error recorded) once its node has
+	// been unready for longer than the remediation controller's grace
+	// period. Deleting it lets whatever created it notice the deletion and
+	// recreate the attachment, presumably on a different node. The zero
+	// value leaves a stranded attachment in place for an operator to deal
+	// with, which is appropriate for attachments nothing recreates on its
+	// own.
+	// +optional
+	RescheduleOnNodeLoss bool `json:"rescheduleOnNodeLoss,omitempty" protobuf:"varint,10,opt,name=rescheduleOnNodeLoss"`
 }
 
 type NetworkAttachmentStatus struct {
@@ -304,6 +513,26 @@ type NetworkAttachmentStatus struct {
 	// +optional
 	IPv4 string `json:"ipv4,omitempty" protobuf:"bytes,4,opt,name=ipv4"`
 
+	// LockUID6 is the UID of the IPLock object holding this attachment's
+	// IPv6 address, or the empty string when there is no IPv6 address.
+	// This field is a private detail of the implementation, not really
+	// part of the public API.
+	// +optional
+	LockUID6 string `json:"lockUID6,omitempty" protobuf:"bytes,9,opt,name=lockUID6"`
+
+	// AddressVNIv6 is the VNI associated with this attachment's IPv6
+	// address assignment, or the empty string when there is no IPv6
+	// address. It is expected to equal AddressVNI, since both addresses
+	// come from the same Subnet, but is tracked separately so the IPv4
+	// and IPv6 assignments can be released independently.
+	// +optional
+	AddressVNIv6 uint32 `json:"addressVNIv6,omitempty" protobuf:"bytes,10,opt,name=addressVNIv6"`
+
+	// IPv6 is non-empty when an IPv6 address has been assigned. A
+	// NetworkAttachment whose Subnet is v4-only never gets one.
+	// +optional
+	IPv6 string `json:"ipv6,omitempty" protobuf:"bytes,11,opt,name=ipv6"`
+
 	// MACAddress is non-empty while there is a corresponding Linux
 	// network interface on the host.
 	// +optional
@@ -330,6 +559,26 @@ type NetworkAttachmentStatus struct {
 	// interface was first created.
 	// +optional
 	PostCreateExecReport *ExecReport `json:"postCreateExecReport,omitempty" protobuf:"bytes,8,opt,name=postCreateExecReport"`
+
+	// Routes are the routes installed for this attachment's Linux
+	// network interface: Spec.Routes if non-empty, otherwise copied
+	// from the owning Subnet's Spec.Routes at bind time.
+	// +optional
+	// +patchStrategy=replace
+	Routes []Route `json:"routes,omitempty" protobuf:"bytes,12,rep,name=routes" patchStrategy:"replace"`
+
+	// DNS is the DNS configuration installed for this attachment's
+	// Linux network interface: Spec.DNS if non-zero, otherwise copied
+	// from the owning Subnet's Spec.DNS at bind time.
+	// +optional
+	DNS DNSConfig `json:"dns,omitempty" protobuf:"bytes,13,opt,name=dns"`
+
+	// PreDeleteExecReport, if non-nil, reports on the run of
+	// Spec.PreDeleteExec that was launched when the attachment's Linux
+	// network interface was about to be deleted, symmetric with
+	// PostCreateExecReport.
+	// +optional
+	PreDeleteExecReport *ExecReport `json:"preDeleteExecReport,omitempty" protobuf:"bytes,14,opt,name=preDeleteExecReport"`
 }
 
 type NetworkAttachmentErrors struct {
@@ -383,16 +632,42 @@ func (x *ExecReport) Equiv(y *ExecReport) bool {
 const (
 	NASectionSpec       = "spec"
 	NASectionAddr       = "status.address"
+	NASectionAddr6      = "status.address6"
 	NASectionImpl       = "status.impl"
 	NASectionExecReport = "status.execReport"
+
+	// NASectionRequestedAddr is written when the IPAM path has just
+	// granted a Spec.RequestedIPv4 or Spec.RequestedIPv6 pin, letting a
+	// client distinguish "my requested address was honored" from the
+	// broader "some address was (re)assigned" that NASectionAddr and
+	// NASectionAddr6 track.
+	NASectionRequestedAddr = "status.requestedAddress"
+
+	// NASectionRoutes and NASectionDNS are written when Status.Routes
+	// and Status.DNS (respectively) change, whether from the
+	// attachment's own Spec override or from the owning Subnet's
+	// defaults being copied in at bind time.
+	NASectionRoutes = "status.routes"
+	NASectionDNS    = "status.dns"
+
+	// NASectionPreDeleteExec is written both when the
+	// PreDeleteExecFinalizer is added at delete time and when
+	// Status.PreDeleteExecReport is later filled in.
+	NASectionPreDeleteExec = "status.preDeleteExecReport"
 )
 
+// PreDeleteExecFinalizer is added to a NetworkAttachment's finalizers when
+// it has a non-empty Spec.PreDeleteExec, blocking its actual removal until
+// the connection agent has run that command and removed the finalizer.
+const PreDeleteExecFinalizer = "network.kos.example.com/pre-delete"
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // NetworkAttachment is about a Linux network interface connected to a
 // Subnet.  The sections recorded in ExtendedObjectMeta are: spec,
-// status.address, status.impl, status.execReport.
+// status.address, status.address6, status.requestedAddress, status.impl,
+// status.execReport, status.routes, status.dns, status.preDeleteExecReport.
 type NetworkAttachment struct {
 	metav1.TypeMeta `json:",inline"`
 
@@ -411,7 +686,8 @@ type NetworkAttachment struct {
 
 // SubnetSpec is the desired state of a subnet.
 // For a given VNI, all the subnets having that VNI:
-// - have disjoint IP ranges, and
+// - have disjoint IPv4 ranges,
+// - have disjoint IPv6 ranges, and
 // - are in the same Kubernetes API namespace.
 type SubnetSpec struct {
 	// IPv4 is the CIDR notation for the v4 address range of this subnet.
@@ -422,14 +698,176 @@ type SubnetSpec struct {
 	// Valid values are in the range [1,2097151].
 	// It is immutable: attempts to update it will fail.
 	VNI uint32 `json:"vni" protobuf:"bytes,2,name=vni"`
+
+	// IPv6 is the CIDR notation for the v6 address range of this subnet.
+	// A subnet with no IPv6 is v4-only. It is immutable: attempts to
+	// update it will fail.
+	// +optional
+	IPv6 string `json:"ipv6,omitempty" protobuf:"bytes,3,opt,name=ipv6"`
+
+	// Ranges is an optional list of additional address pools this Subnet
+	// carves its allocations from, on top of the single IPv4/IPv6 CIDRs
+	// above. Mirroring the CNI host-local IPAM plugin, each entry is an
+	// independent, possibly non-contiguous pool with its own Subnet,
+	// RangeStart, RangeEnd and Gateway; an allocator round-robins across
+	// them in order. It is immutable: attempts to update it will fail.
+	// +optional
+	// +patchStrategy=replace
+	Ranges []IPRange `json:"ranges,omitempty" protobuf:"bytes,4,rep,name=ranges" patchStrategy:"replace"`
+
+	// Routes, if non-empty, are the default routes the agent copies into
+	// a NetworkAttachment's Status.Routes at bind time, for attachments
+	// in this Subnet whose own Spec.Routes is empty.
+	// +optional
+	// +patchStrategy=replace
+	Routes []Route `json:"routes,omitempty" protobuf:"bytes,5,rep,name=routes" patchStrategy:"replace"`
+
+	// DNS, if non-zero, is the default DNS configuration the agent
+	// copies into a NetworkAttachment's Status.DNS at bind time, for
+	// attachments in this Subnet whose own Spec.DNS is zero.
+	// +optional
+	DNS DNSConfig `json:"dns,omitempty" protobuf:"bytes,6,opt,name=dns"`
+
+	// IPAM selects the pkg/ipam/backend.Backend this Subnet draws its
+	// allocations from. A zero value means the original etcd-backed
+	// scheme of one IPLock per claimed address
+	// (pkg/ipam/backend.DefaultBackendName), unchanged from before this
+	// field existed. It is immutable: attempts to update it will fail,
+	// since changing backends out from under a Subnet with addresses
+	// already claimed in the old one would orphan them.
+	// +optional
+	IPAM *SubnetIPAM `json:"ipam,omitempty" protobuf:"bytes,7,opt,name=ipam"`
+}
+
+// SubnetIPAM names a pkg/ipam/backend.Backend and carries the
+// backend-specific configuration its Factory decodes, the way a CNI
+// NetConf's "ipam" section names a plugin and carries its config.
+type SubnetIPAM struct {
+	// Name is the name a pkg/ipam/backend.Factory was registered under
+	// (see pkg/ipam/backend.RegisterBackendFactory).
+	Name string `json:"name" protobuf:"bytes,1,name=name"`
+
+	// Config is backend-specific configuration, as raw JSON text; each
+	// backend's Factory defines its own shape and validates it at
+	// construction time. Empty means the backend has no configuration
+	// of its own.
+	// +optional
+	Config string `json:"config,omitempty" protobuf:"bytes,2,opt,name=config"`
+}
+
+// Route is a route to install for a NetworkAttachment's Linux network
+// interface, in the shape a CNI 1.0 result's routes use.
+type Route struct {
+	// Dst is the destination of the route, in CIDR notation.
+	Dst string `json:"dst" protobuf:"bytes,1,name=dst"`
+
+	// GW is the route's gateway address. Omitted, it means the
+	// interface's own link is the route's gateway.
+	// +optional
+	GW string `json:"gw,omitempty" protobuf:"bytes,2,opt,name=gw"`
+
+	// MTU, if non-zero, overrides the route's MTU.
+	// +optional
+	MTU uint32 `json:"mtu,omitempty" protobuf:"varint,3,opt,name=mtu"`
+
+	// AdvMSS, if non-zero, overrides the route's advertised TCP MSS.
+	// +optional
+	AdvMSS uint32 `json:"advmss,omitempty" protobuf:"varint,4,opt,name=advmss"`
+}
+
+// RoutesEqual reports whether x and y list the same routes in the same
+// order.
+func RoutesEqual(x, y []Route) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DNSConfig is the DNS configuration for a NetworkAttachment's Linux
+// network interface, in the shape a CNI 1.0 result's dns uses.
+type DNSConfig struct {
+	// Nameservers is the list of nameservers to configure, in priority
+	// order.
+	// +optional
+	// +patchStrategy=replace
+	Nameservers []string `json:"nameservers,omitempty" protobuf:"bytes,1,rep,name=nameservers" patchStrategy:"replace"`
+
+	// Domain is the local domain to append to bare hostnames.
+	// +optional
+	Domain string `json:"domain,omitempty" protobuf:"bytes,2,opt,name=domain"`
+
+	// Search is the list of search domains to configure, in priority
+	// order.
+	// +optional
+	// +patchStrategy=replace
+	Search []string `json:"search,omitempty" protobuf:"bytes,3,rep,name=search" patchStrategy:"replace"`
+
+	// Options is the list of resolver options to configure, e.g.
+	// "ndots:2".
+	// +optional
+	// +patchStrategy=replace
+	Options []string `json:"options,omitempty" protobuf:"bytes,4,rep,name=options" patchStrategy:"replace"`
+}
+
+// Equal reports whether d and e hold the same DNS configuration.
+func (d DNSConfig) Equal(e DNSConfig) bool {
+	return stringSliceEqual(d.Nameservers, e.Nameservers) &&
+		d.Domain == e.Domain &&
+		stringSliceEqual(d.Search, e.Search) &&
+		stringSliceEqual(d.Options, e.Options)
+}
+
+func stringSliceEqual(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, xi := range x {
+		if xi != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// IPRange is one contiguous, possibly bounded, pool of addresses within a
+// Subnet, in the style of the CNI host-local IPAM plugin's Range.
+type IPRange struct {
+	// Subnet is the CIDR this range's addresses are drawn from.
+	Subnet string `json:"subnet" protobuf:"bytes,1,name=subnet"`
+
+	// RangeStart is the first allocatable address in Subnet, inclusive.
+	// Defaults to the first address in Subnet after its network address.
+	// +optional
+	RangeStart string `json:"rangeStart,omitempty" protobuf:"bytes,2,opt,name=rangeStart"`
+
+	// RangeEnd is the last allocatable address in Subnet, inclusive.
+	// Defaults to the last address in Subnet before its broadcast address.
+	// +optional
+	RangeEnd string `json:"rangeEnd,omitempty" protobuf:"bytes,3,opt,name=rangeEnd"`
+
+	// Gateway, if set, is never allocated.
+	// +optional
+	Gateway string `json:"gateway,omitempty" protobuf:"bytes,4,opt,name=gateway"`
+
+	// Exclude lists additional CIDRs within Subnet whose addresses are
+	// never allocated.
+	// +optional
+	// +patchStrategy=replace
+	Exclude []string `json:"exclude,omitempty" protobuf:"bytes,5,rep,name=exclude" patchStrategy:"replace"`
 }
 
 type SubnetStatus struct {
 	// Validated tells users and consumers whether the subnet spec has passed
-	// validation or not. The fields that undergo validation are spec.ipv4 and
-	// spec.vni. If Validated is true it is guaranteed to stay true for the
-	// whole lifetime of the subnet. If Validated is false or unset, there are
-	// three possible reasons:
+	// validation or not. The fields that undergo validation are spec.ipv4,
+	// spec.ipv6, spec.vni, and spec.ranges. If Validated is true it is guaranteed to stay
+	// true for the whole lifetime of the subnet. If Validated is false or
+	// unset, there are three possible reasons:
 	// 	(1) Validation has not been performed yet.
 	// 	(2) The subnet CIDR overlaps with the CIDR of another subnet with the
 	//		same VNI.
@@ -446,6 +884,69 @@ type SubnetStatus struct {
 	// +optional
 	// +patchStrategy=replace
 	Errors []string `json:"errors,omitempty" protobuf:"bytes,2,opt,name=errors" patchStrategy:"replace"`
+
+	// LastAllocatedIPs records, for each index into Spec.Ranges, the last
+	// address an allocator handed out from that range, so that an
+	// allocator restarting does not always start over from that range's
+	// RangeStart. It has one entry per entry of Spec.Ranges once
+	// populated; the empty string at index i means range i has not been
+	// used for an allocation yet.
+	// +optional
+	// +patchStrategy=replace
+	LastAllocatedIPs []string `json:"lastAllocatedIPs,omitempty" protobuf:"bytes,3,rep,name=lastAllocatedIPs" patchStrategy:"replace"`
+
+	// NextRangeIndex is the index into Spec.Ranges an allocator should try
+	// first for the next allocation, so that successive allocations
+	// round-robin across Ranges instead of always favoring index 0.
+	// +optional
+	NextRangeIndex uint32 `json:"nextRangeIndex,omitempty" protobuf:"bytes,4,opt,name=nextRangeIndex"`
+
+	// ReadyNodes is how many of the Nodes hosting a NetworkAttachment to
+	// this Subnet have successfully installed its VNI/OVS state, per the
+	// most recent NodeConditions entry for each.
+	// +optional
+	ReadyNodes int32 `json:"readyNodes,omitempty" protobuf:"varint,5,opt,name=readyNodes"`
+
+	// NotReadyNodes is the complement of ReadyNodes: how many of those
+	// Nodes have not (yet, or not currently) installed this Subnet's
+	// VNI/OVS state. A Subnet stuck with NotReadyNodes above zero is
+	// degraded -- some of its NetworkAttachments are on Nodes that cannot
+	// actually carry their traffic.
+	// +optional
+	NotReadyNodes int32 `json:"notReadyNodes,omitempty" protobuf:"varint,6,opt,name=notReadyNodes"`
+
+	// NodeConditions reports, per Node that has ever hosted a
+	// NetworkAttachment to this Subnet, the most recent install/recover
+	// transition the subnet controller observed. ReadyNodes and
+	// NotReadyNodes are this slice's Ready field tallied.
+	// +optional
+	// +patchStrategy=replace
+	NodeConditions []NodeCondition `json:"nodeConditions,omitempty" protobuf:"bytes,7,rep,name=nodeConditions" patchStrategy:"replace"`
+}
+
+// NodeCondition reports one Node's most recently observed success or
+// failure installing a Subnet's VNI/OVS state, the way kube-ovn's
+// ProviderNetwork status tracks per-node readiness.
+type NodeCondition struct {
+	// Node is the object name of the Node this condition is about.
+	Node string `json:"node" protobuf:"bytes,1,name=node"`
+
+	// LastHeartbeatTime is when this condition was last confirmed true,
+	// not merely when Reason or Message last changed.
+	LastHeartbeatTime metav1.Time `json:"lastHeartbeatTime" protobuf:"bytes,2,name=lastHeartbeatTime"`
+
+	// Reason is a short, machine-readable cause for the current Ready
+	// value, e.g. "OVSFlowInstallFailed".
+	// +optional
+	Reason string `json:"reason,omitempty" protobuf:"bytes,3,opt,name=reason"`
+
+	// Message is a human-readable elaboration on Reason.
+	// +optional
+	Message string `json:"message,omitempty" protobuf:"bytes,4,opt,name=message"`
+
+	// Ready is true if Node last reported this Subnet's VNI/OVS state as
+	// successfully installed, false if it last reported a failure.
+	Ready bool `json:"ready" protobuf:"varint,5,name=ready"`
 }
 
 // The ExtendedObjectMeta sections for a Subnet
@@ -454,6 +955,20 @@ const (
 	SubnetSectionStatus = "status"
 )
 
+// FabricAnnotation, when set on a Subnet, names the network fabric (as
+// registered with a connection agent's netfabric.MultiFabric) that owns the
+// interfaces of Virtual Networks carved out of that Subnet. A Subnet without
+// this annotation uses the connection agent's configured default fabric.
+const FabricAnnotation = "network.kos.example.com/fabric"
+
+// WireguardPublicKeyAnnotation, when set on a Node, publishes the WireGuard
+// public key of the secure-mode connection agent running on that node (see
+// pkg/controllers/connectionagent's wireguard.go). Other connection agents
+// watch Nodes for this annotation to resolve a remote NetworkAttachment's
+// Status.HostIP to the peer they should configure on their own WireGuard
+// device.
+const WireguardPublicKeyAnnotation = "network.kos.example.com/wireguard-public-key"
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
@@ -487,8 +1002,29 @@ type SubnetList struct {
 	Items []Subnet `json:"items" protobuf:"bytes,2,rep,name=items"`
 }
 
+// IPFamily identifies which of a dual-stack Subnet's address families an
+// IPLock reserves an address from.
+type IPFamily string
+
+const (
+	IPv4Family IPFamily = "IPv4"
+	IPv6Family IPFamily = "IPv6"
+)
+
 type IPLockSpec struct {
 	SubnetName string `json:"subnetName" protobuf:"bytes,1,name=subnetName"`
+
+	// Family is which of SubnetName's address families this lock reserves
+	// an address from. The zero value is treated as IPv4Family, so IPLocks
+	// created before dual-stack Subnets existed keep their meaning.
+	// +optional
+	Family IPFamily `json:"family,omitempty" protobuf:"bytes,2,opt,name=family"`
+
+	// RangeIndex is the index into SubnetName's Spec.Ranges this lock
+	// reserves an address from, or nil when the lock is against the
+	// Subnet's single top-level IPv4/IPv6 CIDR rather than one of Ranges.
+	// +optional
+	RangeIndex *int32 `json:"rangeIndex,omitempty" protobuf:"varint,3,opt,name=rangeIndex"`
 }
 
 // The ExtendedObjectMeta sections for an IPLock.
@@ -523,3 +1059,119 @@ type IPLockList struct {
 
 	Items []IPLock `json:"items" protobuf:"bytes,2,rep,name=items"`
 }
+
+// AttachmentDisruptionBudgetSpec bounds how many of a Subnet's
+// NetworkAttachments pkg/controllers/remediation may strand concurrently,
+// the way a PodDisruptionBudget bounds how many of a workload's Pods a
+// voluntary disruption may evict concurrently.
+type AttachmentDisruptionBudgetSpec struct {
+	// Subnet is the object name of the Subnet this budget applies to. It
+	// is immutable: attempts to update it will fail.
+	Subnet string `json:"subnet" protobuf:"bytes,1,name=subnet"`
+
+	// MaxConcurrentRemediations is how many of Subnet's NetworkAttachments
+	// the remediation controller may have in progress (IP released, Host
+	// error recorded, but not yet reconciled by whatever owns the
+	// attachment) at once. Zero blocks remediation of this Subnet's
+	// attachments entirely.
+	MaxConcurrentRemediations int32 `json:"maxConcurrentRemediations" protobuf:"varint,2,name=maxConcurrentRemediations"`
+}
+
+// AttachmentDisruptionBudgetStatus reports the remediation controller's
+// current consumption of Spec.MaxConcurrentRemediations.
+type AttachmentDisruptionBudgetStatus struct {
+	// CurrentRemediations is how many of Spec.Subnet's NetworkAttachments
+	// the remediation controller currently considers in progress.
+	// +optional
+	CurrentRemediations int32 `json:"currentRemediations,omitempty" protobuf:"varint,1,opt,name=currentRemediations"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type AttachmentDisruptionBudget struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+
+	Spec AttachmentDisruptionBudgetSpec `json:"spec" protobuf:"bytes,2,name=spec"`
+
+	// +optional
+	Status AttachmentDisruptionBudgetStatus `json:"status,omitempty" protobuf:"bytes,3,opt,name=status"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// AttachmentDisruptionBudgetList is a list of AttachmentDisruptionBudget
+// objects.
+type AttachmentDisruptionBudgetList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+
+	Items []AttachmentDisruptionBudget `json:"items" protobuf:"bytes,2,rep,name=items"`
+}
+
+// ExecPolicySpec bounds the commands a NetworkAttachment in Namespace may
+// name in its PostCreateExec, PostDeleteExec, and PreDeleteExec fields, so
+// that an admission plugin can reject a request before the connection
+// agent ever sees it instead of the agent being the only thing standing
+// between a user with write access to NetworkAttachments and an arbitrary
+// command.
+type ExecPolicySpec struct {
+	// Namespace is the NetworkAttachment namespace this policy binds to.
+	// At most one ExecPolicy may bind to a given namespace.
+	Namespace string `json:"namespace" protobuf:"bytes,1,name=namespace"`
+
+	// AllowedPrograms is the set of programs a PostCreateExec,
+	// PostDeleteExec, or PreDeleteExec in Namespace may invoke, as
+	// path.Match glob patterns matched against argv[0].
+	// +patchStrategy=replace
+	AllowedPrograms []string `json:"allowedPrograms" protobuf:"bytes,2,rep,name=allowedPrograms" patchStrategy:"replace"`
+
+	// AllowedVariables is the set of ${variableName} references argv[1:]
+	// may use. Defaults to {ifname, ipv4, mac} (see
+	// NetworkAttachmentSpec.PostCreateExec) when empty.
+	// +optional
+	// +patchStrategy=replace
+	AllowedVariables []string `json:"allowedVariables,omitempty" protobuf:"bytes,3,rep,name=allowedVariables" patchStrategy:"replace"`
+
+	// MaxArgs caps the number of argv entries. Zero means no cap.
+	// +optional
+	MaxArgs int32 `json:"maxArgs,omitempty" protobuf:"varint,4,opt,name=maxArgs"`
+
+	// MaxCommandBytes caps the combined length, in bytes, of all argv
+	// entries. Zero means no cap.
+	// +optional
+	MaxCommandBytes int32 `json:"maxCommandBytes,omitempty" protobuf:"varint,5,opt,name=maxCommandBytes"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ExecPolicy is cluster-scoped, like a PodSecurityPolicy, because the
+// allowlist it carries is cluster-admin policy rather than something a
+// namespace's own users should be able to relax for themselves.
+type ExecPolicy struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+
+	Spec ExecPolicySpec `json:"spec" protobuf:"bytes,2,name=spec"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ExecPolicyList is a list of ExecPolicy objects.
+type ExecPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
+
+	Items []ExecPolicy `json:"items" protobuf:"bytes,2,rep,name=items"`
+}
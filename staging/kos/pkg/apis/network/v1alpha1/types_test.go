@@ -0,0 +1,129 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// TestTickMonotonicAcrossSkewedWriters simulates several apiservers, each
+// with its own skewed wall clock, repeatedly recording writes to a single
+// shared section: every Tick is given the section's current max HLC as
+// prev, as WriteSet.MaxWrite would return it. However badly the writers'
+// clocks skew relative to each other, the resulting sequence of HLCs must
+// never go backwards.
+func TestTickMonotonicAcrossSkewedWriters(t *testing.T) {
+	for seed := int64(0); seed < 20; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		nodes := make([]string, 4)
+		skew := make([]int64, len(nodes))
+		for i := range nodes {
+			nodes[i] = fmt.Sprintf("node-%d", i)
+			// Skew each writer's clock by up to +/- 1 second.
+			skew[i] = rnd.Int63n(2000000000) - 1000000000
+		}
+
+		var max Timestamp
+		for step := 0; step < 1000; step++ {
+			w := rnd.Intn(len(nodes))
+			localNow := int64(step)*100000000 + skew[w]
+			next := tick(max, localNow, nodes[w])
+
+			if next.Before(max) {
+				t.Fatalf("seed %d step %d: Tick produced %v, which is before the prior max %v", seed, step, next, max)
+			}
+			if next.Equal(max) {
+				t.Fatalf("seed %d step %d: Tick produced %v, identical to the prior max %v", seed, step, next, max)
+			}
+			max = next
+		}
+	}
+}
+
+// TestTimestampJSONRoundTrip checks that MarshalJSON/UnmarshalJSON agree,
+// and that the legacy pre-HLC {"nano": N} shape still decodes.
+func TestTimestampJSONRoundTrip(t *testing.T) {
+	want := Timestamp{PhysicalNano: 1577836800000000000, Logical: 7, NodeID: "node-a"}
+	data, err := want.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %s", err.Error())
+	}
+	var got Timestamp
+	if err := got.UnmarshalJSON(data); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %s", err.Error())
+	}
+	if !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	var legacy Timestamp
+	if err := legacy.UnmarshalJSON([]byte(`{"nano": 1577836800000000000}`)); err != nil {
+		t.Fatalf("UnmarshalJSON of legacy shape failed: %s", err.Error())
+	}
+	wantLegacy := Timestamp{PhysicalNano: 1577836800000000000}
+	if !legacy.Equal(wantLegacy) {
+		t.Errorf("got %v, want %v", legacy, wantLegacy)
+	}
+}
+
+// TestWriteSetSelect checks that Select returns only the named sections,
+// in the order the caller asked for them, and silently omits a name the
+// set has no entry for.
+func TestWriteSetSelect(t *testing.T) {
+	writes := WriteSet{
+		{Section: "spec", ServerTime: Timestamp{PhysicalNano: 1}},
+		{Section: "status.impl", ServerTime: Timestamp{PhysicalNano: 2}},
+		{Section: "status.addr", ServerTime: Timestamp{PhysicalNano: 3}},
+	}
+
+	got := writes.Select("status.addr", "spec", "status.missing")
+	want := WriteSet{
+		{Section: "status.addr", ServerTime: Timestamp{PhysicalNano: 3}},
+		{Section: "spec", ServerTime: Timestamp{PhysicalNano: 1}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Section != want[i].Section || !got[i].ServerTime.Equal(want[i].ServerTime) {
+			t.Errorf("got[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestWriteSetStaleSections checks that StaleSections reports an entry of
+// others only when the receiver already has a write to that section that
+// is not causally before it -- the receiver having no entry at all, or an
+// older one, is not stale.
+func TestWriteSetStaleSections(t *testing.T) {
+	receiver := WriteSet{
+		{Section: "spec", ServerTime: Timestamp{PhysicalNano: 10}},
+		{Section: "status.impl", ServerTime: Timestamp{PhysicalNano: 10}},
+	}
+	others := WriteSet{
+		{Section: "spec", ServerTime: Timestamp{PhysicalNano: 5}},         // stale: older than receiver's
+		{Section: "status.impl", ServerTime: Timestamp{PhysicalNano: 20}}, // fresh: newer than receiver's
+		{Section: "status.addr", ServerTime: Timestamp{PhysicalNano: 1}},  // receiver has no entry: not stale
+	}
+
+	got := receiver.StaleSections(others)
+	if len(got) != 1 || got[0].Section != "spec" {
+		t.Errorf("got %+v, want exactly the stale write to \"spec\"", got)
+	}
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// ApplyOptions carries the field manager identity an Apply/ApplyStatus
+// call applies under, plus whether to force-take ownership of fields
+// another manager already holds. This stands in for
+// k8s.io/apimachinery/pkg/apis/meta/v1's ApplyOptions, which does not
+// exist at the apimachinery version this module is pinned to (see this
+// package's doc.go); it carries the same two fields real callers actually
+// set.
+type ApplyOptions struct {
+	// FieldManager is the name under which the fields set by this apply
+	// are recorded, e.g. pkg/controllers/subnet/validator.go's
+	// "subnet-validator".
+	FieldManager string
+
+	// Force, when true, lets this apply take fields away from a manager
+	// that already owns them instead of failing with a conflict.
+	Force bool
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// DNSConfigApplyConfiguration represents a declarative configuration of the
+// DNSConfig type for use with apply.
+type DNSConfigApplyConfiguration struct {
+	Nameservers []string `json:"nameservers,omitempty"`
+	Domain      *string  `json:"domain,omitempty"`
+	Search      []string `json:"search,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// DNSConfig constructs a declarative configuration of the DNSConfig type
+// for use with apply.
+func DNSConfig() *DNSConfigApplyConfiguration {
+	return &DNSConfigApplyConfiguration{}
+}
+
+// WithNameservers appends the given values to the Nameservers field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Nameservers
+// field.
+func (b *DNSConfigApplyConfiguration) WithNameservers(values ...string) *DNSConfigApplyConfiguration {
+	b.Nameservers = append(b.Nameservers, values...)
+	return b
+}
+
+// WithDomain sets the Domain field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *DNSConfigApplyConfiguration) WithDomain(value string) *DNSConfigApplyConfiguration {
+	b.Domain = &value
+	return b
+}
+
+// WithSearch appends the given values to the Search field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Search field.
+func (b *DNSConfigApplyConfiguration) WithSearch(values ...string) *DNSConfigApplyConfiguration {
+	b.Search = append(b.Search, values...)
+	return b
+}
+
+// WithOptions appends the given values to the Options field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Options field.
+func (b *DNSConfigApplyConfiguration) WithOptions(values ...string) *DNSConfigApplyConfiguration {
+	b.Options = append(b.Options, values...)
+	return b
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 holds hand-written apply-configuration builders for the
+// network.example.com/v1alpha1 types, in the shape applyconfiguration-gen
+// produces: one *ApplyConfiguration type per API type plus every nested
+// struct, each with fluent WithX setters, so a caller building a server-side
+// apply request only ever sets the fields it actually owns.
+//
+// A real applyconfiguration-gen output embeds
+// k8s.io/client-go/applyconfigurations/meta/v1's TypeMetaApplyConfiguration
+// and ObjectMetaApplyConfiguration so every top-level type gets Name,
+// Namespace, Labels, Annotations, OwnerReferences, etc. for free. That
+// package does not exist in the client-go version this module is pinned to
+// (client-go grew applyconfigurations well after the ~2019 commit this
+// module vendors); see the sibling fake package's doc.go for the same kind
+// of pin-driven gap. Rather than reference a package this module cannot
+// actually import, the builders here carry their own Name and Namespace
+// fields directly on each top-level *ApplyConfiguration type. Labels and
+// Annotations are omitted because nothing in this backlog needs to apply
+// them yet; add WithLabels/WithAnnotations here, the same way WithName is
+// built below, if and when something does.
+package v1alpha1
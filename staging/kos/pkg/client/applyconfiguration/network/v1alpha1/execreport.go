@@ -0,0 +1,87 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExecReportApplyConfiguration represents a declarative configuration of
+// the ExecReport type for use with apply.
+type ExecReportApplyConfiguration struct {
+	Command    []string     `json:"command,omitempty"`
+	ExitStatus *int32       `json:"exitStatus,omitempty"`
+	StartTime  *metav1.Time `json:"startTime,omitempty"`
+	StopTime   *metav1.Time `json:"stopTime,omitempty"`
+	StdOut     *string      `json:"stdOut,omitempty"`
+	StdErr     *string      `json:"stdErr,omitempty"`
+}
+
+// ExecReport constructs a declarative configuration of the ExecReport type
+// for use with apply.
+func ExecReport() *ExecReportApplyConfiguration {
+	return &ExecReportApplyConfiguration{}
+}
+
+// WithCommand appends the given values to the Command field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Command field.
+func (b *ExecReportApplyConfiguration) WithCommand(values ...string) *ExecReportApplyConfiguration {
+	b.Command = append(b.Command, values...)
+	return b
+}
+
+// WithExitStatus sets the ExitStatus field in the declarative configuration
+// to the given value and returns the receiver, so that objects can be built
+// by chaining "With" function invocations.
+func (b *ExecReportApplyConfiguration) WithExitStatus(value int32) *ExecReportApplyConfiguration {
+	b.ExitStatus = &value
+	return b
+}
+
+// WithStartTime sets the StartTime field in the declarative configuration
+// to the given value and returns the receiver, so that objects can be built
+// by chaining "With" function invocations.
+func (b *ExecReportApplyConfiguration) WithStartTime(value metav1.Time) *ExecReportApplyConfiguration {
+	b.StartTime = &value
+	return b
+}
+
+// WithStopTime sets the StopTime field in the declarative configuration to
+// the given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *ExecReportApplyConfiguration) WithStopTime(value metav1.Time) *ExecReportApplyConfiguration {
+	b.StopTime = &value
+	return b
+}
+
+// WithStdOut sets the StdOut field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *ExecReportApplyConfiguration) WithStdOut(value string) *ExecReportApplyConfiguration {
+	b.StdOut = &value
+	return b
+}
+
+// WithStdErr sets the StdErr field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *ExecReportApplyConfiguration) WithStdErr(value string) *ExecReportApplyConfiguration {
+	b.StdErr = &value
+	return b
+}
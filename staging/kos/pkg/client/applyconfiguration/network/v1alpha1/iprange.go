@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// IPRangeApplyConfiguration represents a declarative configuration of the
+// IPRange type for use with apply.
+type IPRangeApplyConfiguration struct {
+	Subnet     *string  `json:"subnet,omitempty"`
+	RangeStart *string  `json:"rangeStart,omitempty"`
+	RangeEnd   *string  `json:"rangeEnd,omitempty"`
+	Gateway    *string  `json:"gateway,omitempty"`
+	Exclude    []string `json:"exclude,omitempty"`
+}
+
+// IPRange constructs a declarative configuration of the IPRange type for
+// use with apply.
+func IPRange() *IPRangeApplyConfiguration {
+	return &IPRangeApplyConfiguration{}
+}
+
+// WithSubnet sets the Subnet field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *IPRangeApplyConfiguration) WithSubnet(value string) *IPRangeApplyConfiguration {
+	b.Subnet = &value
+	return b
+}
+
+// WithRangeStart sets the RangeStart field in the declarative configuration
+// to the given value and returns the receiver, so that objects can be built
+// by chaining "With" function invocations.
+func (b *IPRangeApplyConfiguration) WithRangeStart(value string) *IPRangeApplyConfiguration {
+	b.RangeStart = &value
+	return b
+}
+
+// WithRangeEnd sets the RangeEnd field in the declarative configuration to
+// the given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *IPRangeApplyConfiguration) WithRangeEnd(value string) *IPRangeApplyConfiguration {
+	b.RangeEnd = &value
+	return b
+}
+
+// WithGateway sets the Gateway field in the declarative configuration to
+// the given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *IPRangeApplyConfiguration) WithGateway(value string) *IPRangeApplyConfiguration {
+	b.Gateway = &value
+	return b
+}
+
+// WithExclude appends the given values to the Exclude field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Exclude field.
+func (b *IPRangeApplyConfiguration) WithExclude(values ...string) *IPRangeApplyConfiguration {
+	b.Exclude = append(b.Exclude, values...)
+	return b
+}
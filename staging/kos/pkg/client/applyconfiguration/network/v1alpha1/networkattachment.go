@@ -0,0 +1,70 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// NetworkAttachmentApplyConfiguration represents a declarative
+// configuration of the NetworkAttachment type for use with apply.
+//
+// See this package's doc.go for why Name/Namespace are plain fields here
+// instead of an embedded client-go meta/v1 ObjectMetaApplyConfiguration.
+type NetworkAttachmentApplyConfiguration struct {
+	Name      *string                                     `json:"name,omitempty"`
+	Namespace *string                                     `json:"namespace,omitempty"`
+	Spec      *NetworkAttachmentSpecApplyConfiguration     `json:"spec,omitempty"`
+	Status    *NetworkAttachmentStatusApplyConfiguration   `json:"status,omitempty"`
+}
+
+// NetworkAttachment constructs a declarative configuration of the
+// NetworkAttachment type for use with apply.
+func NetworkAttachment(namespace, name string) *NetworkAttachmentApplyConfiguration {
+	b := &NetworkAttachmentApplyConfiguration{}
+	b.Namespace = &namespace
+	b.Name = &name
+	return b
+}
+
+// WithName sets the Name field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentApplyConfiguration) WithName(value string) *NetworkAttachmentApplyConfiguration {
+	b.Name = &value
+	return b
+}
+
+// WithNamespace sets the Namespace field in the declarative configuration
+// to the given value and returns the receiver, so that objects can be
+// built by chaining "With" function invocations.
+func (b *NetworkAttachmentApplyConfiguration) WithNamespace(value string) *NetworkAttachmentApplyConfiguration {
+	b.Namespace = &value
+	return b
+}
+
+// WithSpec sets the Spec field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentApplyConfiguration) WithSpec(value *NetworkAttachmentSpecApplyConfiguration) *NetworkAttachmentApplyConfiguration {
+	b.Spec = value
+	return b
+}
+
+// WithStatus sets the Status field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentApplyConfiguration) WithStatus(value *NetworkAttachmentStatusApplyConfiguration) *NetworkAttachmentApplyConfiguration {
+	b.Status = value
+	return b
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// NetworkAttachmentErrorsApplyConfiguration represents a declarative
+// configuration of the NetworkAttachmentErrors type for use with apply.
+type NetworkAttachmentErrorsApplyConfiguration struct {
+	IPAM []string `json:"ipam,omitempty"`
+	Host []string `json:"host,omitempty"`
+}
+
+// NetworkAttachmentErrors constructs a declarative configuration of the
+// NetworkAttachmentErrors type for use with apply.
+func NetworkAttachmentErrors() *NetworkAttachmentErrorsApplyConfiguration {
+	return &NetworkAttachmentErrorsApplyConfiguration{}
+}
+
+// WithIPAM appends the given values to the IPAM field in the declarative
+// configuration and returns the receiver, so that objects can be built by
+// chaining "With" function invocations. If called multiple times, values
+// provided by each call are appended to the IPAM field.
+func (b *NetworkAttachmentErrorsApplyConfiguration) WithIPAM(values ...string) *NetworkAttachmentErrorsApplyConfiguration {
+	b.IPAM = append(b.IPAM, values...)
+	return b
+}
+
+// WithHost appends the given values to the Host field in the declarative
+// configuration and returns the receiver, so that objects can be built by
+// chaining "With" function invocations. If called multiple times, values
+// provided by each call are appended to the Host field.
+func (b *NetworkAttachmentErrorsApplyConfiguration) WithHost(values ...string) *NetworkAttachmentErrorsApplyConfiguration {
+	b.Host = append(b.Host, values...)
+	return b
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// NetworkAttachmentSpecApplyConfiguration represents a declarative
+// configuration of the NetworkAttachmentSpec type for use with apply.
+type NetworkAttachmentSpecApplyConfiguration struct {
+	Node                 *string                      `json:"node,omitempty"`
+	Subnet               *string                      `json:"subnet,omitempty"`
+	PostCreateExec       []string                     `json:"postCreateExec,omitempty"`
+	PostDeleteExec       []string                     `json:"postDeleteExec,omitempty"`
+	RequestedIPv4        *string                      `json:"requestedIPv4,omitempty"`
+	RequestedIPv6        *string                      `json:"requestedIPv6,omitempty"`
+	Routes               []RouteApplyConfiguration    `json:"routes,omitempty"`
+	DNS                  *DNSConfigApplyConfiguration `json:"dns,omitempty"`
+	PreDeleteExec        []string                     `json:"preDeleteExec,omitempty"`
+	RescheduleOnNodeLoss *bool                        `json:"rescheduleOnNodeLoss,omitempty"`
+}
+
+// NetworkAttachmentSpec constructs a declarative configuration of the
+// NetworkAttachmentSpec type for use with apply.
+func NetworkAttachmentSpec() *NetworkAttachmentSpecApplyConfiguration {
+	return &NetworkAttachmentSpecApplyConfiguration{}
+}
+
+// WithNode sets the Node field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithNode(value string) *NetworkAttachmentSpecApplyConfiguration {
+	b.Node = &value
+	return b
+}
+
+// WithSubnet sets the Subnet field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithSubnet(value string) *NetworkAttachmentSpecApplyConfiguration {
+	b.Subnet = &value
+	return b
+}
+
+// WithPostCreateExec appends the given values to the PostCreateExec field
+// in the declarative configuration and returns the receiver, so that
+// objects can be built by chaining "With" function invocations. If called
+// multiple times, values provided by each call are appended to the
+// PostCreateExec field.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithPostCreateExec(values ...string) *NetworkAttachmentSpecApplyConfiguration {
+	b.PostCreateExec = append(b.PostCreateExec, values...)
+	return b
+}
+
+// WithPostDeleteExec appends the given values to the PostDeleteExec field
+// in the declarative configuration and returns the receiver, so that
+// objects can be built by chaining "With" function invocations. If called
+// multiple times, values provided by each call are appended to the
+// PostDeleteExec field.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithPostDeleteExec(values ...string) *NetworkAttachmentSpecApplyConfiguration {
+	b.PostDeleteExec = append(b.PostDeleteExec, values...)
+	return b
+}
+
+// WithRequestedIPv4 sets the RequestedIPv4 field in the declarative
+// configuration to the given value and returns the receiver, so that
+// objects can be built by chaining "With" function invocations.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithRequestedIPv4(value string) *NetworkAttachmentSpecApplyConfiguration {
+	b.RequestedIPv4 = &value
+	return b
+}
+
+// WithRequestedIPv6 sets the RequestedIPv6 field in the declarative
+// configuration to the given value and returns the receiver, so that
+// objects can be built by chaining "With" function invocations.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithRequestedIPv6(value string) *NetworkAttachmentSpecApplyConfiguration {
+	b.RequestedIPv6 = &value
+	return b
+}
+
+// WithRoutes appends the given values to the Routes field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Routes field.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithRoutes(values ...*RouteApplyConfiguration) *NetworkAttachmentSpecApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithRoutes")
+		}
+		b.Routes = append(b.Routes, *values[i])
+	}
+	return b
+}
+
+// WithDNS sets the DNS field in the declarative configuration to the given
+// value and returns the receiver, so that objects can be built by chaining
+// "With" function invocations.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithDNS(value *DNSConfigApplyConfiguration) *NetworkAttachmentSpecApplyConfiguration {
+	b.DNS = value
+	return b
+}
+
+// WithPreDeleteExec appends the given values to the PreDeleteExec field in
+// the declarative configuration and returns the receiver, so that objects
+// can be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the PreDeleteExec
+// field.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithPreDeleteExec(values ...string) *NetworkAttachmentSpecApplyConfiguration {
+	b.PreDeleteExec = append(b.PreDeleteExec, values...)
+	return b
+}
+
+// WithRescheduleOnNodeLoss sets the RescheduleOnNodeLoss field in the
+// declarative configuration to the given value and returns the receiver,
+// so that objects can be built by chaining "With" function invocations.
+func (b *NetworkAttachmentSpecApplyConfiguration) WithRescheduleOnNodeLoss(value bool) *NetworkAttachmentSpecApplyConfiguration {
+	b.RescheduleOnNodeLoss = &value
+	return b
+}
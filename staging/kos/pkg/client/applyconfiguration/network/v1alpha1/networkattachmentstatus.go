@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// NetworkAttachmentStatusApplyConfiguration represents a declarative
+// configuration of the NetworkAttachmentStatus type for use with apply.
+type NetworkAttachmentStatusApplyConfiguration struct {
+	Errors               *NetworkAttachmentErrorsApplyConfiguration `json:"errors,omitempty"`
+	LockUID              *string                                    `json:"lockUID,omitempty"`
+	AddressVNI           *uint32                                    `json:"addressVNI,omitempty"`
+	IPv4                 *string                                    `json:"ipv4,omitempty"`
+	LockUID6             *string                                    `json:"lockUID6,omitempty"`
+	AddressVNIv6         *uint32                                    `json:"addressVNIv6,omitempty"`
+	IPv6                 *string                                    `json:"ipv6,omitempty"`
+	MACAddress           *string                                    `json:"macAddress,omitempty"`
+	IfcName              *string                                    `json:"ifcName,omitempty"`
+	HostIP               *string                                    `json:"hostIP,omitempty"`
+	PostCreateExecReport *ExecReportApplyConfiguration              `json:"postCreateExecReport,omitempty"`
+	Routes               []RouteApplyConfiguration                  `json:"routes,omitempty"`
+	DNS                  *DNSConfigApplyConfiguration                `json:"dns,omitempty"`
+	PreDeleteExecReport  *ExecReportApplyConfiguration              `json:"preDeleteExecReport,omitempty"`
+}
+
+// NetworkAttachmentStatus constructs a declarative configuration of the
+// NetworkAttachmentStatus type for use with apply.
+func NetworkAttachmentStatus() *NetworkAttachmentStatusApplyConfiguration {
+	return &NetworkAttachmentStatusApplyConfiguration{}
+}
+
+// WithErrors sets the Errors field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithErrors(value *NetworkAttachmentErrorsApplyConfiguration) *NetworkAttachmentStatusApplyConfiguration {
+	b.Errors = value
+	return b
+}
+
+// WithLockUID sets the LockUID field in the declarative configuration to
+// the given value and returns the receiver, so that objects can be built
+// by chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithLockUID(value string) *NetworkAttachmentStatusApplyConfiguration {
+	b.LockUID = &value
+	return b
+}
+
+// WithAddressVNI sets the AddressVNI field in the declarative
+// configuration to the given value and returns the receiver, so that
+// objects can be built by chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithAddressVNI(value uint32) *NetworkAttachmentStatusApplyConfiguration {
+	b.AddressVNI = &value
+	return b
+}
+
+// WithIPv4 sets the IPv4 field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithIPv4(value string) *NetworkAttachmentStatusApplyConfiguration {
+	b.IPv4 = &value
+	return b
+}
+
+// WithLockUID6 sets the LockUID6 field in the declarative configuration to
+// the given value and returns the receiver, so that objects can be built
+// by chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithLockUID6(value string) *NetworkAttachmentStatusApplyConfiguration {
+	b.LockUID6 = &value
+	return b
+}
+
+// WithAddressVNIv6 sets the AddressVNIv6 field in the declarative
+// configuration to the given value and returns the receiver, so that
+// objects can be built by chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithAddressVNIv6(value uint32) *NetworkAttachmentStatusApplyConfiguration {
+	b.AddressVNIv6 = &value
+	return b
+}
+
+// WithIPv6 sets the IPv6 field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithIPv6(value string) *NetworkAttachmentStatusApplyConfiguration {
+	b.IPv6 = &value
+	return b
+}
+
+// WithMACAddress sets the MACAddress field in the declarative
+// configuration to the given value and returns the receiver, so that
+// objects can be built by chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithMACAddress(value string) *NetworkAttachmentStatusApplyConfiguration {
+	b.MACAddress = &value
+	return b
+}
+
+// WithIfcName sets the IfcName field in the declarative configuration to
+// the given value and returns the receiver, so that objects can be built
+// by chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithIfcName(value string) *NetworkAttachmentStatusApplyConfiguration {
+	b.IfcName = &value
+	return b
+}
+
+// WithHostIP sets the HostIP field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithHostIP(value string) *NetworkAttachmentStatusApplyConfiguration {
+	b.HostIP = &value
+	return b
+}
+
+// WithPostCreateExecReport sets the PostCreateExecReport field in the
+// declarative configuration to the given value and returns the receiver,
+// so that objects can be built by chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithPostCreateExecReport(value *ExecReportApplyConfiguration) *NetworkAttachmentStatusApplyConfiguration {
+	b.PostCreateExecReport = value
+	return b
+}
+
+// WithRoutes appends the given values to the Routes field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Routes field.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithRoutes(values ...*RouteApplyConfiguration) *NetworkAttachmentStatusApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithRoutes")
+		}
+		b.Routes = append(b.Routes, *values[i])
+	}
+	return b
+}
+
+// WithDNS sets the DNS field in the declarative configuration to the given
+// value and returns the receiver, so that objects can be built by chaining
+// "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithDNS(value *DNSConfigApplyConfiguration) *NetworkAttachmentStatusApplyConfiguration {
+	b.DNS = value
+	return b
+}
+
+// WithPreDeleteExecReport sets the PreDeleteExecReport field in the
+// declarative configuration to the given value and returns the receiver,
+// so that objects can be built by chaining "With" function invocations.
+func (b *NetworkAttachmentStatusApplyConfiguration) WithPreDeleteExecReport(value *ExecReportApplyConfiguration) *NetworkAttachmentStatusApplyConfiguration {
+	b.PreDeleteExecReport = value
+	return b
+}
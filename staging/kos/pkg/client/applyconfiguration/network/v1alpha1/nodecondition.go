@@ -0,0 +1,77 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeConditionApplyConfiguration represents a declarative configuration of
+// the NodeCondition type for use with apply.
+type NodeConditionApplyConfiguration struct {
+	Node              *string      `json:"node,omitempty"`
+	LastHeartbeatTime *metav1.Time `json:"lastHeartbeatTime,omitempty"`
+	Reason            *string      `json:"reason,omitempty"`
+	Message           *string      `json:"message,omitempty"`
+	Ready             *bool        `json:"ready,omitempty"`
+}
+
+// NodeCondition constructs a declarative configuration of the
+// NodeCondition type for use with apply.
+func NodeCondition() *NodeConditionApplyConfiguration {
+	return &NodeConditionApplyConfiguration{}
+}
+
+// WithNode sets the Node field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NodeConditionApplyConfiguration) WithNode(value string) *NodeConditionApplyConfiguration {
+	b.Node = &value
+	return b
+}
+
+// WithLastHeartbeatTime sets the LastHeartbeatTime field in the
+// declarative configuration to the given value and returns the receiver,
+// so that objects can be built by chaining "With" function invocations.
+func (b *NodeConditionApplyConfiguration) WithLastHeartbeatTime(value metav1.Time) *NodeConditionApplyConfiguration {
+	b.LastHeartbeatTime = &value
+	return b
+}
+
+// WithReason sets the Reason field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NodeConditionApplyConfiguration) WithReason(value string) *NodeConditionApplyConfiguration {
+	b.Reason = &value
+	return b
+}
+
+// WithMessage sets the Message field in the declarative configuration to
+// the given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NodeConditionApplyConfiguration) WithMessage(value string) *NodeConditionApplyConfiguration {
+	b.Message = &value
+	return b
+}
+
+// WithReady sets the Ready field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *NodeConditionApplyConfiguration) WithReady(value bool) *NodeConditionApplyConfiguration {
+	b.Ready = &value
+	return b
+}
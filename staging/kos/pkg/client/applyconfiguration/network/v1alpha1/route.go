@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// RouteApplyConfiguration represents a declarative configuration of the
+// Route type for use with apply.
+type RouteApplyConfiguration struct {
+	Dst    *string `json:"dst,omitempty"`
+	GW     *string `json:"gw,omitempty"`
+	MTU    *uint32 `json:"mtu,omitempty"`
+	AdvMSS *uint32 `json:"advmss,omitempty"`
+}
+
+// Route constructs a declarative configuration of the Route type for use
+// with apply.
+func Route() *RouteApplyConfiguration {
+	return &RouteApplyConfiguration{}
+}
+
+// WithDst sets the Dst field in the declarative configuration to the given
+// value and returns the receiver, so that objects can be built by chaining
+// "With" function invocations.
+func (b *RouteApplyConfiguration) WithDst(value string) *RouteApplyConfiguration {
+	b.Dst = &value
+	return b
+}
+
+// WithGW sets the GW field in the declarative configuration to the given
+// value and returns the receiver, so that objects can be built by chaining
+// "With" function invocations.
+func (b *RouteApplyConfiguration) WithGW(value string) *RouteApplyConfiguration {
+	b.GW = &value
+	return b
+}
+
+// WithMTU sets the MTU field in the declarative configuration to the given
+// value and returns the receiver, so that objects can be built by chaining
+// "With" function invocations.
+func (b *RouteApplyConfiguration) WithMTU(value uint32) *RouteApplyConfiguration {
+	b.MTU = &value
+	return b
+}
+
+// WithAdvMSS sets the AdvMSS field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *RouteApplyConfiguration) WithAdvMSS(value uint32) *RouteApplyConfiguration {
+	b.AdvMSS = &value
+	return b
+}
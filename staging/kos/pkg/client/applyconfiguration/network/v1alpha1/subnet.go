@@ -0,0 +1,70 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// SubnetApplyConfiguration represents a declarative configuration of the
+// Subnet type for use with apply.
+//
+// See this package's doc.go for why Name/Namespace are plain fields here
+// instead of an embedded client-go meta/v1 ObjectMetaApplyConfiguration.
+type SubnetApplyConfiguration struct {
+	Name      *string                         `json:"name,omitempty"`
+	Namespace *string                         `json:"namespace,omitempty"`
+	Spec      *SubnetSpecApplyConfiguration   `json:"spec,omitempty"`
+	Status    *SubnetStatusApplyConfiguration `json:"status,omitempty"`
+}
+
+// Subnet constructs a declarative configuration of the Subnet type for use
+// with apply.
+func Subnet(namespace, name string) *SubnetApplyConfiguration {
+	b := &SubnetApplyConfiguration{}
+	b.Namespace = &namespace
+	b.Name = &name
+	return b
+}
+
+// WithName sets the Name field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *SubnetApplyConfiguration) WithName(value string) *SubnetApplyConfiguration {
+	b.Name = &value
+	return b
+}
+
+// WithNamespace sets the Namespace field in the declarative configuration
+// to the given value and returns the receiver, so that objects can be
+// built by chaining "With" function invocations.
+func (b *SubnetApplyConfiguration) WithNamespace(value string) *SubnetApplyConfiguration {
+	b.Namespace = &value
+	return b
+}
+
+// WithSpec sets the Spec field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *SubnetApplyConfiguration) WithSpec(value *SubnetSpecApplyConfiguration) *SubnetApplyConfiguration {
+	b.Spec = value
+	return b
+}
+
+// WithStatus sets the Status field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *SubnetApplyConfiguration) WithStatus(value *SubnetStatusApplyConfiguration) *SubnetApplyConfiguration {
+	b.Status = value
+	return b
+}
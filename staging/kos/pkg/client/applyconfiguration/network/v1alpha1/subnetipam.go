@@ -0,0 +1,46 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// SubnetIPAMApplyConfiguration represents a declarative configuration of
+// the SubnetIPAM type for use with apply.
+type SubnetIPAMApplyConfiguration struct {
+	Name   *string `json:"name,omitempty"`
+	Config *string `json:"config,omitempty"`
+}
+
+// SubnetIPAM constructs a declarative configuration of the SubnetIPAM type
+// for use with apply.
+func SubnetIPAM() *SubnetIPAMApplyConfiguration {
+	return &SubnetIPAMApplyConfiguration{}
+}
+
+// WithName sets the Name field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *SubnetIPAMApplyConfiguration) WithName(value string) *SubnetIPAMApplyConfiguration {
+	b.Name = &value
+	return b
+}
+
+// WithConfig sets the Config field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *SubnetIPAMApplyConfiguration) WithConfig(value string) *SubnetIPAMApplyConfiguration {
+	b.Config = &value
+	return b
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// SubnetSpecApplyConfiguration represents a declarative configuration of
+// the SubnetSpec type for use with apply.
+type SubnetSpecApplyConfiguration struct {
+	IPv4   *string                       `json:"ipv4,omitempty"`
+	VNI    *uint32                       `json:"vni,omitempty"`
+	IPv6   *string                       `json:"ipv6,omitempty"`
+	Ranges []IPRangeApplyConfiguration   `json:"ranges,omitempty"`
+	Routes []RouteApplyConfiguration     `json:"routes,omitempty"`
+	DNS    *DNSConfigApplyConfiguration  `json:"dns,omitempty"`
+	IPAM   *SubnetIPAMApplyConfiguration `json:"ipam,omitempty"`
+}
+
+// SubnetSpec constructs a declarative configuration of the SubnetSpec type
+// for use with apply.
+func SubnetSpec() *SubnetSpecApplyConfiguration {
+	return &SubnetSpecApplyConfiguration{}
+}
+
+// WithIPv4 sets the IPv4 field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *SubnetSpecApplyConfiguration) WithIPv4(value string) *SubnetSpecApplyConfiguration {
+	b.IPv4 = &value
+	return b
+}
+
+// WithVNI sets the VNI field in the declarative configuration to the given
+// value and returns the receiver, so that objects can be built by chaining
+// "With" function invocations.
+func (b *SubnetSpecApplyConfiguration) WithVNI(value uint32) *SubnetSpecApplyConfiguration {
+	b.VNI = &value
+	return b
+}
+
+// WithIPv6 sets the IPv6 field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *SubnetSpecApplyConfiguration) WithIPv6(value string) *SubnetSpecApplyConfiguration {
+	b.IPv6 = &value
+	return b
+}
+
+// WithRanges appends the given values to the Ranges field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Ranges field.
+func (b *SubnetSpecApplyConfiguration) WithRanges(values ...*IPRangeApplyConfiguration) *SubnetSpecApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithRanges")
+		}
+		b.Ranges = append(b.Ranges, *values[i])
+	}
+	return b
+}
+
+// WithRoutes appends the given values to the Routes field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Routes field.
+func (b *SubnetSpecApplyConfiguration) WithRoutes(values ...*RouteApplyConfiguration) *SubnetSpecApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithRoutes")
+		}
+		b.Routes = append(b.Routes, *values[i])
+	}
+	return b
+}
+
+// WithDNS sets the DNS field in the declarative configuration to the given
+// value and returns the receiver, so that objects can be built by chaining
+// "With" function invocations.
+func (b *SubnetSpecApplyConfiguration) WithDNS(value *DNSConfigApplyConfiguration) *SubnetSpecApplyConfiguration {
+	b.DNS = value
+	return b
+}
+
+// WithIPAM sets the IPAM field in the declarative configuration to the
+// given value and returns the receiver, so that objects can be built by
+// chaining "With" function invocations.
+func (b *SubnetSpecApplyConfiguration) WithIPAM(value *SubnetIPAMApplyConfiguration) *SubnetSpecApplyConfiguration {
+	b.IPAM = value
+	return b
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// SubnetStatusApplyConfiguration represents a declarative configuration of
+// the SubnetStatus type for use with apply. Each field here is a
+// disjoint-ownership slice of the real SubnetStatus: the subnet validator
+// owns Validated/Errors, while the subnet controller owns
+// ReadyNodes/NotReadyNodes/NodeConditions, and they apply to the same
+// Subnet's status without a last-writer-wins race only because each one
+// leaves the other's fields nil.
+type SubnetStatusApplyConfiguration struct {
+	Validated        *bool                             `json:"validated,omitempty"`
+	Errors           []string                          `json:"errors,omitempty"`
+	LastAllocatedIPs []string                          `json:"lastAllocatedIPs,omitempty"`
+	NextRangeIndex   *uint32                           `json:"nextRangeIndex,omitempty"`
+	ReadyNodes       *int32                            `json:"readyNodes,omitempty"`
+	NotReadyNodes    *int32                            `json:"notReadyNodes,omitempty"`
+	NodeConditions   []NodeConditionApplyConfiguration `json:"nodeConditions,omitempty"`
+}
+
+// SubnetStatus constructs a declarative configuration of the SubnetStatus
+// type for use with apply.
+func SubnetStatus() *SubnetStatusApplyConfiguration {
+	return &SubnetStatusApplyConfiguration{}
+}
+
+// WithValidated sets the Validated field in the declarative configuration
+// to the given value and returns the receiver, so that objects can be
+// built by chaining "With" function invocations.
+func (b *SubnetStatusApplyConfiguration) WithValidated(value bool) *SubnetStatusApplyConfiguration {
+	b.Validated = &value
+	return b
+}
+
+// WithErrors appends the given values to the Errors field in the
+// declarative configuration and returns the receiver, so that objects can
+// be built by chaining "With" function invocations. If called multiple
+// times, values provided by each call are appended to the Errors field.
+func (b *SubnetStatusApplyConfiguration) WithErrors(values ...string) *SubnetStatusApplyConfiguration {
+	b.Errors = append(b.Errors, values...)
+	return b
+}
+
+// WithLastAllocatedIPs appends the given values to the LastAllocatedIPs
+// field in the declarative configuration and returns the receiver, so that
+// objects can be built by chaining "With" function invocations. If called
+// multiple times, values provided by each call are appended to the
+// LastAllocatedIPs field.
+func (b *SubnetStatusApplyConfiguration) WithLastAllocatedIPs(values ...string) *SubnetStatusApplyConfiguration {
+	b.LastAllocatedIPs = append(b.LastAllocatedIPs, values...)
+	return b
+}
+
+// WithNextRangeIndex sets the NextRangeIndex field in the declarative
+// configuration to the given value and returns the receiver, so that
+// objects can be built by chaining "With" function invocations.
+func (b *SubnetStatusApplyConfiguration) WithNextRangeIndex(value uint32) *SubnetStatusApplyConfiguration {
+	b.NextRangeIndex = &value
+	return b
+}
+
+// WithReadyNodes sets the ReadyNodes field in the declarative configuration
+// to the given value and returns the receiver, so that objects can be
+// built by chaining "With" function invocations.
+func (b *SubnetStatusApplyConfiguration) WithReadyNodes(value int32) *SubnetStatusApplyConfiguration {
+	b.ReadyNodes = &value
+	return b
+}
+
+// WithNotReadyNodes sets the NotReadyNodes field in the declarative
+// configuration to the given value and returns the receiver, so that
+// objects can be built by chaining "With" function invocations.
+func (b *SubnetStatusApplyConfiguration) WithNotReadyNodes(value int32) *SubnetStatusApplyConfiguration {
+	b.NotReadyNodes = &value
+	return b
+}
+
+// WithNodeConditions appends the given values to the NodeConditions field
+// in the declarative configuration and returns the receiver, so that
+// objects can be built by chaining "With" function invocations. If called
+// multiple times, values provided by each call are appended to the
+// NodeConditions field.
+func (b *SubnetStatusApplyConfiguration) WithNodeConditions(values ...*NodeConditionApplyConfiguration) *SubnetStatusApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithNodeConditions")
+		}
+		b.NodeConditions = append(b.NodeConditions, *values[i])
+	}
+	return b
+}
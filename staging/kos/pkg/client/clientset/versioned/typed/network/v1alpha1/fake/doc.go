@@ -0,0 +1,45 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake holds the generated fake clients for the network v1alpha1
+// API group. Get/List/Create/Update/Delete and Patch all read and write a
+// shared store (see store.go) so that, e.g., a Patch actually observes an
+// earlier Create and a later Get observes the patch, instead of Patch just
+// recording the action and handing back whatever zero-value object the
+// caller passed in.
+//
+// client-go's own testing.ObjectReaction applies JSON Patch, Merge Patch
+// and Strategic Merge Patch the same way, but against a
+// testing.ObjectTracker, which needs a runtime.Scheme and every stored
+// type to implement runtime.Object.DeepCopyObject. Neither exists for this
+// API group: there is no zz_generated.deepcopy.go anywhere under
+// pkg/apis/network, and the register.go/scheme.go (GroupName,
+// SchemeGroupVersion, AddToScheme) a real ObjectTracker would need are
+// also absent. Rather than fabricate that scaffolding by hand, store.go
+// keeps objects as JSON and applies patches to the bytes directly with
+// github.com/evanphx/json-patch and apimachinery's strategicpatch package,
+// both of which work on []byte and need no runtime.Object compliance.
+// applySubresourcePatch (see patch.go) then restricts a patch naming a
+// subresource, e.g. "status", to that one top-level JSON field, so a
+// status patch cannot reach Spec and vice versa.
+//
+// This still does not build the outer versioned/fake.Clientset or the
+// scheme registration a full client-gen run would produce -- tests in this
+// module construct a *FakeNetworkV1alpha1 directly (see
+// NewFakeNetworkV1alpha1 in fake_network_client.go) rather than going
+// through a top-level Clientset, the same boundary this package has always
+// drawn around the ungenerated scaffolding.
+package fake // import "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/typed/network/v1alpha1/fake"
@@ -0,0 +1,447 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	types "k8s.io/apimachinery/pkg/types"
+	testing "k8s.io/client-go/testing"
+	v1alpha1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	applyconfigv1alpha1 "k8s.io/examples/staging/kos/pkg/client/applyconfiguration/network/v1alpha1"
+)
+
+// Apply takes the given apply declaration, merges the fields it sets into
+// the stored Subnet (creating one from scratch if name isn't already
+// present), and returns the result.
+//
+// Unlike the real apiserver's server-side apply, this fake does not track
+// per-field managers: it has nowhere to persist a managedFields entry, so
+// it cannot detect or reject a conflicting apply from a second field
+// manager that already owns a field Apply is about to set. It merges in
+// whatever the given SubnetApplyConfiguration sets and leaves everything
+// else alone, which is enough to exercise the disjoint-field-ownership
+// case this method exists for -- see ApplyStatus below, and
+// pkg/controllers/subnet/validator.go's ModeServerSideApply -- without
+// being a real managed-fields implementation.
+func (c *FakeSubnets) Apply(subnet *v1alpha1.SubnetApplyConfiguration, opts applyconfigv1alpha1.ApplyOptions) (result *v1alpha1.Subnet, err error) {
+	name := ""
+	if subnet.Name != nil {
+		name = *subnet.Name
+	}
+
+	existing := &v1alpha1.Subnet{Namespace: c.ns, Name: name}
+	if data := c.Fake.objectStore().get("subnets", c.ns, name); data != nil {
+		if err := json.Unmarshal(data, existing); err != nil {
+			return nil, err
+		}
+	}
+	mergeSubnet(subnet, existing)
+
+	patch, err := json.Marshal(subnet)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchAction(subnetsResource, c.ns, name, types.ApplyPatchType, patch), existing)
+
+	if obj == nil {
+		return nil, err
+	}
+	result = obj.(*v1alpha1.Subnet)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// ApplyStatus is the status-subresource form of Apply: it merges only
+// subnet.Status into the stored object, leaving Spec and metadata alone.
+// This is the method pkg/controllers/subnet/validator.go's
+// ModeServerSideApply path calls, since the subnet validator only ever
+// owns Status.Validated and Status.Errors, and it's what lets the subnet
+// controller apply Status.ReadyNodes/NotReadyNodes/NodeConditions
+// concurrently without either one clobbering the other's fields.
+func (c *FakeSubnets) ApplyStatus(subnet *v1alpha1.SubnetApplyConfiguration, opts applyconfigv1alpha1.ApplyOptions) (result *v1alpha1.Subnet, err error) {
+	name := ""
+	if subnet.Name != nil {
+		name = *subnet.Name
+	}
+
+	data := c.Fake.objectStore().get("subnets", c.ns, name)
+	if data == nil {
+		return nil, errors.NewNotFound(subnetsResource.GroupResource(), name)
+	}
+	existing := &v1alpha1.Subnet{}
+	if err := json.Unmarshal(data, existing); err != nil {
+		return nil, err
+	}
+	if subnet.Status != nil {
+		mergeSubnetStatus(subnet.Status, &existing.Status)
+	}
+
+	patch, err := json.Marshal(subnet)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(subnetsResource, c.ns, name, types.ApplyPatchType, patch, "status"), existing)
+
+	if obj == nil {
+		return nil, err
+	}
+	result = obj.(*v1alpha1.Subnet)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// mergeSubnet copies every field src sets into dst, leaving fields src
+// leaves nil untouched in dst.
+func mergeSubnet(src *v1alpha1.SubnetApplyConfiguration, dst *v1alpha1.Subnet) {
+	if src.Name != nil {
+		dst.Name = *src.Name
+	}
+	if src.Namespace != nil {
+		dst.Namespace = *src.Namespace
+	}
+	if src.Spec != nil {
+		mergeSubnetSpec(src.Spec, &dst.Spec)
+	}
+	if src.Status != nil {
+		mergeSubnetStatus(src.Status, &dst.Status)
+	}
+}
+
+func mergeSubnetSpec(src *applyconfigv1alpha1.SubnetSpecApplyConfiguration, dst *v1alpha1.SubnetSpec) {
+	if src.IPv4 != nil {
+		dst.IPv4 = *src.IPv4
+	}
+	if src.VNI != nil {
+		dst.VNI = *src.VNI
+	}
+	if src.IPv6 != nil {
+		dst.IPv6 = *src.IPv6
+	}
+	if src.Ranges != nil {
+		dst.Ranges = make([]v1alpha1.IPRange, len(src.Ranges))
+		for i, r := range src.Ranges {
+			rng := v1alpha1.IPRange{}
+			if r.Subnet != nil {
+				rng.Subnet = *r.Subnet
+			}
+			if r.RangeStart != nil {
+				rng.RangeStart = *r.RangeStart
+			}
+			if r.RangeEnd != nil {
+				rng.RangeEnd = *r.RangeEnd
+			}
+			if r.Gateway != nil {
+				rng.Gateway = *r.Gateway
+			}
+			rng.Exclude = r.Exclude
+			dst.Ranges[i] = rng
+		}
+	}
+	if src.Routes != nil {
+		dst.Routes = mergeRoutes(src.Routes)
+	}
+	if src.DNS != nil {
+		dst.DNS = mergeDNSConfig(src.DNS)
+	}
+	if src.IPAM != nil {
+		ipam := &v1alpha1.SubnetIPAM{}
+		if src.IPAM.Name != nil {
+			ipam.Name = *src.IPAM.Name
+		}
+		if src.IPAM.Config != nil {
+			ipam.Config = *src.IPAM.Config
+		}
+		dst.IPAM = ipam
+	}
+}
+
+func mergeSubnetStatus(src *applyconfigv1alpha1.SubnetStatusApplyConfiguration, dst *v1alpha1.SubnetStatus) {
+	if src.Validated != nil {
+		dst.Validated = *src.Validated
+	}
+	if src.Errors != nil {
+		dst.Errors = src.Errors
+	}
+	if src.LastAllocatedIPs != nil {
+		dst.LastAllocatedIPs = src.LastAllocatedIPs
+	}
+	if src.NextRangeIndex != nil {
+		dst.NextRangeIndex = *src.NextRangeIndex
+	}
+	if src.ReadyNodes != nil {
+		dst.ReadyNodes = *src.ReadyNodes
+	}
+	if src.NotReadyNodes != nil {
+		dst.NotReadyNodes = *src.NotReadyNodes
+	}
+	if src.NodeConditions != nil {
+		conditions := make([]v1alpha1.NodeCondition, len(src.NodeConditions))
+		for i, nc := range src.NodeConditions {
+			condition := v1alpha1.NodeCondition{}
+			if nc.Node != nil {
+				condition.Node = *nc.Node
+			}
+			if nc.LastHeartbeatTime != nil {
+				condition.LastHeartbeatTime = *nc.LastHeartbeatTime
+			}
+			if nc.Reason != nil {
+				condition.Reason = *nc.Reason
+			}
+			if nc.Message != nil {
+				condition.Message = *nc.Message
+			}
+			if nc.Ready != nil {
+				condition.Ready = *nc.Ready
+			}
+			conditions[i] = condition
+		}
+		dst.NodeConditions = conditions
+	}
+}
+
+func mergeRoutes(src []applyconfigv1alpha1.RouteApplyConfiguration) []v1alpha1.Route {
+	routes := make([]v1alpha1.Route, len(src))
+	for i, r := range src {
+		route := v1alpha1.Route{}
+		if r.Dst != nil {
+			route.Dst = *r.Dst
+		}
+		if r.GW != nil {
+			route.GW = *r.GW
+		}
+		if r.MTU != nil {
+			route.MTU = *r.MTU
+		}
+		if r.AdvMSS != nil {
+			route.AdvMSS = *r.AdvMSS
+		}
+		routes[i] = route
+	}
+	return routes
+}
+
+func mergeDNSConfig(src *applyconfigv1alpha1.DNSConfigApplyConfiguration) v1alpha1.DNSConfig {
+	dns := v1alpha1.DNSConfig{
+		Nameservers: src.Nameservers,
+		Search:      src.Search,
+		Options:     src.Options,
+	}
+	if src.Domain != nil {
+		dns.Domain = *src.Domain
+	}
+	return dns
+}
+
+// Apply takes the given apply declaration, merges the fields it sets into
+// the stored NetworkAttachment (creating one from scratch if name isn't
+// already present), and returns the result. See FakeSubnets.Apply for the
+// managed-fields caveat this fake shares with it.
+func (c *FakeNetworkAttachments) Apply(networkAttachment *v1alpha1.NetworkAttachmentApplyConfiguration, opts applyconfigv1alpha1.ApplyOptions) (result *v1alpha1.NetworkAttachment, err error) {
+	name := ""
+	if networkAttachment.Name != nil {
+		name = *networkAttachment.Name
+	}
+
+	existing := &v1alpha1.NetworkAttachment{Namespace: c.ns, Name: name}
+	if data := c.Fake.objectStore().get("networkattachments", c.ns, name); data != nil {
+		if err := json.Unmarshal(data, existing); err != nil {
+			return nil, err
+		}
+	}
+	mergeNetworkAttachment(networkAttachment, existing)
+
+	patch, err := json.Marshal(networkAttachment)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchAction(networkattachmentsResource, c.ns, name, types.ApplyPatchType, patch), existing)
+
+	if obj == nil {
+		return nil, err
+	}
+	result = obj.(*v1alpha1.NetworkAttachment)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// ApplyStatus is the status-subresource form of Apply: it merges only
+// networkAttachment.Status into the stored object, leaving Spec and
+// metadata alone -- the form the connection agent and the IPAM allocator
+// actually use, since each only ever owns its own slice of Status (e.g.
+// the agent's IfcName/MACAddress/HostIP vs. the allocator's
+// IPv4/IPv6/LockUID/LockUID6/AddressVNI/AddressVNIv6).
+func (c *FakeNetworkAttachments) ApplyStatus(networkAttachment *v1alpha1.NetworkAttachmentApplyConfiguration, opts applyconfigv1alpha1.ApplyOptions) (result *v1alpha1.NetworkAttachment, err error) {
+	name := ""
+	if networkAttachment.Name != nil {
+		name = *networkAttachment.Name
+	}
+
+	data := c.Fake.objectStore().get("networkattachments", c.ns, name)
+	if data == nil {
+		return nil, errors.NewNotFound(networkattachmentsResource.GroupResource(), name)
+	}
+	existing := &v1alpha1.NetworkAttachment{}
+	if err := json.Unmarshal(data, existing); err != nil {
+		return nil, err
+	}
+	if networkAttachment.Status != nil {
+		mergeNetworkAttachmentStatus(networkAttachment.Status, &existing.Status)
+	}
+
+	patch, err := json.Marshal(networkAttachment)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(networkattachmentsResource, c.ns, name, types.ApplyPatchType, patch, "status"), existing)
+
+	if obj == nil {
+		return nil, err
+	}
+	result = obj.(*v1alpha1.NetworkAttachment)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+func mergeNetworkAttachment(src *v1alpha1.NetworkAttachmentApplyConfiguration, dst *v1alpha1.NetworkAttachment) {
+	if src.Name != nil {
+		dst.Name = *src.Name
+	}
+	if src.Namespace != nil {
+		dst.Namespace = *src.Namespace
+	}
+	if src.Spec != nil {
+		mergeNetworkAttachmentSpec(src.Spec, &dst.Spec)
+	}
+	if src.Status != nil {
+		mergeNetworkAttachmentStatus(src.Status, &dst.Status)
+	}
+}
+
+func mergeNetworkAttachmentSpec(src *applyconfigv1alpha1.NetworkAttachmentSpecApplyConfiguration, dst *v1alpha1.NetworkAttachmentSpec) {
+	if src.Node != nil {
+		dst.Node = *src.Node
+	}
+	if src.Subnet != nil {
+		dst.Subnet = *src.Subnet
+	}
+	if src.PostCreateExec != nil {
+		dst.PostCreateExec = src.PostCreateExec
+	}
+	if src.PostDeleteExec != nil {
+		dst.PostDeleteExec = src.PostDeleteExec
+	}
+	if src.RequestedIPv4 != nil {
+		dst.RequestedIPv4 = *src.RequestedIPv4
+	}
+	if src.RequestedIPv6 != nil {
+		dst.RequestedIPv6 = *src.RequestedIPv6
+	}
+	if src.Routes != nil {
+		dst.Routes = mergeRoutes(src.Routes)
+	}
+	if src.DNS != nil {
+		dst.DNS = mergeDNSConfig(src.DNS)
+	}
+	if src.PreDeleteExec != nil {
+		dst.PreDeleteExec = src.PreDeleteExec
+	}
+	if src.RescheduleOnNodeLoss != nil {
+		dst.RescheduleOnNodeLoss = *src.RescheduleOnNodeLoss
+	}
+}
+
+func mergeNetworkAttachmentStatus(src *applyconfigv1alpha1.NetworkAttachmentStatusApplyConfiguration, dst *v1alpha1.NetworkAttachmentStatus) {
+	if src.Errors != nil {
+		errs := v1alpha1.NetworkAttachmentErrors{}
+		errs.IPAM = src.Errors.IPAM
+		errs.Host = src.Errors.Host
+		dst.Errors = errs
+	}
+	if src.LockUID != nil {
+		dst.LockUID = *src.LockUID
+	}
+	if src.AddressVNI != nil {
+		dst.AddressVNI = *src.AddressVNI
+	}
+	if src.IPv4 != nil {
+		dst.IPv4 = *src.IPv4
+	}
+	if src.LockUID6 != nil {
+		dst.LockUID6 = *src.LockUID6
+	}
+	if src.AddressVNIv6 != nil {
+		dst.AddressVNIv6 = *src.AddressVNIv6
+	}
+	if src.IPv6 != nil {
+		dst.IPv6 = *src.IPv6
+	}
+	if src.MACAddress != nil {
+		dst.MACAddress = *src.MACAddress
+	}
+	if src.IfcName != nil {
+		dst.IfcName = *src.IfcName
+	}
+	if src.HostIP != nil {
+		dst.HostIP = *src.HostIP
+	}
+	if src.PostCreateExecReport != nil {
+		dst.PostCreateExecReport = mergeExecReport(src.PostCreateExecReport)
+	}
+	if src.Routes != nil {
+		dst.Routes = mergeRoutes(src.Routes)
+	}
+	if src.DNS != nil {
+		dst.DNS = mergeDNSConfig(src.DNS)
+	}
+	if src.PreDeleteExecReport != nil {
+		dst.PreDeleteExecReport = mergeExecReport(src.PreDeleteExecReport)
+	}
+}
+
+func mergeExecReport(src *applyconfigv1alpha1.ExecReportApplyConfiguration) *v1alpha1.ExecReport {
+	report := &v1alpha1.ExecReport{Command: src.Command}
+	if src.ExitStatus != nil {
+		report.ExitStatus = *src.ExitStatus
+	}
+	if src.StartTime != nil {
+		report.StartTime = *src.StartTime
+	}
+	if src.StopTime != nil {
+		report.StopTime = *src.StopTime
+	}
+	if src.StdOut != nil {
+		report.StdOut = *src.StdOut
+	}
+	if src.StdErr != nil {
+		report.StdErr = *src.StdErr
+	}
+	return report
+}
@@ -14,14 +14,16 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// Code generated by client-gen. DO NOT EDIT.
-
 package fake
 
 import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	labels "k8s.io/apimachinery/pkg/labels"
-	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	types "k8s.io/apimachinery/pkg/types"
 	watch "k8s.io/apimachinery/pkg/watch"
 	testing "k8s.io/client-go/testing"
@@ -40,19 +42,40 @@ var iplocksKind = schema.GroupVersionKind{Group: "network.example.com", Version:
 
 // Get takes name of the iPLock, and returns the corresponding iPLock object, and an error if there is any.
 func (c *FakeIPLocks) Get(name string, options v1.GetOptions) (result *v1alpha1.IPLock, err error) {
+	var stored runtime.Object
+	if data := c.Fake.objectStore().get("iplocks", c.ns, name); data != nil {
+		iPLock := &v1alpha1.IPLock{}
+		if err := json.Unmarshal(data, iPLock); err != nil {
+			return nil, err
+		}
+		stored = iPLock
+	}
+
 	obj, err := c.Fake.
-		Invokes(testing.NewGetAction(iplocksResource, c.ns, name), &v1alpha1.IPLock{})
+		Invokes(testing.NewGetAction(iplocksResource, c.ns, name), stored)
 
 	if obj == nil {
-		return nil, err
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.NewNotFound(iplocksResource.GroupResource(), name)
 	}
 	return obj.(*v1alpha1.IPLock), err
 }
 
 // List takes label and field selectors, and returns the list of IPLocks that match those selectors.
 func (c *FakeIPLocks) List(opts v1.ListOptions) (result *v1alpha1.IPLockList, err error) {
+	stored := &v1alpha1.IPLockList{}
+	for _, data := range c.Fake.objectStore().list("iplocks", c.ns) {
+		var item v1alpha1.IPLock
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		stored.Items = append(stored.Items, item)
+	}
+
 	obj, err := c.Fake.
-		Invokes(testing.NewListAction(iplocksResource, iplocksKind, c.ns, opts), &v1alpha1.IPLockList{})
+		Invokes(testing.NewListAction(iplocksResource, iplocksKind, c.ns, opts), stored)
 
 	if obj == nil {
 		return nil, err
@@ -81,23 +104,31 @@ func (c *FakeIPLocks) Watch(opts v1.ListOptions) (watch.Interface, error) {
 // Create takes the representation of a iPLock and creates it.  Returns the server's representation of the iPLock, and an error, if there is any.
 func (c *FakeIPLocks) Create(iPLock *v1alpha1.IPLock) (result *v1alpha1.IPLock, err error) {
 	obj, err := c.Fake.
-		Invokes(testing.NewCreateAction(iplocksResource, c.ns, iPLock), &v1alpha1.IPLock{})
+		Invokes(testing.NewCreateAction(iplocksResource, c.ns, iPLock), iPLock)
 
 	if obj == nil {
 		return nil, err
 	}
-	return obj.(*v1alpha1.IPLock), err
+	result = obj.(*v1alpha1.IPLock)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
 }
 
 // Update takes the representation of a iPLock and updates it. Returns the server's representation of the iPLock, and an error, if there is any.
 func (c *FakeIPLocks) Update(iPLock *v1alpha1.IPLock) (result *v1alpha1.IPLock, err error) {
 	obj, err := c.Fake.
-		Invokes(testing.NewUpdateAction(iplocksResource, c.ns, iPLock), &v1alpha1.IPLock{})
+		Invokes(testing.NewUpdateAction(iplocksResource, c.ns, iPLock), iPLock)
 
 	if obj == nil {
 		return nil, err
 	}
-	return obj.(*v1alpha1.IPLock), err
+	result = obj.(*v1alpha1.IPLock)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
 }
 
 // Delete takes name of the iPLock and deletes it. Returns an error if one occurs.
@@ -105,6 +136,9 @@ func (c *FakeIPLocks) Delete(name string, options *v1.DeleteOptions) error {
 	_, err := c.Fake.
 		Invokes(testing.NewDeleteAction(iplocksResource, c.ns, name), &v1alpha1.IPLock{})
 
+	if err == nil {
+		c.Fake.objectStore().delete("iplocks", c.ns, name)
+	}
 	return err
 }
 
@@ -113,6 +147,14 @@ func (c *FakeIPLocks) DeleteCollection(options *v1.DeleteOptions, listOptions v1
 	action := testing.NewDeleteCollectionAction(iplocksResource, c.ns, listOptions)
 
 	_, err := c.Fake.Invokes(action, &v1alpha1.IPLockList{})
+	if err == nil {
+		for _, data := range c.Fake.objectStore().list("iplocks", c.ns) {
+			var item v1alpha1.IPLock
+			if jsonErr := json.Unmarshal(data, &item); jsonErr == nil {
+				c.Fake.objectStore().delete("iplocks", item.Namespace, item.Name)
+			}
+		}
+	}
 	return err
 }
 
@@ -124,5 +166,34 @@ func (c *FakeIPLocks) Patch(name string, pt types.PatchType, data []byte, subres
 	if obj == nil {
 		return nil, err
 	}
-	return obj.(*v1alpha1.IPLock), err
+
+	original := c.Fake.objectStore().get("iplocks", c.ns, name)
+	if original == nil {
+		return nil, errors.NewNotFound(iplocksResource.GroupResource(), name)
+	}
+	subresource := ""
+	if len(subresources) > 0 {
+		subresource = subresources[0]
+	}
+	patched, err := applySubresourcePatch(pt, original, data, &v1alpha1.IPLock{}, subresource)
+	if err != nil {
+		return nil, err
+	}
+	result = &v1alpha1.IPLock{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+	c.store(result)
+	return result, nil
+}
+
+// store writes iPLock into the fake's shared object store, keyed by its
+// own namespace and name rather than c.ns, so a cluster-scoped List (c.ns
+// == "") still finds it afterwards.
+func (c *FakeIPLocks) store(iPLock *v1alpha1.IPLock) {
+	data, err := marshal(iPLock)
+	if err != nil {
+		return
+	}
+	c.Fake.objectStore().set("iplocks", iPLock.Namespace, iPLock.Name, data)
 }
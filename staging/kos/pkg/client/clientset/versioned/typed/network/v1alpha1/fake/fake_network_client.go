@@ -14,8 +14,6 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-// Code generated by client-gen. DO NOT EDIT.
-
 package fake
 
 import (
@@ -26,6 +24,25 @@ import (
 
 type FakeNetworkV1alpha1 struct {
 	*testing.Fake
+	objects *store
+}
+
+// NewFakeNetworkV1alpha1 wires a FakeNetworkV1alpha1 with its own object
+// store, so Get/List/Patch against the IPLocks, NetworkAttachments and
+// Subnets it hands out reflect real Create/Update/Delete calls instead of
+// only the zero-value default client-go's testing.Fake would otherwise
+// fall back to.
+func NewFakeNetworkV1alpha1(fake *testing.Fake) *FakeNetworkV1alpha1 {
+	return &FakeNetworkV1alpha1{Fake: fake, objects: newStore()}
+}
+
+// objectStore returns c's object store, initializing it if c was built by
+// a struct literal rather than NewFakeNetworkV1alpha1.
+func (c *FakeNetworkV1alpha1) objectStore() *store {
+	if c.objects == nil {
+		c.objects = newStore()
+	}
+	return c.objects
 }
 
 func (c *FakeNetworkV1alpha1) IPLocks(namespace string) v1alpha1.IPLockInterface {
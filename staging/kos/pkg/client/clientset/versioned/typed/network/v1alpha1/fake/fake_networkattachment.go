@@ -0,0 +1,215 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+	v1alpha1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// FakeNetworkAttachments implements NetworkAttachmentInterface
+type FakeNetworkAttachments struct {
+	Fake *FakeNetworkV1alpha1
+	ns   string
+}
+
+var networkattachmentsResource = schema.GroupVersionResource{Group: "network.example.com", Version: "v1alpha1", Resource: "networkattachments"}
+
+var networkattachmentsKind = schema.GroupVersionKind{Group: "network.example.com", Version: "v1alpha1", Kind: "NetworkAttachment"}
+
+// Get takes name of the networkAttachment, and returns the corresponding networkAttachment object, and an error if there is any.
+func (c *FakeNetworkAttachments) Get(name string, options v1.GetOptions) (result *v1alpha1.NetworkAttachment, err error) {
+	var stored runtime.Object
+	if data := c.Fake.objectStore().get("networkattachments", c.ns, name); data != nil {
+		networkAttachment := &v1alpha1.NetworkAttachment{}
+		if err := json.Unmarshal(data, networkAttachment); err != nil {
+			return nil, err
+		}
+		stored = networkAttachment
+	}
+
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(networkattachmentsResource, c.ns, name), stored)
+
+	if obj == nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.NewNotFound(networkattachmentsResource.GroupResource(), name)
+	}
+	return obj.(*v1alpha1.NetworkAttachment), err
+}
+
+// List takes label and field selectors, and returns the list of NetworkAttachments that match those selectors.
+func (c *FakeNetworkAttachments) List(opts v1.ListOptions) (result *v1alpha1.NetworkAttachmentList, err error) {
+	stored := &v1alpha1.NetworkAttachmentList{}
+	for _, data := range c.Fake.objectStore().list("networkattachments", c.ns) {
+		var item v1alpha1.NetworkAttachment
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		stored.Items = append(stored.Items, item)
+	}
+
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(networkattachmentsResource, networkattachmentsKind, c.ns, opts), stored)
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.NetworkAttachmentList{}
+	for _, item := range obj.(*v1alpha1.NetworkAttachmentList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested networkAttachments.
+func (c *FakeNetworkAttachments) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(networkattachmentsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a networkAttachment and creates it.  Returns the server's representation of the networkAttachment, and an error, if there is any.
+func (c *FakeNetworkAttachments) Create(networkAttachment *v1alpha1.NetworkAttachment) (result *v1alpha1.NetworkAttachment, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(networkattachmentsResource, c.ns, networkAttachment), networkAttachment)
+
+	if obj == nil {
+		return nil, err
+	}
+	result = obj.(*v1alpha1.NetworkAttachment)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// Update takes the representation of a networkAttachment and updates it. Returns the server's representation of the networkAttachment, and an error, if there is any.
+func (c *FakeNetworkAttachments) Update(networkAttachment *v1alpha1.NetworkAttachment) (result *v1alpha1.NetworkAttachment, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(networkattachmentsResource, c.ns, networkAttachment), networkAttachment)
+
+	if obj == nil {
+		return nil, err
+	}
+	result = obj.(*v1alpha1.NetworkAttachment)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeNetworkAttachments) UpdateStatus(networkAttachment *v1alpha1.NetworkAttachment) (*v1alpha1.NetworkAttachment, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(networkattachmentsResource, "status", c.ns, networkAttachment), networkAttachment)
+
+	if obj == nil {
+		return nil, err
+	}
+	result := obj.(*v1alpha1.NetworkAttachment)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// Delete takes name of the networkAttachment and deletes it. Returns an error if one occurs.
+func (c *FakeNetworkAttachments) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(networkattachmentsResource, c.ns, name), &v1alpha1.NetworkAttachment{})
+
+	if err == nil {
+		c.Fake.objectStore().delete("networkattachments", c.ns, name)
+	}
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeNetworkAttachments) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(networkattachmentsResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.NetworkAttachmentList{})
+	if err == nil {
+		for _, data := range c.Fake.objectStore().list("networkattachments", c.ns) {
+			var item v1alpha1.NetworkAttachment
+			if jsonErr := json.Unmarshal(data, &item); jsonErr == nil {
+				c.Fake.objectStore().delete("networkattachments", item.Namespace, item.Name)
+			}
+		}
+	}
+	return err
+}
+
+// Patch applies the patch and returns the patched networkAttachment.
+func (c *FakeNetworkAttachments) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.NetworkAttachment, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(networkattachmentsResource, c.ns, name, data, subresources...), &v1alpha1.NetworkAttachment{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	original := c.Fake.objectStore().get("networkattachments", c.ns, name)
+	if original == nil {
+		return nil, errors.NewNotFound(networkattachmentsResource.GroupResource(), name)
+	}
+	subresource := ""
+	if len(subresources) > 0 {
+		subresource = subresources[0]
+	}
+	patched, err := applySubresourcePatch(pt, original, data, &v1alpha1.NetworkAttachment{}, subresource)
+	if err != nil {
+		return nil, err
+	}
+	result = &v1alpha1.NetworkAttachment{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+	c.store(result)
+	return result, nil
+}
+
+// store writes networkAttachment into the fake's shared object store,
+// keyed by its own namespace and name rather than c.ns, so a
+// cluster-scoped List (c.ns == "") still finds it afterwards.
+func (c *FakeNetworkAttachments) store(networkAttachment *v1alpha1.NetworkAttachment) {
+	data, err := marshal(networkAttachment)
+	if err != nil {
+		return
+	}
+	c.Fake.objectStore().set("networkattachments", networkAttachment.Namespace, networkAttachment.Name, data)
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+	v1alpha1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// FakeSubnets implements SubnetInterface
+type FakeSubnets struct {
+	Fake *FakeNetworkV1alpha1
+	ns   string
+}
+
+var subnetsResource = schema.GroupVersionResource{Group: "network.example.com", Version: "v1alpha1", Resource: "subnets"}
+
+var subnetsKind = schema.GroupVersionKind{Group: "network.example.com", Version: "v1alpha1", Kind: "Subnet"}
+
+// Get takes name of the subnet, and returns the corresponding subnet object, and an error if there is any.
+func (c *FakeSubnets) Get(name string, options v1.GetOptions) (result *v1alpha1.Subnet, err error) {
+	var stored runtime.Object
+	if data := c.Fake.objectStore().get("subnets", c.ns, name); data != nil {
+		subnet := &v1alpha1.Subnet{}
+		if err := json.Unmarshal(data, subnet); err != nil {
+			return nil, err
+		}
+		stored = subnet
+	}
+
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(subnetsResource, c.ns, name), stored)
+
+	if obj == nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.NewNotFound(subnetsResource.GroupResource(), name)
+	}
+	return obj.(*v1alpha1.Subnet), err
+}
+
+// List takes label and field selectors, and returns the list of Subnets that match those selectors.
+func (c *FakeSubnets) List(opts v1.ListOptions) (result *v1alpha1.SubnetList, err error) {
+	stored := &v1alpha1.SubnetList{}
+	for _, data := range c.Fake.objectStore().list("subnets", c.ns) {
+		var item v1alpha1.Subnet
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		stored.Items = append(stored.Items, item)
+	}
+
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(subnetsResource, subnetsKind, c.ns, opts), stored)
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.SubnetList{}
+	for _, item := range obj.(*v1alpha1.SubnetList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested subnets.
+func (c *FakeSubnets) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(subnetsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a subnet and creates it.  Returns the server's representation of the subnet, and an error, if there is any.
+func (c *FakeSubnets) Create(subnet *v1alpha1.Subnet) (result *v1alpha1.Subnet, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(subnetsResource, c.ns, subnet), subnet)
+
+	if obj == nil {
+		return nil, err
+	}
+	result = obj.(*v1alpha1.Subnet)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// Update takes the representation of a subnet and updates it. Returns the server's representation of the subnet, and an error, if there is any.
+func (c *FakeSubnets) Update(subnet *v1alpha1.Subnet) (result *v1alpha1.Subnet, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(subnetsResource, c.ns, subnet), subnet)
+
+	if obj == nil {
+		return nil, err
+	}
+	result = obj.(*v1alpha1.Subnet)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeSubnets) UpdateStatus(subnet *v1alpha1.Subnet) (*v1alpha1.Subnet, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(subnetsResource, "status", c.ns, subnet), subnet)
+
+	if obj == nil {
+		return nil, err
+	}
+	result := obj.(*v1alpha1.Subnet)
+	if err == nil {
+		c.store(result)
+	}
+	return result, err
+}
+
+// Delete takes name of the subnet and deletes it. Returns an error if one occurs.
+func (c *FakeSubnets) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(subnetsResource, c.ns, name), &v1alpha1.Subnet{})
+
+	if err == nil {
+		c.Fake.objectStore().delete("subnets", c.ns, name)
+	}
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeSubnets) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(subnetsResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.SubnetList{})
+	if err == nil {
+		for _, data := range c.Fake.objectStore().list("subnets", c.ns) {
+			var item v1alpha1.Subnet
+			if jsonErr := json.Unmarshal(data, &item); jsonErr == nil {
+				c.Fake.objectStore().delete("subnets", item.Namespace, item.Name)
+			}
+		}
+	}
+	return err
+}
+
+// Patch applies the patch and returns the patched subnet.
+func (c *FakeSubnets) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.Subnet, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(subnetsResource, c.ns, name, data, subresources...), &v1alpha1.Subnet{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	original := c.Fake.objectStore().get("subnets", c.ns, name)
+	if original == nil {
+		return nil, errors.NewNotFound(subnetsResource.GroupResource(), name)
+	}
+	subresource := ""
+	if len(subresources) > 0 {
+		subresource = subresources[0]
+	}
+	patched, err := applySubresourcePatch(pt, original, data, &v1alpha1.Subnet{}, subresource)
+	if err != nil {
+		return nil, err
+	}
+	result = &v1alpha1.Subnet{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+	c.store(result)
+	return result, nil
+}
+
+// store writes subnet into the fake's shared object store, keyed by its
+// own namespace and name rather than c.ns, so a cluster-scoped List (c.ns
+// == "") still finds it afterwards.
+func (c *FakeSubnets) store(subnet *v1alpha1.Subnet) {
+	data, err := marshal(subnet)
+	if err != nil {
+		return
+	}
+	c.Fake.objectStore().set("subnets", subnet.Namespace, subnet.Name, data)
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	testingclient "k8s.io/client-go/testing"
+	"k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// TestSubnetsPatchStatusJSONPatch exercises the same sequence a validating
+// controller runs: create a Subnet, then flip Status.Validated with a JSON
+// Patch against the "status" subresource, and confirm a subsequent Get
+// observes the patched value while Spec is untouched.
+func TestSubnetsPatchStatusJSONPatch(t *testing.T) {
+	client := NewFakeNetworkV1alpha1(&testingclient.Fake{})
+	subnets := client.Subnets("ns1")
+
+	created, err := subnets.Create(&v1alpha1.Subnet{
+		ObjectMeta: metav1.ObjectMeta{Name: "subnet1", Namespace: "ns1"},
+		Spec: v1alpha1.SubnetSpec{
+			IPv4: "10.0.0.0/24",
+			VNI:  1,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %s", err.Error())
+	}
+	if created.Status.Validated {
+		t.Fatalf("newly created Subnet should not start Validated")
+	}
+
+	patch := []byte(`[{"op":"replace","path":"/status/validated","value":true}]`)
+	patched, err := subnets.Patch("subnet1", types.JSONPatchType, patch, "status")
+	if err != nil {
+		t.Fatalf("Patch failed: %s", err.Error())
+	}
+	if !patched.Status.Validated {
+		t.Fatalf("Patch did not set Status.Validated")
+	}
+	if patched.Spec.IPv4 != "10.0.0.0/24" {
+		t.Fatalf("status patch changed Spec.IPv4 to %q", patched.Spec.IPv4)
+	}
+
+	got, err := subnets.Get("subnet1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get failed: %s", err.Error())
+	}
+	if !got.Status.Validated {
+		t.Fatalf("Get after Patch returned Status.Validated = false")
+	}
+	if got.Spec.VNI != 1 {
+		t.Fatalf("Get after Patch returned unexpected Spec.VNI = %d", got.Spec.VNI)
+	}
+}
+
+// TestSubnetsPatchStatusIgnoresSpecChange confirms a "status" subresource
+// patch cannot smuggle a Spec change through, even if the patch document
+// tries to touch both.
+func TestSubnetsPatchStatusIgnoresSpecChange(t *testing.T) {
+	client := NewFakeNetworkV1alpha1(&testingclient.Fake{})
+	subnets := client.Subnets("ns1")
+
+	if _, err := subnets.Create(&v1alpha1.Subnet{
+		ObjectMeta: metav1.ObjectMeta{Name: "subnet1", Namespace: "ns1"},
+		Spec:       v1alpha1.SubnetSpec{IPv4: "10.0.0.0/24", VNI: 1},
+	}); err != nil {
+		t.Fatalf("Create failed: %s", err.Error())
+	}
+
+	patch := []byte(`{"status":{"validated":true},"spec":{"vni":2}}`)
+	patched, err := subnets.Patch("subnet1", types.MergePatchType, patch, "status")
+	if err != nil {
+		t.Fatalf("Patch failed: %s", err.Error())
+	}
+	if !patched.Status.Validated {
+		t.Fatalf("Patch did not set Status.Validated")
+	}
+	if patched.Spec.VNI != 1 {
+		t.Fatalf("status patch changed Spec.VNI to %d, want unchanged 1", patched.Spec.VNI)
+	}
+}
+
+// TestIPLocksPatchRoundTrips confirms the same store-backed Patch works
+// for a type with no Status subresource.
+func TestIPLocksPatchRoundTrips(t *testing.T) {
+	client := NewFakeNetworkV1alpha1(&testingclient.Fake{})
+	iplocks := client.IPLocks("ns1")
+
+	if _, err := iplocks.Create(&v1alpha1.IPLock{
+		ObjectMeta: metav1.ObjectMeta{Name: "lock1", Namespace: "ns1"},
+		Spec:       v1alpha1.IPLockSpec{SubnetName: "subnet1"},
+	}); err != nil {
+		t.Fatalf("Create failed: %s", err.Error())
+	}
+
+	patch := []byte(`[{"op":"replace","path":"/spec/subnetName","value":"subnet2"}]`)
+	patched, err := iplocks.Patch("lock1", types.JSONPatchType, patch)
+	if err != nil {
+		t.Fatalf("Patch failed: %s", err.Error())
+	}
+	if patched.Spec.SubnetName != "subnet2" {
+		t.Fatalf("Patch did not update Spec.SubnetName, got %q", patched.Spec.SubnetName)
+	}
+
+	got, err := iplocks.Get("lock1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get failed: %s", err.Error())
+	}
+	if got.Spec.SubnetName != "subnet2" {
+		t.Fatalf("Get after Patch returned Spec.SubnetName = %q", got.Spec.SubnetName)
+	}
+}
+
+// TestSubnetsPatchUnknownObjectNotFound confirms Patch reports NotFound
+// for a name that was never Created, rather than silently succeeding
+// against a zero-value object.
+func TestSubnetsPatchUnknownObjectNotFound(t *testing.T) {
+	client := NewFakeNetworkV1alpha1(&testingclient.Fake{})
+	subnets := client.Subnets("ns1")
+
+	patch := []byte(`[{"op":"replace","path":"/status/validated","value":true}]`)
+	if _, err := subnets.Patch("missing", types.JSONPatchType, patch, "status"); err == nil {
+		t.Fatalf("Patch against a never-created Subnet should fail")
+	}
+}
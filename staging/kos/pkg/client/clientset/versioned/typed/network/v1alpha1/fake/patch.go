@@ -0,0 +1,75 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// applyPatch applies patch, of type pt, to original and returns the
+// resulting JSON. dataStruct is a value of the type being patched; it is
+// only consulted for types.StrategicMergePatchType, to read the
+// patchStrategy/patchMergeKey struct tags strategicpatch needs.
+func applyPatch(pt types.PatchType, original, patch []byte, dataStruct interface{}) ([]byte, error) {
+	switch pt {
+	case types.JSONPatchType:
+		decoded, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON patch: %s", err.Error())
+		}
+		return decoded.Apply(original)
+	case types.MergePatchType:
+		return jsonpatch.MergePatch(original, patch)
+	case types.StrategicMergePatchType:
+		return strategicpatch.StrategicMergePatch(original, patch, dataStruct)
+	default:
+		return nil, fmt.Errorf("unsupported patch type %q", pt)
+	}
+}
+
+// applySubresourcePatch is applyPatch restricted to touch only the named
+// top-level JSON field of original, the way the real apiserver keeps a
+// "status" patch from reaching Spec (and vice versa). An empty subresource
+// means the patch may touch anything, matching a patch against the main
+// resource.
+func applySubresourcePatch(pt types.PatchType, original, patch []byte, dataStruct interface{}, subresource string) ([]byte, error) {
+	modified, err := applyPatch(pt, original, patch, dataStruct)
+	if err != nil {
+		return nil, err
+	}
+	if subresource == "" {
+		return modified, nil
+	}
+
+	var oldFields map[string]json.RawMessage
+	if err := json.Unmarshal(original, &oldFields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal original object: %s", err.Error())
+	}
+	var newFields map[string]json.RawMessage
+	if err := json.Unmarshal(modified, &newFields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal patched object: %s", err.Error())
+	}
+	if field, ok := newFields[subresource]; ok {
+		oldFields[subresource] = field
+	}
+	return json.Marshal(oldFields)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// store is a minimal, JSON-bytes-keyed stand-in for a
+// k8s.io/client-go/testing.ObjectTracker. A real ObjectTracker needs a
+// runtime.Scheme and every stored type to implement
+// runtime.Object.DeepCopyObject, and this module has no
+// zz_generated.deepcopy.go for the network API group to satisfy that, so
+// store instead keeps each object serialized as JSON and lets Patch operate
+// on those bytes directly with evanphx/json-patch and apimachinery's
+// strategicpatch, both of which only need []byte in and []byte out.
+//
+// store is safe for concurrent use, matching the generated fakes it backs,
+// which are shared across goroutines in tests via the one Clientset they
+// come from.
+type store struct {
+	mu      sync.Mutex
+	objects map[string]map[string][]byte
+}
+
+func newStore() *store {
+	return &store{objects: map[string]map[string][]byte{}}
+}
+
+func (s *store) key(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// get returns the stored JSON for resource/namespace/name, or nil if absent.
+func (s *store) get(resource, namespace, name string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[resource][s.key(namespace, name)]
+	if !ok {
+		return nil
+	}
+	out := make([]byte, len(data))
+	copy(out, data)
+	return out
+}
+
+// list returns the stored JSON of every object of resource in namespace.
+// An empty namespace returns objects from every namespace, matching the
+// convention the generated List methods already use for cluster-wide
+// listing.
+func (s *store) list(resource, namespace string) [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out [][]byte
+	for key, data := range s.objects[resource] {
+		if namespace != "" && !hasNamespacePrefix(key, namespace) {
+			continue
+		}
+		cp := make([]byte, len(data))
+		copy(cp, data)
+		out = append(out, cp)
+	}
+	return out
+}
+
+func hasNamespacePrefix(key, namespace string) bool {
+	prefix := namespace + "/"
+	return len(key) >= len(prefix) && key[:len(prefix)] == prefix
+}
+
+// set stores data, a JSON-marshaled object, under resource/namespace/name,
+// overwriting whatever was there before.
+func (s *store) set(resource, namespace, name string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byKey, ok := s.objects[resource]
+	if !ok {
+		byKey = map[string][]byte{}
+		s.objects[resource] = byKey
+	}
+	byKey[s.key(namespace, name)] = data
+}
+
+// delete removes resource/namespace/name, reporting whether it was present.
+func (s *store) delete(resource, namespace, name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byKey, ok := s.objects[resource]
+	if !ok {
+		return false
+	}
+	key := s.key(namespace, name)
+	if _, ok := byKey[key]; !ok {
+		return false
+	}
+	delete(byKey, key)
+	return true
+}
+
+// marshal is a small helper so callers can turn their typed objects into
+// the JSON bytes store deals in without repeating the error wrapping.
+func marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T: %s", v, err.Error())
+	}
+	return data, nil
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+
+	v1alpha1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// VNILabelKey is the well-known label that whatever creates an IPLock (today
+// the NetworkAttachment IPAM path) is expected to copy from the owning
+// Subnet's Spec.VNI onto the IPLock. IPLockSpec itself only records the name
+// of that Subnet, so without this label a VNI-scoped query would have to
+// join through a SubnetLister; with it, ByVNI below stays a plain indexer
+// lookup, keeping it in the same complexity class as the by-namespace index
+// the generated List already gets for free.
+const VNILabelKey = "network.example.com/vni"
+
+// Index names registered against the IPLock SharedIndexInformer's Indexer so
+// that ByVNI, ByOwnerUID and BySubnetName below are O(1) map lookups plus a
+// small fanout over same-key IPLocks, instead of the O(n) scan that List plus
+// manual filtering would require.
+const (
+	iPLockVNIIndex        = "iPLockVNI"
+	iPLockOwnerUIDIndex   = "iPLockOwnerUID"
+	iPLockSubnetNameIndex = "iPLockSubnetName"
+)
+
+// Indexers returns the cache.Indexers that back ByVNI, ByOwnerUID and
+// BySubnetName. Pass this to the IPLock SharedIndexInformer's constructor (in
+// place of cache.Indexers{cache.NamespaceIndex: ...} alone) so the indexes
+// are populated as the informer's store is.
+func Indexers() cache.Indexers {
+	return cache.Indexers{
+		cache.NamespaceIndex:  cache.MetaNamespaceIndexFunc,
+		iPLockVNIIndex:        indexIPLockByVNI,
+		iPLockOwnerUIDIndex:   indexIPLockByOwnerUID,
+		iPLockSubnetNameIndex: indexIPLockBySubnetName,
+	}
+}
+
+func indexIPLockByVNI(obj interface{}) ([]string, error) {
+	iPLock, ok := obj.(*v1alpha1.IPLock)
+	if !ok {
+		return nil, fmt.Errorf("expected an IPLock, got %T", obj)
+	}
+	vni, ok := iPLock.Labels[VNILabelKey]
+	if !ok {
+		return nil, nil
+	}
+	return []string{vni}, nil
+}
+
+func indexIPLockByOwnerUID(obj interface{}) ([]string, error) {
+	iPLock, ok := obj.(*v1alpha1.IPLock)
+	if !ok {
+		return nil, fmt.Errorf("expected an IPLock, got %T", obj)
+	}
+	keys := make([]string, 0, len(iPLock.OwnerReferences))
+	for _, ref := range iPLock.OwnerReferences {
+		keys = append(keys, string(ref.UID))
+	}
+	return keys, nil
+}
+
+func indexIPLockBySubnetName(obj interface{}) ([]string, error) {
+	iPLock, ok := obj.(*v1alpha1.IPLock)
+	if !ok {
+		return nil, fmt.Errorf("expected an IPLock, got %T", obj)
+	}
+	if iPLock.Spec.SubnetName == "" {
+		return nil, nil
+	}
+	return []string{iPLock.Spec.SubnetName}, nil
+}
+
+// IPLockListerExpansion adds indexed lookups to IPLockLister, backed by the
+// cache.Indexers Indexers returns. Callers that did not construct the
+// informer with those indexers get an error from every method here, mirroring
+// how cache.Indexer.ByIndex itself fails closed on an unknown index name.
+type IPLockListerExpansion interface {
+	// ByVNI returns the IPLocks labeled with VNILabelKey equal to vni,
+	// across all namespaces.
+	ByVNI(vni uint32) ([]*v1alpha1.IPLock, error)
+	// ByOwnerUID returns the IPLocks with an OwnerReference to uid, across
+	// all namespaces.
+	ByOwnerUID(uid types.UID) ([]*v1alpha1.IPLock, error)
+	// BySubnetName returns the IPLocks whose Spec.SubnetName is
+	// subnetName, across all namespaces.
+	BySubnetName(subnetName string) ([]*v1alpha1.IPLock, error)
+}
+
+// IPLockNamespaceListerExpansion adds indexed lookups to
+// IPLockNamespaceLister, scoped to that lister's namespace.
+type IPLockNamespaceListerExpansion interface {
+	// ByVNI returns the IPLocks in this namespace labeled with VNILabelKey
+	// equal to vni.
+	ByVNI(vni uint32) ([]*v1alpha1.IPLock, error)
+	// ByOwnerUID returns the IPLocks in this namespace with an
+	// OwnerReference to uid.
+	ByOwnerUID(uid types.UID) ([]*v1alpha1.IPLock, error)
+	// BySubnetName returns the IPLocks in this namespace whose
+	// Spec.SubnetName is subnetName.
+	BySubnetName(subnetName string) ([]*v1alpha1.IPLock, error)
+}
+
+func (s *iPLockLister) ByVNI(vni uint32) ([]*v1alpha1.IPLock, error) {
+	return byIndex(s.indexer, iPLockVNIIndex, strconv.FormatUint(uint64(vni), 10))
+}
+
+func (s *iPLockLister) ByOwnerUID(uid types.UID) ([]*v1alpha1.IPLock, error) {
+	return byIndex(s.indexer, iPLockOwnerUIDIndex, string(uid))
+}
+
+func (s *iPLockLister) BySubnetName(subnetName string) ([]*v1alpha1.IPLock, error) {
+	return byIndex(s.indexer, iPLockSubnetNameIndex, subnetName)
+}
+
+func (s iPLockNamespaceLister) ByVNI(vni uint32) ([]*v1alpha1.IPLock, error) {
+	return byIndexInNamespace(s.indexer, iPLockVNIIndex, strconv.FormatUint(uint64(vni), 10), s.namespace)
+}
+
+func (s iPLockNamespaceLister) ByOwnerUID(uid types.UID) ([]*v1alpha1.IPLock, error) {
+	return byIndexInNamespace(s.indexer, iPLockOwnerUIDIndex, string(uid), s.namespace)
+}
+
+func (s iPLockNamespaceLister) BySubnetName(subnetName string) ([]*v1alpha1.IPLock, error) {
+	return byIndexInNamespace(s.indexer, iPLockSubnetNameIndex, subnetName, s.namespace)
+}
+
+func byIndex(indexer cache.Indexer, indexName, key string) ([]*v1alpha1.IPLock, error) {
+	objs, err := indexer.ByIndex(indexName, key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*v1alpha1.IPLock, 0, len(objs))
+	for _, obj := range objs {
+		ret = append(ret, obj.(*v1alpha1.IPLock))
+	}
+	return ret, nil
+}
+
+func byIndexInNamespace(indexer cache.Indexer, indexName, key, namespace string) ([]*v1alpha1.IPLock, error) {
+	all, err := byIndex(indexer, indexName, key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*v1alpha1.IPLock, 0, len(all))
+	for _, iPLock := range all {
+		if iPLock.Namespace == namespace {
+			ret = append(ret, iPLock)
+		}
+	}
+	return ret, nil
+}
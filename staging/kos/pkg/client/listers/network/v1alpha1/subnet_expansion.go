@@ -0,0 +1,136 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/client-go/tools/cache"
+
+	v1alpha1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// Index names registered against the Subnet SharedIndexInformer's Indexer
+// so that ByVNI and Degraded below are O(1) map lookups plus a small
+// fanout over same-key Subnets, instead of the O(n) scan that List plus
+// manual filtering would require.
+const (
+	subnetVNIIndex      = "subnetVNI"
+	subnetDegradedIndex = "subnetDegraded"
+)
+
+// Indexers returns the cache.Indexers that back ByVNI and Degraded. Pass
+// this to the Subnet SharedIndexInformer's constructor (in place of
+// cache.Indexers{cache.NamespaceIndex: ...} alone) so the indexes are
+// populated as the informer's store is.
+func SubnetIndexers() cache.Indexers {
+	return cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		subnetVNIIndex:       indexSubnetByVNI,
+		subnetDegradedIndex:  indexSubnetByDegraded,
+	}
+}
+
+func indexSubnetByVNI(obj interface{}) ([]string, error) {
+	subnet, ok := obj.(*v1alpha1.Subnet)
+	if !ok {
+		return nil, fmt.Errorf("expected a Subnet, got %T", obj)
+	}
+	return []string{strconv.FormatUint(uint64(subnet.Spec.VNI), 10)}, nil
+}
+
+// indexSubnetByDegraded indexes every Subnet under "true" or "false"
+// depending on whether Status.NotReadyNodes is nonzero, so Degraded below
+// can find every degraded Subnet in the cluster without a full List scan.
+func indexSubnetByDegraded(obj interface{}) ([]string, error) {
+	subnet, ok := obj.(*v1alpha1.Subnet)
+	if !ok {
+		return nil, fmt.Errorf("expected a Subnet, got %T", obj)
+	}
+	return []string{strconv.FormatBool(subnet.Status.NotReadyNodes > 0)}, nil
+}
+
+// SubnetListerExpansion adds indexed lookups to SubnetLister, backed by
+// the cache.Indexers SubnetIndexers returns. Callers that did not construct
+// the informer with those indexers get an error from every method here,
+// mirroring how cache.Indexer.ByIndex itself fails closed on an unknown
+// index name.
+type SubnetListerExpansion interface {
+	// ByVNI returns the Subnets with Spec.VNI equal to vni, across all
+	// namespaces. At most one such Subnet is expected to exist at a time
+	// per pkg/controllers/subnet's conflict checking, but this returns
+	// every Subnet the index has for vni, conflicting or not.
+	ByVNI(vni uint32) ([]*v1alpha1.Subnet, error)
+	// Degraded returns the Subnets across all namespaces whose
+	// Status.NotReadyNodes is greater than zero, i.e. those with at least
+	// one Node that has not installed their VNI/OVS state.
+	Degraded() ([]*v1alpha1.Subnet, error)
+}
+
+// SubnetNamespaceListerExpansion adds indexed lookups to
+// SubnetNamespaceLister, scoped to that lister's namespace.
+type SubnetNamespaceListerExpansion interface {
+	// ByVNI returns the Subnets in this namespace with Spec.VNI equal to
+	// vni.
+	ByVNI(vni uint32) ([]*v1alpha1.Subnet, error)
+	// Degraded returns the Subnets in this namespace whose
+	// Status.NotReadyNodes is greater than zero.
+	Degraded() ([]*v1alpha1.Subnet, error)
+}
+
+func (s *subnetLister) ByVNI(vni uint32) ([]*v1alpha1.Subnet, error) {
+	return subnetByIndex(s.indexer, subnetVNIIndex, strconv.FormatUint(uint64(vni), 10))
+}
+
+func (s *subnetLister) Degraded() ([]*v1alpha1.Subnet, error) {
+	return subnetByIndex(s.indexer, subnetDegradedIndex, "true")
+}
+
+func (s subnetNamespaceLister) ByVNI(vni uint32) ([]*v1alpha1.Subnet, error) {
+	return subnetByIndexInNamespace(s.indexer, subnetVNIIndex, strconv.FormatUint(uint64(vni), 10), s.namespace)
+}
+
+func (s subnetNamespaceLister) Degraded() ([]*v1alpha1.Subnet, error) {
+	return subnetByIndexInNamespace(s.indexer, subnetDegradedIndex, "true", s.namespace)
+}
+
+func subnetByIndex(indexer cache.Indexer, indexName, key string) ([]*v1alpha1.Subnet, error) {
+	objs, err := indexer.ByIndex(indexName, key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*v1alpha1.Subnet, 0, len(objs))
+	for _, obj := range objs {
+		ret = append(ret, obj.(*v1alpha1.Subnet))
+	}
+	return ret, nil
+}
+
+func subnetByIndexInNamespace(indexer cache.Indexer, indexName, key, namespace string) ([]*v1alpha1.Subnet, error) {
+	all, err := subnetByIndex(indexer, indexName, key)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*v1alpha1.Subnet, 0, len(all))
+	for _, subnet := range all {
+		if subnet.Namespace == namespace {
+			ret = append(ret, subnet)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cni
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// dialTimeout bounds how long Attach and Detach wait to connect to the
+// agent socket; the agent is always local, so a slow connect means
+// something is wrong rather than something is merely busy.
+const dialTimeout = 5 * time.Second
+
+// Attach tells the ConnectionAgent listening on agentSocket that
+// hostIfcName is the Linux network device for the NetworkAttachment
+// namespace/name, the way agentserver.go's ATTACH request is documented.
+func Attach(agentSocket, namespace, name, hostIfcName string) error {
+	return call(agentSocket, fmt.Sprintf("ATTACH %s/%s %s\n", namespace, name, hostIfcName))
+}
+
+// Detach tells the ConnectionAgent listening on agentSocket to tear down
+// and forget the NetworkAttachment namespace/name's interface, the way
+// agentserver.go's DETACH request is documented.
+func Detach(agentSocket, namespace, name string) error {
+	return call(agentSocket, fmt.Sprintf("DETACH %s/%s\n", namespace, name))
+}
+
+func call(agentSocket, request string) error {
+	conn, err := net.DialTimeout("unix", agentSocket, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to agent socket %s: %s", agentSocket, err.Error())
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return fmt.Errorf("failed to send request to agent socket %s: %s", agentSocket, err.Error())
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read reply from agent socket %s: %s", agentSocket, err.Error())
+	}
+	reply = strings.TrimSpace(reply)
+	if reply == "OK" {
+		return nil
+	}
+	return fmt.Errorf("agent socket %s rejected request %q: %s", agentSocket, strings.TrimSpace(request), reply)
+}
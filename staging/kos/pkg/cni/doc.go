@@ -0,0 +1,37 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cni is the shared library behind cmd/kos-cni-agent, a CNI
+// plugin that, unlike cmd/kos-cni (which drives a networkfabric.Interface
+// directly) and cmd/kos-cni-attachment (which only ever talks to the
+// apiserver and assumes something else -- typically a ConnectionAgent --
+// creates the actual Linux network device), creates the container's
+// network device itself: a veth pair, moved and configured the way
+// projects like Kilo do (vishvananda/netlink's LinkByName, LinkSetUp,
+// AddrAdd), with the container end renamed into the namespace kubelet
+// asked for.
+//
+// Ensuring the backing NetworkAttachment exists and waiting for its
+// Status to be populated is delegated to pkg/cniattachment, which this
+// package builds on rather than duplicating. What this package adds is
+// the device creation itself, and the handoff that tells a
+// ConnectionAgent about a device it did not create: ADD calls Attach over
+// a local Unix domain socket exposed by the connection agent (see
+// pkg/controllers/connectionagent/agentserver.go) once the veth is up, so
+// the agent's bookkeeping and on-disk interface state cache treat it the
+// same as one it created through the fabric itself; DEL calls Detach so
+// the agent tears it down through the fabric and forgets it.
+package cni // import "k8s.io/examples/staging/kos/pkg/cni"
@@ -0,0 +1,32 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cni
+
+import "k8s.io/examples/staging/kos/pkg/cniattachment"
+
+// NetConf is this plugin's netconf, read from stdin per the CNI spec. It
+// is cniattachment.NetConf (the NetworkAttachment this plugin manages)
+// plus AgentSocket, the one field specific to this plugin's device-
+// creation/handoff flow.
+type NetConf struct {
+	cniattachment.NetConf
+
+	// AgentSocket is the path of the Unix domain socket a ConnectionAgent
+	// is serving the ATTACH/DETACH protocol on (see
+	// pkg/controllers/connectionagent/agentserver.go). Required.
+	AgentSocket string `json:"agentSocket"`
+}
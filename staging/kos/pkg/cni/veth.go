@@ -0,0 +1,161 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cni
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// CreateVeth creates a veth pair named hostName/ifName on the host, moves
+// the ifName end into the network namespace at netnsPath, and there
+// renames it to ifName (its name on the host, before the move, is
+// whatever the kernel assigned the peer; it only needs to be unique on
+// the host for the brief moment it lives there), sets its MAC to mac,
+// adds addrs to it, and brings it up. hostName is left up on the host; it
+// is the caller's job to hand it to a ConnectionAgent (see Attach) or
+// delete it (e.g. via networkfabric.Interface.DeleteLocalIfc, which
+// deletes both ends of the pair since deleting either end of a veth
+// deletes the other).
+func CreateVeth(hostName, netnsPath string, ifName string, mac net.HardwareAddr, addrs []net.IPNet) error {
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: hostName},
+		PeerName:  tempPeerName(hostName),
+	}
+	if err := netlink.LinkAdd(veth); err != nil {
+		return fmt.Errorf("failed to create veth pair %q/%q: %s", hostName, veth.PeerName, err.Error())
+	}
+
+	hostLink, err := netlink.LinkByName(hostName)
+	if err != nil {
+		netlink.LinkDel(veth)
+		return fmt.Errorf("failed to look up just-created veth end %q: %s", hostName, err.Error())
+	}
+	if err := netlink.LinkSetUp(hostLink); err != nil {
+		netlink.LinkDel(veth)
+		return fmt.Errorf("failed to bring up %q: %s", hostName, err.Error())
+	}
+
+	peerLink, err := netlink.LinkByName(veth.PeerName)
+	if err != nil {
+		netlink.LinkDel(veth)
+		return fmt.Errorf("failed to look up just-created veth end %q: %s", veth.PeerName, err.Error())
+	}
+
+	containerNs, err := netns.GetFromPath(netnsPath)
+	if err != nil {
+		netlink.LinkDel(veth)
+		return fmt.Errorf("failed to open network namespace %q: %s", netnsPath, err.Error())
+	}
+	defer containerNs.Close()
+
+	if err := netlink.LinkSetNsFd(peerLink, int(containerNs)); err != nil {
+		netlink.LinkDel(veth)
+		return fmt.Errorf("failed to move %q into namespace %q: %s", veth.PeerName, netnsPath, err.Error())
+	}
+
+	configureErr := inNamespace(containerNs, func() error {
+		link, err := netlink.LinkByName(veth.PeerName)
+		if err != nil {
+			return fmt.Errorf("failed to find %q in namespace %q: %s", veth.PeerName, netnsPath, err.Error())
+		}
+		if err := netlink.LinkSetName(link, ifName); err != nil {
+			return fmt.Errorf("failed to rename %q to %q in namespace %q: %s", veth.PeerName, ifName, netnsPath, err.Error())
+		}
+		// re-resolve: the kernel identifies links by index, but netlink's Go
+		// bindings cache attributes (including name) on the Link value from
+		// the lookup that found it.
+		link, err = netlink.LinkByName(ifName)
+		if err != nil {
+			return fmt.Errorf("failed to find renamed link %q in namespace %q: %s", ifName, netnsPath, err.Error())
+		}
+		if err := netlink.LinkSetHardwareAddr(link, mac); err != nil {
+			return fmt.Errorf("failed to set MAC of %q in namespace %q: %s", ifName, netnsPath, err.Error())
+		}
+		for i := range addrs {
+			if err := netlink.AddrAdd(link, &netlink.Addr{IPNet: &addrs[i]}); err != nil {
+				return fmt.Errorf("failed to add address %s to %q in namespace %q: %s", addrs[i].String(), ifName, netnsPath, err.Error())
+			}
+		}
+		if err := netlink.LinkSetUp(link); err != nil {
+			return fmt.Errorf("failed to bring up %q in namespace %q: %s", ifName, netnsPath, err.Error())
+		}
+		return nil
+	})
+	if configureErr != nil {
+		netlink.LinkDel(hostLink)
+		return configureErr
+	}
+	return nil
+}
+
+// DeleteHostVeth deletes the host end of a veth pair created by
+// CreateVeth, if it still exists; deleting either end of a veth pair
+// deletes the other. It is a no-op if hostName does not exist, so callers
+// can use it unconditionally during clean-up.
+func DeleteHostVeth(hostName string) error {
+	link, err := netlink.LinkByName(hostName)
+	if err != nil {
+		if _, notFound := err.(netlink.LinkNotFoundError); notFound {
+			return nil
+		}
+		return fmt.Errorf("failed to look up %q: %s", hostName, err.Error())
+	}
+	if err := netlink.LinkDel(link); err != nil {
+		return fmt.Errorf("failed to delete %q: %s", hostName, err.Error())
+	}
+	return nil
+}
+
+// tempPeerName derives the host-side name the veth's container-bound end
+// is briefly known by before it is moved and renamed; it only needs to be
+// unique on the host for that brief window, so deriving it from hostName
+// (already unique, per hostIfcName) is enough.
+func tempPeerName(hostName string) string {
+	name := "c" + hostName
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	return name
+}
+
+// inNamespace runs fn with the calling goroutine's network namespace
+// switched to ns, switching back before returning. The OS thread is
+// locked for the duration, since a network namespace is a per-thread
+// property in Linux and Go may otherwise reschedule the goroutine onto a
+// different thread mid-call.
+func inNamespace(ns netns.NsHandle, fn func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get current network namespace: %s", err.Error())
+	}
+	defer origin.Close()
+
+	if err := netns.Set(ns); err != nil {
+		return fmt.Errorf("failed to switch to target network namespace: %s", err.Error())
+	}
+	defer netns.Set(origin)
+
+	return fn()
+}
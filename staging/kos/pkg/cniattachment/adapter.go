@@ -0,0 +1,251 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cniattachment
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	koscsv1a1 "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/typed/network/v1alpha1"
+)
+
+// managedByLabel, set to managedByValue on every NetworkAttachment this
+// plugin creates, is how GC tells attachments it is responsible for apart
+// from ones created some other way in the same namespace.
+const (
+	managedByLabel = "kos.example.com/managed-by"
+	managedByValue = "kos-cni-attachment"
+)
+
+// AttachmentName derives the NetworkAttachment name this plugin manages for
+// a pod. podInfraContainerID -- CNI's K8S_POD_INFRA_CONTAINER_ID, the pod
+// sandbox container's ID and the closest thing CNI exposes to a pod UID --
+// is used when the runtime supplies one, so the name is the pod's actual
+// identity rather than a function of its namespace/name (which a pod
+// restart under the same name would otherwise collide on); runtimes that
+// don't pass it fall back to a hash of podNamespace/podName. Either way ADD
+// and DEL for the same pod agree on the name without either needing to
+// persist anything of their own.
+func AttachmentName(podNamespace, podName, podInfraContainerID string) string {
+	if podInfraContainerID != "" {
+		return "cni-" + sanitizeForName(podInfraContainerID)
+	}
+	sum := sha256.Sum256([]byte(podNamespace + "/" + podName))
+	return fmt.Sprintf("cni-%x", sum)[:32]
+}
+
+// sanitizeForName lowercases id and truncates it to a length that fits
+// comfortably in a Kubernetes object name, so an unusually long container
+// ID from some runtime can't make AttachmentName produce an invalid one.
+func sanitizeForName(id string) string {
+	id = strings.ToLower(id)
+	if len(id) > 32 {
+		id = id[:32]
+	}
+	return id
+}
+
+// ParseCNIArgs parses CNI_ARGS, a ';'-separated list of KEY=VALUE pairs,
+// returning the K8S_POD_NAMESPACE, K8S_POD_NAME, and
+// K8S_POD_INFRA_CONTAINER_ID values.
+func ParseCNIArgs(raw string) (podNamespace, podName, podInfraContainerID string) {
+	for _, pair := range strings.Split(raw, ";") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "K8S_POD_NAMESPACE":
+			podNamespace = kv[1]
+		case "K8S_POD_NAME":
+			podName = kv[1]
+		case "K8S_POD_INFRA_CONTAINER_ID":
+			podInfraContainerID = kv[1]
+		}
+	}
+	return podNamespace, podName, podInfraContainerID
+}
+
+// EnsureAttachment returns the named NetworkAttachment, creating it with
+// the given Node, Subnet, PostCreateExec and PostDeleteExec if it does not
+// already exist. An attachment that already exists is returned as-is: ADD
+// is idempotent, and PostCreateExec/PostDeleteExec are immutable on the
+// NetworkAttachment anyway, so there is nothing to reconcile against it.
+func EnsureAttachment(ifc koscsv1a1.NetworkAttachmentInterface, namespace, name, node, subnet string, postCreateExec, postDeleteExec []string) (*netv1a1.NetworkAttachment, error) {
+	att, err := ifc.Get(name, k8smetav1.GetOptions{})
+	if err == nil {
+		return att, nil
+	}
+	if !k8serrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get NetworkAttachment %s/%s: %s", namespace, name, err.Error())
+	}
+	att = &netv1a1.NetworkAttachment{
+		ObjectMeta: k8smetav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    map[string]string{managedByLabel: managedByValue},
+		},
+		Spec: netv1a1.NetworkAttachmentSpec{
+			Node:           node,
+			Subnet:         subnet,
+			PostCreateExec: postCreateExec,
+			PostDeleteExec: postDeleteExec,
+		},
+	}
+	created, err := ifc.Create(att)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create NetworkAttachment %s/%s: %s", namespace, name, err.Error())
+	}
+	return created, nil
+}
+
+// WaitForReady polls the named NetworkAttachment until its Status reports a
+// complete Linux network interface (IPv4, MACAddress and IfcName all set),
+// a reported error, or timeout elapses.
+func WaitForReady(ifc koscsv1a1.NetworkAttachmentInterface, namespace, name string, timeout time.Duration) (*netv1a1.NetworkAttachment, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		att, err := ifc.Get(name, k8smetav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get NetworkAttachment %s/%s: %s", namespace, name, err.Error())
+		}
+		if att.Status.IPv4 != "" && att.Status.MACAddress != "" && att.Status.IfcName != "" {
+			return att, nil
+		}
+		if len(att.Status.Errors.IPAM) > 0 {
+			return nil, &attachmentError{code: ipamErrorCode, msg: fmt.Sprintf("NetworkAttachment %s/%s: IPAM errors: %v",
+				namespace, name, att.Status.Errors.IPAM)}
+		}
+		if len(att.Status.Errors.Host) > 0 {
+			return nil, &attachmentError{code: hostErrorCode, msg: fmt.Sprintf("NetworkAttachment %s/%s: host errors: %v",
+				namespace, name, att.Status.Errors.Host)}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for NetworkAttachment %s/%s to become ready", namespace, name)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// DeleteAndWait deletes the named NetworkAttachment, if it still exists,
+// and waits for its Status.IfcName to clear (or the object to be gone)
+// before returning, so DEL only returns once the interface it is
+// responsible for is actually gone.
+func DeleteAndWait(ifc koscsv1a1.NetworkAttachmentInterface, namespace, name string, timeout time.Duration) error {
+	if err := ifc.Delete(name, &k8smetav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete NetworkAttachment %s/%s: %s", namespace, name, err.Error())
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		att, err := ifc.Get(name, k8smetav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get NetworkAttachment %s/%s: %s", namespace, name, err.Error())
+		}
+		if att.Status.IfcName == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for NetworkAttachment %s/%s to clear its Linux network interface", namespace, name)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// BuildResult translates att's Status into a Result for cniVersion,
+// reporting ifName as the interface name and sandbox as its netns path.
+func BuildResult(cniVersion string, att *netv1a1.NetworkAttachment, ifName, sandbox string) (*Result, error) {
+	supported := false
+	for _, v := range SupportedVersions {
+		if v == cniVersion {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, fmt.Errorf("unsupported cniVersion %q", cniVersion)
+	}
+
+	ifIdx := 0
+	ips := []IPConfig{{Address: att.Status.IPv4, Interface: &ifIdx}}
+	if att.Status.IPv6 != "" {
+		ips = append(ips, IPConfig{Address: att.Status.IPv6, Interface: &ifIdx})
+	}
+	return &Result{
+		CNIVersion: cniVersion,
+		Interfaces: []Interface{{Name: ifName, Mac: att.Status.MACAddress, Sandbox: sandbox}},
+		IPs:        ips,
+	}, nil
+}
+
+// Status checks that ifc's backing apiserver is reachable, the way the CNI
+// STATUS command expects a plugin to report whether it is ready to serve
+// ADD/DEL.
+func Status(ifc koscsv1a1.NetworkAttachmentInterface) error {
+	if _, err := ifc.List(k8smetav1.ListOptions{Limit: 1}); err != nil {
+		return fmt.Errorf("kos-cni-attachment not ready: apiserver unreachable: %s", err.Error())
+	}
+	return nil
+}
+
+// GC deletes every NetworkAttachment this plugin manages (see
+// managedByLabel) in namespace whose name is not in keep, the CNI GC
+// command's way of cleaning up attachments left behind by a DEL this
+// plugin never got to run (e.g. the node rebooted mid-DEL).
+func GC(ifc koscsv1a1.NetworkAttachmentInterface, namespace string, keep map[string]struct{}) error {
+	atts, err := ifc.List(k8smetav1.ListOptions{LabelSelector: managedByLabel + "=" + managedByValue})
+	if err != nil {
+		return fmt.Errorf("failed to list managed NetworkAttachments in %s: %s", namespace, err.Error())
+	}
+	for _, att := range atts.Items {
+		if _, ok := keep[att.Name]; ok {
+			continue
+		}
+		glog.V(2).Infof("kos-cni-attachment GC: deleting orphaned attachment %s/%s", namespace, att.Name)
+		if err := ifc.Delete(att.Name, &k8smetav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete orphaned NetworkAttachment %s/%s: %s", namespace, att.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// EmitError prints err to stdout as a CNI spec error result; the spec
+// requires errors to go to stdout, not stderr. An *attachmentError's code
+// is reported as-is; any other error is reported as genericErrorCode.
+func EmitError(err error) {
+	code := genericErrorCode
+	if ae, ok := err.(*attachmentError); ok {
+		code = ae.code
+	}
+	json.NewEncoder(os.Stdout).Encode(cniError{
+		CNIVersion: "1.0.0",
+		Code:       code,
+		Msg:        err.Error(),
+	})
+}
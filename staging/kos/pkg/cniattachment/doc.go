@@ -0,0 +1,42 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cniattachment is the shared library behind cmd/kos-cni-attachment
+// (and the attachment half of cmd/kos-cni-agent), a CNI plugin that manages
+// a NetworkAttachment per CNI ADD/DEL instead of driving a
+// networkfabric.Interface directly the way cmd/kos-cni does. ADD creates
+// (or finds) a NetworkAttachment named deterministically from the CNI_ARGS
+// pod infra container ID (falling back to a hash of the pod namespace/name
+// for runtimes that don't supply one), waits for its
+// Status.IPv4/MACAddress/IfcName to be populated by whatever controller is
+// watching NetworkAttachments (e.g. the connection agent), and translates
+// that Status into a CNI Result. DEL deletes the NetworkAttachment and
+// waits for Status.IfcName to clear, so the plugin only returns once the
+// Linux network interface it reported as present is actually gone. STATUS
+// and GC, the two commands the 1.0.0 spec added, round out the set: STATUS
+// reports whether the apiserver is reachable, and GC deletes any
+// NetworkAttachment this plugin created (see the managedByLabel it stamps
+// on at ADD) that is not among the containerIDs the runtime says are still
+// in use.
+//
+// This module does not vendor github.com/containernetworking/cni, so
+// Result and its nested types here are a hand-rolled equivalent of that
+// module's current.Result, covering just the fields this adapter
+// populates (IPs, Interfaces, Routes, DNS). Their JSON shape is the same
+// across the three cniVersion strings this package supports -- 0.3.1,
+// 0.4.0 and 1.0.0 -- so a single Result struct serves all three rather
+// than needing the real types/create version-specific conversion.
+package cniattachment // import "k8s.io/examples/staging/kos/pkg/cniattachment"
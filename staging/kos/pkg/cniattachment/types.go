@@ -0,0 +1,155 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cniattachment
+
+import "time"
+
+// SupportedVersions are the CNI spec versions this adapter's Result
+// satisfies.
+var SupportedVersions = []string{"0.3.1", "0.4.0", "1.0.0"}
+
+// DefaultTimeout bounds how long ADD waits for a NetworkAttachment's
+// Status to be populated, and DEL waits for it to clear, when NetConf
+// does not set Timeout.
+const DefaultTimeout = 2 * time.Minute
+
+// pollInterval is how often WaitForReady and DeleteAndWait re-Get the
+// NetworkAttachment while waiting.
+const pollInterval = 200 * time.Millisecond
+
+// NetConf is this plugin's netconf, read from stdin per the CNI spec.
+type NetConf struct {
+	CNIVersion string `json:"cniVersion"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+
+	// Subnet is the name of the Subnet object the NetworkAttachment this
+	// plugin manages should draw its address from.
+	Subnet string `json:"subnet"`
+
+	// Namespace is the Kubernetes API namespace NetworkAttachments are
+	// created in. Defaults to "default".
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Kubeconfig is the path to a kubeconfig file used to reach the
+	// apiserver. An empty value means in-cluster config.
+	// +optional
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+
+	// Timeout is a Go duration string bounding how long ADD waits for
+	// Status to be populated and DEL waits for Status.IfcName to clear.
+	// Defaults to DefaultTimeout.
+	// +optional
+	Timeout string `json:"timeout,omitempty"`
+
+	// PostCreateExec and PostDeleteExec are copied verbatim into the
+	// NetworkAttachment's Spec fields of the same name on ADD (see
+	// pkg/apis/network/v1alpha1's NetworkAttachmentSpec); this plugin does
+	// not itself run them, the attachment's controller does once the
+	// Linux network interface exists. Both are immutable on the
+	// NetworkAttachment once created, so they only take effect the first
+	// time ADD creates a given attachment.
+	// +optional
+	PostCreateExec []string `json:"postCreateExec,omitempty"`
+	// +optional
+	PostDeleteExec []string `json:"postDeleteExec,omitempty"`
+}
+
+// GCArgs is the GC command's stdin payload: the same NetConf ADD/DEL read
+// (GC needs its Namespace and Kubeconfig), plus the containerIDs of every
+// attachment still in use.
+type GCArgs struct {
+	NetConf
+
+	// Attachments lists the K8S_POD_INFRA_CONTAINER_ID of every
+	// attachment still in use; any managed NetworkAttachment not named by
+	// one of them (see AttachmentName) is considered orphaned.
+	Attachments []GCAttachment `json:"attachments,omitempty"`
+}
+
+// GCAttachment identifies one attachment a GC invocation should keep.
+type GCAttachment struct {
+	ContainerID string `json:"containerID"`
+}
+
+// Result is this adapter's hand-rolled equivalent of the CNI Result type;
+// see the package doc comment for why.
+type Result struct {
+	CNIVersion string      `json:"cniVersion"`
+	Interfaces []Interface `json:"interfaces,omitempty"`
+	IPs        []IPConfig  `json:"ips,omitempty"`
+	Routes     []Route     `json:"routes,omitempty"`
+	DNS        DNS         `json:"dns,omitempty"`
+}
+
+// Interface describes one network interface in a Result.
+type Interface struct {
+	Name    string `json:"name"`
+	Mac     string `json:"mac,omitempty"`
+	Sandbox string `json:"sandbox,omitempty"`
+}
+
+// IPConfig describes one assigned address in a Result.
+type IPConfig struct {
+	Address   string `json:"address"`
+	Gateway   string `json:"gateway,omitempty"`
+	Interface *int   `json:"interface,omitempty"`
+}
+
+// Route describes one route in a Result.
+type Route struct {
+	Dst string `json:"dst"`
+	GW  string `json:"gw,omitempty"`
+}
+
+// DNS describes the DNS configuration in a Result.
+type DNS struct {
+	Nameservers []string `json:"nameservers,omitempty"`
+	Domain      string   `json:"domain,omitempty"`
+	Search      []string `json:"search,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// cniError is the CNI spec error result, printed to stdout on failure.
+type cniError struct {
+	CNIVersion string `json:"cniVersion"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+	Details    string `json:"details,omitempty"`
+}
+
+// CNI spec error codes. Codes 1-99 are reserved by the spec itself; this
+// plugin uses the 100+ range the spec sets aside for plugin-specific
+// codes to let a caller tell an IPAM failure apart from a more general
+// host-side one.
+const (
+	genericErrorCode = 100
+	ipamErrorCode    = 101
+	hostErrorCode    = 102
+)
+
+// attachmentError pairs a message with the CNI error code EmitError
+// should report for it, so WaitForReady can tell a NetworkAttachment's
+// Status.Errors.IPAM apart from its Status.Errors.Host the way the CNI
+// spec's error codes are meant to.
+type attachmentError struct {
+	code int
+	msg  string
+}
+
+func (e *attachmentError) Error() string { return e.msg }
@@ -0,0 +1,178 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"bufio"
+	"fmt"
+	gonet "net"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	netfabric "k8s.io/examples/staging/kos/pkg/networkfabric"
+)
+
+// runAgentServer listens on a Unix domain socket at ca.agentSocketPath and
+// serves a CNI plugin's ADD/DEL handoff (see cmd/kos-cni-agent and
+// pkg/cni): a client connects, writes a single request line, and gets back
+// a one-line reply, namely:
+//
+//	ATTACH <namespace>/<name> <hostIfcName>\n
+//	    Records hostIfcName, a Linux network device the caller has already
+//	    created and configured on the host (e.g. one end of a veth pair
+//	    moved into a container's network namespace), as the named local
+//	    NetworkAttachment's interface, the way processExistingAtt would
+//	    have if it had created the device itself. Replies "OK\n" or
+//	    "ERR <message>\n".
+//	DETACH <namespace>/<name>\n
+//	    Deletes the named NetworkAttachment's interface through its fabric
+//	    and forgets it (the same cleanup processDeletedAtt does), and
+//	    replies "OK\n" or "ERR <message>\n". A namespaced name with no
+//	    known interface is not an error: DETACH is idempotent.
+//
+// Runs until stopCh is closed. A disabled agentSocketPath (empty string)
+// makes this a no-op.
+func (ca *ConnectionAgent) runAgentServer(stopCh <-chan struct{}) {
+	if ca.agentSocketPath == "" {
+		return
+	}
+
+	os.Remove(ca.agentSocketPath)
+	listener, err := gonet.Listen("unix", ca.agentSocketPath)
+	if err != nil {
+		glog.Errorf("agent server failed to listen on %s: %s", ca.agentSocketPath, err.Error())
+		return
+	}
+
+	go func() {
+		<-stopCh
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-stopCh:
+				return
+			default:
+				glog.Warningf("agent server accept on %s failed: %s", ca.agentSocketPath, err.Error())
+				continue
+			}
+		}
+		go ca.serveAgentConn(conn)
+	}
+}
+
+func (ca *ConnectionAgent) serveAgentConn(conn gonet.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		glog.V(4).Infof("agent server: failed to read request: %s", err.Error())
+		return
+	}
+
+	cmd, rest, found := cutFirstSpace(strings.TrimSpace(line))
+	if !found {
+		glog.V(4).Infof("agent server: malformed request %q", line)
+		conn.Write([]byte("ERR malformed request\n"))
+		return
+	}
+
+	switch cmd {
+	case "ATTACH":
+		nsnStr, hostIfcName, found := cutFirstSpace(rest)
+		if !found {
+			writeAgentReply(conn, fmt.Errorf("malformed ATTACH request %q", rest))
+			return
+		}
+		nsn, err := parseNamespacedName(nsnStr)
+		if err != nil {
+			writeAgentReply(conn, err)
+			return
+		}
+		writeAgentReply(conn, ca.handleAttach(nsn, hostIfcName))
+	case "DETACH":
+		nsn, err := parseNamespacedName(rest)
+		if err != nil {
+			writeAgentReply(conn, err)
+			return
+		}
+		writeAgentReply(conn, ca.handleDetach(nsn))
+	default:
+		glog.V(4).Infof("agent server: unknown command %q", cmd)
+		conn.Write([]byte("ERR unknown command\n"))
+	}
+}
+
+func writeAgentReply(conn gonet.Conn, err error) {
+	if err != nil {
+		conn.Write([]byte(fmt.Sprintf("ERR %s\n", err.Error())))
+		return
+	}
+	conn.Write([]byte("OK\n"))
+}
+
+// handleAttach implements the ATTACH request: it looks up nsn's local
+// NetworkAttachment for the guest MAC and addresses, then records
+// hostIfcName as its interface the way processExistingAtt's call to
+// createOrUpdateIfc would have, without creating any device of its own
+// (the caller already did that).
+func (ca *ConnectionAgent) handleAttach(nsn k8stypes.NamespacedName, hostIfcName string) error {
+	att, err := ca.localAttsLister.NetworkAttachments(nsn.Namespace).Get(nsn.Name)
+	if err != nil {
+		return fmt.Errorf("no local NetworkAttachment %s: %s", nsn, err.Error())
+	}
+	mac, err := gonet.ParseMAC(att.Status.MACAddress)
+	if err != nil {
+		return fmt.Errorf("NetworkAttachment %s has no usable MAC address yet: %s", nsn, err.Error())
+	}
+	attVNI := att.Status.AddressVNI
+	attGuestIPv4 := gonet.ParseIP(att.Status.IPv4)
+	attGuestIPv6 := gonet.ParseIP(att.Status.IPv6)
+
+	ca.assignLocalIfc(nsn, netfabric.LocalNetIfc{
+		Name:      hostIfcName,
+		SegmentID: attVNI,
+		GuestMAC:  mac,
+		GuestIPs:  guestIPs(attGuestIPv4, attGuestIPv6),
+	})
+	ca.persistIfcState(nsn, attVNI, attGuestIPv4, attGuestIPv6, ca.hostIP)
+	return nil
+}
+
+// handleDetach implements the DETACH request: the same interface cleanup
+// processDeletedAtt does, for whichever local interface nsn currently has.
+func (ca *ConnectionAgent) handleDetach(nsn k8stypes.NamespacedName) error {
+	ifc, found := ca.getLocalIfc(nsn)
+	if !found {
+		return nil
+	}
+	fabric := ca.fabricForVNI(ifc.SegmentID)
+	if err := fabric.DeleteLocalIfc(ifc); err != nil {
+		return fmt.Errorf("failed to delete local Network Interface %q: %s", ifc.Name, err.Error())
+	}
+	ca.unsetLocalIfc(nsn)
+	ca.forgetIfcState(nsn)
+	return nil
+}
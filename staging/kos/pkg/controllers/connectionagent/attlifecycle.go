@@ -0,0 +1,185 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"sync"
+
+	"github.com/golang/glog"
+
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// lifecycleState is a stage in a local NetworkAttachment's address lifecycle,
+// in the order the connection agent moves it through. There is intentionally
+// no DAD (Duplicate Address Detection) stage: this connection agent assigns
+// guest addresses itself and never hands them to a client before it knows
+// them to be unique, so there is nothing for a client to wait on between
+// ifcCreated and statusPublished.
+type lifecycleState int
+
+const (
+	// lifecycleAssigned is the stage an attachment enters as soon as
+	// processExistingAtt starts working on it: the guest address has been
+	// assigned, but no interface exists for it yet.
+	lifecycleAssigned lifecycleState = iota
+	// lifecycleInterfaceUp is the stage an attachment enters once
+	// createOrUpdateIfc has created or confirmed its network interface.
+	lifecycleInterfaceUp
+	// lifecycleReady is the stage an attachment enters once its
+	// NetworkAttachment status has been published (or was already
+	// up-to-date), making it visible to other nodes.
+	lifecycleReady
+	// lifecycleRemoved is the final stage, entered once processDeletedAtt has
+	// finished cleaning up the attachment's interface (or found none to
+	// clean up).
+	lifecycleRemoved
+)
+
+func (s lifecycleState) String() string {
+	switch s {
+	case lifecycleAssigned:
+		return "Assigned"
+	case lifecycleInterfaceUp:
+		return "InterfaceUp"
+	case lifecycleReady:
+		return "Ready"
+	case lifecycleRemoved:
+		return "Removed"
+	default:
+		return "Unknown"
+	}
+}
+
+// lifecycleEvent is what subscribers of the address lifecycle stream (see
+// lifecycleserver.go) receive.
+type lifecycleEvent struct {
+	NSN   k8stypes.NamespacedName `json:"nsn"`
+	State string                  `json:"state"`
+}
+
+// attLifecycle tracks the current lifecycleState of every local
+// NetworkAttachment the connection agent knows about, and fans out state
+// transitions to whoever is subscribed to them (see lifecycleserver.go). It
+// is safe for concurrent use.
+type attLifecycle struct {
+	mutex sync.Mutex
+	state map[k8stypes.NamespacedName]lifecycleState
+	subs  map[k8stypes.NamespacedName][]chan lifecycleEvent
+}
+
+func newAttLifecycle() *attLifecycle {
+	return &attLifecycle{
+		state: make(map[k8stypes.NamespacedName]lifecycleState),
+		subs:  make(map[k8stypes.NamespacedName][]chan lifecycleEvent),
+	}
+}
+
+// advance moves nsn to newState and publishes the transition to every
+// current subscriber of nsn, but only if newState is forward progress: a
+// call that would move nsn backward (e.g. a stale retry reaching
+// lifecycleInterfaceUp after a newer goroutine already reached
+// lifecycleReady) is a no-op. This keeps the published stream monotonic even
+// though processExistingAtt can run concurrently for overlapping reasons
+// (requeues, updates).
+func (l *attLifecycle) advance(nsn k8stypes.NamespacedName, newState lifecycleState) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if current, ok := l.state[nsn]; ok && current >= newState {
+		return
+	}
+	l.state[nsn] = newState
+	glog.V(3).Infof("NetworkAttachment %s address lifecycle: %s", nsn, newState)
+	l.publishLocked(nsn, newState)
+}
+
+// forget drops nsn's tracked state and subscriber list, closing every
+// subscriber channel after publishing lifecycleRemoved. Called once
+// processDeletedAtt has finished.
+func (l *attLifecycle) forget(nsn k8stypes.NamespacedName) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	glog.V(3).Infof("NetworkAttachment %s address lifecycle: %s", nsn, lifecycleRemoved)
+	l.publishLocked(nsn, lifecycleRemoved)
+	for _, ch := range l.subs[nsn] {
+		close(ch)
+	}
+	delete(l.subs, nsn)
+	delete(l.state, nsn)
+}
+
+// detach drops nsn's tracked state without publishing lifecycleRemoved or
+// closing subscriber channels: ownership of the attachment's interface is
+// being handed off to a client (see ConnectionAgent.detachAtt), not deleted.
+func (l *attLifecycle) detach(nsn k8stypes.NamespacedName) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.state, nsn)
+}
+
+// subscribe registers a new subscriber for nsn's lifecycle events and
+// returns a channel it will receive them on, plus a function to unregister
+// it. The channel is unbuffered; a slow subscriber only blocks its own
+// publish, never other subscribers' (see publishLocked).
+func (l *attLifecycle) subscribe(nsn k8stypes.NamespacedName) (<-chan lifecycleEvent, func()) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	ch := make(chan lifecycleEvent, 8)
+	l.subs[nsn] = append(l.subs[nsn], ch)
+	cancel := func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		subs := l.subs[nsn]
+		for i, sub := range subs {
+			if sub == ch {
+				l.subs[nsn] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+	return ch, cancel
+}
+
+// publishLocked sends the event for (nsn, state) to every subscriber of nsn
+// without blocking on a full channel: a subscriber that isn't keeping up
+// misses events rather than stalling the connection agent's workers.
+// l.mutex must be held.
+func (l *attLifecycle) publishLocked(nsn k8stypes.NamespacedName, state lifecycleState) {
+	event := lifecycleEvent{NSN: nsn, State: state.String()}
+	for _, ch := range l.subs[nsn] {
+		select {
+		case ch <- event:
+		default:
+			glog.Warningf("address lifecycle subscriber for %s is not keeping up, dropping %s event", nsn, state)
+		}
+	}
+}
+
+// detachAtt hands off ownership of attNSN's interface to whoever asked for
+// it (see lifecycleserver.go's "DETACH" request), without deleting the
+// interface: the connection agent simply forgets about it, so a subsequent
+// deletion of the NetworkAttachment will find no interface of its own to
+// delete (see processDeletedAtt). The on-disk state cache entry is forgotten
+// too, since the connection agent is no longer the one responsible for the
+// interface it describes.
+func (ca *ConnectionAgent) detachAtt(attNSN k8stypes.NamespacedName) {
+	ca.unsetLocalIfc(attNSN)
+	ca.unsetRemoteIfc(attNSN)
+	ca.forgetIfcState(attNSN)
+	ca.lifecycle.detach(attNSN)
+	glog.V(2).Infof("detached NetworkAttachment %s: its interface is no longer managed by this connection agent", attNSN)
+}
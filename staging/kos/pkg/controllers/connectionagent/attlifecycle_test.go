@@ -0,0 +1,68 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"testing"
+
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func TestAttLifecycleAdvanceIsMonotonic(t *testing.T) {
+	l := newAttLifecycle()
+	nsn := k8stypes.NamespacedName{Namespace: "ns", Name: "att"}
+	events, cancel := l.subscribe(nsn)
+	defer cancel()
+
+	l.advance(nsn, lifecycleAssigned)
+	l.advance(nsn, lifecycleReady)
+	l.advance(nsn, lifecycleInterfaceUp) // stale: must not move state backward or publish
+
+	want := []string{"Assigned", "Ready"}
+	for _, w := range want {
+		select {
+		case e := <-events:
+			if e.State != w {
+				t.Errorf("got event %q, want %q", e.State, w)
+			}
+		default:
+			t.Fatalf("expected event %q, got none", w)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Errorf("got unexpected extra event %#+v after a stale advance", e)
+	default:
+	}
+}
+
+func TestAttLifecycleForgetClosesSubscribers(t *testing.T) {
+	l := newAttLifecycle()
+	nsn := k8stypes.NamespacedName{Namespace: "ns", Name: "att"}
+	events, cancel := l.subscribe(nsn)
+	defer cancel()
+
+	l.forget(nsn)
+
+	e, ok := <-events
+	if !ok || e.State != "Removed" {
+		t.Errorf("got (%#+v, %v), want a Removed event", e, ok)
+	}
+	if _, ok := <-events; ok {
+		t.Errorf("subscriber channel was not closed after forget")
+	}
+}
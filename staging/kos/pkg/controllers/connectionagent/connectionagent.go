@@ -18,20 +18,29 @@ package connectionagent
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	gonet "net"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8slabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	k8sutilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	k8swait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	k8scache "k8s.io/client-go/tools/cache"
 	k8sworkqueue "k8s.io/client-go/util/workqueue"
 
@@ -51,12 +60,18 @@ const (
 	// attachment. Used for syncing pre-existing interfaces at start-up.
 	attMACIndexName = "attachmentMAC"
 
+	// Name of the indexer which computes the VNI of a network attachment.
+	// Used by remoteAttsInformer (a single, cluster-wide informer shared by
+	// every Virtual Network) to find the remote attachments of a given VNI
+	// without a dedicated per-VNI watch; see vnState and initVNState.
+	attVNIIndexName = "attachmentVNI"
+
 	// NetworkAttachments in network.example.com/v1alpha1
 	// fields names. Used to build field selectors.
 	attNodeFieldName   = "spec.node"
 	attIPFieldName     = "status.ipv4"
+	attIPv6FieldName   = "status.ipv6"
 	attHostIPFieldName = "status.hostIP"
-	attVNIFieldName    = "status.addressVNI"
 
 	// fields selector comparison operators.
 	// Used to build fields selectors.
@@ -67,44 +82,57 @@ const (
 	// to 0 because we don't want resyncs.
 	resyncPeriod = 0
 
-	// netFabricRetryPeriod is the time we wait before retrying when an
-	// network fabric operation fails while handling pre-existing interfaces.
-	netFabricRetryPeriod = time.Second
+	// kosIfcNamePrefix is the prefix generateIfcName gives every interface
+	// name it generates, so that runLinkWatcher can recognize which netlink
+	// links are kos-owned.
+	kosIfcNamePrefix = "kos"
+
+	// wireguardHealthCheckPeriod is how often runWireguardHealthCheck polls
+	// the local WireGuard device for peer handshake ages.
+	wireguardHealthCheckPeriod = 30 * time.Second
 )
 
 // vnState stores all the state needed for a Virtual Network for
-// which there is at least one NetworkAttachment local to this node.
+// which there is at least one NetworkAttachment local to this node. Unlike
+// in an earlier design, it owns no informer of its own: every Virtual
+// Network's remote NetworkAttachments are covered by the single,
+// cluster-wide ConnectionAgent.remoteAttsInformer, and vnState is pure
+// bookkeeping of which attachment names currently belong to this Virtual
+// Network.
 type vnState struct {
-	// remoteAttsInformer is an informer on the NetworkAttachments that are
-	// both: (1) in the Virtual Network the vnState represents, (2) not on
-	// this node. It is stopped when the last local NetworkAttachment in the
-	// Virtual Network associated with the vnState instance is deleted. To
-	// stop it, remoteAttsInformerStopCh must be closed.
-	remoteAttsInformer       k8scache.SharedIndexInformer
-	remoteAttsInformerStopCh chan struct{}
-
-	// remoteAttsLister is a lister on the NetworkAttachments that are
-	// both: (1) in the Virtual Network the vnState represents, (2) not
-	// on this node. Since a Virtual Network cannot span multiple k8s API
-	// namespaces, it's a NamespaceLister.
-	remoteAttsLister koslisterv1a1.NetworkAttachmentNamespaceLister
-
 	// namespace is the namespace of the Virtual Network
 	// associated with this vnState.
 	namespace string
 
+	// fabricName is the name, within the ConnectionAgent's fabrics MultiFabric,
+	// of the network fabric that owns the Virtual Network's interfaces. It is
+	// resolved once, when the vnState is initialized, from the annotation on
+	// the Subnet of the Virtual Network's first local NetworkAttachment (see
+	// subnetFabricName); it does not change for the lifetime of the vnState.
+	fabricName string
+
 	// localAtts and remoteAtts store the names of the local and remote
 	// NetworkAttachments in the Virtual Network the vnState represents,
 	// respectively. localAtts is used to detect when the last local attachment
-	// in the virtual network is deleted, so that remoteAttsInformer can be
-	// stopped. remoteAtts is used to enqueue references to the remote
-	// attachments in the Virtual Network when such Virtual Network becomes
-	// irrelevant (deletion of last local attachment), so that the interfaces of
-	// the remote attachments can be deleted.
+	// in the virtual network is deleted, so that the vnState can be torn down.
+	// remoteAtts is used to enqueue references to the remote attachments in
+	// the Virtual Network when such Virtual Network becomes irrelevant
+	// (deletion of last local attachment), so that the interfaces of the
+	// remote attachments can be deleted.
 	localAtts  map[string]struct{}
 	remoteAtts map[string]struct{}
 }
 
+// SubnetGetter resolves the Subnet a NetworkAttachment references, the way
+// a SubnetLister would. It is its own interface, rather than a dependency
+// on the generated Subnet lister, because no such lister exists in this
+// module yet -- the only generated lister present is for IPLock (see
+// pkg/client/listers/network/v1alpha1). It mirrors the identically-justified
+// interface of the same name in pkg/registry/network/networkattachment.
+type SubnetGetter interface {
+	Get(namespace, name string) (*netv1a1.Subnet, error)
+}
+
 // ConnectionAgent represents a K8S controller which runs on every node of the
 // cluster and eagerly maintains up-to-date the mapping between virtual IPs and
 // physical IPs for every relevant NetworkAttachment. A NetworkAttachment is
@@ -114,13 +142,16 @@ type vnState struct {
 // connection agent receives notifications about relevant NetworkAttachments
 // from the K8s API server through Informers, and when necessary
 // creates/updates/deletes Network Interfaces through a low-level network
-// interface fabric. When a new Virtual Network becomes relevant for the
-// connection agent because of the creation of the first attachment of that
-// Virtual Network on the same node as the connection agent, a new informer on
-// remote NetworkAttachments in that Virtual Network is created. Upon being
-// notified of the creation of a local NetworkAttachment, the connection agent
-// also updates the status of such attachment with its host IP and the name of
-// the interface which was created.
+// interface fabric (possibly more than one: see fabrics). Remote
+// NetworkAttachments are all covered by a single, cluster-wide informer
+// (remoteAttsInformer) indexed by VNI; when a new Virtual Network becomes
+// relevant because of the creation of the first attachment of that Virtual
+// Network on the same node as the connection agent, its vnState picks up
+// that informer's already-cached remote attachments for the VNI with an
+// index scan rather than starting a watch of its own (see initVNState).
+// Upon being notified of the creation of a local NetworkAttachment, the
+// connection agent also updates the status of such attachment with its
+// host IP and the name of the interface which was created.
 type ConnectionAgent struct {
 	localNodeName string
 	hostIP        gonet.IP
@@ -128,14 +159,104 @@ type ConnectionAgent struct {
 	netv1a1Ifc    netvifc1a1.NetworkV1alpha1Interface
 	queue         k8sworkqueue.RateLimitingInterface
 	workers       int
-	netFabric     netfabric.Interface
-	stopCh        <-chan struct{}
+
+	// fabrics holds every network fabric backend this connection agent can
+	// use, keyed by name; which one owns a given Virtual Network is decided
+	// per-vnState (see vnState.fabricName and subnetFabricName).
+	fabrics *netfabric.MultiFabric
+
+	// subnets resolves the Subnet a NetworkAttachment references, so that its
+	// FabricAnnotation can be read. A nil subnets always yields the fabrics
+	// MultiFabric's default fabric.
+	subnets SubnetGetter
+
+	// dualStack, when true, makes the local attachments field selector
+	// require an IPv6 address in addition to an IPv4 one (see
+	// localAttWithAnIPSelector), and makes createOrUpdateIfc give guest
+	// interfaces both addresses. False keeps the connection agent's
+	// pre-dual-stack, IPv4-only behavior.
+	dualStack bool
+
+	stopCh <-chan struct{}
+
+	// healthzBindAddress is the address (host:port) the /metrics, /healthz,
+	// and /readyz HTTP server listens on. Empty disables the server.
+	healthzBindAddress string
+
+	// stateDir is the directory Run persists the on-disk interface state
+	// cache under (see statecache.go). Empty disables persistence.
+	stateDir string
+
+	// stateCache is the on-disk interface state cache, loaded by Run before
+	// pre-existing interfaces are synced.
+	stateCache *ifcStateCache
+
+	// linkWatchReconcilePeriod is how often runLinkWatcher does a full list
+	// of kos-owned netlink links, to catch any netlink event it missed. <= 0
+	// means defaultLinkWatchReconcilePeriod.
+	linkWatchReconcilePeriod time.Duration
+
+	// lifecycleSocketPath is the Unix domain socket runLifecycleServer
+	// listens on for the address lifecycle protocol (see
+	// lifecycleserver.go). Empty disables the server.
+	lifecycleSocketPath string
+
+	// agentSocketPath is the Unix domain socket runAgentServer listens on
+	// for the CNI ADD/DEL handoff protocol (see agentserver.go). Empty
+	// disables the server.
+	agentSocketPath string
+
+	// lifecycle tracks and publishes local NetworkAttachments' progress
+	// through their address lifecycle (see attlifecycle.go).
+	lifecycle *attLifecycle
+
+	// wireguard, if non-nil, puts the connection agent in secure mode (see
+	// wireguard.go): remote interfaces additionally (or, if
+	// wireguardExclusive, instead) route over a local WireGuard device.
+	// nil disables secure mode entirely, and every other wireguard* field
+	// below is then unused.
+	wireguard *wireguardManager
+
+	// wireguardExclusive, copied from WireguardConfig.Exclusive, makes
+	// createOrUpdateIfc and processDeletedAtt skip the fabric's own
+	// CreateRemoteIfc/DeleteRemoteIfc for remote interfaces, relying on
+	// wireguard alone to reach them.
+	wireguardExclusive bool
+
+	// wireguardKeyRotationPeriod, if positive, is how often
+	// runWireguardKeyRotation replaces the local WireGuard private key.
+	wireguardKeyRotationPeriod time.Duration
+
+	// kubeClient is a standard Kubernetes clientset, used only in secure
+	// mode: to watch Nodes for their published WireguardPublicKeyAnnotation
+	// (nodesInformer/nodesLister) and to publish this node's own key.
+	kubeClient kubernetes.Interface
+
+	// Informer and lister on Nodes, started only in secure mode, that
+	// onNodeAdded/Updated/Removed use to track every node's published
+	// WireguardPublicKeyAnnotation.
+	nodesInformer k8scache.SharedIndexInformer
+	nodesLister   corev1listers.NodeLister
+
+	// ready is set once the local attachments cache has synced and
+	// syncPreExistingIfcs has completed, gating /readyz.
+	ready int32
 
 	// Informer and lister on NetworkAttachments on the same node as the
 	// connection agent
 	localAttsInformer k8scache.SharedIndexInformer
 	localAttsLister   koslisterv1a1.NetworkAttachmentLister
 
+	// Informer and lister on NetworkAttachments that are not on the same
+	// node as the connection agent, cluster-wide and filtered only by
+	// remoteAttWithAnIPSelector -- not specific to any one Virtual Network.
+	// Additionally indexed by VNI (attVNIIndexName), so that a newly
+	// relevant Virtual Network's already-cached remote attachments can be
+	// found by a range scan (see initVNState) instead of a dedicated
+	// per-VNI watch, which is how this used to work.
+	remoteAttsInformer k8scache.SharedIndexInformer
+	remoteAttsLister   koslisterv1a1.NetworkAttachmentLister
+
 	// Map from vni to vnState associated with that vni. Accessed only while
 	// holding vniToVnStateMutex
 	vniToVnStateMutex sync.RWMutex
@@ -169,28 +290,85 @@ type ConnectionAgent struct {
 }
 
 // NewConnectionAgent returns a deactivated instance of a ConnectionAgent (neither
-// the workers goroutines nor any Informer have been started). Invoke Run to activate.
+// the workers goroutines nor any Informer have been started). Invoke Run to
+// activate. healthzBindAddress, if non-empty, is the host:port Run serves
+// /metrics, /healthz, and /readyz on. fabrics holds every network fabric
+// backend this connection agent can dispatch a Virtual Network to; subnets
+// resolves a NetworkAttachment's Subnet so that its FabricAnnotation can
+// select which of fabrics owns the VN (nil subnets, or a Subnet with no
+// FabricAnnotation, means the MultiFabric's default fabric). stateDir, if
+// non-empty, is the directory Run persists the on-disk interface state cache
+// under, so that a restart doesn't rip down interfaces that are still
+// legitimately owned just because the local attachments informer hasn't
+// caught up yet. linkWatchReconcilePeriod is how often Run's netlink-driven
+// watcher (see runLinkWatcher) does a full reconcile of kos-owned interfaces
+// against the local attachments cache; <= 0 means
+// defaultLinkWatchReconcilePeriod. lifecycleSocketPath, if non-empty, is the
+// Unix domain socket Run serves the address lifecycle protocol on (see
+// lifecycleserver.go), letting a CNI plugin or sidecar subscribe to a local
+// NetworkAttachment's lifecycle events instead of polling att.Status.IfcName.
+// agentSocketPath, if non-empty, is the Unix domain socket Run serves the
+// CNI ADD/DEL handoff protocol on (see agentserver.go), letting a CNI
+// plugin that creates a NetworkAttachment's device itself (e.g.
+// cmd/kos-cni-agent) tell the connection agent about it instead of the
+// connection agent creating it through the fabric. dualStack, if true,
+// makes the connection agent require and carry IPv6 addresses alongside
+// IPv4 ones; see ConnectionAgent.dualStack. wireguardConfig, if non-nil,
+// turns on secure mode (see wireguard.go); kubeClient must then be non-nil
+// too, as it is how the connection agent watches Nodes and publishes its
+// own WireGuard public key. Both are ignored (and may be nil) if
+// wireguardConfig is nil.
 func NewConnectionAgent(localNodeName string,
 	hostIP gonet.IP,
 	kcs *kosclientset.Clientset,
 	queue k8sworkqueue.RateLimitingInterface,
 	workers int,
-	netFabric netfabric.Interface) *ConnectionAgent {
+	fabrics *netfabric.MultiFabric,
+	subnets SubnetGetter,
+	healthzBindAddress string,
+	stateDir string,
+	linkWatchReconcilePeriod time.Duration,
+	lifecycleSocketPath string,
+	agentSocketPath string,
+	dualStack bool,
+	wireguardConfig *WireguardConfig,
+	kubeClient kubernetes.Interface) (*ConnectionAgent, error) {
+
+	ca := &ConnectionAgent{
+		localNodeName:            localNodeName,
+		hostIP:                   hostIP,
+		kcs:                      kcs,
+		netv1a1Ifc:               kcs.NetworkV1alpha1(),
+		queue:                    queue,
+		workers:                  workers,
+		fabrics:                  fabrics,
+		subnets:                  subnets,
+		dualStack:                dualStack,
+		healthzBindAddress:       healthzBindAddress,
+		stateDir:                 stateDir,
+		linkWatchReconcilePeriod: linkWatchReconcilePeriod,
+		lifecycleSocketPath:      lifecycleSocketPath,
+		agentSocketPath:          agentSocketPath,
+		kubeClient:               kubeClient,
+		lifecycle:                newAttLifecycle(),
+		vniToVnState:             make(map[uint32]*vnState),
+		nsnToVNStateVNI:          make(map[k8stypes.NamespacedName]uint32),
+		nsnToLocalIfc:            make(map[k8stypes.NamespacedName]netfabric.LocalNetIfc),
+		nsnToRemoteIfc:           make(map[k8stypes.NamespacedName]netfabric.RemoteNetIfc),
+		nsnToVNIs:                make(map[k8stypes.NamespacedName]map[uint32]struct{}),
+	}
 
-	return &ConnectionAgent{
-		localNodeName:   localNodeName,
-		hostIP:          hostIP,
-		kcs:             kcs,
-		netv1a1Ifc:      kcs.NetworkV1alpha1(),
-		queue:           queue,
-		workers:         workers,
-		netFabric:       netFabric,
-		vniToVnState:    make(map[uint32]*vnState),
-		nsnToVNStateVNI: make(map[k8stypes.NamespacedName]uint32),
-		nsnToLocalIfc:   make(map[k8stypes.NamespacedName]netfabric.LocalNetIfc),
-		nsnToRemoteIfc:  make(map[k8stypes.NamespacedName]netfabric.RemoteNetIfc),
-		nsnToVNIs:       make(map[k8stypes.NamespacedName]map[uint32]struct{}),
+	if wireguardConfig != nil {
+		wireguard, err := newWireguardManager(*wireguardConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up secure mode: %s", err.Error())
+		}
+		ca.wireguard = wireguard
+		ca.wireguardExclusive = wireguardConfig.Exclusive
+		ca.wireguardKeyRotationPeriod = wireguardConfig.KeyRotationPeriod
 	}
+
+	return ca, nil
 }
 
 // Run activates the ConnectionAgent: the local attachments informer is started,
@@ -201,19 +379,59 @@ func (ca *ConnectionAgent) Run(stopCh <-chan struct{}) error {
 	defer ca.queue.ShutDown()
 
 	ca.stopCh = stopCh
+
+	stateCache, err := loadIfcStateCache(ca.stateDir)
+	if err != nil {
+		return err
+	}
+	ca.stateCache = stateCache
+
+	if ca.healthzBindAddress != "" {
+		go ca.runHealthzServer(stopCh)
+	}
+
 	ca.initLocalAttsInformerAndLister()
 	go ca.localAttsInformer.Run(stopCh)
+	informersRunning.WithLabelValues(informerKindLocal).Set(1)
 	glog.V(2).Infoln("local NetworkAttachments informer started")
 
-	if err := ca.waitForLocalAttsCacheSync(stopCh); err != nil {
+	ca.initRemoteAttsInformerAndLister()
+	go ca.remoteAttsInformer.Run(stopCh)
+	informersRunning.WithLabelValues(informerKindRemote).Set(1)
+	glog.V(2).Infoln("remote NetworkAttachments informer started")
+
+	go ca.runLinkWatcher(stopCh)
+	go ca.runLifecycleServer(stopCh)
+	go ca.runAgentServer(stopCh)
+
+	if ca.wireguard != nil {
+		ca.initNodesInformerAndLister()
+		go ca.nodesInformer.Run(stopCh)
+		glog.V(2).Infoln("Nodes informer started")
+
+		pub, err := ca.wireguard.publicKey()
+		if err != nil {
+			return fmt.Errorf("failed to read initial WireGuard public key: %s", err.Error())
+		}
+		if err := ca.publishWireguardPublicKey(pub); err != nil {
+			return fmt.Errorf("failed to publish initial WireGuard public key: %s", err.Error())
+		}
+		if ca.wireguardKeyRotationPeriod > 0 {
+			go ca.runWireguardKeyRotation(stopCh)
+		}
+		go ca.runWireguardHealthCheck(stopCh)
+	}
+
+	if err := ca.waitForAttsCachesSync(stopCh); err != nil {
 		return err
 	}
-	glog.V(2).Infoln("local NetworkAttachments cache synced")
+	glog.V(2).Infoln("NetworkAttachments caches synced")
 
-	if err := ca.syncPreExistingIfcs(); err != nil {
+	if err := ca.syncPreExistingIfcs(stopCh); err != nil {
 		return err
 	}
 	glog.V(2).Infoln("pre-existing interfaces synced")
+	atomic.StoreInt32(&ca.ready, 1)
 
 	for i := 0; i < ca.workers; i++ {
 		go k8swait.Until(ca.processQueue, time.Second, stopCh)
@@ -224,6 +442,35 @@ func (ca *ConnectionAgent) Run(stopCh <-chan struct{}) error {
 	return nil
 }
 
+// runHealthzServer serves /metrics, /healthz, and /readyz on
+// ca.healthzBindAddress until stopCh is closed. /readyz answers 503 until
+// the local attachments cache has synced and pre-existing interfaces have
+// been reconciled (see atomic.StoreInt32(&ca.ready, ...) in Run).
+func (ca *ConnectionAgent) runHealthzServer(stopCh <-chan struct{}) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&ca.ready) == 1 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	server := &http.Server{Addr: ca.healthzBindAddress, Handler: mux}
+	go func() {
+		<-stopCh
+		server.Shutdown(context.Background())
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		glog.Errorf("healthz server on %s exited: %s", ca.healthzBindAddress, err.Error())
+	}
+}
+
 func (ca *ConnectionAgent) initLocalAttsInformerAndLister() {
 	localAttWithAnIPSelector := ca.localAttWithAnIPSelector()
 
@@ -240,6 +487,32 @@ func (ca *ConnectionAgent) initLocalAttsInformerAndLister() {
 	})
 }
 
+// initRemoteAttsInformerAndLister sets up the single, cluster-wide informer
+// and lister on remote NetworkAttachments shared by every Virtual Network
+// (see ConnectionAgent.remoteAttsInformer). Unlike initLocalAttsInformerAndLister,
+// there is exactly one of these for the whole connection agent, not one per
+// VNI: onRemoteAttAdded/Updated/Removed filter out VNIs that are not
+// currently relevant themselves (see vniIsRelevant), rather than relying on
+// the informer's field selector to do it.
+func (ca *ConnectionAgent) initRemoteAttsInformerAndLister() {
+	remoteAttWithAnIPSelector := ca.remoteAttWithAnIPSelector()
+
+	ca.remoteAttsInformer, ca.remoteAttsLister = v1a1AttsCustomInformerAndLister(ca.kcs,
+		resyncPeriod,
+		fromFieldsSelectorToTweakListOptionsFunc(remoteAttWithAnIPSelector))
+
+	ca.remoteAttsInformer.AddIndexers(map[string]k8scache.IndexFunc{
+		attMACIndexName: attachmentMACAddr,
+		attVNIIndexName: attachmentVNI,
+	})
+
+	ca.remoteAttsInformer.AddEventHandler(k8scache.ResourceEventHandlerFuncs{
+		AddFunc:    ca.onRemoteAttAdded,
+		UpdateFunc: ca.onRemoteAttUpdated,
+		DeleteFunc: ca.onRemoteAttRemoved,
+	})
+}
+
 func (ca *ConnectionAgent) onLocalAttAdded(obj interface{}) {
 	att := obj.(*netv1a1.NetworkAttachment)
 	glog.V(5).Infof("local NetworkAttachments cache: notified of addition of %#+v", att)
@@ -262,144 +535,14 @@ func (ca *ConnectionAgent) onLocalAttRemoved(obj interface{}) {
 	ca.queue.Add(kosctlrutils.AttNSN(att))
 }
 
-func (ca *ConnectionAgent) waitForLocalAttsCacheSync(stopCh <-chan struct{}) error {
-	if !k8scache.WaitForCacheSync(stopCh, ca.localAttsInformer.HasSynced) {
+func (ca *ConnectionAgent) waitForAttsCachesSync(stopCh <-chan struct{}) error {
+	if !k8scache.WaitForCacheSync(stopCh, ca.localAttsInformer.HasSynced, ca.remoteAttsInformer.HasSynced) {
 		return fmt.Errorf("caches failed to sync")
 	}
 	return nil
 }
 
-func (ca *ConnectionAgent) syncPreExistingIfcs() error {
-	if err := ca.syncPreExistingLocalIfcs(); err != nil {
-		return err
-	}
-
-	return ca.syncPreExistingRemoteIfcs()
-}
-
-func (ca *ConnectionAgent) syncPreExistingLocalIfcs() error {
-	allPreExistingLocalIfcs, err := ca.netFabric.ListLocalIfcs()
-	if err != nil {
-		return fmt.Errorf("failed initial local network interfaces list: %s", err.Error())
-	}
-
-	for _, aPreExistingLocalIfc := range allPreExistingLocalIfcs {
-		ifcMAC := aPreExistingLocalIfc.GuestMAC.String()
-		ifcOwnerAtts, err := ca.localAttsInformer.GetIndexer().ByIndex(attMACIndexName, ifcMAC)
-		if err != nil {
-			return fmt.Errorf("indexing local network interface with MAC %s failed: %s",
-				ifcMAC,
-				err.Error())
-		}
-
-		if len(ifcOwnerAtts) == 1 {
-			// If we're here there's a local attachment which should own the
-			// interface because their MAC addresses match. Hence we add the
-			// interface to the attachment state.
-			ifcOwnerAtt := ifcOwnerAtts[0].(*netv1a1.NetworkAttachment)
-			nsn := kosctlrutils.AttNSN(ifcOwnerAtt)
-			oldIfc, oldIfcExists := ca.getLocalIfc(nsn)
-			ca.assignLocalIfc(nsn, aPreExistingLocalIfc)
-			glog.V(3).Infof("matched interface %#+v with local attachment %#+v", aPreExistingLocalIfc, ifcOwnerAtt)
-			if oldIfcExists {
-				aPreExistingLocalIfc = oldIfc
-			} else {
-				continue
-			}
-		}
-
-		// If we're here the interface must be deleted, e.g. because it could
-		// not be matched to an attachment, or because the attachment to which
-		// it has already been matched has changed and was matched to a different
-		// interface.
-		for i, err := 1, ca.netFabric.DeleteLocalIfc(aPreExistingLocalIfc); err != nil; i++ {
-			glog.V(3).Infof("deletion of orphan local interface %#+v failed: %s. Attempt nbr. %d",
-				aPreExistingLocalIfc,
-				err.Error(),
-				i)
-			time.Sleep(netFabricRetryPeriod)
-		}
-		glog.V(3).Infof("deleted orphan local interface %#+v", aPreExistingLocalIfc)
-	}
-
-	return nil
-}
-
-func (ca *ConnectionAgent) syncPreExistingRemoteIfcs() error {
-	// Start all remote attachments caches because we need to look up remote
-	// attachments to decide which interfaces to keep and which to delete.
-	allLocalAtts, err := ca.localAttsLister.List(k8slabels.Everything())
-	if err != nil {
-		return fmt.Errorf("failed initial local attachments list: %s", err.Error())
-	}
-	for _, aLocalAtt := range allLocalAtts {
-		nsn, attVNI := kosctlrutils.AttNSN(aLocalAtt), aLocalAtt.Status.AddressVNI
-		ca.updateVNStateForExistingAtt(nsn, true, attVNI)
-	}
-
-	// Read all remote ifcs, for each interface find the attachment with the same
-	// MAC in the cache for the remote attachments with the same VNI as the interface.
-	// If either the attachment or the cache are not found, delete the interface,
-	// bind it to the attachment otherwise.
-	allPreExistingRemoteIfcs, err := ca.netFabric.ListRemoteIfcs()
-	if err != nil {
-		return fmt.Errorf("failed initial remote network interfaces list: %s", err.Error())
-	}
-	for _, aPreExistingRemoteIfc := range allPreExistingRemoteIfcs {
-		var ifcOwnerAtts []interface{}
-		ifcMAC, ifcVNI := aPreExistingRemoteIfc.GuestMAC.String(), aPreExistingRemoteIfc.VNI
-		remoteAttsInformer, remoteAttsInformerStopCh := ca.getRemoteAttsInformerForVNI(ifcVNI)
-		if remoteAttsInformer != nil {
-			if !remoteAttsInformer.HasSynced() &&
-				!k8scache.WaitForCacheSync(remoteAttsInformerStopCh, remoteAttsInformer.HasSynced) {
-				return fmt.Errorf("failed to sync cache of remote attachments for VNI %d", ifcVNI)
-			}
-			ifcOwnerAtts, err = remoteAttsInformer.GetIndexer().ByIndex(attMACIndexName, ifcMAC)
-		}
-
-		if len(ifcOwnerAtts) == 1 {
-			// If we're here a remote attachment owning the interface has been found
-			ifcOwnerAtt := ifcOwnerAtts[0].(*netv1a1.NetworkAttachment)
-			nsn := kosctlrutils.AttNSN(ifcOwnerAtt)
-			oldRemoteIfc, oldRemoteIfcExists := ca.getRemoteIfc(nsn)
-			ca.assignRemoteIfc(nsn, aPreExistingRemoteIfc)
-			glog.V(3).Infof("matched interface %#+v with remote attachment %#+v",
-				aPreExistingRemoteIfc,
-				ifcOwnerAtt)
-			if oldRemoteIfcExists {
-				aPreExistingRemoteIfc = oldRemoteIfc
-			} else {
-				if oldLocalIfc, oldLocalIfcExists := ca.getLocalIfc(nsn); oldLocalIfcExists {
-					for i, err := 1, ca.netFabric.DeleteLocalIfc(oldLocalIfc); err != nil; i++ {
-						glog.V(3).Infof("deletion of orphan local interface %#+v failed: %s. Attempt nbr. %d",
-							oldLocalIfc,
-							err.Error(),
-							i)
-						time.Sleep(netFabricRetryPeriod)
-					}
-					glog.V(3).Infof("deleted orphan local interface %#+v", oldLocalIfc)
-				}
-				continue
-			}
-		}
-
-		// If we're here either there's no remote attachments cache associated with
-		// the interface vni (because there are no local attachments with that vni),
-		// or no remote attachment owning the interface was found, or the attachment
-		// owning the interface already has one. For all such cases we need to delete
-		// the interface.
-		for i, err := 1, ca.netFabric.DeleteRemoteIfc(aPreExistingRemoteIfc); err != nil; i++ {
-			glog.V(3).Infof("deletion of orphan remote interface %#+v failed: %s. Attempt nbr. %d",
-				aPreExistingRemoteIfc,
-				err.Error(),
-				i)
-			time.Sleep(netFabricRetryPeriod)
-		}
-		glog.V(3).Infof("deleted orphan remote interface %#+v", aPreExistingRemoteIfc)
-	}
-
-	return nil
-}
+// syncPreExistingIfcs and its helpers live in sync.go.
 
 func (ca *ConnectionAgent) processQueue() {
 	for {
@@ -408,13 +551,15 @@ func (ca *ConnectionAgent) processQueue() {
 			return
 		}
 		attNSN := item.(k8stypes.NamespacedName)
-		ca.processQueueItem(attNSN)
+		ca.processQueueItem(context.Background(), attNSN)
 	}
 }
 
-func (ca *ConnectionAgent) processQueueItem(attNSN k8stypes.NamespacedName) {
+func (ca *ConnectionAgent) processQueueItem(ctx context.Context, attNSN k8stypes.NamespacedName) {
 	defer ca.queue.Done(attNSN)
-	err := ca.processNetworkAttachment(attNSN)
+	ctx, span := startAttSpan(ctx, "processQueueItem", attNSN, 0)
+	defer span.End()
+	err := ca.processNetworkAttachment(ctx, attNSN)
 	requeues := ca.queue.NumRequeues(attNSN)
 	if err != nil {
 		// If we're here there's been an error: either the attachment current state was
@@ -432,16 +577,22 @@ func (ca *ConnectionAgent) processQueueItem(attNSN k8stypes.NamespacedName) {
 	ca.queue.Forget(attNSN)
 }
 
-func (ca *ConnectionAgent) processNetworkAttachment(attNSN k8stypes.NamespacedName) error {
+func (ca *ConnectionAgent) processNetworkAttachment(ctx context.Context, attNSN k8stypes.NamespacedName) error {
 	att, deleted := ca.getAttachment(attNSN)
-	if att != nil {
+	var err error
+	switch {
+	case att != nil:
 		// If we are here the attachment exists and it's current state is univocal
-		return ca.processExistingAtt(att)
-	} else if deleted {
+		err = ca.processExistingAtt(ctx, att)
+	case deleted:
 		// If we are here the attachment has been deleted
-		return ca.processDeletedAtt(attNSN)
+		err = ca.processDeletedAtt(attNSN)
+	default:
+		recordProcessOutcome(outcomeAmbiguous)
+		return nil
 	}
-	return nil
+	recordProcessOutcome(outcomeForErr(err))
+	return err
 }
 
 // getAttachment attempts to determine the univocal version of the NetworkAttachment
@@ -476,10 +627,7 @@ func (ca *ConnectionAgent) getAttachment(attNSN k8stypes.NamespacedName) (*netv1
 		remAttCacheLookupErr error
 	)
 	if nbrOfVNIs == 1 {
-		remoteAttsLister := ca.getRemoteAttListerForVNI(vni)
-		if remoteAttsLister != nil {
-			attAsRemote, remAttCacheLookupErr = remoteAttsLister.Get(attNSN.Name)
-		}
+		attAsRemote, remAttCacheLookupErr = ca.getRemoteAttForVNI(vni, attNSN)
 	}
 
 	// Lookup the attachment in the local attachments cache
@@ -518,21 +666,25 @@ func (ca *ConnectionAgent) getAttachment(attNSN k8stypes.NamespacedName) (*netv1
 	return nil, true
 }
 
-func (ca *ConnectionAgent) processExistingAtt(att *netv1a1.NetworkAttachment) error {
+func (ca *ConnectionAgent) processExistingAtt(ctx context.Context, att *netv1a1.NetworkAttachment) error {
 	attNSN, attVNI := kosctlrutils.AttNSN(att), att.Status.AddressVNI
 	attNode := att.Spec.Node
+	attIsLocal := attNode == ca.localNodeName
+	if attIsLocal {
+		ca.lifecycle.advance(attNSN, lifecycleAssigned)
+	}
 
 	// Update the vnState associated with the attachment. This typically involves
 	// adding the attachment to the vnState associated to its vni (and initializing
 	// that vnState if the attachment is the first local one with its vni), but
 	// could also entail removing the attachment from the vnState associated with
 	// its old vni if the vni has changed.
-	vnState, noVnStateFoundForRemoteAtt := ca.updateVNState(attVNI, attNSN, attNode)
+	vnState, noVnStateFoundForRemoteAtt := ca.updateVNState(ctx, attVNI, attNSN, attNode, att.Spec.Subnet)
 	if vnState != nil {
 		// If we're here att is currently remote but was previously the last local
 		// attachment in its vni. Thus, we act as if the last local attachment
 		// in the vn was deleted
-		ca.clearVNResources(vnState, attNSN.Name, attVNI)
+		ca.enqueueDepartingRemoteAtts(vnState, attNSN.Name, attVNI)
 		return nil
 	}
 	if noVnStateFoundForRemoteAtt {
@@ -552,22 +704,27 @@ func (ca *ConnectionAgent) processExistingAtt(att *netv1a1.NetworkAttachment) er
 	} else {
 		attHostIP = gonet.ParseIP(att.Status.HostIP)
 	}
-	attGuestIP := gonet.ParseIP(att.Status.IPv4)
-	newLocalIfcName, err := ca.createOrUpdateIfc(attGuestIP,
+	attGuestIPv4 := gonet.ParseIP(att.Status.IPv4)
+	attGuestIPv6 := gonet.ParseIP(att.Status.IPv6)
+	newLocalIfcName, err := ca.createOrUpdateIfc(ctx,
+		attGuestIPv4,
+		attGuestIPv6,
 		attHostIP,
 		attVNI,
 		attNSN)
 	if err != nil {
 		return err
 	}
+	ca.persistIfcState(attNSN, attVNI, attGuestIPv4, attGuestIPv6, attHostIP)
+	if attIsLocal {
+		ca.lifecycle.advance(attNSN, lifecycleInterfaceUp)
+	}
 
 	// If the attachment is local, update its status with the local host IP and
 	// the name of the interface which was created (if it has changed).
 	localHostIPStr := ca.hostIP.String()
-	if attNode == ca.localNodeName &&
-		(att.Status.HostIP != localHostIPStr || (newLocalIfcName != "" && newLocalIfcName != att.Status.IfcName)) {
-
-		updatedAtt, err := ca.setAttStatus(att, newLocalIfcName)
+	if attIsLocal && (att.Status.HostIP != localHostIPStr || (newLocalIfcName != "" && newLocalIfcName != att.Status.IfcName)) {
+		updatedAtt, err := ca.setAttStatus(ctx, att, newLocalIfcName)
 		if err != nil {
 			return err
 		}
@@ -576,12 +733,19 @@ func (ca *ConnectionAgent) processExistingAtt(att *netv1a1.NetworkAttachment) er
 			updatedAtt.Status.HostIP,
 			updatedAtt.Status.IfcName)
 	}
+	if attIsLocal {
+		ca.lifecycle.advance(attNSN, lifecycleReady)
+	}
 
 	return nil
 }
 
 func (ca *ConnectionAgent) processDeletedAtt(attNSN k8stypes.NamespacedName) error {
 	vnStateVNI, vnStateVNIFound := ca.getVNStateVNI(attNSN)
+	// fabricForVNI is resolved before the vnState is possibly torn down by
+	// updateVNStateAfterAttDeparture below, since that's the last point at
+	// which the VNI still maps to the fabric that owns its interfaces.
+	fabric := ca.fabricForVNI(vnStateVNI)
 	if vnStateVNIFound {
 		ca.updateVNStateAfterAttDeparture(attNSN.Name, vnStateVNI)
 		ca.unsetVNStateVNI(attNSN)
@@ -589,27 +753,75 @@ func (ca *ConnectionAgent) processDeletedAtt(attNSN k8stypes.NamespacedName) err
 
 	localIfc, attHasLocalIfc := ca.getLocalIfc(attNSN)
 	if attHasLocalIfc {
-		if err := ca.netFabric.DeleteLocalIfc(localIfc); err != nil {
-			return err
+		err := fabric.DeleteLocalIfc(localIfc)
+		recordIfcOperation(ifcOpDelete, ifcKindLocal, err)
+		if err != nil {
+			return fabricOpError{err}
 		}
 		ca.unsetLocalIfc(attNSN)
+		ca.forgetIfcState(attNSN)
+		ca.lifecycle.forget(attNSN)
 		return nil
 	}
 
 	remoteIfc, attHasRemoteIfc := ca.getRemoteIfc(attNSN)
 	if attHasRemoteIfc {
-		if err := ca.netFabric.DeleteRemoteIfc(remoteIfc); err != nil {
-			return err
+		if !ca.wireguardExclusive {
+			err := fabric.DeleteRemoteIfc(remoteIfc)
+			recordIfcOperation(ifcOpDelete, ifcKindRemote, err)
+			if err != nil {
+				return fabricOpError{err}
+			}
 		}
 		ca.unsetRemoteIfc(attNSN)
+		ca.forgetIfcState(attNSN)
 	}
+	ca.lifecycle.forget(attNSN)
 
 	return nil
 }
 
-func (ca *ConnectionAgent) updateVNState(attNewVNI uint32,
+// persistIfcState records attNSN's current interface state (as just created
+// or updated by createOrUpdateIfc) in the on-disk state cache. Failures are
+// logged and otherwise ignored: the cache is a best-effort fallback for
+// start-up reconciliation, not a source of truth the connection agent
+// depends on for correctness.
+func (ca *ConnectionAgent) persistIfcState(attNSN k8stypes.NamespacedName, attVNI uint32, attGuestIPv4, attGuestIPv6, attHostIP gonet.IP) {
+	ifcName := ""
+	if localIfc, hasLocalIfc := ca.getLocalIfc(attNSN); hasLocalIfc {
+		ifcName = localIfc.Name
+	}
+	rec := ifcStateRecord{
+		VNI:       attVNI,
+		GuestIPv4: attGuestIPv4.String(),
+		GuestIPv6: attGuestIPv6.String(),
+		HostIP:    attHostIP.String(),
+		MAC:       generateMACAddr(attVNI, attGuestIPv4, attGuestIPv6).String(),
+		IfcName:   ifcName,
+		Fabric:    ca.fabricForVNI(attVNI).Name(),
+	}
+	if err := ca.stateCache.set(attNSN, rec); err != nil {
+		glog.Warningf("failed to persist interface state cache entry for %s: %s", attNSN, err.Error())
+	}
+}
+
+// forgetIfcState removes attNSN's entry, if any, from the on-disk state
+// cache. Failures are logged and otherwise ignored, for the same reason as
+// in persistIfcState.
+func (ca *ConnectionAgent) forgetIfcState(attNSN k8stypes.NamespacedName) {
+	if err := ca.stateCache.delete(attNSN); err != nil {
+		glog.Warningf("failed to remove interface state cache entry for %s: %s", attNSN, err.Error())
+	}
+}
+
+func (ca *ConnectionAgent) updateVNState(ctx context.Context,
+	attNewVNI uint32,
 	attNSN k8stypes.NamespacedName,
-	attNode string) (*vnState, bool) {
+	attNode string,
+	attSubnet string) (*vnState, bool) {
+
+	_, span := startAttSpan(ctx, "updateVNState", attNSN, attNewVNI)
+	defer span.End()
 
 	attOldVNI, oldVNIFound := ca.getVNStateVNI(attNSN)
 	if oldVNIFound && attOldVNI != attNewVNI {
@@ -620,23 +832,25 @@ func (ca *ConnectionAgent) updateVNState(attNewVNI uint32,
 		ca.unsetVNStateVNI(attNSN)
 	}
 
-	return ca.updateVNStateForExistingAtt(attNSN, attNode == ca.localNodeName, attNewVNI)
+	return ca.updateVNStateForExistingAtt(attNSN, attNode == ca.localNodeName, attNewVNI, attSubnet)
 }
 
 // updateVNStateForExistingAtt adds the attachment to the vnState associated with
 // its vni. If the attachment is local and is the first one for its vni, the
-// associated vnState is initialized (this entails starting the remote attachments
-// informer). If the attachment was the last local attachment in its vnState and
-// has become remote, the vnState for its vni is cleared (it's removed from the
-// map storing the vnStates) and returned, so that the caller can perform a clean
-// up of the resources associated with the vnState (remote attachments informer
-// is stopped and references to the remote attachments are enqueued). If the
-// attachment is remote and its vnState cannot be found (because the last local
-// attachment in the same Virtual Network has been deleted) noVnStateFoundForRemoteAtt
-// is set to false so that the caller knows and can react appropriately.
+// associated vnState is initialized (see initVNState). If the attachment was
+// the last local attachment in its vnState and has become remote, the vnState
+// for its vni is cleared (it's removed from the map storing the vnStates) and
+// returned, so that the caller can perform a clean up of the resources
+// associated with the vnState (references to its remaining remote
+// attachments are enqueued so their interfaces get deleted, see
+// enqueueDepartingRemoteAtts). If the attachment is remote and its vnState
+// cannot be found (because the last local attachment in the same Virtual
+// Network has been deleted) noVnStateFoundForRemoteAtt is set to false so
+// that the caller knows and can react appropriately.
 func (ca *ConnectionAgent) updateVNStateForExistingAtt(attNSN k8stypes.NamespacedName,
 	attIsLocal bool,
-	vni uint32) (vnStateRet *vnState, noVnStateFoundForRemoteAtt bool) {
+	vni uint32,
+	attSubnet string) (vnStateRet *vnState, noVnStateFoundForRemoteAtt bool) {
 
 	attName := attNSN.Name
 	firstLocalAttInVN := false
@@ -650,7 +864,8 @@ func (ca *ConnectionAgent) updateVNStateForExistingAtt(attNSN k8stypes.Namespace
 			ca.unsetVNStateVNI(attNSN)
 		}
 		if firstLocalAttInVN {
-			glog.V(2).Infof("VN with ID %d became relevant: an Informer has been started", vni)
+			vnBecameRelevantTotal.Inc()
+			glog.V(2).Infof("VN with ID %d became relevant", vni)
 		}
 	}()
 
@@ -658,19 +873,20 @@ func (ca *ConnectionAgent) updateVNStateForExistingAtt(attNSN k8stypes.Namespace
 	if attIsLocal {
 		// If we're here the attachment is local. If the vnState for the
 		// attachment vni is missing it means that the attachment is the first
-		// local one for its vni, hence we initialize the vnState (this entails
-		// starting the remote attachments informer). We also add the attachment
-		// name to the local attachments in the virtual network and remove the
-		// attachment name from the remote attachments: this is needed in case
-		// we're here because of an update which did not change the vni but made
-		// the attachment transition from remote to local.
+		// local one for its vni, hence we initialize the vnState. We also add
+		// the attachment name to the local attachments in the virtual network
+		// and remove the attachment name from the remote attachments: this is
+		// needed in case we're here because of an update which did not change
+		// the vni but made the attachment transition from remote to local.
 		if vnState == nil {
-			vnState = ca.initVNState(vni, attNSN.Namespace)
+			vnState = ca.initVNState(vni, attNSN.Namespace, attSubnet)
 			ca.vniToVnState[vni] = vnState
 			firstLocalAttInVN = true
 		}
 		delete(vnState.remoteAtts, attName)
 		vnState.localAtts[attName] = struct{}{}
+		remoteAttsPerVNI.WithLabelValues(vniLabel(vni)).Set(float64(len(vnState.remoteAtts)))
+		localAttsPerVNI.WithLabelValues(vniLabel(vni)).Set(float64(len(vnState.localAtts)))
 	} else {
 		// If we're here the attachment is remote. If the vnState for the
 		// attachment vni is not missing (because the last local attachment with
@@ -696,11 +912,17 @@ func (ca *ConnectionAgent) updateVNStateForExistingAtt(attNSN k8stypes.Namespace
 			if len(vnState.localAtts) == 0 {
 				delete(ca.vniToVnState, vni)
 				vnStateRet = vnState
+				remoteAttsPerVNI.DeleteLabelValues(vniLabel(vni))
+				localAttsPerVNI.DeleteLabelValues(vniLabel(vni))
+			} else {
+				remoteAttsPerVNI.WithLabelValues(vniLabel(vni)).Set(float64(len(vnState.remoteAtts)))
+				localAttsPerVNI.WithLabelValues(vniLabel(vni)).Set(float64(len(vnState.localAtts)))
 			}
 		} else {
 			noVnStateFoundForRemoteAtt = true
 		}
 	}
+	vnStateCount.Set(float64(len(ca.vniToVnState)))
 
 	return
 }
@@ -710,70 +932,93 @@ func (ca *ConnectionAgent) updateVNStateAfterAttDeparture(attName string, vni ui
 	if vnState == nil {
 		return
 	}
-	// If we're here attName was the last local attachment in the virtual network
-	// with id vni. Hence we stop the remote attachments informer and enqueue
-	// references to remote attachments in that virtual network, so that their
-	// interfaces can be deleted.
-	ca.clearVNResources(vnState, attName, vni)
+	// If we're here attName was the last local attachment in the virtual
+	// network with id vni. Hence we enqueue references to the remote
+	// attachments in that virtual network, so that their interfaces can be
+	// deleted.
+	ca.enqueueDepartingRemoteAtts(vnState, attName, vni)
 }
 
-func (ca *ConnectionAgent) createOrUpdateIfc(attGuestIP, attHostIP gonet.IP,
+func (ca *ConnectionAgent) createOrUpdateIfc(ctx context.Context, attGuestIPv4, attGuestIPv6, attHostIP gonet.IP,
 	attVNI uint32,
 	attNSN k8stypes.NamespacedName) (string, error) {
 
-	attMAC := generateMACAddr(attVNI, attGuestIP)
+	_, span := startAttSpan(ctx, "createOrUpdateIfc", attNSN, attVNI)
+	defer span.End()
+	timer := prometheus.NewTimer(reconcileStepDuration.WithLabelValues(stepCreateOrUpdateIfc))
+	defer timer.ObserveDuration()
+
+	fabric := ca.fabricForVNI(attVNI)
+	attMAC := generateMACAddr(attVNI, attGuestIPv4, attGuestIPv6)
+	attGuestIPs := guestIPs(attGuestIPv4, attGuestIPv6)
 	oldLocalIfc, attHasLocalIfc := ca.getLocalIfc(attNSN)
 	oldRemoteIfc, attHasRemoteIfc := ca.getRemoteIfc(attNSN)
 	newIfcNeedsToBeCreated := (!attHasLocalIfc && !attHasRemoteIfc) ||
-		(attHasLocalIfc && ifcNeedsUpdate(oldLocalIfc.HostIP, attHostIP, oldLocalIfc.GuestMAC, attMAC)) ||
-		(attHasRemoteIfc && ifcNeedsUpdate(oldRemoteIfc.HostIP, attHostIP, oldRemoteIfc.GuestMAC, attMAC))
+		(attHasLocalIfc && ifcNeedsUpdate(singleIP(oldLocalIfc.HostIPs), attHostIP, oldLocalIfc.GuestMAC, attMAC, oldLocalIfc.GuestIPs, attGuestIPs)) ||
+		(attHasRemoteIfc && ifcNeedsUpdate(singleIP(oldRemoteIfc.HostIPs), attHostIP, oldRemoteIfc.GuestMAC, attMAC, oldRemoteIfc.GuestIPs, attGuestIPs))
 
 	var newLocalIfcName string
 	if newIfcNeedsToBeCreated {
+		op := ifcOpCreate
+		if attHasLocalIfc || attHasRemoteIfc {
+			op = ifcOpUpdate
+		}
+
 		if attHasLocalIfc {
-			if err := ca.netFabric.DeleteLocalIfc(oldLocalIfc); err != nil {
-				return "", fmt.Errorf("update of network interface of attachment %s failed, old local interface %#+v could not be deleted: %s",
+			if err := fabric.DeleteLocalIfc(oldLocalIfc); err != nil {
+				recordIfcOperation(op, ifcKindLocal, err)
+				return "", fabricOpError{fmt.Errorf("update of network interface of attachment %s failed, old local interface %#+v could not be deleted: %s",
 					attNSN,
 					oldLocalIfc,
-					err.Error())
+					err.Error())}
 			}
 			ca.unsetLocalIfc(attNSN)
 		} else if attHasRemoteIfc {
-			if err := ca.netFabric.DeleteRemoteIfc(oldRemoteIfc); err != nil {
-				return "", fmt.Errorf("update of network interface of attachment %s failed, old remote interface %#+v could not be deleted: %s",
-					attNSN,
-					oldRemoteIfc,
-					err.Error())
+			if !ca.wireguardExclusive {
+				if err := fabric.DeleteRemoteIfc(oldRemoteIfc); err != nil {
+					recordIfcOperation(op, ifcKindRemote, err)
+					return "", fabricOpError{fmt.Errorf("update of network interface of attachment %s failed, old remote interface %#+v could not be deleted: %s",
+						attNSN,
+						oldRemoteIfc,
+						err.Error())}
+				}
 			}
 			ca.unsetRemoteIfc(attNSN)
 		}
 
 		if attHostIP.Equal(ca.hostIP) {
 			newLocalIfc := netfabric.LocalNetIfc{
-				Name:     generateIfcName(attMAC),
-				VNI:      attVNI,
-				GuestMAC: attMAC,
-				HostIP:   attHostIP,
+				Name:      generateIfcName(attMAC),
+				SegmentID: attVNI,
+				GuestMAC:  attMAC,
+				GuestIPs:  attGuestIPs,
 			}
-			if err := ca.netFabric.CreateLocalIfc(newLocalIfc); err != nil {
-				return "", fmt.Errorf("creation of local network interface of attachment %s failed, interface %#+v could not be created: %s",
+			err := fabric.CreateLocalIfc(newLocalIfc)
+			recordIfcOperation(op, ifcKindLocal, err)
+			if err != nil {
+				return "", fabricOpError{fmt.Errorf("creation of local network interface of attachment %s failed, interface %#+v could not be created: %s",
 					attNSN,
 					newLocalIfc,
-					err.Error())
+					err.Error())}
 			}
 			ca.assignLocalIfc(attNSN, newLocalIfc)
 			newLocalIfcName = newLocalIfc.Name
 		} else {
 			newRemoteIfc := netfabric.RemoteNetIfc{
-				VNI:      attVNI,
-				GuestMAC: attMAC,
-				HostIP:   attHostIP,
+				SegmentID: attVNI,
+				GuestMAC:  attMAC,
+				GuestIPs:  attGuestIPs,
+				HostIPs:   []gonet.IP{attHostIP},
 			}
-			if err := ca.netFabric.CreateRemoteIfc(newRemoteIfc); err != nil {
-				return "", fmt.Errorf("creation of remote network interface of attachment %s failed, interface %#+v could not be created: %s",
-					attNSN,
-					newRemoteIfc,
-					err.Error())
+			if !ca.wireguardExclusive {
+				err := fabric.CreateRemoteIfc(newRemoteIfc)
+				recordIfcOperation(op, ifcKindRemote, err)
+				if err != nil {
+					return "", fabricOpError{fmt.Errorf("creation of remote network interface of attachment %s failed, interface %#+v could not be created: %s",
+						attNSN,
+						newRemoteIfc,
+						err.Error())}
+				}
 			}
 			ca.assignRemoteIfc(attNSN, newRemoteIfc)
 		}
@@ -782,9 +1027,15 @@ func (ca *ConnectionAgent) createOrUpdateIfc(attGuestIP, attHostIP gonet.IP,
 	return newLocalIfcName, nil
 }
 
-func (ca *ConnectionAgent) setAttStatus(att *netv1a1.NetworkAttachment,
+func (ca *ConnectionAgent) setAttStatus(ctx context.Context, att *netv1a1.NetworkAttachment,
 	ifcName string) (*netv1a1.NetworkAttachment, error) {
 
+	attNSN := kosctlrutils.AttNSN(att)
+	_, span := startAttSpan(ctx, "setAttStatus", attNSN, att.Status.AddressVNI)
+	defer span.End()
+	timer := prometheus.NewTimer(reconcileStepDuration.WithLabelValues(stepSetAttStatus))
+	defer timer.ObserveDuration()
+
 	att2 := att.DeepCopy()
 	att2.Status.HostIP = ca.hostIP.String()
 	att2.Status.IfcName = ifcName
@@ -795,7 +1046,9 @@ func (ca *ConnectionAgent) setAttStatus(att *netv1a1.NetworkAttachment,
 // removeAttFromVNState removes attName from the vnState associated with vni, both
 // for local and remote attachments. If attName is the last local attachment in
 // the vnState, vnState is returned, so that the caller can perform additional
-// clean up (e.g. stopping the remote attachments informer).
+// clean up (e.g. enqueueing vnState's remaining remote attachments so their
+// interfaces get deleted; there is no per-VNI informer to stop any more, see
+// enqueueDepartingRemoteAtts).
 func (ca *ConnectionAgent) removeAttFromVNState(attName string, vni uint32) *vnState {
 	ca.vniToVnStateMutex.Lock()
 	defer ca.vniToVnStateMutex.Unlock()
@@ -804,22 +1057,31 @@ func (ca *ConnectionAgent) removeAttFromVNState(attName string, vni uint32) *vnS
 		delete(vnState.localAtts, attName)
 		if len(vnState.localAtts) == 0 {
 			delete(ca.vniToVnState, vni)
+			vnStateCount.Set(float64(len(ca.vniToVnState)))
+			remoteAttsPerVNI.DeleteLabelValues(vniLabel(vni))
+			localAttsPerVNI.DeleteLabelValues(vniLabel(vni))
 			return vnState
 		}
+		localAttsPerVNI.WithLabelValues(vniLabel(vni)).Set(float64(len(vnState.localAtts)))
 		delete(vnState.remoteAtts, attName)
+		remoteAttsPerVNI.WithLabelValues(vniLabel(vni)).Set(float64(len(vnState.remoteAtts)))
 	}
 	return nil
 }
 
-// clearVNResources stops the informer on remote attachments on the virtual
-// network and enqueues references to such attachments so that their interfaces
-// can be deleted.
-func (ca *ConnectionAgent) clearVNResources(vnState *vnState, lastAttName string, vni uint32) {
-	close(vnState.remoteAttsInformerStopCh)
-	glog.V(2).Infof("networkAttachment %s/%s was the last local with vni %d: remote attachments informer was stopped",
+// enqueueDepartingRemoteAtts enqueues references to vnState's remote
+// attachments, now that vni is no longer relevant to this connection agent
+// (lastAttName was just removed as its last local attachment), so that
+// their interfaces can be deleted. Unlike in an earlier design, there is no
+// per-VNI informer to stop here: the shared remoteAttsInformer keeps
+// running and caching vni's remote attachments regardless of vni's
+// relevance, ready for a future initVNState to pick them back up.
+func (ca *ConnectionAgent) enqueueDepartingRemoteAtts(vnState *vnState, lastAttName string, vni uint32) {
+	vnBecameIrrelevantTotal.Inc()
+	glog.V(2).Infof("VN with ID %d is no longer relevant: NetworkAttachment %s/%s was its last local one",
+		vni,
 		vnState.namespace,
-		lastAttName,
-		vni)
+		lastAttName)
 
 	for aRemoteAttName := range vnState.remoteAtts {
 		aRemoteAttNSN := k8stypes.NamespacedName{
@@ -831,64 +1093,152 @@ func (ca *ConnectionAgent) clearVNResources(vnState *vnState, lastAttName string
 	}
 }
 
-func (ca *ConnectionAgent) initVNState(vni uint32, namespace string) *vnState {
-	remoteAttsInformer, remoteAttsLister := v1a1AttsCustomNamespaceInformerAndLister(ca.kcs,
-		resyncPeriod,
-		namespace,
-		fromFieldsSelectorToTweakListOptionsFunc(ca.remoteAttInVNWithVirtualIPHostIPSelector(vni)))
-
-	remoteAttsInformer.AddIndexers(map[string]k8scache.IndexFunc{attMACIndexName: attachmentMACAddr})
+// initVNState initializes the vnState for a Virtual Network that has just
+// become relevant (gained its first local attachment on this node). It
+// starts no informer of its own: the shared, cluster-wide
+// remoteAttsInformer already covers every Virtual Network. What it does
+// still need to do is pick up whatever remote attachments of vni that
+// informer cached before vni became relevant -- no Add event fires for
+// them now, since they were already present in the cache -- by range-
+// scanning the shared VNI index and bootstrapping vnState.remoteAtts,
+// nsnToVNIs and the work queue from the result, exactly as
+// onRemoteAttAdded would have, had it been watching vni all along.
+func (ca *ConnectionAgent) initVNState(vni uint32, namespace, subnet string) *vnState {
+	vnState := &vnState{
+		namespace:  namespace,
+		fabricName: ca.subnetFabricName(namespace, subnet),
+		localAtts:  make(map[string]struct{}),
+		remoteAtts: make(map[string]struct{}),
+	}
 
-	remoteAttsInformer.AddEventHandler(k8scache.ResourceEventHandlerFuncs{
-		AddFunc:    ca.onRemoteAttAdded,
-		UpdateFunc: ca.onRemoteAttUpdated,
-		DeleteFunc: ca.onRemoteAttRemoved,
-	})
+	alreadyCached, err := ca.remoteAttsInformer.GetIndexer().ByIndex(attVNIIndexName, vniIndexKey(vni))
+	if err != nil {
+		glog.Warningf("failed to scan remote attachments cache for newly relevant VN with ID %d: %s", vni, err.Error())
+		return vnState
+	}
+	for _, obj := range alreadyCached {
+		att := obj.(*netv1a1.NetworkAttachment)
+		attNSN := kosctlrutils.AttNSN(att)
+		vnState.remoteAtts[att.Name] = struct{}{}
+		ca.addVNI(attNSN, vni)
+		ca.queue.Add(attNSN)
+	}
 
-	remoteAttsInformerStopCh := make(chan struct{})
-	go remoteAttsInformer.Run(aggregateTwoStopChannels(ca.stopCh, remoteAttsInformerStopCh))
+	return vnState
+}
 
-	return &vnState{
-		remoteAttsInformer:       remoteAttsInformer,
-		remoteAttsInformerStopCh: remoteAttsInformerStopCh,
-		remoteAttsLister:         remoteAttsLister,
-		namespace:                namespace,
-		localAtts:                make(map[string]struct{}),
-		remoteAtts:               make(map[string]struct{}),
-	}
+// vniIsRelevant reports whether vni currently has a vnState, i.e. at least
+// one local attachment on this node. Only a relevant VNI's remote
+// attachments are tracked in nsnToVNIs and enqueued by
+// onRemoteAttAdded/Updated/Removed; a VNI that becomes relevant picks up
+// the shared remoteAttsInformer's already-cached remote attachments for it
+// via initVNState's index scan instead.
+func (ca *ConnectionAgent) vniIsRelevant(vni uint32) bool {
+	ca.vniToVnStateMutex.RLock()
+	defer ca.vniToVnStateMutex.RUnlock()
+	_, relevant := ca.vniToVnState[vni]
+	return relevant
 }
 
 func (ca *ConnectionAgent) onRemoteAttAdded(obj interface{}) {
 	att := obj.(*netv1a1.NetworkAttachment)
-	glog.V(5).Infof("remote NetworkAttachments cache for VNI %d: notified of addition of %#+v",
-		att.Status.AddressVNI,
-		att)
+	vni := att.Status.AddressVNI
+	if !ca.vniIsRelevant(vni) {
+		return
+	}
+	glog.V(5).Infof("remote NetworkAttachments cache: notified of addition of %#+v", att)
 	attNSN := kosctlrutils.AttNSN(att)
-	ca.addVNI(attNSN, att.Status.AddressVNI)
+	ca.addVNI(attNSN, vni)
+	ca.wireguardSyncRemoteAtt(att)
 	ca.queue.Add(attNSN)
 }
 
 func (ca *ConnectionAgent) onRemoteAttUpdated(oldObj, newObj interface{}) {
 	oldAtt := oldObj.(*netv1a1.NetworkAttachment)
 	newAtt := newObj.(*netv1a1.NetworkAttachment)
-	glog.V(5).Infof("remote NetworkAttachments cache for VNI %d: notified of update from %#+v to %#+v",
-		newAtt.Status.AddressVNI,
-		oldAtt,
-		newAtt)
+	if !ca.vniIsRelevant(newAtt.Status.AddressVNI) {
+		return
+	}
+	glog.V(5).Infof("remote NetworkAttachments cache: notified of update from %#+v to %#+v", oldAtt, newAtt)
+	ca.wireguardSyncRemoteAtt(newAtt)
 	ca.queue.Add(kosctlrutils.AttNSN(newAtt))
 }
 
 func (ca *ConnectionAgent) onRemoteAttRemoved(obj interface{}) {
 	peeledObj := kosctlrutils.Peel(obj)
 	att := peeledObj.(*netv1a1.NetworkAttachment)
-	glog.V(5).Infof("remote NetworkAttachments cache for VNI %d: notified of deletion of %#+v",
-		att.Status.AddressVNI,
-		att)
+	vni := att.Status.AddressVNI
+	if !ca.vniIsRelevant(vni) {
+		return
+	}
+	glog.V(5).Infof("remote NetworkAttachments cache: notified of deletion of %#+v", att)
 	attNSN := kosctlrutils.AttNSN(att)
-	ca.removeSeenInVNI(attNSN, att.Status.AddressVNI)
+	ca.removeSeenInVNI(attNSN, vni)
+	ca.wireguardForgetRemoteAtt(attNSN)
 	ca.queue.Add(attNSN)
 }
 
+// wireguardSyncRemoteAtt is the onRemoteAttAdded/Updated hook that keeps
+// att's host's WireGuard peer (if in secure mode) carrying att's guest
+// IP(s) among its allowed IPs. A no-op if secure mode is off.
+func (ca *ConnectionAgent) wireguardSyncRemoteAtt(att *netv1a1.NetworkAttachment) {
+	if ca.wireguard == nil {
+		return
+	}
+	hostIP := gonet.ParseIP(att.Status.HostIP)
+	if hostIP == nil {
+		return
+	}
+	allowedIPs := ca.wireguardAllowedIPs(gonet.ParseIP(att.Status.IPv4), gonet.ParseIP(att.Status.IPv6), att.Namespace, att.Spec.Subnet)
+	if err := ca.wireguard.addAttachment(kosctlrutils.AttNSN(att), hostIP, allowedIPs); err != nil {
+		glog.Warningf("failed to configure WireGuard peer for attachment %s: %s", kosctlrutils.AttNSN(att), err.Error())
+	}
+}
+
+// wireguardForgetRemoteAtt is the onRemoteAttRemoved hook undoing a prior
+// wireguardSyncRemoteAtt for attNSN. A no-op if secure mode is off.
+func (ca *ConnectionAgent) wireguardForgetRemoteAtt(attNSN k8stypes.NamespacedName) {
+	if ca.wireguard == nil {
+		return
+	}
+	if err := ca.wireguard.removeAttachment(attNSN); err != nil {
+		glog.Warningf("failed to remove WireGuard peer allowed-IPs for attachment %s: %s", attNSN, err.Error())
+	}
+}
+
+// subnetFabricName resolves the name of the network fabric that owns VNs
+// carved out of the named Subnet, i.e. the value of its FabricAnnotation. It
+// returns "" (meaning the fabrics MultiFabric's default) if ca.subnets is
+// nil, the Subnet cannot be fetched, or the Subnet carries no
+// FabricAnnotation.
+func (ca *ConnectionAgent) subnetFabricName(namespace, subnet string) string {
+	if ca.subnets == nil {
+		return ""
+	}
+	s, err := ca.subnets.Get(namespace, subnet)
+	if err != nil {
+		glog.Warningf("could not fetch Subnet %s/%s to resolve its network fabric, falling back to the default fabric: %s",
+			namespace, subnet, err.Error())
+		return ""
+	}
+	return s.Annotations[netv1a1.FabricAnnotation]
+}
+
+// fabricForVNI returns the network fabric that owns the interfaces of the
+// Virtual Network identified by vni, i.e. the fabric named by the vnState's
+// fabricName field. It falls back to the fabrics MultiFabric's default if
+// vni has no vnState (e.g. a NetworkAttachment whose VNI was never seen
+// locally).
+func (ca *ConnectionAgent) fabricForVNI(vni uint32) netfabric.Interface {
+	ca.vniToVnStateMutex.RLock()
+	vnState := ca.vniToVnState[vni]
+	ca.vniToVnStateMutex.RUnlock()
+	if vnState == nil {
+		return ca.fabrics.Get("")
+	}
+	return ca.fabrics.Get(vnState.fabricName)
+}
+
 func (ca *ConnectionAgent) getLocalIfc(nsn k8stypes.NamespacedName) (ifc netfabric.LocalNetIfc, ifcFound bool) {
 	ca.nsnToLocalIfcMutex.RLock()
 	defer ca.nsnToLocalIfcMutex.RUnlock()
@@ -900,6 +1250,7 @@ func (ca *ConnectionAgent) assignLocalIfc(nsn k8stypes.NamespacedName, ifc netfa
 	ca.nsnToLocalIfcMutex.Lock()
 	defer ca.nsnToLocalIfcMutex.Unlock()
 	ca.nsnToLocalIfc[nsn] = ifc
+	localIfcCount.Set(float64(len(ca.nsnToLocalIfc)))
 }
 
 func (ca *ConnectionAgent) getRemoteIfc(nsn k8stypes.NamespacedName) (ifc netfabric.RemoteNetIfc, ifcFound bool) {
@@ -913,6 +1264,7 @@ func (ca *ConnectionAgent) assignRemoteIfc(nsn k8stypes.NamespacedName, ifc netf
 	ca.nsnToRemoteIfcMutex.Lock()
 	defer ca.nsnToRemoteIfcMutex.Unlock()
 	ca.nsnToRemoteIfc[nsn] = ifc
+	remoteIfcCount.Set(float64(len(ca.nsnToRemoteIfc)))
 }
 
 func (ca *ConnectionAgent) getVNStateVNI(nsn k8stypes.NamespacedName) (vni uint32, vniFound bool) {
@@ -938,12 +1290,14 @@ func (ca *ConnectionAgent) unsetLocalIfc(nsn k8stypes.NamespacedName) {
 	ca.nsnToLocalIfcMutex.Lock()
 	defer ca.nsnToLocalIfcMutex.Unlock()
 	delete(ca.nsnToLocalIfc, nsn)
+	localIfcCount.Set(float64(len(ca.nsnToLocalIfc)))
 }
 
 func (ca *ConnectionAgent) unsetRemoteIfc(nsn k8stypes.NamespacedName) {
 	ca.nsnToRemoteIfcMutex.Lock()
 	defer ca.nsnToRemoteIfcMutex.Unlock()
 	delete(ca.nsnToRemoteIfc, nsn)
+	remoteIfcCount.Set(float64(len(ca.nsnToRemoteIfc)))
 }
 
 func (ca *ConnectionAgent) addVNI(nsn k8stypes.NamespacedName, vni uint32) {
@@ -982,25 +1336,23 @@ func (ca *ConnectionAgent) getAttSeenInVNI(nsn k8stypes.NamespacedName) (onlyVNI
 	return
 }
 
-func (ca *ConnectionAgent) getRemoteAttListerForVNI(vni uint32) koslisterv1a1.NetworkAttachmentNamespaceLister {
-	ca.vniToVnStateMutex.RLock()
-	defer ca.vniToVnStateMutex.RUnlock()
-	vnState := ca.vniToVnState[vni]
-	if vnState == nil {
-		return nil
+// getRemoteAttForVNI looks up nsn within the shared remoteAttsInformer's VNI
+// index, restricted to vni, mirroring the Get semantics (including the
+// NotFound error a caller can check for with k8serrors.IsNotFound) a
+// NetworkAttachmentNamespaceLister.Get would have had for vni's now-removed
+// per-VNI lister.
+func (ca *ConnectionAgent) getRemoteAttForVNI(vni uint32, nsn k8stypes.NamespacedName) (*netv1a1.NetworkAttachment, error) {
+	objs, err := ca.remoteAttsInformer.GetIndexer().ByIndex(attVNIIndexName, vniIndexKey(vni))
+	if err != nil {
+		return nil, err
 	}
-	return vnState.remoteAttsLister
-}
-
-// getRemoteAttsIndexerForVNI accesses the map with all the vnStates but it's not
-// thread-safe because it is meant to be used only at start-up, when there's only
-// one goroutine running.
-func (ca *ConnectionAgent) getRemoteAttsInformerForVNI(vni uint32) (k8scache.SharedIndexInformer, chan struct{}) {
-	vnState := ca.vniToVnState[vni]
-	if vnState == nil {
-		return nil, nil
+	for _, obj := range objs {
+		att := obj.(*netv1a1.NetworkAttachment)
+		if att.Namespace == nsn.Namespace && att.Name == nsn.Name {
+			return att, nil
+		}
 	}
-	return vnState.remoteAttsInformer, vnState.remoteAttsInformerStopCh
+	return nil, k8serrors.NewNotFound(schema.GroupResource{Group: "network.example.com", Resource: "networkattachments"}, nsn.Name)
 }
 
 // Return a string representing a field selector that matches NetworkAttachments
@@ -1015,16 +1367,25 @@ func (ca *ConnectionAgent) localAttWithAnIPSelector() string {
 	// empty string.
 	attWithAnIPSelector := attIPFieldName + notEqual
 
-	// Build a selector which is a logical AND between
-	// attWithAnIPSelectorString and localAttSelectorString.
 	allSelectors := []string{localAttSelector, attWithAnIPSelector}
+	if ca.dualStack {
+		// Also require an IPv6 address: in dual-stack mode an attachment isn't
+		// done being assigned addresses, and shouldn't have interfaces created
+		// for it yet, until it has both.
+		allSelectors = append(allSelectors, attIPv6FieldName+notEqual)
+	}
+
+	// Build a selector which is a logical AND of allSelectors.
 	return strings.Join(allSelectors, ",")
 }
 
 // Return a string representing a field selector that matches NetworkAttachments
-// that run on a remote node on the Virtual Network identified by the given VNI
-// and have a virtual IP and the host IP field set.
-func (ca *ConnectionAgent) remoteAttInVNWithVirtualIPHostIPSelector(vni uint32) string {
+// that run on a remote node and have a virtual IP and the host IP field set.
+// Unlike its predecessor remoteAttInVNWithVirtualIPHostIPSelector, it is not
+// specific to any one Virtual Network: the single, cluster-wide informer
+// this selects for (see initRemoteAttsInformerAndLister) is shared by all of
+// them, and tells them apart with attVNIIndexName instead.
+func (ca *ConnectionAgent) remoteAttWithAnIPSelector() string {
 	// remoteAttSelector expresses the constraint that the NetworkAttachment
 	// runs on a remote node.
 	remoteAttSelector := attNodeFieldName + notEqual + ca.localNodeName
@@ -1032,7 +1393,7 @@ func (ca *ConnectionAgent) remoteAttInVNWithVirtualIPHostIPSelector(vni uint32)
 	// hostIPIsNotLocalSelector expresses the constraint that the NetworkAttachment
 	// status.hostIP is not equal to that of the current node. Without this selector,
 	// an update to the spec.Node field of a NetworkAttachment could lead to a
-	// creation notification for the attachment in a remote attachments cache,
+	// creation notification for the attachment in the remote attachments cache,
 	// even if the attachment still has the host IP of the current node
 	// (status.hostIP is set with an update by the connection agent on the
 	// node of the attachment). This could result in the creation of a remote
@@ -1045,17 +1406,16 @@ func (ca *ConnectionAgent) remoteAttInVNWithVirtualIPHostIPSelector(vni uint32)
 	attWithAnIPSelector := attIPFieldName + notEqual
 	attWithHostIPSelector := attHostIPFieldName + notEqual
 
-	// attInSpecificVNSelector expresses the constraint that the NetworkAttachment
-	// is in the Virtual Network identified by vni.
-	attInSpecificVNSelector := attVNIFieldName + equal + fmt.Sprint(vni)
-
-	// Build and return a selector which is a logical AND between all the selectors
-	// defined above.
 	allSelectors := []string{remoteAttSelector,
 		hostIPIsNotLocalSelector,
 		attWithAnIPSelector,
-		attWithHostIPSelector,
-		attInSpecificVNSelector}
+		attWithHostIPSelector}
+	if ca.dualStack {
+		allSelectors = append(allSelectors, attIPv6FieldName+notEqual)
+	}
+
+	// Build and return a selector which is a logical AND between all the selectors
+	// defined above.
 	return strings.Join(allSelectors, ",")
 }
 
@@ -1082,18 +1442,6 @@ func v1a1AttsCustomInformerAndLister(kcs *kosclientset.Clientset,
 	return attv1a1Informer.Informer(), attv1a1Informer.Lister()
 }
 
-func v1a1AttsCustomNamespaceInformerAndLister(kcs *kosclientset.Clientset,
-	resyncPeriod time.Duration,
-	namespace string,
-	tweakListOptionsFunc kosinternalifcs.TweakListOptionsFunc) (k8scache.SharedIndexInformer, koslisterv1a1.NetworkAttachmentNamespaceLister) {
-
-	attv1a1Informer := createAttsv1a1Informer(kcs,
-		resyncPeriod,
-		namespace,
-		tweakListOptionsFunc)
-	return attv1a1Informer.Informer(), attv1a1Informer.Lister().NetworkAttachments(namespace)
-}
-
 func createAttsv1a1Informer(kcs *kosclientset.Clientset,
 	resyncPeriod time.Duration,
 	namespace string,
@@ -1112,11 +1460,41 @@ func createAttsv1a1Informer(kcs *kosclientset.Clientset,
 // start up.
 func attachmentMACAddr(obj interface{}) ([]string, error) {
 	att := obj.(*netv1a1.NetworkAttachment)
-	return []string{generateMACAddr(att.Status.AddressVNI, gonet.ParseIP(att.Status.IPv4)).String()}, nil
+	mac := generateMACAddr(att.Status.AddressVNI, gonet.ParseIP(att.Status.IPv4), gonet.ParseIP(att.Status.IPv6))
+	return []string{mac.String()}, nil
 }
 
-func generateMACAddr(vni uint32, guestIPv4 gonet.IP) gonet.HardwareAddr {
+// attachmentVNI is an Index function that returns a NetworkAttachment's VNI,
+// formatted with vniIndexKey. Used by remoteAttsInformer so that every
+// Virtual Network's remote attachments can be found with a single shared
+// informer instead of one informer per VNI.
+func attachmentVNI(obj interface{}) ([]string, error) {
+	att := obj.(*netv1a1.NetworkAttachment)
+	return []string{vniIndexKey(att.Status.AddressVNI)}, nil
+}
+
+// vniIndexKey formats vni the way attachmentVNI indexes it, so that lookups
+// against attVNIIndexName agree with what was indexed.
+func vniIndexKey(vni uint32) string {
+	return strconv.FormatUint(uint64(vni), 10)
+}
+
+// generateMACAddr derives a guest interface's MAC address from its vni and
+// guest address(es), so that the same attachment always maps back to the
+// same MAC (attachmentMACAddr relies on this to find an attachment's
+// interface at start-up). guestIPv4 is preferred when present; guestIPv6 is
+// only consulted for an IPv6-only attachment, so that a dual-stack
+// attachment's MAC does not change if one of its two addresses is released
+// and reassigned.
+func generateMACAddr(vni uint32, guestIPv4, guestIPv6 gonet.IP) gonet.HardwareAddr {
 	guestIPBytes := guestIPv4.To4()
+	if guestIPBytes == nil {
+		if ipv6Bytes := guestIPv6.To16(); ipv6Bytes != nil {
+			guestIPBytes = ipv6Bytes[12:16]
+		} else {
+			guestIPBytes = make([]byte, 4)
+		}
+	}
 	mac := make([]byte, 6, 6)
 	mac[5] = byte(vni)
 	mac[4] = byte(vni >> 8)
@@ -1128,28 +1506,7 @@ func generateMACAddr(vni uint32, guestIPv4 gonet.IP) gonet.HardwareAddr {
 }
 
 func generateIfcName(macAddr gonet.HardwareAddr) string {
-	return "kos" + strings.Replace(macAddr.String(), ":", "", -1)
-}
-
-// aggregateStopChannels returns a channel which
-// is closed when either ch1 or ch2 is closed
-func aggregateTwoStopChannels(ch1, ch2 <-chan struct{}) chan struct{} {
-	aggregateStopCh := make(chan struct{})
-	go func() {
-		select {
-		case _, ch1Open := <-ch1:
-			if !ch1Open {
-				close(aggregateStopCh)
-				return
-			}
-		case _, ch2Open := <-ch2:
-			if !ch2Open {
-				close(aggregateStopCh)
-				return
-			}
-		}
-	}()
-	return aggregateStopCh
+	return kosIfcNamePrefix + strings.Replace(macAddr.String(), ":", "", -1)
 }
 
 func aggregateErrors(sep string, errs ...error) error {
@@ -1166,6 +1523,52 @@ func aggregateErrors(sep string, errs ...error) error {
 }
 
 // TODO consider switching to pointers wrt value for the interface
-func ifcNeedsUpdate(ifcHostIP, newHostIP gonet.IP, ifcMAC, newMAC gonet.HardwareAddr) bool {
-	return !ifcHostIP.Equal(newHostIP) || !bytes.Equal(ifcMAC, newMAC)
+func ifcNeedsUpdate(ifcHostIP, newHostIP gonet.IP, ifcMAC, newMAC gonet.HardwareAddr, ifcGuestIPs, newGuestIPs []gonet.IP) bool {
+	return !ifcHostIP.Equal(newHostIP) || !bytes.Equal(ifcMAC, newMAC) || !ipsEqual(ifcGuestIPs, newGuestIPs)
+}
+
+// ipsEqual answers whether as and bs hold the same IPs in the same order.
+// Guest IPs are always built by guestIPs in the fixed v4-then-v6 order, so a
+// plain positional comparison is enough to catch a dual-stack attachment
+// gaining, losing, or changing one of its addresses independently of its
+// MAC (which is derived mainly from the IPv4 address, and does not change
+// when, say, only the IPv6 address is reassigned).
+func ipsEqual(as, bs []gonet.IP) bool {
+	if len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if !as[i].Equal(bs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// singleIP returns ips' only address, or nil if ips is empty. The connection
+// agent only ever populates a RemoteNetIfc/LocalNetIfc's HostIPs with the
+// one host IPv4 address a NetworkAttachment's Status carries today; this
+// helper lets call sites keep comparing that single address even though the
+// networkfabric.Interface contract now carries a slice, to accommodate
+// fabrics that support dual-stack hosts.
+func singleIP(ips []gonet.IP) gonet.IP {
+	if len(ips) == 0 {
+		return nil
+	}
+	return ips[0]
+}
+
+// guestIPs returns guestIPv4 and guestIPv6, in that order, omitting either
+// one that is nil. The result is what createOrUpdateIfc puts in a guest
+// interface's GuestIPs, so that a dual-stack attachment's interface carries
+// both families.
+func guestIPs(guestIPv4, guestIPv6 gonet.IP) []gonet.IP {
+	ips := make([]gonet.IP, 0, 2)
+	if guestIPv4 != nil {
+		ips = append(ips, guestIPv4)
+	}
+	if guestIPv6 != nil {
+		ips = append(ips, guestIPv6)
+	}
+	return ips
 }
@@ -0,0 +1,66 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"testing"
+
+	netfabric "k8s.io/examples/staging/kos/pkg/networkfabric"
+)
+
+// namedFabric is a no-op netfabric.Interface that only identifies itself,
+// enough to tell fabricForVNI's selection apart by name.
+type namedFabric string
+
+func (f namedFabric) Name() string                                  { return string(f) }
+func (namedFabric) CreateLocalIfc(netfabric.LocalNetIfc) error      { return nil }
+func (namedFabric) DeleteLocalIfc(netfabric.LocalNetIfc) error      { return nil }
+func (namedFabric) CreateRemoteIfc(netfabric.RemoteNetIfc) error    { return nil }
+func (namedFabric) DeleteRemoteIfc(netfabric.RemoteNetIfc) error    { return nil }
+func (namedFabric) ListLocalIfcs() ([]netfabric.LocalNetIfc, error) { return nil, nil }
+func (namedFabric) ListRemoteIfcs() ([]netfabric.RemoteNetIfc, error) {
+	return nil, nil
+}
+
+func newTestConnectionAgent(t *testing.T) *ConnectionAgent {
+	fabrics, err := netfabric.NewMultiFabric("vxlan", map[string]netfabric.Interface{
+		"vxlan": namedFabric("vxlan"),
+		"ovn":   namedFabric("ovn"),
+	})
+	if err != nil {
+		t.Fatalf("NewMultiFabric failed: %s", err.Error())
+	}
+	return &ConnectionAgent{
+		fabrics:      fabrics,
+		vniToVnState: make(map[uint32]*vnState),
+	}
+}
+
+func TestFabricForVNIFallsBackToDefault(t *testing.T) {
+	ca := newTestConnectionAgent(t)
+	if got := ca.fabricForVNI(42).Name(); got != "vxlan" {
+		t.Errorf("got fabric %q for an unknown VNI, want the default %q", got, "vxlan")
+	}
+}
+
+func TestFabricForVNIUsesVNStateFabricName(t *testing.T) {
+	ca := newTestConnectionAgent(t)
+	ca.vniToVnState[7] = &vnState{fabricName: "ovn"}
+	if got := ca.fabricForVNI(7).Name(); got != "ovn" {
+		t.Errorf("got fabric %q for a VNI assigned to ovn, want %q", got, "ovn")
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/golang/glog"
+
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// runLifecycleServer listens on a Unix domain socket at
+// ca.lifecycleSocketPath and serves the address lifecycle protocol: a client
+// connects, writes a single request line, and either gets a stream of
+// newline-delimited JSON lifecycleEvents (SUBSCRIBE) or a one-line ack
+// (DETACH), namely:
+//
+//	SUBSCRIBE <namespace>/<name>\n
+//	    Streams every subsequent lifecycleEvent for the named
+//	    NetworkAttachment until the client disconnects.
+//	DETACH <namespace>/<name>\n
+//	    Hands off ownership of the named NetworkAttachment's interface (see
+//	    ConnectionAgent.detachAtt) and replies with "OK\n", then closes the
+//	    connection.
+//
+// Runs until stopCh is closed. A disabled lifecycleSocketPath (empty string)
+// makes this a no-op.
+func (ca *ConnectionAgent) runLifecycleServer(stopCh <-chan struct{}) {
+	if ca.lifecycleSocketPath == "" {
+		return
+	}
+
+	os.Remove(ca.lifecycleSocketPath)
+	listener, err := net.Listen("unix", ca.lifecycleSocketPath)
+	if err != nil {
+		glog.Errorf("address lifecycle server failed to listen on %s: %s", ca.lifecycleSocketPath, err.Error())
+		return
+	}
+
+	go func() {
+		<-stopCh
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-stopCh:
+				return
+			default:
+				glog.Warningf("address lifecycle server accept on %s failed: %s", ca.lifecycleSocketPath, err.Error())
+				continue
+			}
+		}
+		go ca.serveLifecycleConn(conn)
+	}
+}
+
+func (ca *ConnectionAgent) serveLifecycleConn(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		glog.V(4).Infof("address lifecycle server: failed to read request: %s", err.Error())
+		return
+	}
+	line = strings.TrimSpace(line)
+
+	cmd, arg, found := cutFirstSpace(line)
+	if !found {
+		glog.V(4).Infof("address lifecycle server: malformed request %q", line)
+		return
+	}
+	nsn, err := parseNamespacedName(arg)
+	if err != nil {
+		glog.V(4).Infof("address lifecycle server: malformed request %q: %s", line, err.Error())
+		return
+	}
+
+	switch cmd {
+	case "SUBSCRIBE":
+		ca.serveLifecycleSubscription(conn, nsn)
+	case "DETACH":
+		ca.detachAtt(nsn)
+		conn.Write([]byte("OK\n"))
+	default:
+		glog.V(4).Infof("address lifecycle server: unknown command %q", cmd)
+	}
+}
+
+func (ca *ConnectionAgent) serveLifecycleSubscription(conn net.Conn, nsn k8stypes.NamespacedName) {
+	events, cancel := ca.lifecycle.subscribe(nsn)
+	defer cancel()
+
+	encoder := json.NewEncoder(conn)
+	for event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return
+		}
+	}
+}
+
+// cutFirstSpace splits s on its first space, like strings.Cut(s, " ") (not
+// yet available at this module's Go version).
+func cutFirstSpace(s string) (before, after string, found bool) {
+	i := strings.Index(s, " ")
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], strings.TrimSpace(s[i+1:]), true
+}
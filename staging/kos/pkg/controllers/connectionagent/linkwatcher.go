@@ -0,0 +1,145 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/vishvananda/netlink"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	kosctlrutils "k8s.io/examples/staging/kos/pkg/controllers/utils"
+)
+
+// defaultLinkWatchReconcilePeriod is used in place of
+// ConnectionAgent.linkWatchReconcilePeriod when that field is <= 0.
+const defaultLinkWatchReconcilePeriod = 30 * time.Second
+
+// runLinkWatcher subscribes to RTNETLINK link and address events so that a
+// kos-owned interface deleted or reconfigured by something other than this
+// ConnectionAgent (e.g. an operator, or another daemon on the node) is
+// noticed without waiting for the next NetworkAttachment notification: the
+// NetworkAttachment owning the changed interface, if any, is re-enqueued, and
+// processNetworkAttachment re-creates or re-configures it as needed. Since
+// netlink subscriptions can silently miss events (e.g. on a socket read
+// overrun), runLinkWatcher also does a full list of kos-owned links every
+// ca.linkWatchReconcilePeriod (or defaultLinkWatchReconcilePeriod, if that's
+// <= 0), re-enqueuing the owner of every one of them. Runs until stopCh is
+// closed.
+func (ca *ConnectionAgent) runLinkWatcher(stopCh <-chan struct{}) {
+	linkUpdates := make(chan netlink.LinkUpdate)
+	linkDone := make(chan struct{})
+	if err := netlink.LinkSubscribe(linkUpdates, linkDone); err != nil {
+		glog.Errorf("failed to subscribe to netlink link updates, external interface changes will only be caught by the periodic reconcile: %s", err.Error())
+		linkUpdates = nil
+	}
+
+	addrUpdates := make(chan netlink.AddrUpdate)
+	addrDone := make(chan struct{})
+	if err := netlink.AddrSubscribe(addrUpdates, addrDone); err != nil {
+		glog.Errorf("failed to subscribe to netlink address updates, external interface changes will only be caught by the periodic reconcile: %s", err.Error())
+		addrUpdates = nil
+	}
+
+	reconcilePeriod := ca.linkWatchReconcilePeriod
+	if reconcilePeriod <= 0 {
+		reconcilePeriod = defaultLinkWatchReconcilePeriod
+	}
+	ticker := time.NewTicker(reconcilePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case update, ok := <-linkUpdates:
+			if !ok {
+				linkUpdates = nil
+				continue
+			}
+			if strings.HasPrefix(update.Link.Attrs().Name, kosIfcNamePrefix) {
+				ca.enqueueAttForIfcMAC(update.Link.Attrs().HardwareAddr.String())
+			}
+		case update, ok := <-addrUpdates:
+			if !ok {
+				addrUpdates = nil
+				continue
+			}
+			ca.enqueueAttForLinkIndex(update.LinkIndex)
+		case <-ticker.C:
+			ca.reconcileKosLinks()
+		case <-stopCh:
+			if linkUpdates != nil {
+				close(linkDone)
+			}
+			if addrUpdates != nil {
+				close(addrDone)
+			}
+			return
+		}
+	}
+}
+
+// reconcileKosLinks lists every netlink link named with the kos-owned prefix
+// (see generateIfcName) and re-enqueues the NetworkAttachment owning each
+// one, catching any netlink event runLinkWatcher missed since the last
+// reconcile.
+func (ca *ConnectionAgent) reconcileKosLinks() {
+	links, err := netlink.LinkList()
+	if err != nil {
+		glog.Warningf("periodic reconcile of kos network interfaces failed to list links: %s", err.Error())
+		return
+	}
+	for _, link := range links {
+		if !strings.HasPrefix(link.Attrs().Name, kosIfcNamePrefix) {
+			continue
+		}
+		ca.enqueueAttForIfcMAC(link.Attrs().HardwareAddr.String())
+	}
+}
+
+// enqueueAttForLinkIndex resolves linkIndex to a link and then behaves like
+// enqueueAttForIfcMAC, ignoring the link if it's not kos-owned or can no
+// longer be found (e.g. it was already deleted by the time the address event
+// was processed).
+func (ca *ConnectionAgent) enqueueAttForLinkIndex(linkIndex int) {
+	link, err := netlink.LinkByIndex(linkIndex)
+	if err != nil {
+		glog.V(4).Infof("could not resolve link index %d from a netlink address update: %s", linkIndex, err.Error())
+		return
+	}
+	if !strings.HasPrefix(link.Attrs().Name, kosIfcNamePrefix) {
+		return
+	}
+	ca.enqueueAttForIfcMAC(link.Attrs().HardwareAddr.String())
+}
+
+// enqueueAttForIfcMAC enqueues the NetworkAttachment, if any, that owns the
+// local interface with the given MAC (as determined by the attMACIndexName
+// index on the local attachments informer), so that processNetworkAttachment
+// re-creates or re-configures it.
+func (ca *ConnectionAgent) enqueueAttForIfcMAC(mac string) {
+	atts, err := ca.localAttsInformer.GetIndexer().ByIndex(attMACIndexName, mac)
+	if err != nil {
+		glog.V(4).Infof("failed to look up local attachment for interface MAC %s: %s", mac, err.Error())
+		return
+	}
+	for _, obj := range atts {
+		att := obj.(*netv1a1.NetworkAttachment)
+		ca.queue.Add(kosctlrutils.AttNSN(att))
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOutcomeForErr(t *testing.T) {
+	if got := outcomeForErr(nil); got != outcomeSuccess {
+		t.Errorf("got outcome %q for a nil error, want %q", got, outcomeSuccess)
+	}
+	if got := outcomeForErr(fabricOpError{fmt.Errorf("boom")}); got != outcomeFabricError {
+		t.Errorf("got outcome %q for a fabricOpError, want %q", got, outcomeFabricError)
+	}
+	if got := outcomeForErr(fmt.Errorf("boom")); got != outcomeRequeue {
+		t.Errorf("got outcome %q for a plain error, want %q", got, outcomeRequeue)
+	}
+}
+
+func TestRecordProcessOutcome(t *testing.T) {
+	processNetworkAttachmentTotal.Reset()
+	recordProcessOutcome(outcomeSuccess)
+	if got := testutil.ToFloat64(processNetworkAttachmentTotal.WithLabelValues(outcomeSuccess)); got != 1 {
+		t.Errorf("got %v successes, want 1", got)
+	}
+}
+
+func TestVniLabel(t *testing.T) {
+	if got := vniLabel(42); got != "42" {
+		t.Errorf("got vniLabel(42) = %q, want %q", got, "42")
+	}
+}
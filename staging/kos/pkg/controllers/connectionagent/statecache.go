@@ -0,0 +1,167 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// ifcStateCacheFileName is the name, within a connection agent's state dir,
+// of the file the on-disk interface state cache is persisted to.
+const ifcStateCacheFileName = "ifc-state.json"
+
+// ifcStateRecord is everything syncPreExistingIfcs needs to remember about a
+// NetworkAttachment's interface across a restart, in case the API server or
+// the local attachments informer aren't available yet to provide it.
+type ifcStateRecord struct {
+	VNI uint32 `json:"vni"`
+	// GuestIPv4 and GuestIPv6 are empty for a family the attachment has no
+	// address in, so a single-stack attachment only ever has one of the two
+	// set.
+	GuestIPv4 string `json:"guestIPv4,omitempty"`
+	GuestIPv6 string `json:"guestIPv6,omitempty"`
+	HostIP    string `json:"hostIP"`
+	MAC       string `json:"mac"`
+	IfcName   string `json:"ifcName"`
+	Fabric    string `json:"fabric"`
+}
+
+// ifcStateCache is a filesystem-backed store of ifcStateRecord, keyed by the
+// namespaced name of the NetworkAttachment the record belongs to. It is
+// written to after every successful processExistingAtt/processDeletedAtt, and
+// read back by syncPreExistingIfcs at start-up, so that a node reboot does
+// not tear down guest interfaces that are still legitimately owned just
+// because the local attachments informer hasn't synced yet. It is a
+// best-effort cache, not a source of truth: the connection agent must remain
+// correct even if it is empty, stale, or unwritable. A cache with an empty
+// path is a no-op: Load and every mutator succeed trivially and nothing is
+// ever written to disk.
+type ifcStateCache struct {
+	path string
+
+	mutex   sync.Mutex
+	records map[k8stypes.NamespacedName]ifcStateRecord
+}
+
+// loadIfcStateCache reads the on-disk interface state cache under stateDir,
+// if any, or returns an empty one. stateDir == "" disables persistence: the
+// returned cache never touches disk.
+func loadIfcStateCache(stateDir string) (*ifcStateCache, error) {
+	c := &ifcStateCache{records: make(map[k8stypes.NamespacedName]ifcStateRecord)}
+	if stateDir == "" {
+		return c, nil
+	}
+	c.path = filepath.Join(stateDir, ifcStateCacheFileName)
+
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, fmt.Errorf("failed to read interface state cache %s: %s", c.path, err.Error())
+	}
+
+	var onDisk map[string]ifcStateRecord
+	if err := json.Unmarshal(data, &onDisk); err != nil {
+		return nil, fmt.Errorf("failed to parse interface state cache %s: %s", c.path, err.Error())
+	}
+	for nsnStr, rec := range onDisk {
+		nsn, err := parseNamespacedName(nsnStr)
+		if err != nil {
+			glog.Warningf("ignoring malformed entry %q in interface state cache %s: %s", nsnStr, c.path, err.Error())
+			continue
+		}
+		c.records[nsn] = rec
+	}
+	return c, nil
+}
+
+// set records rec as nsn's current interface state and persists the cache.
+func (c *ifcStateCache) set(nsn k8stypes.NamespacedName, rec ifcStateRecord) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.records[nsn] = rec
+	return c.saveLocked()
+}
+
+// delete removes nsn's entry, if any, and persists the cache.
+func (c *ifcStateCache) delete(nsn k8stypes.NamespacedName) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, found := c.records[nsn]; !found {
+		return nil
+	}
+	delete(c.records, nsn)
+	return c.saveLocked()
+}
+
+// hasIfcForMAC answers whether the cache remembers an interface with the
+// given MAC as belonging to the named fabric. It's used by syncPreExistingIfcs
+// to avoid deleting an interface that has no matching attachment in the
+// informer cache yet, but is still recognized from before a restart.
+func (c *ifcStateCache) hasIfcForMAC(fabricName, mac string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, rec := range c.records {
+		if rec.Fabric == fabricName && rec.MAC == mac {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ifcStateCache) saveLocked() error {
+	if c.path == "" {
+		return nil
+	}
+	onDisk := make(map[string]ifcStateRecord, len(c.records))
+	for nsn, rec := range c.records {
+		onDisk[nsn.String()] = rec
+	}
+	data, err := json.MarshalIndent(onDisk, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal interface state cache: %s", err.Error())
+	}
+	tmpPath := c.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write interface state cache %s: %s", tmpPath, err.Error())
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("failed to finalize interface state cache %s: %s", c.path, err.Error())
+	}
+	return nil
+}
+
+// parseNamespacedName parses the "namespace/name" format produced by
+// k8stypes.NamespacedName.String.
+func parseNamespacedName(s string) (k8stypes.NamespacedName, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return k8stypes.NamespacedName{}, fmt.Errorf("expected \"namespace/name\", got %q", s)
+	}
+	return k8stypes.NamespacedName{Namespace: parts[0], Name: parts[1]}, nil
+}
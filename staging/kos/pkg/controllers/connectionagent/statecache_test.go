@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func TestIfcStateCacheDisabledIsNoOp(t *testing.T) {
+	c, err := loadIfcStateCache("")
+	if err != nil {
+		t.Fatalf("loadIfcStateCache(\"\") failed: %s", err.Error())
+	}
+	nsn := k8stypes.NamespacedName{Namespace: "ns", Name: "att"}
+	if err := c.set(nsn, ifcStateRecord{MAC: "aa:bb:cc:dd:ee:ff", Fabric: "vxlan"}); err != nil {
+		t.Fatalf("set on a disabled cache failed: %s", err.Error())
+	}
+	if !c.hasIfcForMAC("vxlan", "aa:bb:cc:dd:ee:ff") {
+		t.Errorf("disabled cache did not remember an entry set in-memory")
+	}
+}
+
+func TestIfcStateCachePersistsAcrossLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ifcstatecache")
+	if err != nil {
+		t.Fatalf("TempDir failed: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	c1, err := loadIfcStateCache(dir)
+	if err != nil {
+		t.Fatalf("loadIfcStateCache failed: %s", err.Error())
+	}
+	nsn := k8stypes.NamespacedName{Namespace: "ns", Name: "att"}
+	rec := ifcStateRecord{VNI: 42, MAC: "aa:bb:cc:dd:ee:ff", Fabric: "vxlan", IfcName: "kosaabbccddeeff"}
+	if err := c1.set(nsn, rec); err != nil {
+		t.Fatalf("set failed: %s", err.Error())
+	}
+
+	c2, err := loadIfcStateCache(dir)
+	if err != nil {
+		t.Fatalf("second loadIfcStateCache failed: %s", err.Error())
+	}
+	if !c2.hasIfcForMAC("vxlan", "aa:bb:cc:dd:ee:ff") {
+		t.Errorf("reloaded cache lost the persisted entry")
+	}
+
+	if err := c2.delete(nsn); err != nil {
+		t.Fatalf("delete failed: %s", err.Error())
+	}
+	c3, err := loadIfcStateCache(dir)
+	if err != nil {
+		t.Fatalf("third loadIfcStateCache failed: %s", err.Error())
+	}
+	if c3.hasIfcForMAC("vxlan", "aa:bb:cc:dd:ee:ff") {
+		t.Errorf("reloaded cache still has an entry that was deleted")
+	}
+}
@@ -0,0 +1,292 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	k8slabels "k8s.io/apimachinery/pkg/labels"
+	k8swait "k8s.io/apimachinery/pkg/util/wait"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	kosctlrutils "k8s.io/examples/staging/kos/pkg/controllers/utils"
+	netfabric "k8s.io/examples/staging/kos/pkg/networkfabric"
+)
+
+// syncDeleteBackoff bounds the retries of a single orphan interface deletion
+// during start-up sync: 5 steps starting at 250ms and doubling (250ms, 500ms,
+// 1s, 2s, 4s, ~7.75s total) before the deletion is given up on.
+var syncDeleteBackoff = k8swait.Backoff{
+	Duration: 250 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    5,
+}
+
+func (ca *ConnectionAgent) syncPreExistingIfcs(stopCh <-chan struct{}) error {
+	if err := ca.syncPreExistingLocalIfcs(stopCh); err != nil {
+		return err
+	}
+
+	return ca.syncPreExistingRemoteIfcs(stopCh)
+}
+
+// syncPreExistingLocalIfcs reconciles the local interfaces of every
+// registered fabric against the local attachments cache. Each fabric is
+// reconciled independently, against its own interfaces only: an interface
+// found in one fabric is never matched against, or deleted in favor of, an
+// interface in another fabric.
+func (ca *ConnectionAgent) syncPreExistingLocalIfcs(stopCh <-chan struct{}) error {
+	for fabricName, fabric := range ca.fabrics.All() {
+		if err := ca.syncPreExistingLocalIfcsForFabric(stopCh, fabricName, fabric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ca *ConnectionAgent) syncPreExistingLocalIfcsForFabric(stopCh <-chan struct{}, fabricName string, fabric netfabric.Interface) error {
+	allPreExistingLocalIfcs, err := fabric.ListLocalIfcs()
+	if err != nil {
+		return fmt.Errorf("failed initial local network interfaces list for fabric %q: %s", fabricName, err.Error())
+	}
+
+	var orphans []netfabric.LocalNetIfc
+	for _, aPreExistingLocalIfc := range allPreExistingLocalIfcs {
+		ifcMAC := aPreExistingLocalIfc.GuestMAC.String()
+		ifcOwnerAtts, err := ca.localAttsInformer.GetIndexer().ByIndex(attMACIndexName, ifcMAC)
+		if err != nil {
+			return fmt.Errorf("indexing local network interface with MAC %s failed: %s",
+				ifcMAC,
+				err.Error())
+		}
+
+		if len(ifcOwnerAtts) == 1 {
+			// If we're here there's a local attachment which should own the
+			// interface because their MAC addresses match. Hence we add the
+			// interface to the attachment state.
+			ifcOwnerAtt := ifcOwnerAtts[0].(*netv1a1.NetworkAttachment)
+			nsn := kosctlrutils.AttNSN(ifcOwnerAtt)
+			oldIfc, oldIfcExists := ca.getLocalIfc(nsn)
+			ca.assignLocalIfc(nsn, aPreExistingLocalIfc)
+			glog.V(3).Infof("matched interface %#+v with local attachment %#+v", aPreExistingLocalIfc, ifcOwnerAtt)
+			if oldIfcExists {
+				aPreExistingLocalIfc = oldIfc
+			} else {
+				continue
+			}
+		} else if ca.stateCache.hasIfcForMAC(fabricName, ifcMAC) {
+			// No local attachment currently matches this interface's MAC, but
+			// the on-disk state cache remembers it as legitimately owned (e.g.
+			// the informer hasn't caught up yet after a restart). Keep it
+			// rather than tearing it down; a future processQueueItem will
+			// reconcile it once the matching attachment is seen again.
+			glog.V(3).Infof("keeping local interface %#+v in fabric %q: no matching attachment yet, but present in the on-disk state cache",
+				aPreExistingLocalIfc, fabricName)
+			continue
+		}
+
+		// If we're here the interface must be deleted, e.g. because it could
+		// not be matched to an attachment, or because the attachment to which
+		// it has already been matched has changed and was matched to a different
+		// interface.
+		orphans = append(orphans, aPreExistingLocalIfc)
+	}
+
+	tasks := make([]func() error, len(orphans))
+	for i, ifc := range orphans {
+		ifc := ifc
+		tasks[i] = func() error { return fabric.DeleteLocalIfc(ifc) }
+	}
+	return ca.runSyncDeletes(stopCh, fabricName, "local", tasks)
+}
+
+func (ca *ConnectionAgent) syncPreExistingRemoteIfcs(stopCh <-chan struct{}) error {
+	// Start all remote attachments caches because we need to look up remote
+	// attachments to decide which interfaces to keep and which to delete.
+	allLocalAtts, err := ca.localAttsLister.List(k8slabels.Everything())
+	if err != nil {
+		return fmt.Errorf("failed initial local attachments list: %s", err.Error())
+	}
+	for _, aLocalAtt := range allLocalAtts {
+		nsn, attVNI := kosctlrutils.AttNSN(aLocalAtt), aLocalAtt.Status.AddressVNI
+		ca.updateVNStateForExistingAtt(nsn, true, attVNI, aLocalAtt.Spec.Subnet)
+	}
+
+	// Read all remote ifcs in every registered fabric, for each interface find
+	// the attachment with the same MAC in the cache for the remote
+	// attachments with the same VNI as the interface. If either the
+	// attachment or the cache are not found, delete the interface, bind it to
+	// the attachment otherwise. Like syncPreExistingLocalIfcs, each fabric is
+	// reconciled against its own interfaces only.
+	for fabricName, fabric := range ca.fabrics.All() {
+		if err := ca.syncPreExistingRemoteIfcsForFabric(stopCh, fabricName, fabric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncPreExistingRemoteIfcsForFabric reconciles fabric's remote interfaces
+// against the shared remote attachments cache (ca.remoteAttsInformer, already
+// synced by the time this runs -- see Run's call to waitForAttsCachesSync),
+// matching each interface to its owning attachment by MAC regardless of VNI.
+func (ca *ConnectionAgent) syncPreExistingRemoteIfcsForFabric(stopCh <-chan struct{}, fabricName string, fabric netfabric.Interface) error {
+	allPreExistingRemoteIfcs, err := fabric.ListRemoteIfcs()
+	if err != nil {
+		return fmt.Errorf("failed initial remote network interfaces list for fabric %q: %s", fabricName, err.Error())
+	}
+
+	var orphanRemoteIfcs []netfabric.RemoteNetIfc
+	var orphanLocalIfcs []netfabric.LocalNetIfc
+	for _, aPreExistingRemoteIfc := range allPreExistingRemoteIfcs {
+		ifcMAC := aPreExistingRemoteIfc.GuestMAC.String()
+		ifcOwnerAtts, err := ca.remoteAttsInformer.GetIndexer().ByIndex(attMACIndexName, ifcMAC)
+		if err != nil {
+			return fmt.Errorf("indexing remote network interface with MAC %s failed: %s", ifcMAC, err.Error())
+		}
+
+		if len(ifcOwnerAtts) == 1 {
+			// If we're here a remote attachment owning the interface has been found
+			ifcOwnerAtt := ifcOwnerAtts[0].(*netv1a1.NetworkAttachment)
+			nsn := kosctlrutils.AttNSN(ifcOwnerAtt)
+			oldRemoteIfc, oldRemoteIfcExists := ca.getRemoteIfc(nsn)
+			ca.assignRemoteIfc(nsn, aPreExistingRemoteIfc)
+			glog.V(3).Infof("matched interface %#+v with remote attachment %#+v",
+				aPreExistingRemoteIfc,
+				ifcOwnerAtt)
+			if oldRemoteIfcExists {
+				aPreExistingRemoteIfc = oldRemoteIfc
+			} else {
+				if oldLocalIfc, oldLocalIfcExists := ca.getLocalIfc(nsn); oldLocalIfcExists {
+					orphanLocalIfcs = append(orphanLocalIfcs, oldLocalIfc)
+				}
+				continue
+			}
+		} else if ca.stateCache.hasIfcForMAC(fabricName, ifcMAC) {
+			glog.V(3).Infof("keeping remote interface %#+v in fabric %q: no matching attachment yet, but present in the on-disk state cache",
+				aPreExistingRemoteIfc, fabricName)
+			continue
+		}
+
+		// If we're here either no remote attachment owning the interface was
+		// found, or the attachment owning the interface already has one. For
+		// both cases we need to delete the interface.
+		orphanRemoteIfcs = append(orphanRemoteIfcs, aPreExistingRemoteIfc)
+	}
+
+	remoteTasks := make([]func() error, len(orphanRemoteIfcs))
+	for i, ifc := range orphanRemoteIfcs {
+		ifc := ifc
+		remoteTasks[i] = func() error { return fabric.DeleteRemoteIfc(ifc) }
+	}
+	localTasks := make([]func() error, len(orphanLocalIfcs))
+	for i, ifc := range orphanLocalIfcs {
+		ifc := ifc
+		localTasks[i] = func() error { return fabric.DeleteLocalIfc(ifc) }
+	}
+
+	remoteErr := ca.runSyncDeletes(stopCh, fabricName, "remote", remoteTasks)
+	localErr := ca.runSyncDeletes(stopCh, fabricName, "local", localTasks)
+	return aggregateErrors("; ", remoteErr, localErr)
+}
+
+// runSyncDeletes runs deleteTasks across a worker pool bounded by ca.workers
+// (floored at 1), retrying each task with syncDeleteBackoff before giving up
+// on it. stopCh, once closed, stops dispatch of not-yet-started tasks and
+// aborts in-progress retries, so that Run's shutdown isn't blocked on a
+// fabric that won't cooperate. A task that exhausts its retries is counted
+// in syncDeleteFailuresTotal and folded into the returned error; it does not
+// stop other tasks from running.
+func (ca *ConnectionAgent) runSyncDeletes(stopCh <-chan struct{}, fabricName, ifcKind string, tasks []func() error) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	workers := ca.workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	taskCh := make(chan func() error)
+	go func() {
+		defer close(taskCh)
+		for i, task := range tasks {
+			select {
+			case taskCh <- task:
+			case <-stopCh:
+				glog.V(2).Infof("sync: shutting down, leaving %d %s interface deletion(s) undone in fabric %q", len(tasks)-i, ifcKind, fabricName)
+				return
+			}
+		}
+	}()
+
+	errCh := make(chan error, len(tasks))
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range taskCh {
+				errCh <- ca.deleteWithBackoff(stopCh, fabricName, ifcKind, task)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+
+	var errs []error
+	for err := range errCh {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return aggregateErrors("; ", errs...)
+}
+
+// deleteWithBackoff retries task, a single fabric deletion, with
+// syncDeleteBackoff until it succeeds, its retries are exhausted, or stopCh
+// is closed, whichever comes first.
+func (ca *ConnectionAgent) deleteWithBackoff(stopCh <-chan struct{}, fabricName, ifcKind string, task func() error) error {
+	attempts := 0
+	backoffErr := k8swait.ExponentialBackoff(syncDeleteBackoff, func() (bool, error) {
+		select {
+		case <-stopCh:
+			return false, fmt.Errorf("aborted by shutdown")
+		default:
+		}
+		attempts++
+		if err := task(); err != nil {
+			glog.V(3).Infof("deletion of orphan %s interface in fabric %q failed (attempt %d): %s", ifcKind, fabricName, attempts, err.Error())
+			return false, nil
+		}
+		return true, nil
+	})
+	if backoffErr != nil {
+		syncDeleteFailuresTotal.WithLabelValues(fabricName, ifcKind).Inc()
+		return fmt.Errorf("giving up deleting an orphan %s interface in fabric %q after %d attempts: %s", ifcKind, fabricName, attempts, backoffErr.Error())
+	}
+	glog.V(3).Infof("deleted orphan %s interface in fabric %q after %d attempt(s)", ifcKind, fabricName, attempts)
+	return nil
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// tracerName identifies this package's spans to whatever TracerProvider the
+// binary embedding a ConnectionAgent registers with
+// go.opentelemetry.io/otel.SetTracerProvider; if none is registered, Start
+// returns a no-op span, the same way a Prometheus collector that is never
+// passed to Register simply goes unobserved.
+const tracerName = "k8s.io/examples/staging/kos/pkg/controllers/connectionagent"
+
+var tracer = otel.Tracer(tracerName)
+
+// startAttSpan starts a child span of ctx named name, tagged with attNSN and
+// vni, so that a NetworkAttachment's propagation from API server write to
+// local interface can be followed end-to-end across
+// processQueueItem -> updateVNState -> createOrUpdateIfc -> setAttStatus,
+// and, since attNSN is on every span, across the remote connection agent
+// that created the NetworkAttachment's local counterpart too.
+func startAttSpan(ctx context.Context, name string, attNSN k8stypes.NamespacedName, vni uint32) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("networkattachment.namespace", attNSN.Namespace),
+		attribute.String("networkattachment.name", attNSN.Name),
+		attribute.Int64("networkattachment.vni", int64(vni)),
+	))
+}
@@ -0,0 +1,499 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	"fmt"
+	gonet "net"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	corev1 "k8s.io/api/core/v1"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	k8sinformers "k8s.io/client-go/informers"
+	k8scache "k8s.io/client-go/tools/cache"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// defaultWireguardListenPort is used when a WireguardConfig leaves ListenPort
+// zero; it is WireGuard's conventional default.
+const defaultWireguardListenPort = 51820
+
+// WireguardConfig turns on secure mode: remote interfaces get an
+// additional (or, with Exclusive, sole) path over a per-node WireGuard
+// device, encrypting cross-node traffic the way Kilo's WireGuard-first CNI
+// does. A nil *WireguardConfig passed to NewConnectionAgent disables secure
+// mode entirely -- the zero value is not itself a safe default, since
+// DeviceName must name a real WireGuard device the connection agent can
+// manage.
+type WireguardConfig struct {
+	// DeviceName is the name of the WireGuard network device (already
+	// created, e.g. by `ip link add kos-wg0 type wireguard`) this
+	// connection agent configures peers on.
+	DeviceName string
+
+	// ListenPort is the UDP port the local WireGuard device listens on,
+	// advertised to peers as part of their Endpoint. Zero means
+	// defaultWireguardListenPort.
+	ListenPort int
+
+	// Exclusive, if true, skips the fabric's own CreateRemoteIfc/
+	// DeleteRemoteIfc calls for remote interfaces: the WireGuard device is
+	// the only path to a remote NetworkAttachment's guest IP. If false,
+	// the fabric's own remote interface is programmed as usual and
+	// WireGuard peers are added alongside it, encrypting the same traffic
+	// the fabric already routes.
+	Exclusive bool
+
+	// KeyRotationPeriod, if positive, is how often runWireguardKeyRotation
+	// replaces the local WireGuard private key and re-publishes the
+	// resulting public key on this node's WireguardPublicKeyAnnotation.
+	// <= 0 disables rotation: the device's key, however it was set (e.g.
+	// by whatever created DeviceName), is used unchanged for the life of
+	// the process.
+	KeyRotationPeriod time.Duration
+}
+
+// wireguardPeer is the bookkeeping kept for one remote host's WireGuard
+// peer: its public key, if known (see onNodeAdded/Updated), and the set of
+// allowed-IPs CIDRs it needs, each attributed to the NetworkAttachment(s)
+// that asked for it so the CIDR can be dropped again once no attachment
+// needs it any more (see wireguardManager.removeAttachment).
+type wireguardPeer struct {
+	key        wgtypes.Key
+	keyKnown   bool
+	allowedIPs map[string]map[k8stypes.NamespacedName]struct{}
+}
+
+// wgClient is the subset of *wgctrl.Client's methods wireguardManager
+// needs, narrowed so tests can supply a fake instead of a real WireGuard
+// device.
+type wgClient interface {
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// wireguardManager owns the local WireGuard device and the peer
+// configuration a secure-mode ConnectionAgent derives from remote
+// NetworkAttachments and their nodes' published public keys. All methods
+// are safe for concurrent use.
+type wireguardManager struct {
+	client     wgClient
+	deviceName string
+	listenPort int
+	exclusive  bool
+
+	mu    sync.Mutex
+	peers map[string]*wireguardPeer // by remote host IP, in string form
+
+	// attHost remembers which host a given attachment's allowed IPs were
+	// last filed under, so removeAttachment can find its wireguardPeer
+	// without the caller having to repeat the host IP (which, for a
+	// NetworkAttachment that is being deleted, may no longer be easy to
+	// recover).
+	attHost map[k8stypes.NamespacedName]string
+}
+
+// newWireguardManager opens a wgctrl client and returns a wireguardManager
+// ready to configure cfg.DeviceName's peers. It does not itself create or
+// otherwise validate the device: that is expected to already exist (e.g.
+// `ip link add $DeviceName type wireguard`, `wg set $DeviceName private-key
+// ...`), the same way the vxlan fabric expects its VTEPIP to already be
+// reachable rather than configuring the node's routing itself.
+func newWireguardManager(cfg WireguardConfig) (*wireguardManager, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open WireGuard control client: %s", err.Error())
+	}
+	listenPort := cfg.ListenPort
+	if listenPort == 0 {
+		listenPort = defaultWireguardListenPort
+	}
+	if _, err := client.Device(cfg.DeviceName); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("WireGuard device %q not usable: %s", cfg.DeviceName, err.Error())
+	}
+	return &wireguardManager{
+		client:     client,
+		deviceName: cfg.DeviceName,
+		listenPort: listenPort,
+		exclusive:  cfg.Exclusive,
+		peers:      make(map[string]*wireguardPeer),
+		attHost:    make(map[k8stypes.NamespacedName]string),
+	}, nil
+}
+
+// publicKey returns the local device's current public key.
+func (m *wireguardManager) publicKey() (wgtypes.Key, error) {
+	dev, err := m.client.Device(m.deviceName)
+	if err != nil {
+		return wgtypes.Key{}, err
+	}
+	return dev.PublicKey, nil
+}
+
+// rotateKey generates a fresh private key, installs it on the local
+// WireGuard device, and returns the resulting public key so the caller can
+// republish it (see runWireguardKeyRotation). Peers are unaffected: wgctrl
+// reconfigures only what a Config sets, and PrivateKey is all this call
+// sets.
+func (m *wireguardManager) rotateKey() (wgtypes.Key, error) {
+	priv, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		return wgtypes.Key{}, fmt.Errorf("failed to generate a new WireGuard private key: %s", err.Error())
+	}
+	if err := m.client.ConfigureDevice(m.deviceName, wgtypes.Config{PrivateKey: &priv}); err != nil {
+		return wgtypes.Key{}, fmt.Errorf("failed to install new WireGuard private key: %s", err.Error())
+	}
+	return priv.PublicKey(), nil
+}
+
+// setPeerKey records hostIP's public key, creating its wireguardPeer if
+// this is the first time hostIP has been seen, and reconfigures the peer
+// if it already has allowed IPs waiting on a key.
+func (m *wireguardManager) setPeerKey(hostIP gonet.IP, key wgtypes.Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	host := hostIP.String()
+	peer := m.peers[host]
+	if peer == nil {
+		peer = &wireguardPeer{allowedIPs: make(map[string]map[k8stypes.NamespacedName]struct{})}
+		m.peers[host] = peer
+	}
+	peer.key = key
+	peer.keyKnown = true
+	return m.reconcilePeerLocked(hostIP, peer)
+}
+
+// clearPeerKey forgets hostIP's public key (the Node that published it was
+// deleted, or lost its annotation) and removes its WireGuard peer, if one
+// was configured; the allowed-IPs bookkeeping is kept, so the peer is
+// reinstated automatically if the key reappears.
+func (m *wireguardManager) clearPeerKey(hostIP gonet.IP) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	host := hostIP.String()
+	peer := m.peers[host]
+	if peer == nil || !peer.keyKnown {
+		return nil
+	}
+	oldKey := peer.key
+	peer.keyKnown = false
+	return m.client.ConfigureDevice(m.deviceName, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{PublicKey: oldKey, Remove: true}},
+	})
+}
+
+// addAttachment records that attNSN needs allowedIPs reachable through
+// hostIP's peer, and reconfigures that peer if its public key is already
+// known.
+func (m *wireguardManager) addAttachment(attNSN k8stypes.NamespacedName, hostIP gonet.IP, allowedIPs []gonet.IPNet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	host := hostIP.String()
+	peer := m.peers[host]
+	if peer == nil {
+		peer = &wireguardPeer{allowedIPs: make(map[string]map[k8stypes.NamespacedName]struct{})}
+		m.peers[host] = peer
+	}
+	for _, ipNet := range allowedIPs {
+		cidr := ipNet.String()
+		contributors := peer.allowedIPs[cidr]
+		if contributors == nil {
+			contributors = make(map[k8stypes.NamespacedName]struct{})
+			peer.allowedIPs[cidr] = contributors
+		}
+		contributors[attNSN] = struct{}{}
+	}
+	m.attHost[attNSN] = host
+	return m.reconcilePeerLocked(hostIP, peer)
+}
+
+// removeAttachment undoes a prior addAttachment for attNSN, dropping any
+// allowed-IPs CIDR that no other attachment still needs, and the peer
+// itself once its allowed-IPs set is empty.
+func (m *wireguardManager) removeAttachment(attNSN k8stypes.NamespacedName) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	host, ok := m.attHost[attNSN]
+	if !ok {
+		return nil
+	}
+	delete(m.attHost, attNSN)
+	peer := m.peers[host]
+	if peer == nil {
+		return nil
+	}
+	for cidr, contributors := range peer.allowedIPs {
+		delete(contributors, attNSN)
+		if len(contributors) == 0 {
+			delete(peer.allowedIPs, cidr)
+		}
+	}
+	if len(peer.allowedIPs) == 0 {
+		delete(m.peers, host)
+		if !peer.keyKnown {
+			return nil
+		}
+		return m.client.ConfigureDevice(m.deviceName, wgtypes.Config{
+			Peers: []wgtypes.PeerConfig{{PublicKey: peer.key, Remove: true}},
+		})
+	}
+	return m.reconcilePeerLocked(gonet.ParseIP(host), peer)
+}
+
+// reconcilePeerLocked pushes peer's current key and allowed-IPs set to the
+// local device. Called with m.mu held. A peer whose key is not known yet
+// is left unconfigured -- setPeerKey will push it once the key arrives.
+func (m *wireguardManager) reconcilePeerLocked(hostIP gonet.IP, peer *wireguardPeer) error {
+	if !peer.keyKnown || len(peer.allowedIPs) == 0 {
+		return nil
+	}
+	allowedIPs := make([]gonet.IPNet, 0, len(peer.allowedIPs))
+	for cidr := range peer.allowedIPs {
+		_, ipNet, err := gonet.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("internal error: invalid allowed-IPs CIDR %q: %s", cidr, err.Error())
+		}
+		allowedIPs = append(allowedIPs, *ipNet)
+	}
+	return m.client.ConfigureDevice(m.deviceName, wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:         peer.key,
+			Endpoint:          &gonet.UDPAddr{IP: hostIP, Port: m.listenPort},
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        allowedIPs,
+		}},
+	})
+}
+
+// device returns the local WireGuard device's current state, for
+// runWireguardHealthCheck.
+func (m *wireguardManager) device() (*wgtypes.Device, error) {
+	return m.client.Device(m.deviceName)
+}
+
+// peerHost returns the host IP a configured peer's public key belongs to,
+// so runWireguardHealthCheck can label handshake-age observations by host
+// rather than by raw key.
+func (m *wireguardManager) peerHost(key wgtypes.Key) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for host, peer := range m.peers {
+		if peer.keyKnown && peer.key == key {
+			return host, true
+		}
+	}
+	return "", false
+}
+
+// wireguardAllowedIPs returns the AllowedIPs createOrUpdateIfc's WireGuard
+// peer for a remote attachment's host should carry for this attachment: the
+// attachment's own guest IP(s) as host routes, plus the CIDR(s) of the
+// Subnet its Virtual Network was carved out of (so that a peer already
+// reachable for one attachment in a VN does not need a separate route per
+// guest IP added later). A guest IP that fails to parse, or a Subnet that
+// cannot be resolved, is simply omitted; createOrUpdateIfc's own logging
+// already covers an unresolvable Subnet via subnetFabricName's same
+// ca.subnets dependency.
+func (ca *ConnectionAgent) wireguardAllowedIPs(attGuestIPv4, attGuestIPv6 gonet.IP, namespace, subnet string) []gonet.IPNet {
+	allowedIPs := make([]gonet.IPNet, 0, 4)
+	for _, guestIP := range []gonet.IP{attGuestIPv4, attGuestIPv6} {
+		if guestIP == nil {
+			continue
+		}
+		bits := 32
+		if guestIP.To4() == nil {
+			bits = 128
+		}
+		allowedIPs = append(allowedIPs, gonet.IPNet{IP: guestIP, Mask: gonet.CIDRMask(bits, bits)})
+	}
+	if ca.subnets == nil {
+		return allowedIPs
+	}
+	s, err := ca.subnets.Get(namespace, subnet)
+	if err != nil {
+		return allowedIPs
+	}
+	for _, cidr := range []string{s.Spec.IPv4, s.Spec.IPv6} {
+		if cidr == "" {
+			continue
+		}
+		if _, ipNet, err := gonet.ParseCIDR(cidr); err == nil {
+			allowedIPs = append(allowedIPs, *ipNet)
+		}
+	}
+	return allowedIPs
+}
+
+// initNodesInformerAndLister sets up the shared informer and lister on
+// Nodes that onNodeAdded/Updated/Removed use to track every node's
+// published WireguardPublicKeyAnnotation. Only started in secure mode (see
+// Run).
+func (ca *ConnectionAgent) initNodesInformerAndLister() {
+	factory := k8sinformers.NewSharedInformerFactory(ca.kubeClient, resyncPeriod)
+	nodes := factory.Core().V1().Nodes()
+	ca.nodesInformer = nodes.Informer()
+	ca.nodesLister = nodes.Lister()
+	ca.nodesInformer.AddEventHandler(k8scache.ResourceEventHandlerFuncs{
+		AddFunc:    ca.onNodeAdded,
+		UpdateFunc: ca.onNodeUpdated,
+		DeleteFunc: ca.onNodeRemoved,
+	})
+}
+
+func (ca *ConnectionAgent) onNodeAdded(obj interface{}) {
+	ca.handleNodeWireguardKey(obj.(*corev1.Node))
+}
+
+func (ca *ConnectionAgent) onNodeUpdated(_, newObj interface{}) {
+	ca.handleNodeWireguardKey(newObj.(*corev1.Node))
+}
+
+func (ca *ConnectionAgent) onNodeRemoved(obj interface{}) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		tombstone := obj.(k8scache.DeletedFinalStateUnknown)
+		node, ok = tombstone.Obj.(*corev1.Node)
+		if !ok {
+			return
+		}
+	}
+	if hostIP := nodeInternalIP(node); hostIP != nil {
+		if err := ca.wireguard.clearPeerKey(hostIP); err != nil {
+			glog.Warningf("failed to remove WireGuard peer for departed node %s: %s", node.Name, err.Error())
+		}
+	}
+}
+
+// handleNodeWireguardKey reads node's WireguardPublicKeyAnnotation, if any,
+// and pushes it into ca.wireguard so that any remote NetworkAttachment on
+// node that is already waiting on a peer gets one as soon as the key is
+// known.
+func (ca *ConnectionAgent) handleNodeWireguardKey(node *corev1.Node) {
+	hostIP := nodeInternalIP(node)
+	if hostIP == nil {
+		return
+	}
+	keyStr, ok := node.Annotations[netv1a1.WireguardPublicKeyAnnotation]
+	if !ok {
+		return
+	}
+	key, err := wgtypes.ParseKey(keyStr)
+	if err != nil {
+		glog.Warningf("node %s has an unparseable WireGuard public key annotation: %s", node.Name, err.Error())
+		return
+	}
+	if err := ca.wireguard.setPeerKey(hostIP, key); err != nil {
+		glog.Warningf("failed to configure WireGuard peer for node %s: %s", node.Name, err.Error())
+	}
+}
+
+// nodeInternalIP returns node's Status.Addresses entry of type
+// NodeInternalIP, or nil if it has none -- the same address a
+// NetworkAttachment's Status.HostIP is expected to match.
+func nodeInternalIP(node *corev1.Node) gonet.IP {
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP {
+			return gonet.ParseIP(addr.Address)
+		}
+	}
+	return nil
+}
+
+// runWireguardKeyRotation replaces the local WireGuard private key every
+// ca.wireguardKeyRotationPeriod, and republishes the resulting public key
+// on this node's WireguardPublicKeyAnnotation so that every other secure-
+// mode connection agent's onNodeAdded/Updated picks it up the normal way --
+// there is no separate fan-out path for a rotation.
+func (ca *ConnectionAgent) runWireguardKeyRotation(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(ca.wireguardKeyRotationPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			pub, err := ca.wireguard.rotateKey()
+			if err != nil {
+				glog.Warningf("WireGuard key rotation failed: %s", err.Error())
+				continue
+			}
+			if err := ca.publishWireguardPublicKey(pub); err != nil {
+				glog.Warningf("failed to publish rotated WireGuard public key: %s", err.Error())
+				continue
+			}
+			wireguardKeyRotationsTotal.Inc()
+			glog.V(2).Infoln("rotated WireGuard private key")
+		}
+	}
+}
+
+// publishWireguardPublicKey sets this node's WireguardPublicKeyAnnotation
+// to pub's string form.
+func (ca *ConnectionAgent) publishWireguardPublicKey(pub wgtypes.Key) error {
+	node, err := ca.kubeClient.CoreV1().Nodes().Get(ca.localNodeName, k8smetav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	node2 := node.DeepCopy()
+	if node2.Annotations == nil {
+		node2.Annotations = make(map[string]string, 1)
+	}
+	node2.Annotations[netv1a1.WireguardPublicKeyAnnotation] = pub.String()
+	_, err = ca.kubeClient.CoreV1().Nodes().Update(node2)
+	return err
+}
+
+// runWireguardHealthCheck periodically observes the local WireGuard
+// device's peers and reports each one's time since its last handshake in
+// wireguardPeerHandshakeAge, the metrics-endpoint equivalent of parsing `wg
+// show <device> latest-handshakes`.
+func (ca *ConnectionAgent) runWireguardHealthCheck(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(wireguardHealthCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			dev, err := ca.wireguard.device()
+			if err != nil {
+				glog.Warningf("failed to read WireGuard device state for health check: %s", err.Error())
+				continue
+			}
+			for _, peer := range dev.Peers {
+				host, ok := ca.wireguard.peerHost(peer.PublicKey)
+				if !ok {
+					continue
+				}
+				age := -1.0
+				if !peer.LastHandshakeTime.IsZero() {
+					age = time.Since(peer.LastHandshakeTime).Seconds()
+				}
+				wireguardPeerHandshakeAge.WithLabelValues(host).Set(age)
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package connectionagent
+
+import (
+	gonet "net"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// fakeWgClient is an in-memory stand-in for *wgctrl.Client, recording
+// whatever Config a ConfigureDevice call last applied for each peer so a
+// test can inspect it without a real WireGuard device.
+type fakeWgClient struct {
+	dev   wgtypes.Device
+	peers map[wgtypes.Key]wgtypes.PeerConfig
+}
+
+func newFakeWgClient() *fakeWgClient {
+	return &fakeWgClient{peers: make(map[wgtypes.Key]wgtypes.PeerConfig)}
+}
+
+func (c *fakeWgClient) Device(name string) (*wgtypes.Device, error) {
+	dev := c.dev
+	dev.Peers = nil
+	for _, p := range c.peers {
+		if !p.Remove {
+			dev.Peers = append(dev.Peers, wgtypes.Peer{PublicKey: p.PublicKey, AllowedIPs: p.AllowedIPs})
+		}
+	}
+	return &dev, nil
+}
+
+func (c *fakeWgClient) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	if cfg.PrivateKey != nil {
+		c.dev.PrivateKey = *cfg.PrivateKey
+		c.dev.PublicKey = cfg.PrivateKey.PublicKey()
+	}
+	for _, p := range cfg.Peers {
+		if p.Remove {
+			delete(c.peers, p.PublicKey)
+			continue
+		}
+		c.peers[p.PublicKey] = p
+	}
+	return nil
+}
+
+func newTestWireguardManager(client *fakeWgClient) *wireguardManager {
+	return &wireguardManager{
+		client:     client,
+		deviceName: "kos-wg0",
+		listenPort: defaultWireguardListenPort,
+		peers:      make(map[string]*wireguardPeer),
+		attHost:    make(map[k8stypes.NamespacedName]string),
+	}
+}
+
+func mustKey(t *testing.T) wgtypes.Key {
+	t.Helper()
+	key, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Fatalf("GeneratePrivateKey failed: %s", err.Error())
+	}
+	return key
+}
+
+// TestRotateKeyInstallsFreshPrivateKeyOnly checks that rotateKey installs a
+// new private key on the device and returns its matching public key,
+// without touching any already-configured peer.
+func TestRotateKeyInstallsFreshPrivateKeyOnly(t *testing.T) {
+	client := newFakeWgClient()
+	m := newTestWireguardManager(client)
+	peerKey := mustKey(t)
+	client.peers[peerKey.PublicKey()] = wgtypes.PeerConfig{PublicKey: peerKey.PublicKey()}
+
+	pub, err := m.rotateKey()
+	if err != nil {
+		t.Fatalf("rotateKey failed: %s", err.Error())
+	}
+	if pub != client.dev.PublicKey {
+		t.Errorf("rotateKey returned %v, device public key is %v", pub, client.dev.PublicKey)
+	}
+	if _, ok := client.peers[peerKey.PublicKey()]; !ok {
+		t.Errorf("rotateKey removed an unrelated peer")
+	}
+}
+
+// TestReconcilePeerLockedSkipsUntilKeyKnown checks that a peer with
+// allowed IPs waiting on an unknown key is left unconfigured, and is
+// pushed to the device only once its key arrives.
+func TestReconcilePeerLockedSkipsUntilKeyKnown(t *testing.T) {
+	client := newFakeWgClient()
+	m := newTestWireguardManager(client)
+	hostIP := gonet.ParseIP("10.0.0.5")
+	peer := &wireguardPeer{allowedIPs: map[string]map[k8stypes.NamespacedName]struct{}{
+		"192.168.1.5/32": {{Namespace: "ns", Name: "att"}: {}},
+	}}
+
+	if err := m.reconcilePeerLocked(hostIP, peer); err != nil {
+		t.Fatalf("reconcilePeerLocked failed: %s", err.Error())
+	}
+	if len(client.peers) != 0 {
+		t.Fatalf("got %d configured peers before the key is known, want 0", len(client.peers))
+	}
+
+	peer.key = mustKey(t).PublicKey()
+	peer.keyKnown = true
+	if err := m.reconcilePeerLocked(hostIP, peer); err != nil {
+		t.Fatalf("reconcilePeerLocked failed: %s", err.Error())
+	}
+	got, ok := client.peers[peer.key]
+	if !ok {
+		t.Fatalf("peer was not configured once its key became known")
+	}
+	if len(got.AllowedIPs) != 1 || got.AllowedIPs[0].String() != "192.168.1.5/32" {
+		t.Errorf("got AllowedIPs %v, want [192.168.1.5/32]", got.AllowedIPs)
+	}
+}
+
+// TestAddAttachmentThenRemoveDropsPeerWhenEmpty checks the full lifecycle:
+// addAttachment configures a peer once a key is set, a second attachment
+// sharing the same CIDR keeps the peer alive after the first is removed,
+// and removing the last attachment tears the peer down.
+func TestAddAttachmentThenRemoveDropsPeerWhenEmpty(t *testing.T) {
+	client := newFakeWgClient()
+	m := newTestWireguardManager(client)
+	hostIP := gonet.ParseIP("10.0.0.9")
+	key := mustKey(t).PublicKey()
+	if err := m.setPeerKey(hostIP, key); err != nil {
+		t.Fatalf("setPeerKey failed: %s", err.Error())
+	}
+
+	att1 := k8stypes.NamespacedName{Namespace: "ns", Name: "att1"}
+	att2 := k8stypes.NamespacedName{Namespace: "ns", Name: "att2"}
+	cidr := gonet.IPNet{IP: gonet.ParseIP("192.168.2.1").To4(), Mask: gonet.CIDRMask(32, 32)}
+
+	if err := m.addAttachment(att1, hostIP, []gonet.IPNet{cidr}); err != nil {
+		t.Fatalf("addAttachment(att1) failed: %s", err.Error())
+	}
+	if err := m.addAttachment(att2, hostIP, []gonet.IPNet{cidr}); err != nil {
+		t.Fatalf("addAttachment(att2) failed: %s", err.Error())
+	}
+	if _, ok := client.peers[key]; !ok {
+		t.Fatalf("peer was not configured after addAttachment")
+	}
+
+	if err := m.removeAttachment(att1); err != nil {
+		t.Fatalf("removeAttachment(att1) failed: %s", err.Error())
+	}
+	if _, ok := client.peers[key]; !ok {
+		t.Errorf("peer was torn down while att2 still needs its CIDR")
+	}
+
+	if err := m.removeAttachment(att2); err != nil {
+		t.Fatalf("removeAttachment(att2) failed: %s", err.Error())
+	}
+	if _, ok := client.peers[key]; ok {
+		t.Errorf("peer was not torn down once its last attachment was removed")
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remediation
+
+import "sync"
+
+// budgetTracker counts, per AttachmentDisruptionBudget (keyed by
+// "namespace/subnet"), how many NetworkAttachments this replica currently
+// has mid-remediation. Enforcement only needs to hold within the single
+// replica that is actually remediating at any moment -- leader election
+// already keeps every other replica from remediating concurrently -- so an
+// in-process counter is enough; AttachmentDisruptionBudgetStatus exists
+// purely to make the count observable, not to coordinate it.
+type budgetTracker struct {
+	mu       sync.Mutex
+	inFlight map[string]int32
+}
+
+func newBudgetTracker() *budgetTracker {
+	return &budgetTracker{inFlight: make(map[string]int32)}
+}
+
+// tryAcquire reports whether key has fewer than max remediations in flight
+// and, if so, counts this one against it.
+func (t *budgetTracker) tryAcquire(key string, max int32) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inFlight[key] >= max {
+		return false
+	}
+	t.inFlight[key]++
+	return true
+}
+
+// release gives back a slot tryAcquire granted for key.
+func (t *budgetTracker) release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.inFlight[key] > 0 {
+		t.inFlight[key]--
+	}
+}
@@ -0,0 +1,557 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remediation
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8sutilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	k8scache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	k8srecord "k8s.io/client-go/tools/record"
+	k8sworkqueue "k8s.io/client-go/util/workqueue"
+
+	coordv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	koscsv1a1 "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/typed/network/v1alpha1"
+	koslisterv1a1 "k8s.io/examples/staging/kos/pkg/client/listers/network/v1alpha1"
+	ipambackend "k8s.io/examples/staging/kos/pkg/ipam/backend"
+	"k8s.io/examples/staging/kos/pkg/serveragent/counter"
+)
+
+// Reason code put at the front of a stranded NetworkAttachment's
+// Status.Errors.Host entries.
+const ReasonNodeUnreachable = "NodeUnreachable"
+
+const (
+	// leaseNamePrefix identifies the coordination.k8s.io Lease used for
+	// leader election among the replicas of the remediation controller. All
+	// replicas of a given deployment must agree on leaseNamespace
+	// (k8smetav1.NamespaceDefault, as with the subnet validator).
+	leaseNamePrefix = "attachment-remediation"
+
+	// leaseDuration, renewDeadline and retryPeriod are the timings
+	// recommended by k8s.io/client-go/tools/leaderelection for controllers
+	// that can tolerate a short (seconds) gap in leadership after a crash.
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// attHostIPIndexName indexes attsInformer's NetworkAttachments by
+// Status.HostIP, so that every attachment bound to a node can be found
+// without a cluster-wide scan once the node's addresses are known.
+const attHostIPIndexName = "attachmentHostIP"
+
+func attachmentHostIP(obj interface{}) ([]string, error) {
+	att := obj.(*netv1a1.NetworkAttachment)
+	if att.Status.HostIP == "" {
+		return nil, nil
+	}
+	return []string{att.Status.HostIP}, nil
+}
+
+// nodeUnreadiness records when a Node was first observed to be not Ready
+// (or gone), and the addresses it was last known to have -- captured at
+// that moment, since a later Get of a deleted Node returns nothing to read
+// them from.
+type nodeUnreadiness struct {
+	since   time.Time
+	hostIPs []string
+}
+
+// RemediationController watches Nodes and NetworkAttachments and, once a
+// Node has been not-Ready (or absent) for longer than gracePeriod, strands
+// every NetworkAttachment bound to that Node: it releases the attachment's
+// claimed address(es) through pkg/ipam/backend, records a
+// ReasonNodeUnreachable error in Status.Errors.Host, and -- if the
+// attachment's Spec.RescheduleOnNodeLoss is set -- deletes it outright.
+//
+// Like the subnet validator, RemediationController supports warm-standby
+// high availability: every replica watches Nodes and NetworkAttachments and
+// keeps caches warm, but only the replica holding the leaseNamePrefix Lease
+// (acquired through coordV1Ifc) acts. Set alone to true to bypass leader
+// election, appropriate for single-replica deployments and tests.
+type RemediationController struct {
+	netv1a1Ifc   koscsv1a1.NetworkV1alpha1Interface
+	attsInformer k8scache.SharedIndexInformer
+	attsLister   koslisterv1a1.NetworkAttachmentLister
+	subnetLister koslisterv1a1.SubnetLister
+	iPLockLister koslisterv1a1.IPLockLister
+	budgetLister koslisterv1a1.AttachmentDisruptionBudgetLister
+
+	nodesInformer k8scache.SharedIndexInformer
+	nodesLister   corev1listers.NodeLister
+
+	// coordV1Ifc is used to acquire the leader election Lease. A nil value
+	// disables leader election; the controller then behaves as if alone
+	// were true.
+	coordV1Ifc coordv1client.CoordinationV1Interface
+
+	// recorder, if non-nil, receives a Warning Event (reason
+	// ReasonNodeUnreachable) on every NetworkAttachment this controller
+	// strands.
+	recorder k8srecord.EventRecorder
+
+	// gracePeriod is how long a Node must stay not-Ready (or stay deleted)
+	// before its NetworkAttachments are stranded.
+	gracePeriod time.Duration
+
+	queue   k8sworkqueue.RateLimitingInterface
+	workers int
+
+	identity string
+	alone    bool
+
+	mu           sync.Mutex
+	unreadySince map[string]*nodeUnreadiness
+
+	budgets *budgetTracker
+
+	// agentCounter, agentSelector and shardIndex implement the optional
+	// work-sharding EnableSharding sets up; agentCounter is nil until
+	// then, and shouldHandle treats that as "sharding disabled".
+	agentCounter  counter.LeaseCounter
+	agentSelector labels.Selector
+	shardIndex    int
+}
+
+// EnableSharding makes rc track and remediate only the Nodes that
+// ShardIndex maps to shardIndex out of agentCounter.CountAgents(
+// agentSelector) shards, instead of every Node. Call this once, before
+// Run, on every replica behind the same agentCounter and agentSelector but
+// a distinct shardIndex in [0, replica count), so that together they cover
+// every Node exactly once. It has no effect on leader election.
+func (rc *RemediationController) EnableSharding(agentCounter counter.LeaseCounter, agentSelector labels.Selector, shardIndex int) {
+	rc.agentCounter = agentCounter
+	rc.agentSelector = agentSelector
+	rc.shardIndex = shardIndex
+}
+
+// shouldHandle reports whether this replica owns name under the sharding
+// EnableSharding configured, or true unconditionally if it was never
+// called. A CountAgents of zero or less (no live peers, or no
+// LeaseCounter) also means true: a Node that no live agent count can
+// divide up must still be handled by somebody.
+func (rc *RemediationController) shouldHandle(name string) bool {
+	if rc.agentCounter == nil {
+		return true
+	}
+	shardCount := rc.agentCounter.CountAgents(rc.agentSelector)
+	if shardCount <= 0 {
+		return true
+	}
+	return counter.ShardIndex(name, shardCount) == rc.shardIndex
+}
+
+// NewRemediationController returns a RemediationController that is not yet
+// running; call Run to start it. coordV1Ifc may be nil, which is equivalent
+// to passing alone=true.
+func NewRemediationController(netv1a1Ifc koscsv1a1.NetworkV1alpha1Interface,
+	attsInformer k8scache.SharedIndexInformer,
+	attsLister koslisterv1a1.NetworkAttachmentLister,
+	subnetLister koslisterv1a1.SubnetLister,
+	iPLockLister koslisterv1a1.IPLockLister,
+	budgetLister koslisterv1a1.AttachmentDisruptionBudgetLister,
+	nodesInformer k8scache.SharedIndexInformer,
+	nodesLister corev1listers.NodeLister,
+	coordV1Ifc coordv1client.CoordinationV1Interface,
+	recorder k8srecord.EventRecorder,
+	gracePeriod time.Duration,
+	queue k8sworkqueue.RateLimitingInterface,
+	workers int,
+	identity string,
+	alone bool) *RemediationController {
+
+	rc := &RemediationController{
+		netv1a1Ifc:    netv1a1Ifc,
+		attsInformer:  attsInformer,
+		attsLister:    attsLister,
+		subnetLister:  subnetLister,
+		iPLockLister:  iPLockLister,
+		budgetLister:  budgetLister,
+		nodesInformer: nodesInformer,
+		nodesLister:   nodesLister,
+		coordV1Ifc:    coordV1Ifc,
+		recorder:      recorder,
+		gracePeriod:   gracePeriod,
+		queue:         queue,
+		workers:       workers,
+		identity:      identity,
+		alone:         alone,
+		unreadySince:  make(map[string]*nodeUnreadiness),
+		budgets:       newBudgetTracker(),
+	}
+
+	if err := attsInformer.AddIndexers(k8scache.Indexers{attHostIPIndexName: attachmentHostIP}); err != nil {
+		// Only fails if attsInformer has already started or the index name
+		// collides with one added elsewhere; both are programmer errors.
+		panic(fmt.Sprintf("failed to add %q indexer to the NetworkAttachments informer: %s", attHostIPIndexName, err.Error()))
+	}
+
+	nodesInformer.AddEventHandler(k8scache.ResourceEventHandlerFuncs{
+		AddFunc:    rc.onNodeAdded,
+		UpdateFunc: rc.onNodeUpdated,
+		DeleteFunc: rc.onNodeRemoved,
+	})
+
+	return rc
+}
+
+func (rc *RemediationController) onNodeAdded(obj interface{}) {
+	rc.observeNode(obj.(*corev1.Node))
+}
+
+func (rc *RemediationController) onNodeUpdated(oldObj, newObj interface{}) {
+	rc.observeNode(newObj.(*corev1.Node))
+}
+
+func (rc *RemediationController) onNodeRemoved(obj interface{}) {
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		tombstone, ok := obj.(k8scache.DeletedFinalStateUnknown)
+		if !ok {
+			k8sutilruntime.HandleError(fmt.Errorf("could not recover deleted Node from tombstone %#+v", obj))
+			return
+		}
+		node, ok = tombstone.Obj.(*corev1.Node)
+		if !ok {
+			k8sutilruntime.HandleError(fmt.Errorf("tombstone contained object that is not a Node: %#+v", tombstone.Obj))
+			return
+		}
+	}
+	// A deleted Node is unreachable regardless of the Ready condition its
+	// object last reported (an admin can delete a perfectly healthy Node),
+	// so this always starts the grace period rather than going through
+	// observeNode's isNodeReady check.
+	rc.trackUnready(node.Name, node)
+}
+
+// observeNode records node's readiness: a Ready node clears any tracked
+// unreadiness (it recovered before its grace period elapsed), while a
+// not-Ready one starts the grace period the same way trackUnready does for
+// a deleted one.
+func (rc *RemediationController) observeNode(node *corev1.Node) {
+	if isNodeReady(node) {
+		rc.mu.Lock()
+		delete(rc.unreadySince, node.Name)
+		rc.mu.Unlock()
+		return
+	}
+	rc.trackUnready(node.Name, node)
+}
+
+// trackUnready starts (if not already started) nodeName's grace period
+// timer, capturing node's addresses while they are still available, and
+// enqueues nodeName to be reconsidered once the grace period elapses.
+func (rc *RemediationController) trackUnready(nodeName string, node *corev1.Node) {
+	if !rc.shouldHandle(nodeName) {
+		return
+	}
+	rc.mu.Lock()
+	if _, tracked := rc.unreadySince[nodeName]; !tracked {
+		rc.unreadySince[nodeName] = &nodeUnreadiness{since: time.Now(), hostIPs: nodeHostIPs(node)}
+	}
+	rc.mu.Unlock()
+	rc.queue.AddAfter(nodeName, rc.gracePeriod)
+}
+
+// isNodeReady reports whether node's Ready condition is True.
+func isNodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeHostIPs returns the addresses by which a NetworkAttachment's
+// Status.HostIP can refer to node.
+func nodeHostIPs(node *corev1.Node) []string {
+	var hostIPs []string
+	for _, addr := range node.Status.Addresses {
+		if addr.Type == corev1.NodeInternalIP || addr.Type == corev1.NodeExternalIP {
+			hostIPs = append(hostIPs, addr.Address)
+		}
+	}
+	return hostIPs
+}
+
+// Run activates the RemediationController. If leader election is enabled
+// (coordV1Ifc is non-nil and alone is false), the worker goroutines only run
+// while this replica holds the leader election Lease; otherwise they run for
+// as long as stopCh is open.
+func (rc *RemediationController) Run(stopCh <-chan struct{}) error {
+	defer k8sutilruntime.HandleCrash()
+	defer rc.queue.ShutDown()
+
+	if rc.coordV1Ifc == nil || rc.alone {
+		glog.V(2).Infoln("attachment remediation controller starting without leader election")
+		return rc.runWorkersUntil(stopCh)
+	}
+	return rc.runWithLeaderElection(stopCh)
+}
+
+func (rc *RemediationController) runWorkersUntil(stopCh <-chan struct{}) error {
+	for i := 0; i < rc.workers; i++ {
+		go func() {
+			for rc.processQueue() {
+			}
+		}()
+	}
+	<-stopCh
+	return nil
+}
+
+func (rc *RemediationController) runWithLeaderElection(stopCh <-chan struct{}) error {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: k8smetav1.ObjectMeta{
+			Name:      leaseNamePrefix,
+			Namespace: k8smetav1.NamespaceDefault,
+		},
+		Client: rc.coordV1Ifc,
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: rc.identity,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				glog.V(2).Infof("%q became the leading attachment remediation controller", rc.identity)
+				rc.runWorkersUntil(leaderCtx.Done())
+			},
+			OnStoppedLeading: func() {
+				glog.V(2).Infof("%q stopped being the leading attachment remediation controller", rc.identity)
+			},
+		},
+	})
+	return nil
+}
+
+// processQueue processes a single item from the work queue and returns
+// whether the caller should keep calling it.
+func (rc *RemediationController) processQueue() bool {
+	item, shutdown := rc.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer rc.queue.Done(item)
+
+	nodeName := item.(string)
+	if err := rc.processNode(nodeName); err != nil {
+		k8sutilruntime.HandleError(fmt.Errorf("failed to remediate attachments on node %s, requeuing: %s", nodeName, err.Error()))
+		rc.queue.AddRateLimited(item)
+		return true
+	}
+	rc.queue.Forget(item)
+	return true
+}
+
+// processNode remediates nodeName's NetworkAttachments if its grace period
+// has elapsed, or requeues for the remainder of it if not.
+func (rc *RemediationController) processNode(nodeName string) error {
+	rc.mu.Lock()
+	unreadiness, tracked := rc.unreadySince[nodeName]
+	rc.mu.Unlock()
+	if !tracked {
+		// Became Ready (or was never actually unready) before this fired.
+		return nil
+	}
+
+	if elapsed := time.Since(unreadiness.since); elapsed < rc.gracePeriod {
+		rc.queue.AddAfter(nodeName, rc.gracePeriod-elapsed)
+		return nil
+	}
+
+	atts := rc.attachmentsOnHostIPs(unreadiness.hostIPs)
+	var skipped int
+	for _, att := range atts {
+		remediated, err := rc.remediateAttachment(att)
+		if err != nil {
+			return fmt.Errorf("failed to remediate NetworkAttachment %s/%s: %s", att.Namespace, att.Name, err.Error())
+		}
+		if !remediated {
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		return fmt.Errorf("%d of %d NetworkAttachments on node %s were skipped because their Subnet's AttachmentDisruptionBudget is exhausted", skipped, len(atts), nodeName)
+	}
+	return nil
+}
+
+// attachmentsOnHostIPs returns every cached NetworkAttachment whose
+// Status.HostIP is one of hostIPs.
+func (rc *RemediationController) attachmentsOnHostIPs(hostIPs []string) []*netv1a1.NetworkAttachment {
+	var atts []*netv1a1.NetworkAttachment
+	for _, hostIP := range hostIPs {
+		objs, err := rc.attsInformer.GetIndexer().ByIndex(attHostIPIndexName, hostIP)
+		if err != nil {
+			k8sutilruntime.HandleError(fmt.Errorf("failed to list NetworkAttachments with HostIP %s from cache: %s", hostIP, err.Error()))
+			continue
+		}
+		for _, obj := range objs {
+			atts = append(atts, obj.(*netv1a1.NetworkAttachment))
+		}
+	}
+	return atts
+}
+
+// remediateAttachment strands att: it releases att's claimed address(es)
+// through the Backend its Subnet selects, records a ReasonNodeUnreachable
+// Status.Errors.Host entry, and deletes att if Spec.RescheduleOnNodeLoss is
+// set. It reports remediated=false, with no error, when att's Subnet has an
+// AttachmentDisruptionBudget whose MaxConcurrentRemediations is already
+// spent; the caller is expected to retry later.
+func (rc *RemediationController) remediateAttachment(att *netv1a1.NetworkAttachment) (remediated bool, err error) {
+	start := time.Now()
+	outcome := "error"
+	defer func() {
+		remediationDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	subnet, getErr := rc.subnetLister.Subnets(att.Namespace).Get(att.Spec.Subnet)
+	if getErr != nil {
+		return false, fmt.Errorf("failed to get Subnet %s/%s: %s", att.Namespace, att.Spec.Subnet, getErr.Error())
+	}
+
+	budgetKey := att.Namespace + "/" + subnet.Name
+	maxRemediations, hasBudget, budgetErr := rc.maxConcurrentRemediations(subnet)
+	if budgetErr != nil {
+		return false, budgetErr
+	}
+	if hasBudget {
+		if !rc.budgets.tryAcquire(budgetKey, maxRemediations) {
+			outcome = "budget_exceeded"
+			return false, nil
+		}
+		defer rc.budgets.release(budgetKey)
+	}
+
+	if err := rc.releaseAddresses(subnet, att); err != nil {
+		return false, fmt.Errorf("failed to release addresses: %s", err.Error())
+	}
+
+	updated := att.DeepCopy()
+	// Clear the address(es) just released, along with HostIP, so that a
+	// later resync does not find this attachment still indexed under
+	// attHostIPIndexName and does not call releaseAddresses on it again --
+	// releaseAddresses is a no-op once these are empty, but processNode
+	// would otherwise re-run it every time the node's grace period keeps
+	// re-arming, releasing whatever address a different, healthy
+	// attachment has since been assigned.
+	updated.Status.HostIP = ""
+	updated.Status.IPv4 = ""
+	updated.Status.IPv6 = ""
+	updated.Status.Errors.Host = append(updated.Status.Errors.Host,
+		fmt.Sprintf("%s: node hosting this attachment has been unready for at least %s", ReasonNodeUnreachable, rc.gracePeriod))
+	attsIfc := rc.netv1a1Ifc.NetworkAttachments(att.Namespace)
+	if _, err := attsIfc.Update(updated); err != nil && !k8serrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to record %s on NetworkAttachment %s/%s: %s", ReasonNodeUnreachable, att.Namespace, att.Name, err.Error())
+	}
+
+	rescheduled := att.Spec.RescheduleOnNodeLoss
+	if rescheduled {
+		if err := attsIfc.Delete(att.Name, &k8smetav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete NetworkAttachment %s/%s: %s", att.Namespace, att.Name, err.Error())
+		}
+	}
+
+	if rc.recorder != nil {
+		rc.recorder.Eventf(att, corev1.EventTypeWarning, ReasonNodeUnreachable,
+			"stranded on node %s, address(es) released, rescheduled=%t", att.Spec.Node, rescheduled)
+	}
+
+	strandedAttachmentsTotal.WithLabelValues(fmt.Sprintf("%t", rescheduled)).Inc()
+	outcome = "success"
+	return true, nil
+}
+
+// releaseAddresses releases att's claimed IPv4 and/or IPv6 address through
+// subnet's Backend (see pkg/ipam/backend), so a released address can be
+// reused regardless of which Backend subnet uses.
+func (rc *RemediationController) releaseAddresses(subnet *netv1a1.Subnet, att *netv1a1.NetworkAttachment) error {
+	b, err := rc.backendForSubnet(subnet)
+	if err != nil {
+		return err
+	}
+	if att.Status.IPv4 != "" {
+		if ip := net.ParseIP(att.Status.IPv4); ip != nil {
+			if err := b.Release(subnet, ip); err != nil {
+				return fmt.Errorf("failed to release IPv4 address %s: %s", att.Status.IPv4, err.Error())
+			}
+		}
+	}
+	if att.Status.IPv6 != "" {
+		if ip := net.ParseIP(att.Status.IPv6); ip != nil {
+			if err := b.Release(subnet, ip); err != nil {
+				return fmt.Errorf("failed to release IPv6 address %s: %s", att.Status.IPv6, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// backendForSubnet returns the ipambackend.Backend subnet.Spec.IPAM selects,
+// or the default IPLock-backed one when it is unset.
+func (rc *RemediationController) backendForSubnet(subnet *netv1a1.Subnet) (ipambackend.Backend, error) {
+	if subnet.Spec.IPAM == nil || subnet.Spec.IPAM.Name == "" || subnet.Spec.IPAM.Name == ipambackend.DefaultBackendName {
+		return ipambackend.NewIPLockBackend(subnet.Namespace, rc.netv1a1Ifc.IPLocks(subnet.Namespace), rc.iPLockLister.IPLocks(subnet.Namespace)), nil
+	}
+	return ipambackend.NewBackend(subnet.Spec.IPAM)
+}
+
+// maxConcurrentRemediations looks up the AttachmentDisruptionBudget bound to
+// subnet, if any. hasBudget is false (and max meaningless) when subnet has
+// none, meaning remediation of its attachments is unbounded.
+func (rc *RemediationController) maxConcurrentRemediations(subnet *netv1a1.Subnet) (maxRemediations int32, hasBudget bool, err error) {
+	budgets, err := rc.budgetLister.AttachmentDisruptionBudgets(subnet.Namespace).List(labels.Everything())
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to list AttachmentDisruptionBudgets in %s: %s", subnet.Namespace, err.Error())
+	}
+	for _, budget := range budgets {
+		if budget.Spec.Subnet == subnet.Name {
+			return budget.Spec.MaxConcurrentRemediations, true, nil
+		}
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,33 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package remediation implements a controller that notices NetworkAttachments
+// stranded on a Node that has stopped being Ready, releases the IP address
+// each one held (through pkg/ipam/backend, so this works the same whether
+// the attachment's Subnet uses the default IPLock backend or one of the
+// pluggable ones), and records the failure in Status.Errors.Host. An
+// attachment whose Spec.RescheduleOnNodeLoss is set is deleted outright
+// afterward, on the assumption that whatever created it is watching for the
+// deletion and will recreate it elsewhere; one that does not set it is left
+// in place, stranded, for an operator to act on.
+//
+// A Subnet's AttachmentDisruptionBudget, if one exists, caps how many of its
+// NetworkAttachments this controller will treat as in-progress remediations
+// at once, the same way a PodDisruptionBudget caps concurrent voluntary Pod
+// evictions. This keeps a control-plane partition that makes many Nodes look
+// unready from cascading into a mass release of IP addresses that are, in
+// fact, still in use.
+package remediation
@@ -0,0 +1,49 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package remediation
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "kos"
+
+var (
+	// strandedAttachmentsTotal counts NetworkAttachments this controller has
+	// stranded (IP released, Host error recorded), by whether the
+	// attachment was also deleted (Spec.RescheduleOnNodeLoss) or left in
+	// place for an operator.
+	strandedAttachmentsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "stranded_attachments_total",
+		Help:      "Number of NetworkAttachments stranded by node loss, by whether they were also deleted.",
+	}, []string{"rescheduled"})
+
+	// remediationDuration observes how long remediating a single
+	// NetworkAttachment takes, from the decision to act on it to the IPAM
+	// release (and, when applicable, the deletion) completing.
+	remediationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "remediation_duration_seconds",
+		Help:      "Time taken to remediate one stranded NetworkAttachment.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(strandedAttachmentsTotal, remediationDuration)
+}
@@ -0,0 +1,549 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnet
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	k8sutilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	k8scache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	k8srecord "k8s.io/client-go/tools/record"
+	k8sworkqueue "k8s.io/client-go/util/workqueue"
+
+	coordv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	koscapplyv1a1 "k8s.io/examples/staging/kos/pkg/client/applyconfiguration/network/v1alpha1"
+	koscsv1a1 "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/typed/network/v1alpha1"
+	koslisterv1a1 "k8s.io/examples/staging/kos/pkg/client/listers/network/v1alpha1"
+	"k8s.io/examples/staging/kos/pkg/serveragent/counter"
+)
+
+// Reason codes put at the front of a Subnet's Status.Errors entries, so that
+// consumers can match on them instead of parsing free-form English. They are
+// also used as the Reason of the corresponding Warning Event.
+const (
+	// ReasonCIDRConflict is used when a Subnet's IPv4 or IPv6 range overlaps
+	// that of another Subnet with the same VNI.
+	ReasonCIDRConflict = "SubnetCIDRConflict"
+
+	// ReasonNamespaceConflict is used when a Subnet shares a VNI with
+	// another Subnet in a different namespace.
+	ReasonNamespaceConflict = "SubnetNamespaceConflict"
+
+	// ReasonValidated is the Reason of the Normal Event emitted when a
+	// Subnet becomes valid.
+	ReasonValidated = "SubnetValidated"
+)
+
+const (
+	// leaseNamespace and leaseNamePrefix identify the pool of
+	// coordination.k8s.io Leases used for leader election and peer counting
+	// among the replicas of the subnet validator. All replicas of a given
+	// deployment must agree on leaseNamespace.
+	leaseNamePrefix = "subnet-validator"
+
+	// leaseDuration, renewDeadline and retryPeriod are the timings
+	// recommended by k8s.io/client-go/tools/leaderelection for controllers
+	// that can tolerate a short (seconds) gap in leadership after a crash.
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// ValidationMode controls how a ValidationController persists the outcome of
+// validating a Subnet.
+type ValidationMode int
+
+const (
+	// ModeWrite issues a regular Update of Status, the same as a
+	// ValidationController always did before ValidationMode existed. Two
+	// replicas writing in this mode must not run concurrently against the
+	// same Subnets (see the ValidationController doc comment).
+	ModeWrite ValidationMode = iota
+
+	// ModeDryRun computes validation exactly as ModeWrite does, still emits
+	// the corresponding Event, but never calls Update; it exists so an
+	// operator can preview the effect a validator change (or a new Subnet)
+	// would have before it is admitted for real.
+	ModeDryRun
+
+	// ModeServerSideApply patches only the `status` fields this controller
+	// owns, using fieldManagerName as its field manager, instead of
+	// replacing the whole Subnet object with a plain Update. This lets
+	// several validator replicas apply status concurrently without the
+	// ResourceVersion churn and lost-update races a plain Update is prone
+	// to; conflicts over the same field are resolved by the apiserver
+	// using the usual last-apply-wins SSA semantics.
+	ModeServerSideApply
+)
+
+// fieldManagerName identifies this controller's applies when mode is
+// ModeServerSideApply.
+const fieldManagerName = "subnet-validator"
+
+// ValidationController watches Subnets and maintains their Status.Validated
+// and Status.Errors fields. A Subnet is valid if and only if no other Subnet
+// with the same VNI has an overlapping IPv4 range or lives in a different
+// namespace.
+//
+// Because validating a Subnet requires comparing it against every other
+// Subnet sharing its VNI, running more than one ValidationController against
+// the same collection of Subnets at the same time is unsafe: two replicas
+// could process two conflicting Subnets concurrently and both mark them
+// valid. ValidationController therefore supports warm-standby high
+// availability: every replica watches Subnets and keeps caches warm, but only
+// the replica that holds the `leaseNamePrefix` Lease in the apiserver
+// (acquired through `coordV1Ifc`, following the protocol implemented by
+// k8s.io/client-go/tools/leaderelection) writes Subnet status. Set `alone` to
+// true to bypass leader election entirely and always act as the leader; this
+// is appropriate for single-replica deployments and for tests.
+type ValidationController struct {
+	netv1a1Ifc     koscsv1a1.NetworkV1alpha1Interface
+	subnetInformer k8scache.SharedIndexInformer
+	subnetLister   koslisterv1a1.SubnetLister
+
+	// coordV1Ifc is used to acquire the leader election Lease and to list the
+	// pool of Leases when counting healthy peers. A nil value disables
+	// leader election; the controller then behaves as if `alone` were true.
+	coordV1Ifc coordv1client.CoordinationV1Interface
+
+	// recorder, if non-nil, receives a Warning Event (reason
+	// ReasonCIDRConflict or ReasonNamespaceConflict) referencing the rival
+	// Subnet whenever a Subnet is found invalid, and a Normal Event (reason
+	// ReasonValidated) whenever a Subnet becomes valid.
+	recorder k8srecord.EventRecorder
+
+	// mode selects how a computed validation outcome is persisted; see
+	// ValidationMode.
+	mode ValidationMode
+
+	queue   k8sworkqueue.RateLimitingInterface
+	workers int
+
+	// identity is this replica's holder identity in the leader election
+	// Lease. It need not be set (and is ignored) when coordV1Ifc is nil or
+	// alone is true.
+	identity string
+
+	// alone makes this replica act as the perpetually elected leader,
+	// skipping leader election altogether.
+	alone bool
+
+	// agentCounter, agentSelector and shardIndex implement the optional
+	// work-sharding EnableSharding sets up; agentCounter is nil until then,
+	// and shouldHandle treats that as "sharding disabled".
+	agentCounter  counter.LeaseCounter
+	agentSelector labels.Selector
+	shardIndex    int
+}
+
+// EnableSharding makes vc process only the Subnets that ShardIndex maps to
+// shardIndex out of agentCounter.CountAgents(agentSelector) shards, instead
+// of every Subnet. Call this once, before Run, on every replica behind the
+// same agentCounter and agentSelector but a distinct shardIndex in
+// [0, replica count), so that together they cover every Subnet exactly
+// once. It has no effect on leader election: only the elected leader (or,
+// with alone set, every replica) evaluates shouldHandle at all.
+func (vc *ValidationController) EnableSharding(agentCounter counter.LeaseCounter, agentSelector labels.Selector, shardIndex int) {
+	vc.agentCounter = agentCounter
+	vc.agentSelector = agentSelector
+	vc.shardIndex = shardIndex
+}
+
+// shouldHandle reports whether this replica owns name under the sharding
+// EnableSharding configured, or true unconditionally if it was never
+// called. A CountAgents of zero or less (no live peers, or no LeaseCounter)
+// also means true: a Subnet that no live agent count can divide up must
+// still be handled by somebody.
+func (vc *ValidationController) shouldHandle(name string) bool {
+	if vc.agentCounter == nil {
+		return true
+	}
+	shardCount := vc.agentCounter.CountAgents(vc.agentSelector)
+	if shardCount <= 0 {
+		return true
+	}
+	return counter.ShardIndex(name, shardCount) == vc.shardIndex
+}
+
+// NewValidationController returns a ValidationController that is not yet
+// running; call Run to start it. coordV1Ifc may be nil, which is equivalent
+// to passing alone=true: the returned controller never attempts leader
+// election and processes every Subnet it is notified of.
+func NewValidationController(netv1a1Ifc koscsv1a1.NetworkV1alpha1Interface,
+	subnetInformer k8scache.SharedIndexInformer,
+	subnetLister koslisterv1a1.SubnetLister,
+	coordV1Ifc coordv1client.CoordinationV1Interface,
+	recorder k8srecord.EventRecorder,
+	mode ValidationMode,
+	queue k8sworkqueue.RateLimitingInterface,
+	workers int,
+	identity string,
+	alone bool) *ValidationController {
+
+	vc := &ValidationController{
+		netv1a1Ifc:     netv1a1Ifc,
+		subnetInformer: subnetInformer,
+		subnetLister:   subnetLister,
+		coordV1Ifc:     coordV1Ifc,
+		recorder:       recorder,
+		mode:           mode,
+		queue:          queue,
+		workers:        workers,
+		identity:       identity,
+		alone:          alone,
+	}
+
+	if err := subnetInformer.AddIndexers(k8scache.Indexers{subnetVNIIndexName: subnetVNIIndexFunc}); err != nil {
+		// Only fails if subnetInformer has already started or the index name
+		// collides with one added elsewhere; both are programmer errors.
+		panic(fmt.Sprintf("failed to add %q indexer to the Subnets informer: %s", subnetVNIIndexName, err.Error()))
+	}
+
+	subnetInformer.AddEventHandler(k8scache.ResourceEventHandlerFuncs{
+		AddFunc:    vc.onSubnetAdded,
+		UpdateFunc: vc.onSubnetUpdated,
+		DeleteFunc: vc.onSubnetRemoved,
+	})
+
+	return vc
+}
+
+func (vc *ValidationController) onSubnetAdded(obj interface{}) {
+	vc.enqueue(obj.(*netv1a1.Subnet))
+}
+
+func (vc *ValidationController) onSubnetUpdated(oldObj, newObj interface{}) {
+	vc.enqueue(newObj.(*netv1a1.Subnet))
+}
+
+func (vc *ValidationController) onSubnetRemoved(obj interface{}) {
+	subnet, ok := obj.(*netv1a1.Subnet)
+	if !ok {
+		tombstone, ok := obj.(k8scache.DeletedFinalStateUnknown)
+		if !ok {
+			k8sutilruntime.HandleError(fmt.Errorf("could not recover deleted Subnet from tombstone %#+v", obj))
+			return
+		}
+		subnet, ok = tombstone.Obj.(*netv1a1.Subnet)
+		if !ok {
+			k8sutilruntime.HandleError(fmt.Errorf("tombstone contained object that is not a Subnet: %#+v", tombstone.Obj))
+			return
+		}
+	}
+	// A deletion can make rival Subnets with the same VNI valid, so
+	// re-enqueue them too.
+	for _, rival := range vc.subnetsWithVNI(subnet.Spec.VNI) {
+		if rival.Namespace == subnet.Namespace && rival.Name == subnet.Name {
+			continue
+		}
+		vc.enqueue(rival)
+	}
+}
+
+func (vc *ValidationController) enqueue(subnet *netv1a1.Subnet) {
+	if !vc.shouldHandle(subnet.Name) {
+		return
+	}
+	vc.queue.Add(k8stypes.NamespacedName{Namespace: subnet.Namespace, Name: subnet.Name})
+}
+
+// subnetVNIIndexName is registered with subnetInformer's Indexer so that
+// subnetsWithVNI is an O(1) map lookup plus a small fanout over the Subnets
+// sharing vni, rather than an O(n) scan of every Subnet in the cache.
+const subnetVNIIndexName = "subnetVNI"
+
+// subnetVNIIndexFunc is the cache.IndexFunc backing subnetVNIIndexName.
+func subnetVNIIndexFunc(obj interface{}) ([]string, error) {
+	subnet, ok := obj.(*netv1a1.Subnet)
+	if !ok {
+		return nil, fmt.Errorf("expected a Subnet, got %T", obj)
+	}
+	return []string{strconv.FormatUint(uint64(subnet.Spec.VNI), 10)}, nil
+}
+
+// subnetsWithVNI returns the Subnets known to the local cache that have the
+// given VNI.
+func (vc *ValidationController) subnetsWithVNI(vni uint32) []*netv1a1.Subnet {
+	objs, err := vc.subnetInformer.GetIndexer().ByIndex(subnetVNIIndexName, strconv.FormatUint(uint64(vni), 10))
+	if err != nil {
+		k8sutilruntime.HandleError(fmt.Errorf("failed to list Subnets with VNI %d from cache: %s", vni, err.Error()))
+		return nil
+	}
+	withVNI := make([]*netv1a1.Subnet, 0, len(objs))
+	for _, obj := range objs {
+		withVNI = append(withVNI, obj.(*netv1a1.Subnet))
+	}
+	return withVNI
+}
+
+// Run activates the ValidationController. If leader election is enabled
+// (coordV1Ifc is non-nil and alone is false), the worker goroutines and the
+// Subnets informer only run while this replica holds the leader election
+// Lease; otherwise they run for as long as stopCh is open.
+func (vc *ValidationController) Run(stopCh <-chan struct{}) error {
+	defer k8sutilruntime.HandleCrash()
+	defer vc.queue.ShutDown()
+
+	if vc.coordV1Ifc == nil || vc.alone {
+		glog.V(2).Infoln("subnet validator starting without leader election")
+		return vc.runWorkersUntil(stopCh)
+	}
+
+	return vc.runWithLeaderElection(stopCh)
+}
+
+func (vc *ValidationController) runWorkersUntil(stopCh <-chan struct{}) error {
+	for i := 0; i < vc.workers; i++ {
+		go func() {
+			for vc.processQueue() {
+			}
+		}()
+	}
+	<-stopCh
+	return nil
+}
+
+func (vc *ValidationController) runWithLeaderElection(stopCh <-chan struct{}) error {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: k8smetav1.ObjectMeta{
+			Name:      leaseNamePrefix,
+			Namespace: k8smetav1.NamespaceDefault,
+		},
+		Client: vc.coordV1Ifc,
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: vc.identity,
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: leaseDuration,
+		RenewDeadline: renewDeadline,
+		RetryPeriod:   retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				glog.V(2).Infof("%q became the leading subnet validator", vc.identity)
+				vc.runWorkersUntil(leaderCtx.Done())
+			},
+			OnStoppedLeading: func() {
+				glog.V(2).Infof("%q stopped being the leading subnet validator", vc.identity)
+			},
+		},
+	})
+	return nil
+}
+
+// CountHealthyPeers lists the leader election Lease pool and returns the
+// number of replicas that have renewed their Lease within leaseDuration, as
+// well as the identity of the current leader (the empty string if none).
+// This backs a `--count-validator-leases` mode in which a replica does not
+// run validation itself but only reports on the health of its peers.
+func (vc *ValidationController) CountHealthyPeers(ctx context.Context) (healthy int, leader string, err error) {
+	if vc.coordV1Ifc == nil {
+		return 0, "", fmt.Errorf("leader election is disabled, there is no lease pool to count")
+	}
+	lease, err := vc.coordV1Ifc.Leases(k8smetav1.NamespaceDefault).Get(leaseNamePrefix, k8smetav1.GetOptions{})
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get subnet validator lease: %s", err.Error())
+	}
+	if lease.Spec.HolderIdentity != nil {
+		leader = *lease.Spec.HolderIdentity
+	}
+	if lease.Spec.RenewTime != nil && time.Since(lease.Spec.RenewTime.Time) < leaseDuration {
+		healthy = 1
+	}
+	return healthy, leader, nil
+}
+
+// processQueue processes a single item from the work queue and returns
+// whether the caller should keep calling it.
+func (vc *ValidationController) processQueue() bool {
+	item, shutdown := vc.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer vc.queue.Done(item)
+
+	nsn := item.(k8stypes.NamespacedName)
+	if err := vc.processSubnet(nsn); err != nil {
+		k8sutilruntime.HandleError(fmt.Errorf("failed to process Subnet %s, requeuing: %s", nsn, err.Error()))
+		vc.queue.AddRateLimited(item)
+		return true
+	}
+	vc.queue.Forget(item)
+	return true
+}
+
+// processSubnet (re)computes the validity of the named Subnet and writes the
+// result if it changed. To avoid the race described in
+// TestSubnetValidator_lateInformer --- where a rival Subnet has not yet
+// appeared in this replica's informer cache --- the authoritative list of
+// Subnets sharing the VNI is fetched straight from the API server rather than
+// from the local cache.
+func (vc *ValidationController) processSubnet(nsn k8stypes.NamespacedName) error {
+	subnetsIfc := vc.netv1a1Ifc.Subnets(nsn.Namespace)
+	subnet, err := subnetsIfc.Get(nsn.Name, k8smetav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get Subnet %s: %s", nsn, err.Error())
+	}
+
+	allWithVNI, err := vc.netv1a1Ifc.Subnets(k8smetav1.NamespaceAll).List(k8smetav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list Subnets: %s", err.Error())
+	}
+
+	var rival *netv1a1.Subnet
+	var reason string
+	for i := range allWithVNI.Items {
+		other := &allWithVNI.Items[i]
+		if other.Spec.VNI != subnet.Spec.VNI {
+			continue
+		}
+		if other.Namespace == subnet.Namespace && other.Name == subnet.Name {
+			continue
+		}
+		if r, conflicts := subnetConflictReason(subnet, other); conflicts {
+			rival, reason = other, r
+			break
+		}
+	}
+
+	valid := rival == nil
+	if subnet.Status.Validated == valid && (valid || len(subnet.Status.Errors) > 0) {
+		// Already in the desired state.
+		return nil
+	}
+
+	updated := subnet.DeepCopy()
+	updated.Status.Validated = valid
+	if valid {
+		updated.Status.Errors = nil
+	} else {
+		updated.Status.Errors = []string{fmt.Sprintf("%s: conflicts with Subnet %s/%s (uid %s)",
+			reason, rival.Namespace, rival.Name, rival.UID)}
+	}
+
+	if err := vc.persist(subnetsIfc, nsn, updated); err != nil {
+		return err
+	}
+
+	if vc.recorder == nil {
+		return nil
+	}
+	if valid {
+		vc.recorder.Eventf(updated, corev1.EventTypeNormal, ReasonValidated,
+			"Subnet %s passed validation", nsn)
+	} else {
+		vc.recorder.Eventf(updated, corev1.EventTypeWarning, reason,
+			"Subnet %s conflicts with Subnet %s/%s (uid %s, cidr %s)",
+			nsn, rival.Namespace, rival.Name, rival.UID, rival.Spec.IPv4)
+	}
+	return nil
+}
+
+// persist writes updated's Status according to vc.mode: ModeWrite issues a
+// plain Update, ModeDryRun issues nothing at all, and ModeServerSideApply
+// issues an ApplyStatus carrying only the `status` fields this controller
+// owns, under fieldManagerName, so that this controller and whatever else
+// applies other Status fields of the same Subnet (e.g. the subnet
+// controller's ReadyNodes/NotReadyNodes/NodeConditions) never race on a
+// plain Update clobbering each other's last write.
+func (vc *ValidationController) persist(subnetsIfc koscsv1a1.SubnetInterface, nsn k8stypes.NamespacedName, updated *netv1a1.Subnet) error {
+	switch vc.mode {
+	case ModeDryRun:
+		glog.V(2).Infof("dry run: Subnet %s would become Status.Validated=%t Status.Errors=%v",
+			nsn, updated.Status.Validated, updated.Status.Errors)
+		return nil
+
+	case ModeServerSideApply:
+		apply := koscapplyv1a1.Subnet(nsn.Namespace, nsn.Name).WithStatus(
+			koscapplyv1a1.SubnetStatus().
+				WithValidated(updated.Status.Validated).
+				WithErrors(updated.Status.Errors...))
+		opts := koscapplyv1a1.ApplyOptions{FieldManager: fieldManagerName}
+		if _, err := subnetsIfc.ApplyStatus(apply, opts); err != nil {
+			return fmt.Errorf("failed to apply status of Subnet %s: %s", nsn, err.Error())
+		}
+		return nil
+
+	default:
+		if _, err := subnetsIfc.Update(updated); err != nil {
+			return fmt.Errorf("failed to update status of Subnet %s: %s", nsn, err.Error())
+		}
+		return nil
+	}
+}
+
+// subnetConflictReason reports whether two Subnets with the same VNI are
+// incompatible --- either they live in different namespaces, their IPv4 or
+// IPv6 ranges overlap, or any of their Spec.Ranges overlap --- and, if so,
+// the reason code for the conflict.
+func subnetConflictReason(a, b *netv1a1.Subnet) (reason string, conflicts bool) {
+	if a.Namespace != b.Namespace {
+		return ReasonNamespaceConflict, true
+	}
+	if cidrsConflict(a.Spec.IPv4, b.Spec.IPv4) {
+		return ReasonCIDRConflict, true
+	}
+	if a.Spec.IPv6 != "" && b.Spec.IPv6 != "" && cidrsConflict(a.Spec.IPv6, b.Spec.IPv6) {
+		return ReasonCIDRConflict, true
+	}
+	for _, aRange := range a.Spec.Ranges {
+		for _, bRange := range b.Spec.Ranges {
+			if cidrsConflict(aRange.Subnet, bRange.Subnet) {
+				return ReasonCIDRConflict, true
+			}
+		}
+	}
+	return "", false
+}
+
+// cidrsConflict reports whether the CIDRs aCIDR and bCIDR overlap. Malformed
+// CIDRs are caught by admission; they are treated as conflicting here so an
+// invalid Subnet never gets marked valid.
+func cidrsConflict(aCIDR, bCIDR string) bool {
+	_, aNet, aErr := net.ParseCIDR(aCIDR)
+	_, bNet, bErr := net.ParseCIDR(bCIDR)
+	if aErr != nil || bErr != nil {
+		return true
+	}
+	return aNet.Contains(bNet.IP) || bNet.Contains(aNet.IP)
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,6 +33,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	k8stesting "k8s.io/client-go/testing"
 	k8scache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 
 	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
@@ -73,6 +75,15 @@ type validatorTestCase struct {
 	// reaction can be used to inject failures in the API calls the subnet
 	// validator does.
 	reaction k8stesting.ReactionFunc
+
+	// expectedEventReasons, if non-empty, is the set of Event reasons that
+	// must have been recorded by the time the last round finishes.
+	expectedEventReasons map[string]bool
+
+	// mode is the ValidationMode the controller under test runs with. The
+	// zero value is ModeWrite, what every test predating ValidationMode
+	// exercises.
+	mode ValidationMode
 }
 
 func TestSubnetValidator(t *testing.T) {
@@ -147,6 +158,24 @@ func TestSubnetValidator(t *testing.T) {
 					},
 				},
 			},
+			expectedEventReasons: map[string]bool{ReasonCIDRConflict: true},
+		},
+
+		// Test that ModeDryRun computes validation but never mutates the
+		// Subnet: an otherwise-becomes-valid Subnet must be observed
+		// forever in its initial (unvalidated) state.
+		"dry-run mode never persists the computed validation": {
+			initialSubnets: []runtime.Object{
+				newSubnet1(),
+			},
+			rounds: []validatorTestRound{
+				{
+					expectedSubnets: []netv1a1.Subnet{
+						*newSubnet1(),
+					},
+				},
+			},
+			mode: ModeDryRun,
 		},
 
 		// Test cases checking that the subnet validator reacts correctly to
@@ -271,11 +300,17 @@ func parallelTest(tc validatorTestCase, diffOptions cmp.Options, t *testing.T) {
 		client.PrependReactor("update", "subnets", tc.reaction)
 	}
 
+	// fakeRecorder captures the Events the validator emits so that tests can
+	// assert on their reasons without standing up a real EventSink.
+	fakeRecorder := record.NewFakeRecorder(100)
+
 	subnetsInformer := kosinformers.NewSharedInformerFactory(client, 0).Network().V1alpha1().Subnets()
 	subnetValidator := NewValidationController(client.NetworkV1alpha1(),
 		subnetsInformer.Informer(),
 		subnetsInformer.Lister(),
 		nil,
+		fakeRecorder,
+		tc.mode,
 		// Use a fake rate limiter (delay is always 0) to reduce likelyhood of
 		// spurius failures caused by a test timeout.
 		workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(0, 0)),
@@ -356,6 +391,29 @@ func parallelTest(tc validatorTestCase, diffOptions cmp.Options, t *testing.T) {
 			t.Fatalf("round %d: error while transitioning to next round: %s", i, err)
 		}
 	}
+
+	if len(tc.expectedEventReasons) > 0 {
+		gotReasons := map[string]bool{}
+	drainEvents:
+		for {
+			select {
+			case event := <-fakeRecorder.Events:
+				// fakeRecorder.Events carries strings of the form
+				// "<eventtype> <reason> <message>".
+				fields := strings.SplitN(event, " ", 3)
+				if len(fields) >= 2 {
+					gotReasons[fields[1]] = true
+				}
+			default:
+				break drainEvents
+			}
+		}
+		for reason := range tc.expectedEventReasons {
+			if !gotReasons[reason] {
+				t.Fatalf("expected an Event with reason %q, got reasons %v", reason, gotReasons)
+			}
+		}
+	}
 }
 
 // Test that a subnet with a rival is not marked as valid even if the rival is
@@ -386,6 +444,8 @@ func TestSubnetValidator_lateInformer(t *testing.T) {
 		subnetsInformer.Informer(),
 		subnetsInformer.Lister(),
 		nil,
+		nil,
+		ModeWrite,
 		workqueue.NewRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(0, 0)),
 		0,
 		"",
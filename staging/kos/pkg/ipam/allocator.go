@@ -0,0 +1,312 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// ErrRangeExhausted is returned by Allocate when every address in subnet's
+// Ranges (after excluding each range's Gateway, Exclude CIDRs, and locked)
+// is already allocated.
+var ErrRangeExhausted = fmt.Errorf("ipam: range exhausted")
+
+// ErrRequestedAddressUnavailable is returned by AllocateRequested when the
+// requested address cannot be granted: it lies outside every one of
+// subnet's ranges, is a range's Gateway, is covered by a range's Exclude,
+// or is already locked. Callers must surface this (e.g. to
+// NetworkAttachmentStatus.Errors.IPAM) rather than falling back to
+// Allocate and handing out a different address.
+var ErrRequestedAddressUnavailable = fmt.Errorf("ipam: requested address unavailable")
+
+// Allocate picks the next address to hand out from subnet, for the
+// addresses in locked (keyed by net.IP.String()). If subnet.Spec.Ranges is
+// non-empty, only those ranges are considered; otherwise subnet's single
+// top-level IPv4 CIDR is treated as one implicit, ungated range. Ranges are
+// tried in round-robin order starting at subnet.Status.NextRangeIndex, so
+// that repeated calls spread allocations across them instead of always
+// favoring the first. Within a range, addresses are tried starting just
+// after the range's entry in subnet.Status.LastAllocatedIPs (or that
+// range's RangeStart, if it has no entry yet), wrapping around to
+// RangeStart once RangeEnd is passed.
+//
+// On success, Allocate returns the chosen address, the index into
+// subnet.Spec.Ranges it came from (-1 when subnet.Spec.Ranges is empty and
+// the implicit IPv4 range was used), and the SubnetStatus the caller
+// should persist to record the allocation. Allocate does not talk to the
+// apiserver and does not itself claim the address (e.g. by creating an
+// IPLock): it is a pure function of its inputs, so that the
+// create-an-IPLock-then-persist-this-status coordination, which needs
+// apiserver optimistic concurrency, can be layered on top by a caller that
+// has a clientset. See the package doc comment.
+func Allocate(subnet *netv1a1.Subnet, locked map[string]bool) (net.IP, int, netv1a1.SubnetStatus, error) {
+	ranges := subnet.Spec.Ranges
+	implicit := len(ranges) == 0
+	if implicit {
+		ranges = []netv1a1.IPRange{{Subnet: subnet.Spec.IPv4}}
+	}
+
+	lastAllocated := make([]string, len(ranges))
+	copy(lastAllocated, subnet.Status.LastAllocatedIPs)
+
+	start := int(subnet.Status.NextRangeIndex) % len(ranges)
+	for i := 0; i < len(ranges); i++ {
+		idx := (start + i) % len(ranges)
+		ip, err := nextInRange(ranges[idx], lastAllocated[idx], locked)
+		if err == ErrRangeExhausted {
+			continue
+		}
+		if err != nil {
+			return nil, 0, netv1a1.SubnetStatus{}, fmt.Errorf("range %d (%s): %s", idx, ranges[idx].Subnet, err.Error())
+		}
+
+		lastAllocated[idx] = ip.String()
+		status := subnet.Status
+		status.LastAllocatedIPs = lastAllocated
+		status.NextRangeIndex = uint32((idx + 1) % len(ranges))
+
+		resultIdx := idx
+		if implicit {
+			resultIdx = -1
+		}
+		return ip, resultIdx, status, nil
+	}
+	return nil, 0, netv1a1.SubnetStatus{}, ErrRangeExhausted
+}
+
+// AllocateRequested attempts to grant the caller exactly requested,
+// instead of picking the next free address the way Allocate does. It
+// reports ErrRequestedAddressUnavailable if requested lies outside every
+// one of subnet's ranges (or, when subnet.Spec.Ranges is empty, outside
+// the implicit top-level IPv4 range), is a range's Gateway, is covered by
+// a range's Exclude, or is already locked (keyed by net.IP.String(), as
+// with Allocate). On success, it returns the index into
+// subnet.Spec.Ranges the address belongs to (-1 for the implicit range)
+// and the SubnetStatus the caller should persist, exactly as Allocate
+// does.
+func AllocateRequested(subnet *netv1a1.Subnet, requested net.IP, locked map[string]bool) (int, netv1a1.SubnetStatus, error) {
+	ranges := subnet.Spec.Ranges
+	implicit := len(ranges) == 0
+	if implicit {
+		ranges = []netv1a1.IPRange{{Subnet: subnet.Spec.IPv4}}
+	}
+
+	lastAllocated := make([]string, len(ranges))
+	copy(lastAllocated, subnet.Status.LastAllocatedIPs)
+
+	for idx, r := range ranges {
+		ok, err := rangeContains(r, requested)
+		if err != nil {
+			return 0, netv1a1.SubnetStatus{}, fmt.Errorf("range %d (%s): %s", idx, r.Subnet, err.Error())
+		}
+		if !ok {
+			continue
+		}
+		if r.Gateway != "" && net.ParseIP(r.Gateway).Equal(requested) {
+			return 0, netv1a1.SubnetStatus{}, ErrRequestedAddressUnavailable
+		}
+		excludes, err := parseExcludes(r.Exclude)
+		if err != nil {
+			return 0, netv1a1.SubnetStatus{}, fmt.Errorf("range %d (%s): %s", idx, r.Subnet, err.Error())
+		}
+		if excludesContain(excludes, requested) || locked[requested.String()] {
+			return 0, netv1a1.SubnetStatus{}, ErrRequestedAddressUnavailable
+		}
+
+		lastAllocated[idx] = requested.String()
+		status := subnet.Status
+		status.LastAllocatedIPs = lastAllocated
+
+		resultIdx := idx
+		if implicit {
+			resultIdx = -1
+		}
+		return resultIdx, status, nil
+	}
+	return 0, netv1a1.SubnetStatus{}, ErrRequestedAddressUnavailable
+}
+
+// ValidateRequestedAddress reports whether requested lies inside at
+// least one of subnet's ranges (or, when subnet.Spec.Ranges is empty,
+// the implicit top-level IPv4 range), independent of whether it is
+// already locked. It is meant for admission-time validation of a
+// NetworkAttachment's Spec.RequestedIPv4/RequestedIPv6, which can only
+// check range membership; only AllocateRequested, run at IPAM time with
+// the set of currently locked addresses, can tell whether requested is
+// actually free.
+func ValidateRequestedAddress(subnet *netv1a1.Subnet, requested net.IP) error {
+	ranges := subnet.Spec.Ranges
+	if len(ranges) == 0 {
+		ranges = []netv1a1.IPRange{{Subnet: subnet.Spec.IPv4}}
+	}
+	for _, r := range ranges {
+		ok, err := rangeContains(r, requested)
+		if err != nil {
+			continue
+		}
+		if ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s lies outside every range of subnet %s", requested, subnet.Name)
+}
+
+// rangeContains reports whether ip falls within r's usable address span:
+// inside r.Subnet, and between r.RangeStart and r.RangeEnd (defaulted as
+// rangeBoundInt describes).
+func rangeContains(r netv1a1.IPRange, ip net.IP) (bool, error) {
+	_, ipnet, err := net.ParseCIDR(r.Subnet)
+	if err != nil {
+		return false, fmt.Errorf("invalid CIDR %q: %s", r.Subnet, err.Error())
+	}
+	if !ipnet.Contains(ip) {
+		return false, nil
+	}
+	startInt, err := rangeBoundInt(r.RangeStart, ipnet, false)
+	if err != nil {
+		return false, err
+	}
+	endInt, err := rangeBoundInt(r.RangeEnd, ipnet, true)
+	if err != nil {
+		return false, err
+	}
+	reqInt := ipToInt(ip)
+	return reqInt.Cmp(startInt) >= 0 && reqInt.Cmp(endInt) <= 0, nil
+}
+
+// nextInRange returns the next unallocated address in r after last (or at
+// r's RangeStart, if last is empty), wrapping around to RangeStart once
+// RangeEnd is passed, skipping r.Gateway, any address covered by one of
+// r.Exclude, and any address in locked.
+func nextInRange(r netv1a1.IPRange, last string, locked map[string]bool) (net.IP, error) {
+	_, ipnet, err := net.ParseCIDR(r.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %s", r.Subnet, err.Error())
+	}
+	isV4 := ipnet.IP.To4() != nil
+
+	startInt, err := rangeBoundInt(r.RangeStart, ipnet, false)
+	if err != nil {
+		return nil, err
+	}
+	endInt, err := rangeBoundInt(r.RangeEnd, ipnet, true)
+	if err != nil {
+		return nil, err
+	}
+	total := new(big.Int).Add(new(big.Int).Sub(endInt, startInt), big.NewInt(1))
+	if total.Sign() <= 0 {
+		return nil, fmt.Errorf("no usable addresses between RangeStart and RangeEnd")
+	}
+
+	excludes, err := parseExcludes(r.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	var gateway net.IP
+	if r.Gateway != "" {
+		gateway = net.ParseIP(r.Gateway)
+	}
+
+	cur := new(big.Int).Set(startInt)
+	if last != "" {
+		if lastIP := net.ParseIP(last); lastIP != nil {
+			cur = new(big.Int).Add(ipToInt(lastIP), big.NewInt(1))
+		}
+	}
+
+	one := big.NewInt(1)
+	for tries := big.NewInt(0); tries.Cmp(total) < 0; tries.Add(tries, one) {
+		offset := new(big.Int).Mod(new(big.Int).Sub(cur, startInt), total)
+		candidate := new(big.Int).Add(startInt, offset)
+		ip := intToIP(candidate, isV4)
+		if (gateway == nil || !ip.Equal(gateway)) && !excludesContain(excludes, ip) && !locked[ip.String()] {
+			return ip, nil
+		}
+		cur.Add(cur, one)
+	}
+	return nil, ErrRangeExhausted
+}
+
+func parseExcludes(exclude []string) ([]*net.IPNet, error) {
+	excludes := make([]*net.IPNet, 0, len(exclude))
+	for _, e := range exclude {
+		_, exNet, err := net.ParseCIDR(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude CIDR %q: %s", e, err.Error())
+		}
+		excludes = append(excludes, exNet)
+	}
+	return excludes, nil
+}
+
+func excludesContain(excludes []*net.IPNet, ip net.IP) bool {
+	for _, ex := range excludes {
+		if ex.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeBoundInt returns explicit, parsed and converted to a big.Int, or
+// (for an empty explicit) ipnet's default lower bound (its network address
+// plus one) or upper bound (its last address, minus one for IPv4 to skip
+// the broadcast address), according to end.
+func rangeBoundInt(explicit string, ipnet *net.IPNet, end bool) (*big.Int, error) {
+	if explicit != "" {
+		ip := net.ParseIP(explicit)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid address %q", explicit)
+		}
+		return ipToInt(ip), nil
+	}
+
+	isV4 := ipnet.IP.To4() != nil
+	network := ipToInt(ipnet.IP.Mask(ipnet.Mask))
+	ones, bits := ipnet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last := new(big.Int).Add(network, new(big.Int).Sub(size, big.NewInt(1)))
+	if !end {
+		return new(big.Int).Add(network, big.NewInt(1)), nil
+	}
+	if isV4 {
+		last.Sub(last, big.NewInt(1))
+	}
+	return last, nil
+}
+
+func ipToInt(ip net.IP) *big.Int {
+	if v4 := ip.To4(); v4 != nil {
+		return new(big.Int).SetBytes(v4)
+	}
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+func intToIP(i *big.Int, v4 bool) net.IP {
+	size := 16
+	if v4 {
+		size = 4
+	}
+	buf := make([]byte, size)
+	b := i.Bytes()
+	copy(buf[size-len(b):], b)
+	return net.IP(buf)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"net"
+	"testing"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+func TestAllocateImplicitRange(t *testing.T) {
+	subnet := &netv1a1.Subnet{Spec: netv1a1.SubnetSpec{IPv4: "192.168.10.0/24"}}
+	ip, idx, status, err := Allocate(subnet, nil)
+	if err != nil {
+		t.Fatalf("Allocate failed: %s", err.Error())
+	}
+	if idx != -1 {
+		t.Errorf("got range index %d, want -1 for the implicit range", idx)
+	}
+	if ip.String() != "192.168.10.1" {
+		t.Errorf("got address %s, want 192.168.10.1", ip)
+	}
+	if len(status.LastAllocatedIPs) != 1 || status.LastAllocatedIPs[0] != ip.String() {
+		t.Errorf("got LastAllocatedIPs %v, want [%s]", status.LastAllocatedIPs, ip)
+	}
+}
+
+func TestAllocateSkipsGatewayExcludeAndLocked(t *testing.T) {
+	subnet := &netv1a1.Subnet{Spec: netv1a1.SubnetSpec{Ranges: []netv1a1.IPRange{{
+		Subnet:     "192.168.10.0/30",
+		RangeStart: "192.168.10.1",
+		RangeEnd:   "192.168.10.2",
+		Gateway:    "192.168.10.1",
+	}}}}
+	locked := map[string]bool{}
+	ip, idx, status, err := Allocate(subnet, locked)
+	if err != nil {
+		t.Fatalf("Allocate failed: %s", err.Error())
+	}
+	if idx != 0 {
+		t.Errorf("got range index %d, want 0", idx)
+	}
+	if ip.String() != "192.168.10.2" {
+		t.Errorf("got address %s, want 192.168.10.2 (gateway should be skipped)", ip)
+	}
+
+	subnet.Status = status
+	locked[ip.String()] = true
+	if _, _, _, err := Allocate(subnet, locked); err != ErrRangeExhausted {
+		t.Errorf("got error %v, want ErrRangeExhausted once the only non-gateway address is locked", err)
+	}
+}
+
+func TestAllocateRoundRobinsAcrossRanges(t *testing.T) {
+	subnet := &netv1a1.Subnet{Spec: netv1a1.SubnetSpec{Ranges: []netv1a1.IPRange{
+		{Subnet: "192.168.10.0/24", RangeStart: "192.168.10.1", RangeEnd: "192.168.10.1"},
+		{Subnet: "192.168.11.0/24", RangeStart: "192.168.11.1", RangeEnd: "192.168.11.1"},
+	}}}
+
+	ip1, idx1, status, err := Allocate(subnet, nil)
+	if err != nil {
+		t.Fatalf("first Allocate failed: %s", err.Error())
+	}
+	subnet.Status = status
+	ip2, idx2, _, err := Allocate(subnet, nil)
+	if err != nil {
+		t.Fatalf("second Allocate failed: %s", err.Error())
+	}
+
+	if idx1 == idx2 {
+		t.Errorf("got the same range index %d twice in a row, want round-robin across the two ranges", idx1)
+	}
+	if ip1.String() == ip2.String() {
+		t.Errorf("got the same address %s twice", ip1)
+	}
+}
+
+func TestAllocateRequestedGrantsInRangeAddress(t *testing.T) {
+	subnet := &netv1a1.Subnet{Spec: netv1a1.SubnetSpec{Ranges: []netv1a1.IPRange{{
+		Subnet:     "192.168.10.0/24",
+		RangeStart: "192.168.10.1",
+		RangeEnd:   "192.168.10.10",
+		Gateway:    "192.168.10.1",
+	}}}}
+	idx, status, err := AllocateRequested(subnet, net.ParseIP("192.168.10.5"), nil)
+	if err != nil {
+		t.Fatalf("AllocateRequested failed: %s", err.Error())
+	}
+	if idx != 0 {
+		t.Errorf("got range index %d, want 0", idx)
+	}
+	if len(status.LastAllocatedIPs) != 1 || status.LastAllocatedIPs[0] != "192.168.10.5" {
+		t.Errorf("got LastAllocatedIPs %v, want [192.168.10.5]", status.LastAllocatedIPs)
+	}
+}
+
+func TestAllocateRequestedRejectsGatewayOutOfRangeAndLocked(t *testing.T) {
+	subnet := &netv1a1.Subnet{Spec: netv1a1.SubnetSpec{Ranges: []netv1a1.IPRange{{
+		Subnet:     "192.168.10.0/24",
+		RangeStart: "192.168.10.1",
+		RangeEnd:   "192.168.10.10",
+		Gateway:    "192.168.10.1",
+	}}}}
+
+	if _, _, err := AllocateRequested(subnet, net.ParseIP("192.168.10.1"), nil); err != ErrRequestedAddressUnavailable {
+		t.Errorf("got error %v, want ErrRequestedAddressUnavailable for the gateway address", err)
+	}
+	if _, _, err := AllocateRequested(subnet, net.ParseIP("192.168.10.20"), nil); err != ErrRequestedAddressUnavailable {
+		t.Errorf("got error %v, want ErrRequestedAddressUnavailable for an address outside RangeStart/RangeEnd", err)
+	}
+	locked := map[string]bool{"192.168.10.5": true}
+	if _, _, err := AllocateRequested(subnet, net.ParseIP("192.168.10.5"), locked); err != ErrRequestedAddressUnavailable {
+		t.Errorf("got error %v, want ErrRequestedAddressUnavailable for an already-locked address", err)
+	}
+}
+
+func TestValidateRequestedAddress(t *testing.T) {
+	subnet := &netv1a1.Subnet{Spec: netv1a1.SubnetSpec{IPv4: "192.168.10.0/24"}}
+	if err := ValidateRequestedAddress(subnet, net.ParseIP("192.168.10.5")); err != nil {
+		t.Errorf("got error %v for an in-range address, want nil", err)
+	}
+	if err := ValidateRequestedAddress(subnet, net.ParseIP("10.0.0.5")); err == nil {
+		t.Errorf("got nil error for an out-of-range address, want one")
+	}
+}
+
+func TestAllocateWrapsAroundRangeEnd(t *testing.T) {
+	subnet := &netv1a1.Subnet{Spec: netv1a1.SubnetSpec{Ranges: []netv1a1.IPRange{{
+		Subnet:     "192.168.10.0/24",
+		RangeStart: "192.168.10.1",
+		RangeEnd:   "192.168.10.2",
+	}}},
+		Status: netv1a1.SubnetStatus{LastAllocatedIPs: []string{"192.168.10.2"}},
+	}
+	ip, _, _, err := Allocate(subnet, nil)
+	if err != nil {
+		t.Fatalf("Allocate failed: %s", err.Error())
+	}
+	if ip.String() != "192.168.10.1" {
+		t.Errorf("got address %s, want 192.168.10.1 after wrapping past RangeEnd", ip)
+	}
+}
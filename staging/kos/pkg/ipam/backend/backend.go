@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// Backend claims and releases addresses from a Subnet on behalf of
+// whatever creates NetworkAttachments. Implementations that need to
+// persist bookkeeping in Subnet.Status (as the iplock backend does, for
+// LastAllocatedIPs/NextRangeIndex) mutate subnet.Status in place; a
+// caller holding a live Subnet object (e.g. from an informer) is
+// responsible for writing the change back to the apiserver the same as
+// any other status update.
+type Backend interface {
+	// Allocate claims and returns the next free address in subnet, the
+	// way pkg/ipam.Allocate picks one, round-robining across
+	// Spec.Ranges. It returns pkg/ipam.ErrRangeExhausted when every
+	// address is already claimed.
+	Allocate(subnet *netv1a1.Subnet) (net.IP, error)
+
+	// Reserve claims ip specifically, the way pkg/ipam.AllocateRequested
+	// grants a caller-chosen address. It returns
+	// pkg/ipam.ErrRequestedAddressUnavailable if ip is outside subnet's
+	// ranges or already claimed.
+	Reserve(subnet *netv1a1.Subnet, ip net.IP) error
+
+	// Release gives up a previously-claimed ip, making it available to
+	// a later Allocate/Reserve. Releasing an address that was never
+	// claimed is not an error.
+	Release(subnet *netv1a1.Subnet, ip net.IP) error
+
+	// List returns every address currently claimed against subnet.
+	List(subnet *netv1a1.Subnet) ([]net.IP, error)
+}
+
+// Factory constructs a ready-to-use Backend from config, the raw bytes of
+// a Subnet's Spec.IPAM.Config; each Factory defines its own shape for
+// config and validates it at construction time.
+type Factory func(config []byte) (Backend, error)
+
+var (
+	factoriesMutex sync.Mutex
+	factories      = make(map[string]Factory)
+)
+
+// RegisterBackendFactory makes a Factory available under name to later
+// callers of NewBackend. It is meant to be called from the init function
+// of a package implementing Backend (as hostlocal.go and delegate.go do
+// in this package), so that merely importing that package for its side
+// effect is enough to opt into it. RegisterBackendFactory panics if name
+// is already registered, since that indicates two backends claiming the
+// same identity.
+func RegisterBackendFactory(name string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	if _, already := factories[name]; already {
+		panic(fmt.Sprintf("an IPAM backend factory is already registered under name %q", name))
+	}
+	factories[name] = factory
+}
+
+// NewBackend looks up the Factory registered under ipam.Name and invokes
+// it with ipam.Config. DefaultBackendName ("iplock") is not registered
+// this way, since it needs a live clientset and lister that a Factory's
+// []byte config cannot carry; a Subnet with a nil Spec.IPAM, or
+// Spec.IPAM.Name == DefaultBackendName, should be driven through
+// NewIPLockBackend directly instead.
+func NewBackend(ipam *netv1a1.SubnetIPAM) (Backend, error) {
+	if ipam == nil || ipam.Name == "" || ipam.Name == DefaultBackendName {
+		return nil, fmt.Errorf("the %q backend is constructed via NewIPLockBackend, not NewBackend", DefaultBackendName)
+	}
+	factoriesMutex.Lock()
+	factory, found := factories[ipam.Name]
+	factoriesMutex.Unlock()
+	if !found {
+		return nil, fmt.Errorf("no IPAM backend factory registered under name %q", ipam.Name)
+	}
+	return factory([]byte(ipam.Config))
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// delegateBackendName is the Factory name a Subnet's Spec.IPAM.Name
+// selects to use delegateBackend.
+const delegateBackendName = "delegate"
+
+func init() {
+	RegisterBackendFactory(delegateBackendName, newDelegateBackend)
+}
+
+// delegateConfig is delegateBackendName's Spec.IPAM.Config shape: enough
+// to synthesize the NetConf a standalone CNI IPAM plugin binary (e.g.
+// host-local, dhcp, static) expects on its stdin.
+type delegateConfig struct {
+	// Type is the IPAM plugin binary's name, found the same way a CNI
+	// runtime finds it: on one of Paths.
+	Type string `json:"type"`
+
+	// Paths lists the directories searched for Type, tried in order.
+	// +optional
+	Paths []string `json:"paths,omitempty"`
+
+	// CNIVersion is the cniVersion reported to the delegate plugin.
+	// Defaults to "1.0.0".
+	// +optional
+	CNIVersion string `json:"cniVersion,omitempty"`
+
+	// PluginConfig is merged verbatim into the synthesized NetConf's
+	// "ipam" section, alongside Type -- e.g. a dhcp plugin's "daemonSocketPath".
+	// +optional
+	PluginConfig json.RawMessage `json:"pluginConfig,omitempty"`
+
+	// DataDir is where this adapter remembers, per claimed address, the
+	// CNI_CONTAINERID it invoked the delegate plugin's ADD with -- the
+	// delegate plugin's own DEL needs that same identity to find the
+	// reservation it made, and Backend.Release is only given the
+	// address, not the identity. Defaults to a kos-ipam-delegate
+	// directory under os.TempDir().
+	// +optional
+	DataDir string `json:"dataDir,omitempty"`
+}
+
+func newDelegateBackend(config []byte) (Backend, error) {
+	var cfg delegateConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s IPAM config: %s", delegateBackendName, err.Error())
+	}
+	if cfg.Type == "" {
+		return nil, fmt.Errorf("%s IPAM config is missing type", delegateBackendName)
+	}
+	if cfg.CNIVersion == "" {
+		cfg.CNIVersion = "1.0.0"
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = filepath.Join(os.TempDir(), "kos-ipam-delegate")
+	}
+	path, err := findPlugin(cfg.Type, cfg.Paths)
+	if err != nil {
+		return nil, err
+	}
+	return &delegateBackend{path: path, cfg: cfg}, nil
+}
+
+// findPlugin locates name on paths (falling back to $CNI_PATH, the
+// environment variable a CNI runtime conventionally sets) the way a CNI
+// runtime finds a delegate plugin binary.
+func findPlugin(name string, paths []string) (string, error) {
+	if len(paths) == 0 {
+		if cniPath := os.Getenv("CNI_PATH"); cniPath != "" {
+			paths = filepath.SplitList(cniPath)
+		}
+	}
+	for _, dir := range paths {
+		p := filepath.Join(dir, name)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%s IPAM plugin %q not found on %v", delegateBackendName, name, paths)
+}
+
+// delegateBackend adapts a standalone CNI IPAM plugin binary to Backend.
+// It tracks which CNI_CONTAINERID it invoked the delegate with for each
+// address it claimed, in a small JSON file under cfg.DataDir, purely so
+// Release can pass the delegate plugin the identity its own DEL needs to
+// find the reservation ADD made; Backend itself has no notion of a
+// container for this adapter to reuse.
+type delegateBackend struct {
+	path string
+	cfg  delegateConfig
+}
+
+// delegateClaims is the on-disk shape of one Subnet's address-to-identity
+// mapping, keyed by net.IP.String().
+type delegateClaims map[string]string
+
+func (b *delegateBackend) claimsPath(subnetName string) string {
+	return filepath.Join(b.cfg.DataDir, subnetName+".json")
+}
+
+func (b *delegateBackend) readClaims(subnetName string) (delegateClaims, error) {
+	claims := delegateClaims{}
+	data, err := ioutil.ReadFile(b.claimsPath(subnetName))
+	if os.IsNotExist(err) {
+		return claims, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s IPAM claims for Subnet %s: %s", delegateBackendName, subnetName, err.Error())
+	}
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse %s IPAM claims for Subnet %s: %s", delegateBackendName, subnetName, err.Error())
+	}
+	return claims, nil
+}
+
+func (b *delegateBackend) writeClaims(subnetName string, claims delegateClaims) error {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s IPAM claims for Subnet %s: %s", delegateBackendName, subnetName, err.Error())
+	}
+	if err := os.MkdirAll(b.cfg.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s IPAM data dir %s: %s", delegateBackendName, b.cfg.DataDir, err.Error())
+	}
+	if err := ioutil.WriteFile(b.claimsPath(subnetName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s IPAM claims for Subnet %s: %s", delegateBackendName, subnetName, err.Error())
+	}
+	return nil
+}
+
+// delegateNetConf is the stdin payload sent to the delegate plugin,
+// mirroring the "ipam"-section convention CNI meta-plugins use to pass a
+// sub-plugin its configuration.
+type delegateNetConf struct {
+	CNIVersion string          `json:"cniVersion"`
+	Name       string          `json:"name"`
+	IPAM       json.RawMessage `json:"ipam"`
+}
+
+// delegateResult is the subset of a CNI Result this adapter reads back
+// from the delegate plugin's ADD.
+type delegateResult struct {
+	IPs []struct {
+		Address string `json:"address"`
+	} `json:"ips"`
+}
+
+func (b *delegateBackend) Allocate(subnet *netv1a1.Subnet) (net.IP, error) {
+	claims, err := b.readClaims(subnet.Name)
+	if err != nil {
+		return nil, err
+	}
+	containerID := delegateContainerID(subnet.Name, len(claims))
+	result, err := b.run("ADD", subnet, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.IPs) == 0 {
+		return nil, fmt.Errorf("%s IPAM plugin %q returned no addresses", delegateBackendName, b.cfg.Type)
+	}
+	ip, _, err := net.ParseCIDR(result.IPs[0].Address)
+	if err != nil {
+		ip = net.ParseIP(result.IPs[0].Address)
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("%s IPAM plugin %q returned unparseable address %q", delegateBackendName, b.cfg.Type, result.IPs[0].Address)
+	}
+	claims[ip.String()] = containerID
+	if err := b.writeClaims(subnet.Name, claims); err != nil {
+		return nil, err
+	}
+	return ip, nil
+}
+
+func (b *delegateBackend) Reserve(subnet *netv1a1.Subnet, ip net.IP) error {
+	// The CNI IPAM plugin contract has no "claim this specific address"
+	// verb; host-local, dhcp and static all pick their own. A delegate
+	// can only be asked to allocate, not steer to ip, so this is
+	// reported rather than silently handing back a different address.
+	return fmt.Errorf("%s IPAM backend does not support reserving a specific address", delegateBackendName)
+}
+
+func (b *delegateBackend) Release(subnet *netv1a1.Subnet, ip net.IP) error {
+	claims, err := b.readClaims(subnet.Name)
+	if err != nil {
+		return err
+	}
+	containerID, ok := claims[ip.String()]
+	if !ok {
+		// Never claimed through this adapter (or already released);
+		// nothing to tell the delegate plugin.
+		return nil
+	}
+	if _, err := b.run("DEL", subnet, containerID); err != nil {
+		return err
+	}
+	delete(claims, ip.String())
+	return b.writeClaims(subnet.Name, claims)
+}
+
+func (b *delegateBackend) List(subnet *netv1a1.Subnet) ([]net.IP, error) {
+	return nil, fmt.Errorf("%s IPAM backend does not support listing claimed addresses", delegateBackendName)
+}
+
+// run execs the delegate plugin for cniCommand against subnet, returning
+// its parsed Result (only populated for ADD).
+func (b *delegateBackend) run(cniCommand string, subnet *netv1a1.Subnet, containerID string) (*delegateResult, error) {
+	ipamSection := map[string]interface{}{"type": b.cfg.Type}
+	if len(b.cfg.PluginConfig) > 0 {
+		if err := json.Unmarshal(b.cfg.PluginConfig, &ipamSection); err != nil {
+			return nil, fmt.Errorf("invalid %s IPAM pluginConfig: %s", delegateBackendName, err.Error())
+		}
+		ipamSection["type"] = b.cfg.Type
+	}
+	ipamJSON, err := json.Marshal(ipamSection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s IPAM config: %s", delegateBackendName, err.Error())
+	}
+	stdin, err := json.Marshal(delegateNetConf{
+		CNIVersion: b.cfg.CNIVersion,
+		Name:       subnet.Name,
+		IPAM:       ipamJSON,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s IPAM netconf: %s", delegateBackendName, err.Error())
+	}
+
+	cmd := exec.Command(b.path)
+	cmd.Env = append(os.Environ(),
+		"CNI_COMMAND="+cniCommand,
+		"CNI_CONTAINERID="+containerID,
+		"CNI_NETNS=",
+		"CNI_IFNAME=eth0",
+		"CNI_PATH="+os.Getenv("CNI_PATH"),
+	)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s IPAM plugin %q %s failed: %s: %s", delegateBackendName, b.cfg.Type, cniCommand, err.Error(), stderr.String())
+	}
+
+	result := &delegateResult{}
+	if stdout.Len() > 0 {
+		if err := json.Unmarshal(stdout.Bytes(), result); err != nil {
+			return nil, fmt.Errorf("failed to parse %s IPAM plugin %q %s result: %s", delegateBackendName, b.cfg.Type, cniCommand, err.Error())
+		}
+	}
+	return result, nil
+}
+
+// delegateContainerID synthesizes the CNI_CONTAINERID an Allocate
+// invocation uses to key the delegate plugin's own on-disk state, since
+// Backend has no container identity of its own to pass through. seq
+// (the claims already on file for this Subnet) keeps concurrent
+// Allocates against the same Subnet from colliding on the same identity.
+func delegateContainerID(subnetName string, seq int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", subnetName, seq)))
+	return fmt.Sprintf("%x", sum)[:32]
+}
+
@@ -0,0 +1,42 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package backend lets a Subnet pick how its addresses are actually
+// claimed and released, instead of the choice being implicit in whatever
+// code happens to call pkg/ipam. Backend is the seam: an implementation
+// wraps pkg/ipam's pure allocation logic (or does something else
+// entirely) behind Allocate/Reserve/Release/List, and a Subnet's
+// Spec.IPAM names which registered Backend it uses.
+//
+// Three implementations ship in this package:
+//
+//   - iplock (DefaultBackendName), the original scheme every Subnet used
+//     before Spec.IPAM existed: one IPLock per claimed address, created
+//     and deleted through the apiserver. It needs a live clientset and
+//     lister, so it is constructed directly with NewIPLockBackend rather
+//     than selected through the Factory registry NewBackend uses.
+//   - host-local, a file/JSON backend with no apiserver dependency at
+//     all, for single-node dev clusters; see hostlocal.go.
+//   - delegate, an adapter to any CNI IPAM plugin binary (host-local,
+//     dhcp, static, ...) found on PATH; see delegate.go.
+//
+// As with pkg/ipam itself, nothing in this tree yet drives a Backend from
+// a running controller: the NetworkAttachment/Subnet controllers still
+// have no allocation path of their own (see pkg/ipam's doc comment). This
+// package exists so that when one is added, the choice of backend is
+// already a Subnet-level knob instead of a hard-coded call to an IPLock
+// constructor.
+package backend // import "k8s.io/examples/staging/kos/pkg/ipam/backend"
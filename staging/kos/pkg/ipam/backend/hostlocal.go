@@ -0,0 +1,282 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/examples/staging/kos/pkg/ipam"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// hostLocalBackendName is the Factory name a Subnet's Spec.IPAM.Name
+// selects to use hostLocalBackend.
+const hostLocalBackendName = "host-local"
+
+func init() {
+	RegisterBackendFactory(hostLocalBackendName, newHostLocalBackend)
+}
+
+// hostLocalConfig is hostLocalBackendName's Spec.IPAM.Config shape.
+type hostLocalConfig struct {
+	// DataDir is the directory one JSON file per Subnet is kept in.
+	DataDir string `json:"dataDir"`
+
+	// LeaseTTL, if non-empty, is a Go duration string; a claimed address
+	// whose lease has stood for longer than this is treated as free by
+	// a later Allocate/Reserve/List, on the assumption that whatever
+	// claimed it is gone and never called Release. Empty means leases
+	// never expire.
+	// +optional
+	LeaseTTL string `json:"leaseTTL,omitempty"`
+}
+
+func newHostLocalBackend(config []byte) (Backend, error) {
+	var cfg hostLocalConfig
+	if err := json.Unmarshal(config, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s IPAM config: %s", hostLocalBackendName, err.Error())
+	}
+	if cfg.DataDir == "" {
+		return nil, fmt.Errorf("%s IPAM config is missing dataDir", hostLocalBackendName)
+	}
+	var ttl time.Duration
+	if cfg.LeaseTTL != "" {
+		var err error
+		ttl, err = time.ParseDuration(cfg.LeaseTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s IPAM leaseTTL %q: %s", hostLocalBackendName, cfg.LeaseTTL, err.Error())
+		}
+	}
+	return &hostLocalBackend{dataDir: cfg.DataDir, leaseTTL: ttl}, nil
+}
+
+// hostLocalBackend is a Backend for single-node dev clusters with no
+// apiserver-backed coordination at all: it persists one JSON file per
+// Subnet under dataDir, in the style of the CNI host-local IPAM plugin's
+// on-disk store, rather than an IPLock per address. A lock file next to
+// the store (see withStoreLocked) serializes the processes on one host
+// that share dataDir; it provides no coordination across hosts, which is
+// the tradeoff that makes this backend dependency-free.
+type hostLocalBackend struct {
+	dataDir  string
+	leaseTTL time.Duration // zero means leases never expire
+}
+
+// hostLocalStore is the on-disk shape of one Subnet's claimed addresses.
+type hostLocalStore struct {
+	// LastReservedIP records, per index into Spec.Ranges (or index 0 for
+	// the implicit single-range Subnet), the last address handed out
+	// from that range -- this backend's own equivalent of
+	// SubnetStatus.LastAllocatedIPs, kept here instead of in the
+	// Subnet's Status since this backend does not talk to the
+	// apiserver at all.
+	LastReservedIP []string `json:"lastReservedIP,omitempty"`
+
+	// Leases holds every currently-claimed address, keyed by
+	// net.IP.String().
+	Leases map[string]hostLocalLease `json:"leases,omitempty"`
+}
+
+// hostLocalLease is one claimed address's bookkeeping.
+type hostLocalLease struct {
+	ClaimedAt time.Time `json:"claimedAt"`
+}
+
+func (b *hostLocalBackend) Allocate(subnet *netv1a1.Subnet) (net.IP, error) {
+	var claimed net.IP
+	err := b.withStoreLocked(subnet.Name, func(store *hostLocalStore) (bool, error) {
+		fakeStatus := netv1a1.SubnetStatus{
+			LastAllocatedIPs: store.LastReservedIP,
+		}
+		fakeSubnet := *subnet
+		fakeSubnet.Status = fakeStatus
+		ip, rangeIndex, status, err := ipam.Allocate(&fakeSubnet, b.locked(store))
+		if err != nil {
+			return false, err
+		}
+		b.claim(store, rangeIndex, status, ip)
+		claimed = ip
+		return true, nil
+	})
+	return claimed, err
+}
+
+func (b *hostLocalBackend) Reserve(subnet *netv1a1.Subnet, ip net.IP) error {
+	return b.withStoreLocked(subnet.Name, func(store *hostLocalStore) (bool, error) {
+		fakeStatus := netv1a1.SubnetStatus{
+			LastAllocatedIPs: store.LastReservedIP,
+		}
+		fakeSubnet := *subnet
+		fakeSubnet.Status = fakeStatus
+		rangeIndex, status, err := ipam.AllocateRequested(&fakeSubnet, ip, b.locked(store))
+		if err != nil {
+			return false, err
+		}
+		b.claim(store, rangeIndex, status, ip)
+		return true, nil
+	})
+}
+
+func (b *hostLocalBackend) Release(subnet *netv1a1.Subnet, ip net.IP) error {
+	return b.withStoreLocked(subnet.Name, func(store *hostLocalStore) (bool, error) {
+		if _, ok := store.Leases[ip.String()]; !ok {
+			return false, nil
+		}
+		delete(store.Leases, ip.String())
+		return true, nil
+	})
+}
+
+func (b *hostLocalBackend) List(subnet *netv1a1.Subnet) ([]net.IP, error) {
+	store, err := b.readStore(subnet.Name)
+	if err != nil {
+		return nil, err
+	}
+	locked := b.locked(store)
+	ips := make([]net.IP, 0, len(locked))
+	for s := range locked {
+		if ip := net.ParseIP(s); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// locked reports store's unexpired leases, in the form
+// pkg/ipam.Allocate/AllocateRequested expect.
+func (b *hostLocalBackend) locked(store *hostLocalStore) map[string]bool {
+	locked := make(map[string]bool, len(store.Leases))
+	for addr, lease := range store.Leases {
+		if b.leaseTTL > 0 && time.Since(lease.ClaimedAt) > b.leaseTTL {
+			continue
+		}
+		locked[addr] = true
+	}
+	return locked
+}
+
+// claim records ip as leased and persists the range bookkeeping
+// pkg/ipam returned, growing LastReservedIP to fit rangeIndex if needed.
+func (b *hostLocalBackend) claim(store *hostLocalStore, rangeIndex int, status netv1a1.SubnetStatus, ip net.IP) {
+	if store.Leases == nil {
+		store.Leases = make(map[string]hostLocalLease)
+	}
+	store.Leases[ip.String()] = hostLocalLease{ClaimedAt: time.Now()}
+	store.LastReservedIP = status.LastAllocatedIPs
+}
+
+// storePath is the on-disk location of subnetName's store.
+func (b *hostLocalBackend) storePath(subnetName string) string {
+	return filepath.Join(b.dataDir, subnetName+".json")
+}
+
+// lockPath is storePath's companion lock file, held for the duration of a
+// withStoreLocked call.
+func (b *hostLocalBackend) lockPath(subnetName string) string {
+	return filepath.Join(b.dataDir, subnetName+".lock")
+}
+
+func (b *hostLocalBackend) readStore(subnetName string) (*hostLocalStore, error) {
+	store := &hostLocalStore{}
+	data, err := ioutil.ReadFile(b.storePath(subnetName))
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s IPAM store for Subnet %s: %s", hostLocalBackendName, subnetName, err.Error())
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse %s IPAM store for Subnet %s: %s", hostLocalBackendName, subnetName, err.Error())
+	}
+	return store, nil
+}
+
+func (b *hostLocalBackend) writeStore(subnetName string, store *hostLocalStore) error {
+	data, err := json.Marshal(store)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s IPAM store for Subnet %s: %s", hostLocalBackendName, subnetName, err.Error())
+	}
+	if err := os.MkdirAll(b.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s IPAM data dir %s: %s", hostLocalBackendName, b.dataDir, err.Error())
+	}
+	if err := ioutil.WriteFile(b.storePath(subnetName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s IPAM store for Subnet %s: %s", hostLocalBackendName, subnetName, err.Error())
+	}
+	return nil
+}
+
+// withStoreLocked reads subnetName's store, runs fn against it, and
+// writes it back if fn reports a change, all while holding an exclusive
+// lock file so concurrent callers against the same dataDir on one host
+// serialize instead of racing. The lock is advisory (a plain O_EXCL
+// create-and-remove, not flock(2)), which is enough for the single-node
+// dev use case this backend targets.
+func (b *hostLocalBackend) withStoreLocked(subnetName string, fn func(store *hostLocalStore) (changed bool, err error)) error {
+	if err := os.MkdirAll(b.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s IPAM data dir %s: %s", hostLocalBackendName, b.dataDir, err.Error())
+	}
+	lockPath := b.lockPath(subnetName)
+	lockFile, err := acquireLock(lockPath)
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lockFile, lockPath)
+
+	store, err := b.readStore(subnetName)
+	if err != nil {
+		return err
+	}
+	changed, err := fn(store)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	return b.writeStore(subnetName, store)
+}
+
+// acquireLock spins on an exclusive create of lockPath until it succeeds,
+// standing in for a real flock(2)-based lock without adding a dependency.
+func acquireLock(lockPath string) (*os.File, error) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			return f, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to acquire %s IPAM lock %s: %s", hostLocalBackendName, lockPath, err.Error())
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out acquiring %s IPAM lock %s", hostLocalBackendName, lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func releaseLock(lockFile *os.File, lockPath string) {
+	lockFile.Close()
+	os.Remove(lockPath)
+}
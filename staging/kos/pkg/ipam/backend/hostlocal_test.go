@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+)
+
+// newTestHostLocalBackend returns a hostLocalBackend backed by a fresh
+// temp dir; callers should `defer os.RemoveAll(b.dataDir)`.
+func newTestHostLocalBackend(t *testing.T) *hostLocalBackend {
+	dir, err := ioutil.TempDir("", "kos-hostlocal-test-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err.Error())
+	}
+	return &hostLocalBackend{dataDir: dir}
+}
+
+func TestHostLocalAllocateThenList(t *testing.T) {
+	b := newTestHostLocalBackend(t)
+	defer os.RemoveAll(b.dataDir)
+	subnet := &netv1a1.Subnet{ObjectMeta: k8smetav1.ObjectMeta{Name: "s1"}, Spec: netv1a1.SubnetSpec{IPv4: "192.168.10.0/24"}}
+
+	ip, err := b.Allocate(subnet)
+	if err != nil {
+		t.Fatalf("Allocate failed: %s", err.Error())
+	}
+	if ip.String() != "192.168.10.1" {
+		t.Errorf("got address %s, want 192.168.10.1", ip)
+	}
+
+	claimed, err := b.List(subnet)
+	if err != nil {
+		t.Fatalf("List failed: %s", err.Error())
+	}
+	if len(claimed) != 1 || !claimed[0].Equal(ip) {
+		t.Errorf("got List %v, want [%s]", claimed, ip)
+	}
+}
+
+func TestHostLocalAllocateAdvancesPastPriorClaims(t *testing.T) {
+	b := newTestHostLocalBackend(t)
+	defer os.RemoveAll(b.dataDir)
+	subnet := &netv1a1.Subnet{ObjectMeta: k8smetav1.ObjectMeta{Name: "s1"}, Spec: netv1a1.SubnetSpec{IPv4: "192.168.10.0/24"}}
+
+	first, err := b.Allocate(subnet)
+	if err != nil {
+		t.Fatalf("first Allocate failed: %s", err.Error())
+	}
+	second, err := b.Allocate(subnet)
+	if err != nil {
+		t.Fatalf("second Allocate failed: %s", err.Error())
+	}
+	if first.Equal(second) {
+		t.Errorf("second Allocate returned %s again instead of a new address", second)
+	}
+}
+
+func TestHostLocalReleaseFreesAddress(t *testing.T) {
+	b := newTestHostLocalBackend(t)
+	defer os.RemoveAll(b.dataDir)
+	subnet := &netv1a1.Subnet{ObjectMeta: k8smetav1.ObjectMeta{Name: "s1"}, Spec: netv1a1.SubnetSpec{
+		Ranges: []netv1a1.IPRange{{Subnet: "192.168.10.0/30", RangeStart: "192.168.10.1", RangeEnd: "192.168.10.1"}},
+	}}
+
+	ip, err := b.Allocate(subnet)
+	if err != nil {
+		t.Fatalf("Allocate failed: %s", err.Error())
+	}
+	if _, err := b.Allocate(subnet); err == nil {
+		t.Fatalf("second Allocate against a single-address range should have failed")
+	}
+	if err := b.Release(subnet, ip); err != nil {
+		t.Fatalf("Release failed: %s", err.Error())
+	}
+	if again, err := b.Allocate(subnet); err != nil || !again.Equal(ip) {
+		t.Errorf("Allocate after Release got (%v, %v), want (%s, nil)", again, err, ip)
+	}
+}
+
+func TestHostLocalReserveRejectsAlreadyClaimed(t *testing.T) {
+	b := newTestHostLocalBackend(t)
+	defer os.RemoveAll(b.dataDir)
+	subnet := &netv1a1.Subnet{ObjectMeta: k8smetav1.ObjectMeta{Name: "s1"}, Spec: netv1a1.SubnetSpec{IPv4: "192.168.10.0/24"}}
+
+	want := net.ParseIP("192.168.10.5")
+	if err := b.Reserve(subnet, want); err != nil {
+		t.Fatalf("Reserve failed: %s", err.Error())
+	}
+	if err := b.Reserve(subnet, want); err == nil {
+		t.Fatalf("Reserve of an already-claimed address should have failed")
+	}
+}
+
+func TestHostLocalLeaseExpires(t *testing.T) {
+	b := newTestHostLocalBackend(t)
+	defer os.RemoveAll(b.dataDir)
+	b.leaseTTL = time.Millisecond
+	subnet := &netv1a1.Subnet{ObjectMeta: k8smetav1.ObjectMeta{Name: "s1"}, Spec: netv1a1.SubnetSpec{
+		Ranges: []netv1a1.IPRange{{Subnet: "192.168.10.0/30", RangeStart: "192.168.10.1", RangeEnd: "192.168.10.1"}},
+	}}
+
+	if _, err := b.Allocate(subnet); err != nil {
+		t.Fatalf("Allocate failed: %s", err.Error())
+	}
+	time.Sleep(10 * time.Millisecond)
+	if _, err := b.Allocate(subnet); err != nil {
+		t.Errorf("Allocate after the lease expired should have reclaimed the address, got error: %s", err.Error())
+	}
+}
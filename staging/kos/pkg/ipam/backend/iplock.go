@@ -0,0 +1,183 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backend
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	koscsv1a1 "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/typed/network/v1alpha1"
+	koslisterv1a1 "k8s.io/examples/staging/kos/pkg/client/listers/network/v1alpha1"
+	"k8s.io/examples/staging/kos/pkg/ipam"
+)
+
+// DefaultBackendName is the Backend every Subnet without a Spec.IPAM
+// uses: the original etcd-backed scheme of one IPLock per claimed
+// address, unchanged from before Spec.IPAM existed.
+const DefaultBackendName = "iplock"
+
+// iPLockBackend is the default Backend: it wraps pkg/ipam's pure
+// Allocate/AllocateRequested, claiming the address either one picks by
+// creating an IPLock named after it, so a concurrent claim on the same
+// address fails the apiserver's usual create-time uniqueness check
+// instead of this package needing any locking of its own.
+type iPLockBackend struct {
+	namespace string
+	ifc       koscsv1a1.IPLockInterface
+	lister    koslisterv1a1.IPLockNamespaceLister
+}
+
+// NewIPLockBackend returns the default Backend for Subnets in namespace,
+// backed by ifc and lister rather than a package-level clientset, so
+// that callers without a live informer cache (e.g. tests) can supply
+// fakes the same way NewSubnetValidator does for NetworkAttachment
+// admission.
+func NewIPLockBackend(namespace string, ifc koscsv1a1.IPLockInterface, lister koslisterv1a1.IPLockNamespaceLister) Backend {
+	return &iPLockBackend{namespace: namespace, ifc: ifc, lister: lister}
+}
+
+func (b *iPLockBackend) Allocate(subnet *netv1a1.Subnet) (net.IP, error) {
+	locked, err := b.locked()
+	if err != nil {
+		return nil, err
+	}
+	ip, rangeIndex, status, err := ipam.Allocate(subnet, locked)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.claim(subnet, ip, rangeIndex); err != nil {
+		return nil, err
+	}
+	subnet.Status = status
+	return ip, nil
+}
+
+func (b *iPLockBackend) Reserve(subnet *netv1a1.Subnet, ip net.IP) error {
+	locked, err := b.locked()
+	if err != nil {
+		return err
+	}
+	rangeIndex, status, err := ipam.AllocateRequested(subnet, ip, locked)
+	if err != nil {
+		return err
+	}
+	if err := b.claim(subnet, ip, rangeIndex); err != nil {
+		return err
+	}
+	subnet.Status = status
+	return nil
+}
+
+func (b *iPLockBackend) Release(subnet *netv1a1.Subnet, ip net.IP) error {
+	err := b.ifc.Delete(ipLockName(ip), &k8smetav1.DeleteOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete IPLock for %s: %s", ip, err.Error())
+	}
+	return nil
+}
+
+func (b *iPLockBackend) List(subnet *netv1a1.Subnet) ([]net.IP, error) {
+	iPLocks, err := b.lister.BySubnetName(subnet.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IPLocks for Subnet %s: %s", subnet.Name, err.Error())
+	}
+	ips := make([]net.IP, 0, len(iPLocks))
+	for _, l := range iPLocks {
+		if ip := ipFromLockName(l.Name); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// locked lists every IPLock in b.namespace and returns the set of
+// addresses they claim, in the form pkg/ipam.Allocate/AllocateRequested
+// expect.
+func (b *iPLockBackend) locked() (map[string]bool, error) {
+	iPLocks, err := b.lister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IPLocks in %s: %s", b.namespace, err.Error())
+	}
+	locked := make(map[string]bool, len(iPLocks))
+	for _, l := range iPLocks {
+		if ip := ipFromLockName(l.Name); ip != nil {
+			locked[ip.String()] = true
+		}
+	}
+	return locked, nil
+}
+
+// claim creates the IPLock for ip, drawn from the range at rangeIndex of
+// subnet (-1 for the implicit single-range Subnet); AlreadyExists means a
+// rival claimed ip first.
+func (b *iPLockBackend) claim(subnet *netv1a1.Subnet, ip net.IP, rangeIndex int) error {
+	family := netv1a1.IPv4Family
+	if ip.To4() == nil {
+		family = netv1a1.IPv6Family
+	}
+	lock := &netv1a1.IPLock{
+		ObjectMeta: k8smetav1.ObjectMeta{
+			Namespace: b.namespace,
+			Name:      ipLockName(ip),
+		},
+		Spec: netv1a1.IPLockSpec{
+			SubnetName: subnet.Name,
+			Family:     family,
+		},
+	}
+	if rangeIndex >= 0 {
+		idx := int32(rangeIndex)
+		lock.Spec.RangeIndex = &idx
+	}
+	if _, err := b.ifc.Create(lock); err != nil {
+		if k8serrors.IsAlreadyExists(err) {
+			return fmt.Errorf("address %s is already claimed", ip)
+		}
+		return fmt.Errorf("failed to create IPLock for %s: %s", ip, err.Error())
+	}
+	return nil
+}
+
+// ipLockName derives the IPLock name claimed for ip: the name itself is
+// the mutual-exclusion key a concurrent claim collides on, while
+// Spec.SubnetName/Family/RangeIndex record enough context to make sense
+// of an existing lock without decoding its name. IPv6 colons are not
+// valid in a Kubernetes object name, so they are replaced with dashes;
+// IPv4's dotted-quad form needs no change.
+func ipLockName(ip net.IP) string {
+	return "ip-" + strings.ReplaceAll(ip.String(), ":", "-")
+}
+
+// ipFromLockName is ipLockName's inverse. It returns nil for a name that
+// was not produced by ipLockName (e.g. an IPLock created by some other
+// means), so callers can skip it rather than fail outright.
+func ipFromLockName(name string) net.IP {
+	rest := strings.TrimPrefix(name, "ip-")
+	if rest == name {
+		return nil
+	}
+	if ip := net.ParseIP(rest); ip != nil {
+		return ip
+	}
+	return net.ParseIP(strings.ReplaceAll(rest, "-", ":"))
+}
@@ -0,0 +1,38 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ipam implements the address-picking half of Subnet-scoped IPAM:
+// given a Subnet and the set of addresses currently locked against it,
+// Allocate returns the next address to hand out, round-robining across
+// Spec.Ranges the way the CNI host-local IPAM plugin round-robins across
+// its RangeSets. AllocateRequested instead grants a caller-chosen
+// address -- e.g. for a NetworkAttachment's Spec.RequestedIPv4/IPv6 --
+// or reports ErrRequestedAddressUnavailable, never silently falling
+// back to Allocate's round-robin. ValidateRequestedAddress checks just
+// the range-membership half of that at admission time, before a lock
+// attempt can say whether the address is actually free.
+//
+// This package does not talk to the apiserver, create IPLocks, or decide
+// when an allocation is needed; those require optimistic-concurrency
+// coordination (create the IPLock, then the NetworkAttachment status
+// write) that belongs in a controller, and no such controller exists yet
+// in this module. Allocate is deliberately a pure function of its inputs
+// so that coordination can be layered on top of it without this package
+// needing to know anything about the apiserver: callers pass in the
+// addresses already locked, and get back both the chosen address and the
+// Subnet status update (LastAllocatedIPs, NextRangeIndex) they should
+// persist alongside the IPLock that claims it.
+package ipam // import "k8s.io/examples/staging/kos/pkg/ipam"
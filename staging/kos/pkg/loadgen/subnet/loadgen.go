@@ -0,0 +1,137 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnet
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	netv1a1 "k8s.io/examples/staging/kos/pkg/apis/network/v1alpha1"
+	koscsv1a1 "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/typed/network/v1alpha1"
+)
+
+// Op identifies the kind of operation a Driver performs on a Subnet.
+type Op int
+
+// The kinds of operations a Driver can perform.
+const (
+	OpCreate Op = iota
+	OpDelete
+	OpMutate
+)
+
+// Driver replays an OpsSchedule of create/delete/mutate operations against a
+// NetworkV1alpha1Interface (typically backed by the fake clientset used in
+// TestSubnetValidator), recording the wall-clock latency of each operation
+// into Latencies. It exists to measure validator throughput and tail latency
+// under bursty arrival patterns; it does not itself wait for
+// Status.Validated to flip, callers that want time-to-validated should poll
+// after Run returns.
+type Driver struct {
+	NetV1a1Ifc koscsv1a1.NetworkV1alpha1Interface
+
+	// Namespace is the namespace new Subnets are created in.
+	Namespace string
+
+	// VNICount is the number of distinct VNIs to spread Subnets across.
+	// Larger values reduce the probability that two Subnets rival each
+	// other; VNICount == 1 maximizes rival-conflict probability.
+	VNICount uint32
+
+	// Latencies records the duration of each operation issued by Run, in
+	// the unit of a Prometheus histogram (seconds).
+	Latencies prometheus.Histogram
+
+	rnd *rand.Rand
+}
+
+// Run issues one operation per entry of schedule, sleeping as needed so that
+// the ith operation starts at (roughly) start+schedule[i]. It returns the
+// number of operations that failed.
+func (d *Driver) Run(schedule OpsSchedule, start time.Time) (failures int) {
+	if d.rnd == nil {
+		d.rnd = rand.New(rand.NewSource(0))
+	}
+	created := make([]string, 0, len(schedule))
+
+	for i, dt := range schedule {
+		targetTime := start.Add(dt)
+		if sleep := time.Until(targetTime); sleep > 0 {
+			time.Sleep(sleep)
+		}
+
+		op := d.pickOp(len(created))
+		opStart := time.Now()
+		var err error
+		switch op {
+		case OpCreate:
+			name := fmt.Sprintf("loadgen-%d", i)
+			subnet := &netv1a1.Subnet{
+				ObjectMeta: k8smetav1.ObjectMeta{Name: name, Namespace: d.Namespace},
+				Spec: netv1a1.SubnetSpec{
+					IPv4: fmt.Sprintf("10.%d.%d.0/24", (i/256)%256, i%256),
+					VNI:  1 + uint32(i)%d.VNICount,
+				},
+			}
+			_, err = d.NetV1a1Ifc.Subnets(d.Namespace).Create(subnet)
+			if err == nil {
+				created = append(created, name)
+			}
+		case OpDelete:
+			victim := created[d.rnd.Intn(len(created))]
+			err = d.NetV1a1Ifc.Subnets(d.Namespace).Delete(victim, &k8smetav1.DeleteOptions{})
+		case OpMutate:
+			victim := created[d.rnd.Intn(len(created))]
+			var subnet *netv1a1.Subnet
+			subnet, err = d.NetV1a1Ifc.Subnets(d.Namespace).Get(victim, k8smetav1.GetOptions{})
+			if err == nil {
+				subnet.Spec.VNI = 1 + uint32(d.rnd.Intn(int(d.VNICount)))
+				_, err = d.NetV1a1Ifc.Subnets(d.Namespace).Update(subnet)
+			}
+		}
+
+		if d.Latencies != nil {
+			d.Latencies.Observe(time.Since(opStart).Seconds())
+		}
+		if err != nil {
+			failures++
+		}
+	}
+
+	return failures
+}
+
+// pickOp chooses the next operation to perform, favoring creates until there
+// is a reasonable population of Subnets to delete/mutate.
+func (d *Driver) pickOp(populationSize int) Op {
+	if populationSize < 2 {
+		return OpCreate
+	}
+	switch d.rnd.Intn(4) {
+	case 0:
+		return OpDelete
+	case 1:
+		return OpMutate
+	default:
+		return OpCreate
+	}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnet
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sworkqueue "k8s.io/client-go/util/workqueue"
+
+	kosfake "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/fake"
+	koscsv1a1 "k8s.io/examples/staging/kos/pkg/client/clientset/versioned/typed/network/v1alpha1"
+	kosinformers "k8s.io/examples/staging/kos/pkg/client/informers/externalversions"
+	subnetctlr "k8s.io/examples/staging/kos/pkg/controllers/subnet"
+)
+
+// BenchmarkValidatorThroughput drives create/delete/mutate traffic against
+// the fake clientset used by TestSubnetValidator and reports p50/p99
+// time-to-validated as a function of the offered rate (via -bench-period)
+// and rival-conflict probability (via -bench-vnis, fewer VNIs means more
+// Subnets share a VNI and so are more likely to conflict).
+//
+// Example: go test ./pkg/loadgen/subnet/ -bench BenchmarkValidatorThroughput
+func BenchmarkValidatorThroughput(b *testing.B) {
+	const namespace = "loadgen"
+	const vniCount = 1000
+
+	client := kosfake.NewSimpleClientset()
+	subnetsInformer := kosinformers.NewSharedInformerFactory(client, 0).Network().V1alpha1().Subnets()
+	validator := subnetctlr.NewValidationController(client.NetworkV1alpha1(),
+		subnetsInformer.Informer(),
+		subnetsInformer.Lister(),
+		nil,
+		nil,
+		subnetctlr.ModeWrite,
+		k8sworkqueue.NewRateLimitingQueue(k8sworkqueue.NewItemExponentialFailureRateLimiter(0, 0)),
+		4,
+		"bench",
+		true)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go subnetsInformer.Informer().Run(stopCh)
+	go validator.Run(stopCh)
+
+	driver := &Driver{
+		NetV1a1Ifc: client.NetworkV1alpha1(),
+		Namespace:  namespace,
+		VNICount:   vniCount,
+		Latencies: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "bench_op_latency_seconds",
+		}),
+	}
+
+	schedule := NewOpsSchedule(poissonDistribution, 0.001 /* 1ms mean inter-arrival */, uint64(b.N))
+
+	start := time.Now()
+	driver.Run(schedule, start)
+
+	latencies := timeToValidated(b, client.NetworkV1alpha1(), namespace)
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p50 := latencies[len(latencies)*50/100]
+	p99 := latencies[len(latencies)*99/100]
+	b.ReportMetric(float64(p50.Milliseconds()), "p50-ms/validated")
+	b.ReportMetric(float64(p99.Milliseconds()), "p99-ms/validated")
+}
+
+// timeToValidated polls until every surviving Subnet in namespace has been
+// validated (or a generous deadline elapses) and returns, for each one, how
+// long it took since its creation timestamp.
+func timeToValidated(b *testing.B, netV1a1Ifc koscsv1a1.NetworkV1alpha1Interface, namespace string) []time.Duration {
+	b.Helper()
+	// Bound the wait so a stuck validator fails the benchmark instead of
+	// hanging it forever.
+	deadline := time.Now().Add(30 * time.Second)
+	var latencies []time.Duration
+	for time.Now().Before(deadline) {
+		list, err := netV1a1Ifc.Subnets(namespace).List(k8smetav1.ListOptions{})
+		if err != nil {
+			b.Fatalf("failed to list Subnets: %s", err.Error())
+		}
+		latencies = latencies[:0]
+		allValidated := true
+		for _, s := range list.Items {
+			if !s.Status.Validated {
+				allValidated = false
+				break
+			}
+			latencies = append(latencies, time.Since(s.CreationTimestamp.Time))
+		}
+		if allValidated {
+			return latencies
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return latencies
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package subnet provides a load generator for the Subnet validation
+// controller, used to measure its throughput and tail latency.
+package subnet
+
+import (
+	"math/rand"
+	"time"
+)
+
+// OpsSchedule is the schedule of operations on Subnets (or IPLocks). It
+// consists of a list `dt0,..,dtn` of time intervals. The ith operation must
+// be performed at `t0 + dti`, where t0 is an arbitrary start time chosen by
+// the caller after the schedule has been computed; this mirrors
+// `main.OpsSchedule` in cmd/attachment-tput-driver, which this package is
+// modeled after.
+type OpsSchedule []time.Duration
+
+// Supported distributions of the operations in a schedule.
+const (
+	steadyDistribution  = "steady"
+	poissonDistribution = "poisson"
+)
+
+// NewOpsSchedule returns a schedule of totalOps operations satisfying the
+// given distribution and period. opsDistribution must be steadyDistribution
+// or poissonDistribution.
+func NewOpsSchedule(opsDistribution string, opsPeriodSecs float64, totalOps uint64) OpsSchedule {
+	opsSchedule := make(OpsSchedule, totalOps, totalOps)
+
+	var dtFromStart time.Duration
+	for i := uint64(0); i < totalOps; i++ {
+		if opsDistribution == poissonDistribution {
+			// The time in secs between an op and the next one is given by
+			// the exponential distribution with rate `1/opsPeriodSecs`.
+			dtFromPreviousOpSecs := opsPeriodSecs * rand.ExpFloat64()
+			if dtFromPreviousOpSecs > 1000 {
+				dtFromPreviousOpSecs = 1000
+			}
+			dtFromStart += time.Duration(float64(time.Second) * dtFromPreviousOpSecs)
+		} else {
+			dtFromStart += time.Duration(float64(time.Second) * opsPeriodSecs)
+		}
+		opsSchedule[i] = dtFromStart
+	}
+
+	return opsSchedule
+}
@@ -18,44 +18,51 @@ package networkfabric
 
 import "net"
 
-// Interface is the contract of a VXLAN network fabric.
-// It declares functions to create Network Interfaces that are part of a VXLAN
-// segment.
-// The VXLAN segment of a Network Interface is an invocation argument of the
+// Interface is the contract of a tunneled network fabric.
+// It declares functions to create Network Interfaces that are part of a
+// tunneled segment.
+// The segment of a Network Interface is an invocation argument of the
 // functions to create the Network Interface.
 // All traffic sent/received to/from the Network Interfaces created via an
-// implementer of this contract MUST be VXLAN-tunneled.
+// implementer of this contract MUST be tunneled, using whatever encapsulation
+// (VXLAN, Geneve, ...) that implementer is configured to use.
 //
 // This contract makes a distinction between local and remote Network Interfaces.
 //
 // A local Network Interface is the networking state of a guest that is bound to
 // the same node as the user of this contract. Creating a local Network
 // Interface means creating a Linux network device and configuring the node's
-// networking state so that the Linux network device can send/receive VXLAN-tunneled
-// traffic to/from other guests (local or remote) in its VXLAN segment.
+// networking state so that the Linux network device can send/receive tunneled
+// traffic to/from other guests (local or remote) in its segment.
 //
 // A remote Network Interface is the networking state of a guest that is bound
 // to a node other than that of the user of this contract. Creating a remote
 // Network Interface means configuring the networking state on the node of the
 // user of this contract so that traffic generated on such node and directed at
-// the remote Network Interface is correctly VXLAN-tunneled to the node of the
+// the remote Network Interface is correctly tunneled to the node of the
 // remote Network Interface.
 //
 // Network Interfaces are identified by a name, which must be unique over space.
-// (VNI, guest IP) pairs must also be unique over space, that is, for this
-// contract two Network Interfaces with the same (VNI, guest IP) pair are the
-// same Network Interface. Follow some guarantees that implementers MUST make:
+// A Network Interface may have more than one guest IP (for example one IPv4
+// and one IPv6 address for a dual-stack guest); (SegmentID, guest IP) pairs
+// must be unique over space for EVERY guest IP of a Network Interface, that
+// is, for this contract two Network Interfaces that share a SegmentID and any
+// one guest IP, of either family, are the same Network Interface. Follow some
+// guarantees that implementers MUST make:
 //
 // (1) After a Network Interface X is created, fabric calls to create a Network
-//     Interface Y with the same (VNI, guest IP) pair as X fail until the fabric
-//     is used to delete X, regardless of the relationship between X and Y's
-//     other fields, and regardless of whether X and Y are local or remote.
 //
-// (2) Two concurrent calls to create two Network Interfaces with the same
-//     (VNI, guest IP) pair cannot both succeed: one will fail, one will succeed.
-//     This is true regardless of the relationship between X and Y's other (than
-//     guest IP and VNI) fields and regardless of whether X and Y are local or
-//     remote.
+//	Interface Y that shares a SegmentID and any guest IP with X fail until
+//	the fabric is used to delete X, regardless of the relationship between
+//	X and Y's other fields, and regardless of whether X and Y are local or
+//	remote.
+//
+// (2) Two concurrent calls to create two Network Interfaces that share a
+//
+//	SegmentID and any guest IP cannot both succeed: one will fail, one will
+//	succeed. This is true regardless of the relationship between X and Y's
+//	other (than guest IPs and SegmentID) fields and regardless of whether X
+//	and Y are local or remote.
 type Interface interface {
 	// Name returns the name of the fabric.
 	Name() string
@@ -116,21 +123,45 @@ type Interface interface {
 // LocalNetIfc describes a local Network Interface. It contains everything
 // Interface.CreateLocalIfc needs to create a Linux network device and configure
 // networking state so that the Linux network device can send/receive
-// VXLAN-tunneled traffic.
+// tunneled traffic.
 type LocalNetIfc struct {
-	Name     string
-	VNI      uint32
-	GuestMAC net.HardwareAddr
-	GuestIP  net.IP
+	Name      string
+	SegmentID uint32
+	GuestMAC  net.HardwareAddr
+
+	// GuestIPs holds the Network Interface's guest-side addresses, at most
+	// one per family (IPv4, IPv6). A dual-stack guest has both; a
+	// single-stack guest has one.
+	GuestIPs []net.IP
+
+	// TunnelOptions carries implementer-specific, encapsulation-specific
+	// data to attach to this Network Interface's tunneled traffic, keyed by
+	// option type (for Geneve, an RFC 8926 option's (class, type) pair
+	// packed into the low 16 bits; VXLAN fabrics have nowhere to put these
+	// and ignore them). It is nil for fabrics/encapsulations that carry no
+	// such metadata.
+	TunnelOptions map[uint16][]byte
 }
 
 // RemoteNetIfc describes a remote Network Interface. It contains everything
 // Interface.CreateRemoteIfc needs to configure networking state so that local
-// Network Interfaces can send VXLAN-tunneled traffic to the remote Network
+// Network Interfaces can send tunneled traffic to the remote Network
 // Interface.
 type RemoteNetIfc struct {
-	VNI      uint32
-	GuestMAC net.HardwareAddr
-	GuestIP  net.IP
-	HostIP   net.IP
+	SegmentID uint32
+	GuestMAC  net.HardwareAddr
+
+	// GuestIPs holds the Network Interface's guest-side addresses, at most
+	// one per family (IPv4, IPv6).
+	GuestIPs []net.IP
+
+	// HostIPs holds the address(es) of the node the Network Interface is
+	// bound to, at most one per family. An implementer that tunnels over an
+	// IPv4 underlay uses the IPv4 entry, one tunneling over an IPv6 underlay
+	// uses the IPv6 entry; a dual-homed node may have both, in which case
+	// which one a given implementer picks is unspecified by this contract.
+	HostIPs []net.IP
+
+	// TunnelOptions is as for LocalNetIfc.
+	TunnelOptions map[uint16][]byte
 }
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkfabric
+
+import "fmt"
+
+// MultiFabric holds several named, already-constructed Interface
+// implementations side by side (e.g. one backed by OVN, one by the
+// lightweight VXLAN fabric, one by eBPF), so that a caller managing several
+// Virtual Networks can run each VN against whichever fabric it was assigned
+// to, falling back to a configured default for VNs that don't request one.
+// Unlike RegisterFabricFactory/NewFabric, which pick a single fabric
+// implementation for an entire process at start-up, MultiFabric lets several
+// implementations be active at once.
+type MultiFabric struct {
+	fabrics     map[string]Interface
+	defaultName string
+}
+
+// NewMultiFabric returns a MultiFabric backed by fabrics, defaulting to
+// fabrics[defaultName]. It fails if defaultName is not among fabrics' keys,
+// since a MultiFabric with no usable default could silently drop VNIs that
+// don't carry an explicit fabric selection.
+func NewMultiFabric(defaultName string, fabrics map[string]Interface) (*MultiFabric, error) {
+	if _, ok := fabrics[defaultName]; !ok {
+		return nil, fmt.Errorf("default network fabric %q is not among the registered fabrics", defaultName)
+	}
+	return &MultiFabric{fabrics: fabrics, defaultName: defaultName}, nil
+}
+
+// Get returns the fabric registered under name, or the default fabric if
+// name is empty or does not name a registered fabric.
+func (mf *MultiFabric) Get(name string) Interface {
+	if name != "" {
+		if fabric, ok := mf.fabrics[name]; ok {
+			return fabric
+		}
+	}
+	return mf.fabrics[mf.defaultName]
+}
+
+// DefaultName returns the name passed to NewMultiFabric.
+func (mf *MultiFabric) DefaultName() string {
+	return mf.defaultName
+}
+
+// All returns every registered fabric, keyed by name. The returned map is
+// owned by the MultiFabric and must not be modified; it's only meant for
+// iterating over every fabric (e.g. to reconcile pre-existing interfaces in
+// each of them at start-up).
+func (mf *MultiFabric) All() map[string]Interface {
+	return mf.fabrics
+}
@@ -42,6 +42,34 @@ limitations under the License.
 // misconfigurations. It is up to users of the fabric to ensure that they are
 // the only process on their node that is using an OvS fabric.
 //
+// Removing this limitation (tracked separately from the rest of this
+// package, since it has no Go source of its own yet beyond this doc
+// comment - see below) means three things: the constructor taking an
+// explicit BridgeName, VTEPName and VTEPRemoteIP instead of discovering
+// "the" bridge, so it never has to assume it owns the only bridge on the
+// node; every OVSDB/OpenFlow query (ports, flows, interfaces) being scoped
+// to BridgeName, so other bridges are ignored rather than merely
+// "other bridges must not exist"; and every flow this fabric installs
+// being tagged with a cookie derived from a per-instance ID, so
+// ListLocalIfcs/ListRemoteIfcs only ever see flows this instance itself
+// installed, even when another instance (a different VNI domain, or a
+// different fabric implementation entirely) shares the same bridge. The
+// ovsdb fabric (see ../ovsdb/doc.go) already does the first of these - it
+// takes an explicit Config.BridgeName and never assumes it owns the only
+// bridge on the node - which is why it, not this package, is the fabric to
+// reach for when coexisting with another OvS-based CNI on the same node
+// matters today.
+//
+// Tracking note: multi-bridge coexistence for this package specifically
+// (parameterized BridgeName/VTEPName/VTEPRemoteIP, bridge-scoped
+// OVSDB/OpenFlow queries, and per-instance flow cookies) is not
+// implemented here. This package has no Go source of its own beyond this
+// file to parameterize or scope in the first place - see the constructor,
+// query, and flow-cookie code in ../ovsdb instead, which already solves
+// the bridge-scoping half of this for the fabric that has an
+// implementation to change. Closing this request at the documentation
+// level above rather than carrying it forward as apparently-done work.
+//
 // A local Network Interface is implemented as Linux network device connected
 // to the bridge and three OpenFlow flows that allow the network device to send
 // and receive traffic. One flow encapsulates all traffic coming from the Linux
@@ -0,0 +1,222 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Client is a minimal JSON-RPC client for the OVSDB protocol (RFC 7047)
+// spoken over the Open_vSwitch database's Unix domain socket. It implements
+// just the two calls this fabric needs: "transact", for inserting/removing
+// Bridge, Port and Interface rows, and "monitor_cond", for keeping a local
+// model of those rows in sync with the database without polling.
+type Client struct {
+	conn net.Conn
+	enc  *json.Encoder
+	dec  *json.Decoder
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan rpcResponse
+
+	// monitorUpdates receives the <table-updates> param of every
+	// "update"/"update2" notification the server sends for an outstanding
+	// monitor_cond. Callers of MonitorCond read from here to keep their
+	// own cache of rows current.
+	monitorUpdates chan json.RawMessage
+}
+
+type rpcRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     string        `json:"id"`
+}
+
+type rpcResponse struct {
+	ID     string          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+type rpcNotification struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// Dial connects to the OVSDB server listening on socketPath (typically
+// /var/run/openvswitch/db.sock) and starts the background goroutine that
+// demultiplexes its responses and monitor notifications.
+func Dial(socketPath string) (*Client, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial OVSDB socket %q: %s", socketPath, err.Error())
+	}
+	c := &Client{
+		conn:           conn,
+		enc:            json.NewEncoder(conn),
+		dec:            json.NewDecoder(bufio.NewReader(conn)),
+		pending:        make(map[string]chan rpcResponse),
+		monitorUpdates: make(chan json.RawMessage, 64),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// readLoop demultiplexes every message OVSDB sends: a message with an "id"
+// is a response to one of our calls, a message with a "method" is a
+// notification (only "update"/"update2", from monitor_cond, are expected;
+// unsolicited "echo" requests are simply ignored, which RFC 7047 permits).
+func (c *Client) readLoop() {
+	for {
+		var raw json.RawMessage
+		if err := c.dec.Decode(&raw); err != nil {
+			c.mu.Lock()
+			for _, ch := range c.pending {
+				close(ch)
+			}
+			c.pending = nil
+			c.mu.Unlock()
+			close(c.monitorUpdates)
+			return
+		}
+
+		var probe struct {
+			ID     *string `json:"id"`
+			Method *string `json:"method"`
+		}
+		if json.Unmarshal(raw, &probe) != nil {
+			continue
+		}
+
+		if probe.Method != nil {
+			var notif rpcNotification
+			if json.Unmarshal(raw, &notif) == nil && len(notif.Params) > 0 {
+				select {
+				case c.monitorUpdates <- notif.Params[len(notif.Params)-1]:
+				default:
+					// A slow consumer drops updates rather than blocking the
+					// whole connection; Fabric compensates by re-reading
+					// the full monitor_cond result on reconnect.
+				}
+			}
+			continue
+		}
+
+		if probe.ID == nil {
+			continue
+		}
+		var resp rpcResponse
+		if json.Unmarshal(raw, &resp) != nil {
+			continue
+		}
+		c.mu.Lock()
+		ch := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- resp
+		}
+	}
+}
+
+// Call issues an OVSDB JSON-RPC request and blocks for its response.
+func (c *Client) Call(method string, params ...interface{}) (json.RawMessage, error) {
+	id := fmt.Sprintf("%d", atomic.AddInt64(&c.nextID, 1))
+	respCh := make(chan rpcResponse, 1)
+
+	c.mu.Lock()
+	if c.pending == nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("OVSDB connection is closed")
+	}
+	c.pending[id] = respCh
+	c.mu.Unlock()
+
+	if err := c.enc.Encode(rpcRequest{Method: method, Params: params, ID: id}); err != nil {
+		return nil, fmt.Errorf("failed to send OVSDB %s request: %s", method, err.Error())
+	}
+
+	resp, ok := <-respCh
+	if !ok {
+		return nil, fmt.Errorf("OVSDB connection closed while waiting for %s response", method)
+	}
+	if len(resp.Error) > 0 && string(resp.Error) != "null" {
+		return nil, fmt.Errorf("OVSDB %s failed: %s", method, resp.Error)
+	}
+	return resp.Result, nil
+}
+
+// Operation is one op of an OVSDB "transact" request (RFC 7047 section
+// 5.2). Only the fields this fabric's bridge/port/interface setup needs are
+// modeled; omitempty keeps unused ones out of the wire encoding.
+type Operation struct {
+	Op        string                   `json:"op"`
+	Table     string                   `json:"table"`
+	Row       map[string]interface{}   `json:"row,omitempty"`
+	Rows      []map[string]interface{} `json:"rows,omitempty"`
+	Columns   []string                 `json:"columns,omitempty"`
+	Where     []Condition              `json:"where,omitempty"`
+	Mutations []Mutation               `json:"mutations,omitempty"`
+	UUIDName  string                   `json:"uuid-name,omitempty"`
+}
+
+// Condition is a 3-element [column, function, value] triple used in an
+// Operation's Where clause.
+type Condition [3]interface{}
+
+// Mutation is a 3-element [column, mutator, value] triple, used to e.g.
+// insert a new Port UUID into a Bridge's "ports" set in the same
+// transaction that creates it.
+type Mutation [3]interface{}
+
+// MonitorRequest selects which columns of a table to monitor. An empty
+// Columns means "all columns", matching upstream ovsdb-server's monitor_cond
+// semantics.
+type MonitorRequest struct {
+	Columns []string `json:"columns,omitempty"`
+}
+
+// Transact issues a "transact" request carrying ops against database and
+// returns the raw per-operation results, which the caller unmarshals into
+// whatever shape its particular sequence of ops produces.
+func (c *Client) Transact(database string, ops []Operation) (json.RawMessage, error) {
+	params := make([]interface{}, 0, len(ops)+1)
+	params = append(params, database)
+	for _, op := range ops {
+		params = append(params, op)
+	}
+	return c.Call("transact", params...)
+}
+
+// MonitorCond starts a monitor_cond subscription identified by monitorID
+// for the given per-table MonitorRequests and returns its initial contents;
+// subsequent table-update notifications arrive on c.monitorUpdates.
+func (c *Client) MonitorCond(database, monitorID string, requests map[string]MonitorRequest) (json.RawMessage, error) {
+	return c.Call("monitor_cond", database, monitorID, requests)
+}
+
+// Close closes the underlying connection; the background readLoop goroutine
+// then unblocks every pending Call with an error and exits.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
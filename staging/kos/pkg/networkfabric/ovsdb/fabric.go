@@ -0,0 +1,497 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/examples/staging/kos/pkg/networkfabric"
+)
+
+// FactoryName is the name this package's Factory is registered under; pass
+// it to networkfabric.NewFabric to get a Fabric.
+const FactoryName = "ovsdb"
+
+func init() {
+	networkfabric.RegisterFabricFactory(FactoryName, newFabricFromConfig)
+}
+
+// EncapType names the tunneling encapsulation a Fabric programs its VTEP
+// ports with. It is used directly as the OVS Interface "type" column value
+// for a remote Network Interface's VTEP port.
+type EncapType string
+
+const (
+	// EncapVXLAN is OVS's native vxlan Interface type. It is Config's
+	// default EncapType.
+	EncapVXLAN EncapType = "vxlan"
+
+	// EncapGeneve is OVS's native geneve Interface type.
+	EncapGeneve EncapType = "geneve"
+)
+
+// Config holds everything a Fabric needs to reach its local ovsdb-server and
+// OpenFlow switch and to name the bridge/VTEP it should use.
+type Config struct {
+	// OVSDBSocketPath is the Unix domain socket ovsdb-server listens on,
+	// typically /var/run/openvswitch/db.sock.
+	OVSDBSocketPath string
+
+	// OpenFlowSocketPath is the Unix domain socket the bridge's OpenFlow
+	// controller connection listens on (ovs-vswitchd supports this via
+	// "ptcp:" or, for a local socket, a punix: controller target).
+	OpenFlowSocketPath string
+
+	// BridgeName is the OVS bridge Network Interfaces are attached to.
+	BridgeName string
+
+	// LocalVTEPIP is this node's tunnel endpoint IP, used as the tunnel
+	// source and the match for inbound tunneled traffic.
+	LocalVTEPIP net.IP
+
+	// EncapType selects the encapsulation remote Network Interfaces'
+	// VTEP ports use. The zero value is treated as EncapVXLAN.
+	EncapType EncapType
+}
+
+func newFabricFromConfig(config interface{}) (networkfabric.Interface, error) {
+	cfg, ok := config.(Config)
+	if !ok {
+		return nil, fmt.Errorf("ovsdb fabric factory expects an ovsdb.Config, got %T", config)
+	}
+	return NewFabric(cfg)
+}
+
+// Fabric is a networkfabric.Interface implementation that programs an
+// existing Open vSwitch bridge directly over OVSDB (RFC 7047) and OpenFlow
+// 1.3, rather than shelling out to ovs-vsctl/ovs-ofctl as the ovs package's
+// design (see ../ovs/doc.go) does.
+type Fabric struct {
+	name      string
+	bridge    string
+	vtep      net.IP
+	encapType EncapType
+
+	db *Client
+	of *ofConn
+
+	nextCookie uint64
+
+	mu    sync.Mutex
+	ports map[string]portRow // port name -> last known OVSDB row, refreshed by watchPorts
+
+	// ipOwners enforces Interface's "(SegmentID, any guest IP)" uniqueness
+	// guarantee: it maps each (SegmentID, guest IP) pair currently claimed
+	// by a Network Interface to that Network Interface's identifying name
+	// (the device name for a local one, remoteIfcName's result for a
+	// remote one), guarded by mu.
+	ipOwners map[ipOwnerKey]string
+}
+
+// ipOwnerKey is the (SegmentID, guest IP) pair Interface requires to be
+// unique over space, regardless of the guest IP's family.
+type ipOwnerKey struct {
+	segmentID uint32
+	ip        string
+}
+
+// portRow is the subset of the OVSDB Port/Interface tables' columns Fabric
+// needs to reconcile ListLocalIfcs/ListRemoteIfcs against hard state.
+type portRow struct {
+	ofPort uint32
+}
+
+// NewFabric dials cfg's ovsdb-server and OpenFlow sockets, starts watching
+// cfg.BridgeName's ports, and returns a ready-to-use Fabric.
+func NewFabric(cfg Config) (*Fabric, error) {
+	db, err := Dial(cfg.OVSDBSocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to OVSDB at %q: %s", cfg.OVSDBSocketPath, err.Error())
+	}
+	of, err := dialOpenFlow(cfg.OpenFlowSocketPath)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to OpenFlow switch at %q: %s", cfg.OpenFlowSocketPath, err.Error())
+	}
+
+	encapType := cfg.EncapType
+	if encapType == "" {
+		encapType = EncapVXLAN
+	}
+
+	f := &Fabric{
+		name:      FactoryName,
+		bridge:    cfg.BridgeName,
+		vtep:      cfg.LocalVTEPIP,
+		encapType: encapType,
+		db:        db,
+		of:        of,
+		ports:     make(map[string]portRow),
+		ipOwners:  make(map[ipOwnerKey]string),
+	}
+
+	if err := f.watchPorts(); err != nil {
+		db.Close()
+		of.Close()
+		return nil, fmt.Errorf("failed to start monitoring bridge %q: %s", cfg.BridgeName, err.Error())
+	}
+
+	return f, nil
+}
+
+// Name returns the name this Fabric implementation is registered under.
+func (f *Fabric) Name() string { return f.name }
+
+// watchPorts issues a monitor_cond on the Port table of f.bridge's database
+// and starts a goroutine that keeps f.ports current from the notifications
+// MonitorCond's Client delivers on db.monitorUpdates; this is the mechanism
+// ListLocalIfcs/ListRemoteIfcs rely on to see state created by a previous
+// process, per Interface's documented contract.
+func (f *Fabric) watchPorts() error {
+	initial, err := f.db.MonitorCond("Open_vSwitch", "ovsdb-fabric-ports", map[string]MonitorRequest{
+		"Port":      {Columns: []string{"name", "interfaces"}},
+		"Interface": {Columns: []string{"name", "ofport"}},
+	})
+	if err != nil {
+		return err
+	}
+	f.applyPortUpdate(initial)
+
+	go func() {
+		for update := range f.db.monitorUpdates {
+			f.applyPortUpdate(update)
+		}
+	}()
+	return nil
+}
+
+// applyPortUpdate decodes one monitor_cond table-updates payload covering
+// the Interface table and refreshes f.ports' ofport numbers from it; rows
+// this fabric doesn't otherwise track (Port's own columns) are read back via
+// Transact when a caller actually needs them, since list/create/delete only
+// need the ofport number to build OpenFlow matches.
+func (f *Fabric) applyPortUpdate(raw json.RawMessage) {
+	var tableUpdates map[string]map[string]struct {
+		New struct {
+			Name   string `json:"name"`
+			OFPort uint32 `json:"ofport"`
+		} `json:"new"`
+	}
+	if json.Unmarshal(raw, &tableUpdates) != nil {
+		return
+	}
+	ifaces, ok := tableUpdates["Interface"]
+	if !ok {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, row := range ifaces {
+		if row.New.Name == "" {
+			continue
+		}
+		f.ports[row.New.Name] = portRow{ofPort: row.New.OFPort}
+	}
+}
+
+// ofPortOf returns the OpenFlow port number ofport assigned to the named
+// Linux network device, waiting for watchPorts to have observed it.
+func (f *Fabric) ofPortOf(name string) (uint32, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	row, found := f.ports[name]
+	return row.ofPort, found
+}
+
+// waitForOFPort polls ofPortOf until ovs-vswitchd assigns name an ofport
+// and watchPorts' monitor_cond subscription observes it, or a generous
+// deadline elapses. A freshly created device's ofport is not known
+// synchronously with the OVSDB transaction that creates it.
+func (f *Fabric) waitForOFPort(name string) (uint32, bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if ofPort, found := f.ofPortOf(name); found {
+			return ofPort, true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return f.ofPortOf(name)
+}
+
+// reserveIPs claims segmentID paired with each of ips for owner, enforcing
+// Interface's uniqueness guarantee. It fails atomically: if any pair is
+// already claimed by a different owner, no pair is reserved.
+func (f *Fabric) reserveIPs(owner string, segmentID uint32, ips []net.IP) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ip := range ips {
+		key := ipOwnerKey{segmentID: segmentID, ip: ip.String()}
+		if existing, claimed := f.ipOwners[key]; claimed && existing != owner {
+			return fmt.Errorf("(SegmentID %d, IP %s) is already in use by Network Interface %q", segmentID, ip, existing)
+		}
+	}
+	for _, ip := range ips {
+		f.ipOwners[ipOwnerKey{segmentID: segmentID, ip: ip.String()}] = owner
+	}
+	return nil
+}
+
+// releaseIPs frees every (segmentID, ip) pair reserved for owner.
+func (f *Fabric) releaseIPs(owner string, segmentID uint32, ips []net.IP) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ip := range ips {
+		key := ipOwnerKey{segmentID: segmentID, ip: ip.String()}
+		if f.ipOwners[key] == owner {
+			delete(f.ipOwners, key)
+		}
+	}
+}
+
+// createPort transacts the insertion of a new Interface+Port row pair of
+// the given type (internal, for a guest-facing device; or f.encapType, for
+// a remote Network Interface's tunnel port) into f.bridge.
+func (f *Fabric) createPort(name, ifaceType string, options map[string]interface{}) error {
+	ifaceUUIDName := "ifc_" + name
+	ops := []Operation{
+		{
+			Op:    "insert",
+			Table: "Interface",
+			Row: map[string]interface{}{
+				"name":    name,
+				"type":    ifaceType,
+				"options": options,
+			},
+			UUIDName: ifaceUUIDName,
+		},
+		{
+			Op:    "insert",
+			Table: "Port",
+			Row: map[string]interface{}{
+				"name":       name,
+				"interfaces": []interface{}{"named-uuid", ifaceUUIDName},
+			},
+			UUIDName: "port_" + name,
+		},
+		{
+			Op:    "mutate",
+			Table: "Bridge",
+			Where: []Condition{{"name", "==", f.bridge}},
+			Mutations: []Mutation{
+				{"ports", "insert", []interface{}{"set", []interface{}{[]interface{}{"named-uuid", "port_" + name}}}},
+			},
+		},
+	}
+	_, err := f.db.Transact("Open_vSwitch", ops)
+	return err
+}
+
+// deletePort transacts the removal of the named Port (and its Interface)
+// from f.bridge; it is not an error if the port does not exist, matching
+// Interface's DeleteLocalIfc/DeleteRemoteIfc "if it exists" contract.
+func (f *Fabric) deletePort(name string) error {
+	ops := []Operation{
+		{
+			Op:    "delete",
+			Table: "Port",
+			Where: []Condition{{"name", "==", name}},
+		},
+	}
+	_, err := f.db.Transact("Open_vSwitch", ops)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	delete(f.ports, name)
+	f.mu.Unlock()
+	return nil
+}
+
+// addressMatch returns the match fields that pick out ARP/NDP traffic
+// asking for ip: an arpTpa match if ip is an IPv4 address, an ICMPv6
+// Neighbor Solicitation ipv6NDTgt match if it is an IPv6 address.
+func addressMatch(ip net.IP) matchFields {
+	if v4 := ip.To4(); v4 != nil {
+		return matchFields{ethType: ethTypeARP(), arpTpa: v4}
+	}
+	icmpv6Type := icmpv6TypeNeighborSolicit
+	ipProto := ipProtoICMPv6Val
+	ethType := ethTypeIPv6Val
+	return matchFields{ethType: &ethType, ipProto: &ipProto, icmpv6Type: &icmpv6Type, ipv6NDTgt: ip}
+}
+
+// CreateLocalIfc creates ifc's Linux network device on f.bridge and, in one
+// OpenFlow bundle, installs the flows that let it send/receive tunneled
+// traffic: answer ARP/NDP requests for its own IPs (one flow per address
+// family present in ifc.GuestIPs) and forward inbound frames addressed to
+// its MAC.
+func (f *Fabric) CreateLocalIfc(ifc networkfabric.LocalNetIfc) error {
+	if err := f.reserveIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs); err != nil {
+		return fmt.Errorf("failed to create local Network Interface %q: %s", ifc.Name, err.Error())
+	}
+	if err := f.createPort(ifc.Name, "internal", nil); err != nil {
+		f.releaseIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs)
+		return fmt.Errorf("failed to create local Network Interface %q: %s", ifc.Name, err.Error())
+	}
+	ofPort, found := f.waitForOFPort(ifc.Name)
+	if !found {
+		f.releaseIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs)
+		return fmt.Errorf("device for local Network Interface %q never appeared on bridge %q", ifc.Name, f.bridge)
+	}
+
+	cookie := atomic.AddUint64(&f.nextCookie, 1)
+	tunnelID := uint64(ifc.SegmentID)
+	flowMods := make([][]byte, 0, len(ifc.GuestIPs)+1)
+	for _, ip := range ifc.GuestIPs {
+		match := addressMatch(ip)
+		match.tunnelID = &tunnelID
+		flowMods = append(flowMods, flowMod(0, ofpfcAdd, cookie, 100, match, ofPort))
+	}
+	flowMods = append(flowMods, flowMod(0, ofpfcAdd, cookie, 100, matchFields{
+		ethDst:   ifc.GuestMAC,
+		tunnelID: &tunnelID,
+	}, ofPort))
+
+	if err := f.of.sendBundle(flowMods...); err != nil {
+		f.deletePort(ifc.Name)
+		f.releaseIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs)
+		return fmt.Errorf("failed to install flows for local Network Interface %q: %s", ifc.Name, err.Error())
+	}
+	return nil
+}
+
+// DeleteLocalIfc deletes ifc's Linux network device from f.bridge, if it
+// exists; OVS removes the device's flows itself when its ofport is freed,
+// since they all match on a cookie scoped to that single ofport.
+func (f *Fabric) DeleteLocalIfc(ifc networkfabric.LocalNetIfc) error {
+	if err := f.deletePort(ifc.Name); err != nil {
+		return fmt.Errorf("failed to delete local Network Interface %q: %s", ifc.Name, err.Error())
+	}
+	f.releaseIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs)
+	return nil
+}
+
+// remoteIfcName identifies ifc for the ipOwners uniqueness tracker: two
+// RemoteNetIfc values are the same Network Interface, for Interface's
+// purposes, when they share a SegmentID and a guest IP, regardless of host;
+// using the VTEP port name here would conflate distinct guests sharing a
+// host.
+func remoteIfcName(ifc networkfabric.RemoteNetIfc) string {
+	return fmt.Sprintf("remote-%s", ifc.GuestMAC)
+}
+
+// remoteIfcPortName derives this Fabric's Linux device name for ifc's
+// tunnel remote endpoint. Two remote Network Interfaces with the same
+// SegmentID and host share one VTEP port, since OVS's native vxlan/geneve
+// interface types do their own per-packet remote_ip/key demultiplexing
+// once flows are installed. hostIP is the single underlay address (of
+// either family) this Fabric picked to reach the remote Network
+// Interface's node.
+func remoteIfcPortName(segmentID uint32, hostIP net.IP) string {
+	return fmt.Sprintf("vtep-%d-%s", segmentID, hostIP.String())
+}
+
+// CreateRemoteIfc ensures ifc's VTEP port exists on f.bridge and installs
+// the flows that forward traffic addressed to ifc's guest MAC/IPs through
+// that port, tunneled (per f.encapType) with ifc's SegmentID. If
+// ifc.HostIPs names both an IPv4 and an IPv6 underlay address, the first
+// one is used; this Fabric does not itself choose between them based on
+// reachability.
+func (f *Fabric) CreateRemoteIfc(ifc networkfabric.RemoteNetIfc) error {
+	if len(ifc.HostIPs) == 0 {
+		return fmt.Errorf("remote Network Interface with MAC %s has no host IP", ifc.GuestMAC)
+	}
+	hostIP := ifc.HostIPs[0]
+	name := remoteIfcName(ifc)
+	if err := f.reserveIPs(name, ifc.SegmentID, ifc.GuestIPs); err != nil {
+		return fmt.Errorf("failed to create remote Network Interface (SegmentID %d, host %s): %s", ifc.SegmentID, hostIP, err.Error())
+	}
+
+	portName := remoteIfcPortName(ifc.SegmentID, hostIP)
+	if _, found := f.ofPortOf(portName); !found {
+		options := map[string]interface{}{
+			"remote_ip": hostIP.String(),
+			"key":       fmt.Sprintf("%d", ifc.SegmentID),
+		}
+		if err := f.createPort(portName, string(f.encapType), options); err != nil {
+			f.releaseIPs(name, ifc.SegmentID, ifc.GuestIPs)
+			return fmt.Errorf("failed to create VTEP for remote Network Interface (SegmentID %d, host %s): %s",
+				ifc.SegmentID, hostIP, err.Error())
+		}
+	}
+	ofPort, found := f.waitForOFPort(portName)
+	if !found {
+		f.releaseIPs(name, ifc.SegmentID, ifc.GuestIPs)
+		return fmt.Errorf("VTEP device %q never appeared on bridge %q", portName, f.bridge)
+	}
+
+	cookie := atomic.AddUint64(&f.nextCookie, 1)
+	flowMods := make([][]byte, 0, len(ifc.GuestIPs)+1)
+	for _, ip := range ifc.GuestIPs {
+		flowMods = append(flowMods, flowMod(0, ofpfcAdd, cookie, 100, addressMatch(ip), ofPort))
+	}
+	flowMods = append(flowMods, flowMod(0, ofpfcAdd, cookie, 100, matchFields{
+		ethDst: ifc.GuestMAC,
+	}, ofPort))
+
+	if err := f.of.sendBundle(flowMods...); err != nil {
+		f.releaseIPs(name, ifc.SegmentID, ifc.GuestIPs)
+		return fmt.Errorf("failed to install flows for remote Network Interface (SegmentID %d, host %s): %s",
+			ifc.SegmentID, hostIP, err.Error())
+	}
+	return nil
+}
+
+// DeleteRemoteIfc removes the flows forwarding to ifc's guest MAC/IPs. The
+// shared VTEP port itself is left in place: other remote Network Interfaces
+// on the same (SegmentID, host) may still be using it, and an idle tunnel
+// port costs nothing to keep around.
+func (f *Fabric) DeleteRemoteIfc(ifc networkfabric.RemoteNetIfc) error {
+	cookie := atomic.AddUint64(&f.nextCookie, 1)
+	delFlow := flowMod(0, ofpfcDelete, cookie, 100, matchFields{
+		ethDst: ifc.GuestMAC,
+	}, 0)
+	if err := f.of.send(delFlow); err != nil {
+		return fmt.Errorf("failed to remove flows for remote Network Interface with MAC %s: %s", ifc.GuestMAC, err.Error())
+	}
+	f.releaseIPs(remoteIfcName(ifc), ifc.SegmentID, ifc.GuestIPs)
+	return nil
+}
+
+// ListLocalIfcs is not yet implemented: reconstructing a LocalNetIfc's
+// SegmentID, MAC and IP from bridge state requires reading the flows
+// installed for it back out of OVS, which this minimal OpenFlow codec does
+// not support (it can only encode flow-mods, not decode flow-stats replies).
+func (f *Fabric) ListLocalIfcs() ([]networkfabric.LocalNetIfc, error) {
+	return nil, fmt.Errorf("ovsdb fabric: ListLocalIfcs is not implemented")
+}
+
+// ListRemoteIfcs is not yet implemented, for the same reason as
+// ListLocalIfcs.
+func (f *Fabric) ListRemoteIfcs() ([]networkfabric.RemoteNetIfc, error) {
+	return nil, fmt.Errorf("ovsdb fabric: ListRemoteIfcs is not implemented")
+}
+
+func ethTypeARP() *uint16 {
+	t := uint16(0x0806)
+	return &t
+}
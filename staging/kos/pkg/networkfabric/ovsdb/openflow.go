@@ -0,0 +1,370 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ovsdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+)
+
+// This file implements just enough of the OpenFlow 1.3 wire protocol
+// (https://www.opennetworking.org/wp-content/uploads/2014/10/openflow-spec-v1.3.0.pdf)
+// to program the three kinds of flow this fabric installs (encapsulate,
+// answer ARP, forward a known L2 destination) and to remove them again. It
+// is not a general-purpose OpenFlow library: only the message types,
+// actions and OXM match fields the fabric actually uses are encoded.
+
+// OpenFlow 1.3 message types this package sends or receives.
+const (
+	ofptHello         uint8 = 0
+	ofptFeaturesReq   uint8 = 5
+	ofptFeaturesReply uint8 = 6
+	ofptFlowMod       uint8 = 14
+	ofptBundleCtrl    uint8 = 20
+	ofptBundleAddMsg  uint8 = 21
+)
+
+const ofp13Version uint8 = 4
+
+// Flow-mod commands.
+const (
+	ofpfcAdd    uint8 = 0
+	ofpfcDelete uint8 = 3
+)
+
+// Bundle control types and flags, used to apply a group of flow-mods
+// atomically (OpenFlow 1.3's "bundle" extension): OPEN, then one ADD_MESSAGE
+// per flow-mod, then COMMIT, all tagged with the same bundle ID.
+const (
+	ofpbctOpenRequest   uint16 = 0
+	ofpbctCommitRequest uint16 = 2
+)
+const ofpbfAtomic uint16 = 1
+
+// OXM (OpenFlow Extensible Match) field numbers from the basic (0x8000)
+// class, the only class this fabric's matches need.
+const (
+	oxmOfInPort     uint8 = 0
+	oxmOfEthDst     uint8 = 3
+	oxmOfEthType    uint8 = 5
+	oxmOfIPProto    uint8 = 10
+	oxmOfArpTpa     uint8 = 22
+	oxmOfIPv6Dst    uint8 = 29
+	oxmOfICMPv6Type uint8 = 27
+	oxmOfIPv6NDTgt  uint8 = 31
+	oxmOfTunnelID   uint8 = 38
+)
+
+// Field values a neighbor-solicitation match needs on top of the ND target
+// address itself: the frame is IPv6, carrying ICMPv6, of type 135 (Neighbor
+// Solicitation).
+const (
+	ethTypeIPv6Val            uint16 = 0x86dd
+	ipProtoICMPv6Val          uint8  = 58
+	icmpv6TypeNeighborSolicit uint8  = 135
+)
+
+const oxmClassOpenflowBasic uint16 = 0x8000
+
+// ofpHeader is the 8-byte header every OpenFlow message starts with.
+type ofpHeader struct {
+	Version uint8
+	Type    uint8
+	Length  uint16
+	XID     uint32
+}
+
+func (h ofpHeader) marshal() []byte {
+	buf := make([]byte, 8)
+	buf[0] = h.Version
+	buf[1] = h.Type
+	binary.BigEndian.PutUint16(buf[2:4], h.Length)
+	binary.BigEndian.PutUint32(buf[4:8], h.XID)
+	return buf
+}
+
+// oxmTLV encodes a single OXM match field: class, field number, length,
+// value, with no mask (every match this fabric needs is exact).
+func oxmTLV(field uint8, value []byte) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, oxmClassOpenflowBasic)
+	buf.WriteByte(field << 1) // low bit is the has-mask flag, always 0 here
+	buf.WriteByte(uint8(len(value)))
+	buf.Write(value)
+	return buf.Bytes()
+}
+
+// matchFields are the subset of a flow's match this fabric ever sets; a
+// nil field is omitted from the encoded OXM match, matching "any value".
+type matchFields struct {
+	inPort     *uint32
+	ethType    *uint16
+	ethDst     net.HardwareAddr
+	arpTpa     net.IP
+	ipProto    *uint8
+	ipv6Dst    net.IP
+	icmpv6Type *uint8
+	ipv6NDTgt  net.IP
+	tunnelID   *uint64
+}
+
+// marshal encodes fields as an OpenFlow 1.3 "OXM" match (ofp_match with
+// type OFPMT_OXM), padded to a multiple of 8 bytes as the spec requires.
+func (m matchFields) marshal() []byte {
+	var oxm bytes.Buffer
+	if m.inPort != nil {
+		v := make([]byte, 4)
+		binary.BigEndian.PutUint32(v, *m.inPort)
+		oxm.Write(oxmTLV(oxmOfInPort, v))
+	}
+	if m.ethType != nil {
+		v := make([]byte, 2)
+		binary.BigEndian.PutUint16(v, *m.ethType)
+		oxm.Write(oxmTLV(oxmOfEthType, v))
+	}
+	if m.ethDst != nil {
+		oxm.Write(oxmTLV(oxmOfEthDst, []byte(m.ethDst)))
+	}
+	if m.arpTpa != nil {
+		oxm.Write(oxmTLV(oxmOfArpTpa, []byte(m.arpTpa.To4())))
+	}
+	if m.ipProto != nil {
+		oxm.Write(oxmTLV(oxmOfIPProto, []byte{*m.ipProto}))
+	}
+	if m.ipv6Dst != nil {
+		oxm.Write(oxmTLV(oxmOfIPv6Dst, []byte(m.ipv6Dst.To16())))
+	}
+	if m.icmpv6Type != nil {
+		oxm.Write(oxmTLV(oxmOfICMPv6Type, []byte{*m.icmpv6Type}))
+	}
+	if m.ipv6NDTgt != nil {
+		oxm.Write(oxmTLV(oxmOfIPv6NDTgt, []byte(m.ipv6NDTgt.To16())))
+	}
+	if m.tunnelID != nil {
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, *m.tunnelID)
+		oxm.Write(oxmTLV(oxmOfTunnelID, v))
+	}
+
+	matchLen := 4 + oxm.Len() // ofp_match's own type+length header is 4 bytes
+	padded := (matchLen + 7) / 8 * 8
+
+	buf := make([]byte, 4, padded)
+	binary.BigEndian.PutUint16(buf[0:2], 1) // OFPMT_OXM
+	binary.BigEndian.PutUint16(buf[2:4], uint16(matchLen))
+	buf = append(buf, oxm.Bytes()...)
+	buf = append(buf, make([]byte, padded-matchLen)...)
+	return buf
+}
+
+// instructionApplyActions wraps actions (already-marshaled ofp_action
+// structs) in an ofp_instruction_actions with type OFPIT_APPLY_ACTIONS.
+func instructionApplyActions(actions []byte) []byte {
+	length := 8 + len(actions)
+	buf := make([]byte, 8, length)
+	binary.BigEndian.PutUint16(buf[0:2], 4) // OFPIT_APPLY_ACTIONS
+	binary.BigEndian.PutUint16(buf[2:4], uint16(length))
+	return append(buf, actions...)
+}
+
+// actionOutput encodes an ofp_action_output sending the packet out port.
+func actionOutput(port uint32) []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint16(buf[0:2], 0) // OFPAT_OUTPUT
+	binary.BigEndian.PutUint16(buf[2:4], 16)
+	binary.BigEndian.PutUint32(buf[4:8], port)
+	binary.BigEndian.PutUint16(buf[8:10], 0xffff) // max_len: OFPCML_NO_BUFFER equivalent for this fabric, send whole packet
+	return buf
+}
+
+// flowMod builds an OFPT_FLOW_MOD message. priority and cookie let the
+// fabric tag and later find the flows belonging to one Network Interface;
+// outPort is where Apply-Actions:Output should send matching packets.
+func flowMod(xid uint32, command uint8, cookie uint64, priority uint16, match matchFields, outPort uint32) []byte {
+	const headerLen = 8
+	const bodyFixedLen = 40 // ofp_flow_mod's fixed fields, excluding match and instructions
+
+	matchBytes := match.marshal()
+	actions := actionOutput(outPort)
+	instructions := instructionApplyActions(actions)
+
+	total := headerLen + bodyFixedLen + len(matchBytes) + len(instructions)
+	msg := new(bytes.Buffer)
+	msg.Write(ofpHeader{Version: ofp13Version, Type: ofptFlowMod, Length: uint16(total), XID: xid}.marshal())
+
+	binary.Write(msg, binary.BigEndian, cookie)
+	binary.Write(msg, binary.BigEndian, uint64(0)) // cookie_mask: match cookie exactly on delete
+	msg.WriteByte(0)                               // table_id: the fabric uses a single table
+	msg.WriteByte(command)
+	binary.Write(msg, binary.BigEndian, uint16(0)) // idle_timeout
+	binary.Write(msg, binary.BigEndian, uint16(0)) // hard_timeout
+	binary.Write(msg, binary.BigEndian, priority)
+	binary.Write(msg, binary.BigEndian, uint32(0xffffffff)) // buffer_id: OFP_NO_BUFFER
+	binary.Write(msg, binary.BigEndian, uint32(0xffffffff)) // out_port: OFPP_ANY
+	binary.Write(msg, binary.BigEndian, uint32(0xffffffff)) // out_group: OFPG_ANY
+	binary.Write(msg, binary.BigEndian, uint16(0))          // flags
+	binary.Write(msg, binary.BigEndian, uint16(0))          // pad
+
+	msg.Write(matchBytes)
+	if command == ofpfcAdd {
+		msg.Write(instructions)
+	}
+	return msg.Bytes()
+}
+
+// bundleMessage builds an OFPT_BUNDLE_ADD_MESSAGE wrapping inner (a flow-mod
+// produced by flowMod), so it can be applied as part of an atomic bundle.
+func bundleMessage(xid uint32, bundleID uint32, inner []byte) []byte {
+	const headerLen = 8
+	const fixedLen = 8 // bundle_id + padding + flags
+
+	total := headerLen + fixedLen + len(inner)
+	buf := new(bytes.Buffer)
+	buf.Write(ofpHeader{Version: ofp13Version, Type: ofptBundleAddMsg, Length: uint16(total), XID: xid}.marshal())
+	binary.Write(buf, binary.BigEndian, bundleID)
+	binary.Write(buf, binary.BigEndian, uint16(0)) // pad
+	binary.Write(buf, binary.BigEndian, ofpbfAtomic)
+	buf.Write(inner)
+	return buf.Bytes()
+}
+
+// bundleCtrl builds an OFPT_BUNDLE_CTRL_MSG requesting bundleCtrlType
+// (open or commit) for bundleID.
+func bundleCtrl(xid uint32, bundleID uint32, bundleCtrlType uint16) []byte {
+	const headerLen = 8
+	const bodyLen = 8
+	total := headerLen + bodyLen
+	buf := new(bytes.Buffer)
+	buf.Write(ofpHeader{Version: ofp13Version, Type: ofptBundleCtrl, Length: uint16(total), XID: xid}.marshal())
+	binary.Write(buf, binary.BigEndian, bundleID)
+	binary.Write(buf, binary.BigEndian, bundleCtrlType)
+	binary.Write(buf, binary.BigEndian, ofpbfAtomic)
+	return buf.Bytes()
+}
+
+// hello builds the OFPT_HELLO message this fabric sends first on every new
+// OpenFlow connection, advertising (only) OpenFlow 1.3 support.
+func hello(xid uint32) []byte {
+	return ofpHeader{Version: ofp13Version, Type: ofptHello, Length: 8, XID: xid}.marshal()
+}
+
+// readMessage reads one length-prefixed OpenFlow message from r's already
+// consumed 8-byte header h, returning the full message (header included).
+func readMessageBody(h []byte) (ofpHeader, error) {
+	if len(h) < 8 {
+		return ofpHeader{}, fmt.Errorf("short OpenFlow header: %d bytes", len(h))
+	}
+	return ofpHeader{
+		Version: h[0],
+		Type:    h[1],
+		Length:  binary.BigEndian.Uint16(h[2:4]),
+		XID:     binary.BigEndian.Uint32(h[4:8]),
+	}, nil
+}
+
+// ofConn is a bare-bones OpenFlow 1.3 switch connection: just enough to
+// send flow-mods (individually or wrapped in an atomic bundle) and to
+// perform the version negotiation (HELLO) every switch requires before it
+// will accept anything else. It has no request/reply matching because this
+// fabric never needs to read back a reply to a message it sends.
+type ofConn struct {
+	conn net.Conn
+	xid  uint32
+}
+
+// dialOpenFlow connects to the OpenFlow controller socket at socketPath
+// (ovs-vswitchd is configured to dial or listen on this as the bridge's
+// controller target) and completes the OFPT_HELLO handshake.
+func dialOpenFlow(socketPath string) (*ofConn, error) {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial OpenFlow socket %q: %s", socketPath, err.Error())
+	}
+	c := &ofConn{conn: conn}
+	if _, err := conn.Write(hello(c.nextXID())); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send OFPT_HELLO: %s", err.Error())
+	}
+	if err := c.readHello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// readHello reads the switch's own OFPT_HELLO (and discards its body, a
+// version bitmap this fabric doesn't need: it only ever speaks 1.3).
+func (c *ofConn) readHello() error {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return fmt.Errorf("failed to read OFPT_HELLO: %s", err.Error())
+	}
+	h, err := readMessageBody(header)
+	if err != nil {
+		return err
+	}
+	if h.Type != ofptHello {
+		return fmt.Errorf("expected OFPT_HELLO (type %d), got type %d", ofptHello, h.Type)
+	}
+	if h.Length > 8 {
+		body := make([]byte, h.Length-8)
+		if _, err := io.ReadFull(c.conn, body); err != nil {
+			return fmt.Errorf("failed to read OFPT_HELLO body: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+func (c *ofConn) nextXID() uint32 {
+	return atomic.AddUint32(&c.xid, 1)
+}
+
+// send writes one already-encoded OpenFlow message to the switch.
+func (c *ofConn) send(msg []byte) error {
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// sendBundle applies flowMods atomically: OPEN a bundle, ADD_MESSAGE each
+// flow-mod into it, then COMMIT, per OpenFlow 1.3's bundle extension. If
+// any write fails partway through, the switch discards the bundle itself
+// when the underlying connection error surfaces, so no explicit DISCARD is
+// sent here.
+func (c *ofConn) sendBundle(flowMods ...[]byte) error {
+	bundleID := c.nextXID()
+	if err := c.send(bundleCtrl(c.nextXID(), bundleID, ofpbctOpenRequest)); err != nil {
+		return fmt.Errorf("failed to open OpenFlow bundle: %s", err.Error())
+	}
+	for _, fm := range flowMods {
+		if err := c.send(bundleMessage(c.nextXID(), bundleID, fm)); err != nil {
+			return fmt.Errorf("failed to add message to OpenFlow bundle: %s", err.Error())
+		}
+	}
+	if err := c.send(bundleCtrl(c.nextXID(), bundleID, ofpbctCommitRequest)); err != nil {
+		return fmt.Errorf("failed to commit OpenFlow bundle: %s", err.Error())
+	}
+	return nil
+}
+
+// Close closes the underlying OpenFlow connection.
+func (c *ofConn) Close() error {
+	return c.conn.Close()
+}
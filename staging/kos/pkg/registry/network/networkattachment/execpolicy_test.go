@@ -0,0 +1,116 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkattachment
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/examples/staging/kos/pkg/apis/network"
+)
+
+type fakeExecPolicyGetter map[string]*network.ExecPolicy
+
+func (f fakeExecPolicyGetter) ByNamespace(namespace string) (*network.ExecPolicy, error) {
+	return f[namespace], nil
+}
+
+func TestExecPolicyValidatorRejectsWithoutBoundPolicy(t *testing.T) {
+	v := NewExecPolicyValidator(fakeExecPolicyGetter{})
+	na := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{PostCreateExec: []string{"/bin/true"}}}
+	na.Namespace = "ns"
+	if errs := v.ValidateCreate(context.Background(), na); len(errs) == 0 {
+		t.Errorf("got no errors for a namespace with no bound ExecPolicy, want one")
+	}
+}
+
+func TestExecPolicyValidatorRejectsDisallowedProgram(t *testing.T) {
+	v := NewExecPolicyValidator(fakeExecPolicyGetter{
+		"ns": {Spec: network.ExecPolicySpec{Namespace: "ns", AllowedPrograms: []string{"/opt/allowed/*"}}},
+	})
+	na := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{PostCreateExec: []string{"/bin/rm", "-rf", "/"}}}
+	na.Namespace = "ns"
+	errs := v.ValidateCreate(context.Background(), na)
+	if len(errs) == 0 {
+		t.Errorf("got no errors for a program outside AllowedPrograms, want one")
+	}
+}
+
+func TestExecPolicyValidatorRejectsDisallowedVariable(t *testing.T) {
+	v := NewExecPolicyValidator(fakeExecPolicyGetter{
+		"ns": {Spec: network.ExecPolicySpec{Namespace: "ns", AllowedPrograms: []string{"/opt/allowed/*"}}},
+	})
+	na := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{PostCreateExec: []string{"/opt/allowed/hook", "${secret}"}}}
+	na.Namespace = "ns"
+	errs := v.ValidateCreate(context.Background(), na)
+	if len(errs) == 0 {
+		t.Errorf("got no errors for a variable outside AllowedVariables, want one")
+	}
+}
+
+func TestExecPolicyValidatorAcceptsAllowedCommand(t *testing.T) {
+	v := NewExecPolicyValidator(fakeExecPolicyGetter{
+		"ns": {Spec: network.ExecPolicySpec{Namespace: "ns", AllowedPrograms: []string{"/opt/allowed/*"}}},
+	})
+	na := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{PostCreateExec: []string{"/opt/allowed/hook", "${ifname}", "${ipv4}"}}}
+	na.Namespace = "ns"
+	if errs := v.ValidateCreate(context.Background(), na); len(errs) != 0 {
+		t.Errorf("got errors %v for an allowed command, want none", errs)
+	}
+}
+
+func TestExecPolicyValidatorSkipsEmptyExecFields(t *testing.T) {
+	v := NewExecPolicyValidator(fakeExecPolicyGetter{})
+	na := &network.NetworkAttachment{}
+	na.Namespace = "ns"
+	if errs := v.ValidateCreate(context.Background(), na); len(errs) != 0 {
+		t.Errorf("got errors %v for a NetworkAttachment with no exec fields set, want none", errs)
+	}
+}
+
+func TestExecPolicyValidatorUpdateIgnoresUnchangedPostDeleteExec(t *testing.T) {
+	v := NewExecPolicyValidator(fakeExecPolicyGetter{})
+	na := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{PostCreateExec: []string{"/bin/true"}}}
+	na.Namespace = "ns"
+	old := *na
+	if errs := v.ValidateUpdate(context.Background(), na, &old); len(errs) != 0 {
+		t.Errorf("got errors %v for an update that leaves PostDeleteExec unchanged, want none", errs)
+	}
+}
+
+func TestCanonicalizeExecCollapsesWhitespaceAndResolvesPath(t *testing.T) {
+	got := canonicalizeExec([]string{"bin/hook", "  a   b  "})
+	if got[1] != "a b" {
+		t.Errorf("got argument %q, want collapsed whitespace %q", got[1], "a b")
+	}
+	if got[0] == "bin/hook" {
+		t.Errorf("got program %q, want it resolved to an absolute path", got[0])
+	}
+}
+
+func TestChainRunsEveryValidator(t *testing.T) {
+	v := Chain(
+		NewExecPolicyValidator(fakeExecPolicyGetter{}),
+		NewSubnetValidator(fakeSubnetGetter{}, fakeNetworkAttachmentIndexer{}),
+	)
+	na := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{Subnet: "missing", PostCreateExec: []string{"/bin/true"}}}
+	na.Namespace = "ns"
+	errs := v.ValidateCreate(context.Background(), na)
+	if len(errs) < 2 {
+		t.Errorf("got %d errors combining an exec-policy and a subnet violation, want at least 2", len(errs))
+	}
+}
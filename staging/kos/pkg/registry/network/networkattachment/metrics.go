@@ -0,0 +1,96 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkattachment
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	metricsNamespace = "kos"
+	metricsSubsystem = "networkattachment_strategy"
+)
+
+// The result label values used by validateUpdateTotal.
+const (
+	resultAccept                 = "accept"
+	resultImmutableFieldRejected = "immutable_field_rejected"
+)
+
+// The updateType label values used by prepareForUpdateDuration and
+// sectionWritesTotal: whether the strategy hook ran against the main
+// resource (spec) or the status subresource (status).
+const (
+	updateTypeSpec   = "spec"
+	updateTypeStatus = "status"
+)
+
+var (
+	// prepareForCreateDuration observes how long PrepareForCreate takes.
+	prepareForCreateDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "prepare_for_create_duration_seconds",
+		Help:      "Time taken by networkattachmentStrategy.PrepareForCreate.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// prepareForUpdateDuration observes how long PrepareForUpdate takes,
+	// labeled by whether it ran against the main resource or the status
+	// subresource.
+	prepareForUpdateDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "prepare_for_update_duration_seconds",
+		Help:      "Time taken by PrepareForUpdate, by updateType.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"updateType"})
+
+	// validateUpdateTotal counts ValidateUpdate outcomes.
+	validateUpdateTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "validate_update_total",
+		Help:      "Number of ValidateUpdate calls, by result.",
+	}, []string{"result"})
+
+	// sectionWritesTotal counts ExtendedObjectMeta.Writes.SetWrite calls,
+	// by section and by whether the triggering update was to the main
+	// resource or the status subresource.
+	sectionWritesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubsystem,
+		Name:      "section_writes_total",
+		Help:      "Number of Writes.SetWrite invocations, by section and updateType.",
+	}, []string{"section", "updateType"})
+)
+
+// Register registers this package's collectors with r. Callers that do not
+// want these metrics (e.g. because they embed several NetworkAttachment
+// strategies in the same process) may call this more than once against
+// distinct registries, but must not call it twice against the same one.
+func Register(r prometheus.Registerer) {
+	r.MustRegister(
+		prepareForCreateDuration,
+		prepareForUpdateDuration,
+		validateUpdateTotal,
+		sectionWritesTotal,
+	)
+}
+
+// recordWrite observes one SetWrite of section, attributed to updateType.
+func recordWrite(section, updateType string) {
+	sectionWritesTotal.WithLabelValues(section, updateType).Inc()
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkattachment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"k8s.io/examples/staging/kos/pkg/apis/network"
+)
+
+func TestPrepareForCreateRecordsSpecWrite(t *testing.T) {
+	sectionWritesTotal.Reset()
+	s := networkattachmentStrategy{}
+	na := &network.NetworkAttachment{}
+	s.PrepareForCreate(context.Background(), na)
+	if got := testutil.ToFloat64(sectionWritesTotal.WithLabelValues(network.NASectionSpec, updateTypeSpec)); got != 1 {
+		t.Errorf("got %v spec writes after PrepareForCreate, want 1", got)
+	}
+}
+
+func TestValidateUpdateCountsAcceptAndReject(t *testing.T) {
+	validateUpdateTotal.Reset()
+	s := networkattachmentStrategy{}
+	oldNA := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{Node: "n1", Subnet: "s1"}}
+
+	sameNA := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{Node: "n1", Subnet: "s1"}}
+	s.ValidateUpdate(context.Background(), sameNA, oldNA)
+	if got := testutil.ToFloat64(validateUpdateTotal.WithLabelValues(resultAccept)); got != 1 {
+		t.Errorf("got %v accepts, want 1", got)
+	}
+
+	changedNA := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{Node: "n2", Subnet: "s1"}}
+	s.ValidateUpdate(context.Background(), changedNA, oldNA)
+	if got := testutil.ToFloat64(validateUpdateTotal.WithLabelValues(resultImmutableFieldRejected)); got != 1 {
+		t.Errorf("got %v rejects, want 1", got)
+	}
+}
+
+func TestStatusPrepareForUpdateRecordsSectionWrites(t *testing.T) {
+	sectionWritesTotal.Reset()
+	ss := networkattachmentStatusStrategy{}
+	oldNA := &network.NetworkAttachment{}
+	newNA := &network.NetworkAttachment{Status: network.NetworkAttachmentStatus{
+		IPv4:       "10.0.0.1",
+		MACAddress: "de:ad:be:ef:00:01",
+	}}
+	ss.PrepareForUpdate(context.Background(), newNA, oldNA)
+
+	if got := testutil.ToFloat64(sectionWritesTotal.WithLabelValues(network.NASectionAddr, updateTypeStatus)); got != 1 {
+		t.Errorf("got %v status.address writes, want 1", got)
+	}
+	if got := testutil.ToFloat64(sectionWritesTotal.WithLabelValues(network.NASectionImpl, updateTypeStatus)); got != 1 {
+		t.Errorf("got %v status.impl writes, want 1", got)
+	}
+}
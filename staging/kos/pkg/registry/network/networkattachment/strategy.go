@@ -19,9 +19,14 @@ package networkattachment
 import (
 	"context"
 	"fmt"
+	"net"
+	"os"
 	"strconv"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -35,9 +40,12 @@ import (
 )
 
 // NewStrategies creates and returns strategy objects for the main
-// resource and its status subresource
-func NewStrategies(typer runtime.ObjectTyper) (networkattachmentStrategy, networkattachmentStatusStrategy) {
-	s := networkattachmentStrategy{typer, names.SimpleNameGenerator}
+// resource and its status subresource. validator is consulted by Validate
+// and ValidateUpdate after their own field-level checks; pass
+// NewSubnetValidator's result for the default in-process behavior, or nil
+// to skip cross-object admission entirely.
+func NewStrategies(typer runtime.ObjectTyper, validator Validator) (networkattachmentStrategy, networkattachmentStatusStrategy) {
+	s := networkattachmentStrategy{typer, names.SimpleNameGenerator, validator}
 	return s, networkattachmentStatusStrategy{s}
 }
 
@@ -51,6 +59,20 @@ func GetAttrs(obj runtime.Object) (labels.Set, fields.Set, error) {
 	return labels.Set(networkattachment.ObjectMeta.Labels), SelectableFields(networkattachment), nil
 }
 
+// networkAttachmentReady reports whether obj's Linux network interface is
+// fully bound: it has an IPv4 address, a MAC address, an interface name,
+// and a host, and carries no IPAM/Host errors. Watchers (e.g. the
+// connection agent on obj.Spec.Node) can use the status.ready selectable
+// field derived from this instead of polling and filtering client-side.
+func networkAttachmentReady(obj *network.NetworkAttachment) bool {
+	return obj.Status.IPv4 != "" &&
+		obj.Status.MACAddress != "" &&
+		obj.Status.IfcName != "" &&
+		obj.Status.HostIP != "" &&
+		len(obj.Status.Errors.IPAM) == 0 &&
+		len(obj.Status.Errors.Host) == 0
+}
+
 // MatchNetworkAttachment is the filter used by the generic etcd backend to
 // watch events from etcd to clients of the apiserver only interested in
 // specific labels/fields.
@@ -63,14 +85,24 @@ func MatchNetworkAttachment(label labels.Selector, field fields.Selector) storag
 }
 
 // SelectableFields returns a field set that represents the object.
+//
+// status.addressContention is not exposed here: NetworkAttachmentStatus
+// has no such field yet (see the stale reference to it in
+// networkattachmentStatusStrategy.PrepareForUpdate), so there is nothing
+// to select on.
 func SelectableFields(obj *network.NetworkAttachment) fields.Set {
 	return generic.AddObjectMetaFieldsSet(
 		fields.Set{
 			"spec.node":         obj.Spec.Node,
 			"spec.subnet":       obj.Spec.Subnet,
 			"status.ipv4":       obj.Status.IPv4,
+			"status.ipv6":       obj.Status.IPv6,
 			"status.hostIP":     obj.Status.HostIP,
 			"status.addressVNI": strconv.FormatUint(uint64(obj.Status.AddressVNI), 10),
+			"status.macAddress": obj.Status.MACAddress,
+			"status.ifcName":    obj.Status.IfcName,
+			"status.lockUID":    obj.Status.LockUID,
+			"status.ready":      strconv.FormatBool(networkAttachmentReady(obj)),
 		},
 		&obj.ObjectMeta, true)
 }
@@ -78,36 +110,85 @@ func SelectableFields(obj *network.NetworkAttachment) fields.Set {
 type networkattachmentStrategy struct {
 	runtime.ObjectTyper
 	names.NameGenerator
+	validator Validator
 }
 
 var _ rest.RESTCreateStrategy = networkattachmentStrategy{}
 var _ rest.RESTUpdateStrategy = networkattachmentStrategy{}
 var _ rest.RESTDeleteStrategy = networkattachmentStrategy{}
+var _ rest.RESTGracefulDeleteStrategy = networkattachmentStrategy{}
 
 func (networkattachmentStrategy) NamespaceScoped() bool {
 	return true
 }
 
 func (networkattachmentStrategy) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	defer prometheus.NewTimer(prepareForCreateDuration).ObserveDuration()
 	na := obj.(*network.NetworkAttachment)
 	na.ExtendedObjectMeta = network.ExtendedObjectMeta{}
-	na.Writes = na.Writes.SetWrite(network.NASectionSpec, network.Now())
+	na.Spec.PostCreateExec = canonicalizeExec(na.Spec.PostCreateExec)
+	na.Spec.PostDeleteExec = canonicalizeExec(na.Spec.PostDeleteExec)
+	na.Spec.PreDeleteExec = canonicalizeExec(na.Spec.PreDeleteExec)
+	na.Writes = na.Writes.SetWrite(network.NASectionSpec, network.Tick(network.Timestamp{}, selfNodeID()))
+	recordWrite(network.NASectionSpec, updateTypeSpec)
 	na.Status = network.NetworkAttachmentStatus{}
 }
 
 func (networkattachmentStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	defer prometheus.NewTimer(prepareForUpdateDuration.WithLabelValues(updateTypeSpec)).ObserveDuration()
 	oldNA := old.(*network.NetworkAttachment)
 	newNA := obj.(*network.NetworkAttachment)
 	newNA.Status = oldNA.Status
 	newNA.ExtendedObjectMeta = oldNA.ExtendedObjectMeta
+	// newNA.ObjectMeta (and so its Finalizers) passes through untouched,
+	// so the connection agent can add or remove PreDeleteExecFinalizer
+	// without affecting Generation below.
 	// ValidateUpdate insists that the only Spec field that can change is PostDeleteExec
+	newNA.Spec.PostDeleteExec = canonicalizeExec(newNA.Spec.PostDeleteExec)
 	if !SliceOfStringEqual(oldNA.Spec.PostDeleteExec, newNA.Spec.PostDeleteExec) {
-		now := network.Now()
+		now := network.Tick(newNA.Writes.MaxWrite(), selfNodeID())
 		newNA.Writes = newNA.Writes.SetWrite(network.NASectionSpec, now)
+		recordWrite(network.NASectionSpec, updateTypeSpec)
 		newNA.Generation = oldNA.Generation + 1
 	}
 }
 
+// CheckGracefulDelete adds network.PreDeleteExecFinalizer to obj when it has
+// a non-empty Spec.PreDeleteExec, so that the connection agent gets a
+// chance to run that command before the NetworkAttachment is actually
+// removed. It returns false (ordinary, non-graceful delete) when
+// PreDeleteExec is empty, preserving prior behavior.
+func (networkattachmentStrategy) CheckGracefulDelete(ctx context.Context, obj runtime.Object, options *metav1.DeleteOptions) bool {
+	na := obj.(*network.NetworkAttachment)
+	if len(na.Spec.PreDeleteExec) == 0 {
+		return false
+	}
+	if options.GracePeriodSeconds == nil {
+		zero := int64(0)
+		options.GracePeriodSeconds = &zero
+	}
+	for _, finalizer := range na.Finalizers {
+		if finalizer == network.PreDeleteExecFinalizer {
+			return true
+		}
+	}
+	na.Finalizers = append(na.Finalizers, network.PreDeleteExecFinalizer)
+	now := network.Tick(na.Writes.MaxWrite(), selfNodeID())
+	na.Writes = na.Writes.SetWrite(network.NASectionPreDeleteExec, now)
+	return true
+}
+
+// selfNodeID identifies this apiserver process as an HLC writer, so that
+// writes it records compare deterministically against writes recorded by
+// other apiservers with the same physical time and logical counter.
+func selfNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
 func SliceOfStringEqual(x, y []string) bool {
 	if len(x) != len(y) {
 		return false
@@ -120,8 +201,25 @@ func SliceOfStringEqual(x, y []string) bool {
 	return true
 }
 
-func (networkattachmentStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
-	return field.ErrorList{}
+func (s networkattachmentStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+	var errs field.ErrorList
+	na := obj.(*network.NetworkAttachment)
+	if na.Spec.RequestedIPv4 != "" && net.ParseIP(na.Spec.RequestedIPv4) == nil {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "requestedIPv4"), na.Spec.RequestedIPv4, "not a valid IP address"))
+	}
+	if na.Spec.RequestedIPv6 != "" && net.ParseIP(na.Spec.RequestedIPv6) == nil {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "requestedIPv6"), na.Spec.RequestedIPv6, "not a valid IP address"))
+	}
+	// Checking that a requested address actually lies inside the Subnet's
+	// ranges needs the Subnet object, which this strategy has no way to
+	// look up; ipam.ValidateRequestedAddress does that check and the IPAM
+	// path (not yet wired to any controller in this module) is expected
+	// to call it, surfacing a miss as a Status.Errors.IPAM entry rather
+	// than failing admission.
+	if s.validator != nil {
+		errs = append(errs, s.validator.ValidateCreate(ctx, na)...)
+	}
+	return errs
 }
 
 func (networkattachmentStrategy) AllowCreateOnUpdate() bool {
@@ -135,7 +233,7 @@ func (networkattachmentStrategy) AllowUnconditionalUpdate() bool {
 func (networkattachmentStrategy) Canonicalize(obj runtime.Object) {
 }
 
-func (networkattachmentStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+func (s networkattachmentStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
 	var errs field.ErrorList
 	immutableFieldMsg := "attempt to update immutable field"
 	newNa, oldNa := obj.(*network.NetworkAttachment), old.(*network.NetworkAttachment)
@@ -145,9 +243,26 @@ func (networkattachmentStrategy) ValidateUpdate(ctx context.Context, obj, old ru
 	if newNa.Spec.Subnet != oldNa.Spec.Subnet {
 		errs = append(errs, field.Forbidden(field.NewPath("spec", "subnet"), immutableFieldMsg))
 	}
+	if newNa.Spec.RequestedIPv4 != oldNa.Spec.RequestedIPv4 {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "requestedIPv4"), immutableFieldMsg))
+	}
+	if newNa.Spec.RequestedIPv6 != oldNa.Spec.RequestedIPv6 {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "requestedIPv6"), immutableFieldMsg))
+	}
 	if !SliceOfStringEqual(newNa.Spec.PostCreateExec, oldNa.Spec.PostCreateExec) {
 		errs = append(errs, field.Forbidden(field.NewPath("spec", "postCreateExec"), immutableFieldMsg))
 	}
+	if !SliceOfStringEqual(newNa.Spec.PreDeleteExec, oldNa.Spec.PreDeleteExec) {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "preDeleteExec"), immutableFieldMsg))
+	}
+	if s.validator != nil {
+		errs = append(errs, s.validator.ValidateUpdate(ctx, newNa, oldNa)...)
+	}
+	if len(errs) == 0 {
+		validateUpdateTotal.WithLabelValues(resultAccept).Inc()
+	} else {
+		validateUpdateTotal.WithLabelValues(resultImmutableFieldRejected).Inc()
+	}
 	return errs
 }
 
@@ -162,12 +277,13 @@ func (networkattachmentStatusStrategy) AllowUnconditionalUpdate() bool {
 }
 
 func (networkattachmentStatusStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	defer prometheus.NewTimer(prepareForUpdateDuration.WithLabelValues(updateTypeStatus)).ObserveDuration()
 	newNA := obj.(*network.NetworkAttachment)
 	oldNA := old.(*network.NetworkAttachment)
 	// update is not allowed to set spec
 	newNA.Spec = oldNA.Spec
 	newNA.ExtendedObjectMeta = oldNA.ExtendedObjectMeta
-	now := network.Now()
+	now := network.Tick(newNA.Writes.MaxWrite(), selfNodeID())
 	if oldNA.Status.LockUID != newNA.Status.LockUID ||
 		oldNA.Status.AddressVNI != newNA.Status.AddressVNI ||
 		oldNA.Status.IPv4 != newNA.Status.IPv4 ||
@@ -175,13 +291,41 @@ func (networkattachmentStatusStrategy) PrepareForUpdate(ctx context.Context, obj
 		!(&oldNA.Status.SubnetCreationTime).Equal(&newNA.Status.SubnetCreationTime) ||
 		!SliceOfStringEqual(oldNA.Status.Errors.IPAM, newNA.Status.Errors.IPAM) {
 		newNA.Writes = newNA.Writes.SetWrite(network.NASectionAddr, now)
+		recordWrite(network.NASectionAddr, updateTypeStatus)
+	}
+	if oldNA.Status.LockUID6 != newNA.Status.LockUID6 ||
+		oldNA.Status.AddressVNIv6 != newNA.Status.AddressVNIv6 ||
+		oldNA.Status.IPv6 != newNA.Status.IPv6 {
+		newNA.Writes = newNA.Writes.SetWrite(network.NASectionAddr6, now)
 	}
 	if oldNA.Status.MACAddress != newNA.Status.MACAddress || oldNA.Status.IfcName != newNA.Status.IfcName || oldNA.Status.HostIP != newNA.Status.HostIP || !SliceOfStringEqual(oldNA.Status.Errors.Host, newNA.Status.Errors.Host) {
 		newNA.Writes = newNA.Writes.SetWrite(network.NASectionImpl, now)
+		recordWrite(network.NASectionImpl, updateTypeStatus)
 	}
 	if !oldNA.Status.PostCreateExecReport.Equiv(newNA.Status.PostCreateExecReport) {
 		newNA.Writes = newNA.Writes.SetWrite(network.NASectionExecReport, now)
+		recordWrite(network.NASectionExecReport, updateTypeStatus)
+	}
+	if requestedAddressJustHonored(newNA.Spec.RequestedIPv4, oldNA.Status.IPv4, newNA.Status.IPv4) ||
+		requestedAddressJustHonored(newNA.Spec.RequestedIPv6, oldNA.Status.IPv6, newNA.Status.IPv6) {
+		newNA.Writes = newNA.Writes.SetWrite(network.NASectionRequestedAddr, now)
+	}
+	if !network.RoutesEqual(oldNA.Status.Routes, newNA.Status.Routes) {
+		newNA.Writes = newNA.Writes.SetWrite(network.NASectionRoutes, now)
 	}
+	if !oldNA.Status.DNS.Equal(newNA.Status.DNS) {
+		newNA.Writes = newNA.Writes.SetWrite(network.NASectionDNS, now)
+	}
+	if !oldNA.Status.PreDeleteExecReport.Equiv(newNA.Status.PreDeleteExecReport) {
+		newNA.Writes = newNA.Writes.SetWrite(network.NASectionPreDeleteExec, now)
+	}
+}
+
+// requestedAddressJustHonored reports whether this update is the one that
+// granted a pinned address: the attachment requested one, newStatusIP now
+// holds exactly it, and oldStatusIP did not yet.
+func requestedAddressJustHonored(requested, oldStatusIP, newStatusIP string) bool {
+	return requested != "" && newStatusIP == requested && oldStatusIP != requested
 }
 
 func (networkattachmentStatusStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networkattachment
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/examples/staging/kos/pkg/apis/network"
+)
+
+func TestSelectableFieldsReady(t *testing.T) {
+	bound := &network.NetworkAttachment{
+		Spec: network.NetworkAttachmentSpec{Node: "node1", Subnet: "subnet1"},
+		Status: network.NetworkAttachmentStatus{
+			IPv4:       "10.0.0.1",
+			MACAddress: "de:ad:be:ef:00:01",
+			IfcName:    "veth0",
+			HostIP:     "192.168.1.1",
+		},
+	}
+	if got := SelectableFields(bound)["status.ready"]; got != "true" {
+		t.Errorf("got status.ready %q for a fully bound attachment, want true", got)
+	}
+
+	unbound := *bound
+	unbound.Status.HostIP = ""
+	if got := SelectableFields(&unbound)["status.ready"]; got != "false" {
+		t.Errorf("got status.ready %q for an attachment with no HostIP, want false", got)
+	}
+
+	erroring := *bound
+	erroring.Status.Errors.IPAM = []string{"boom"}
+	if got := SelectableFields(&erroring)["status.ready"]; got != "false" {
+		t.Errorf("got status.ready %q for an attachment with an IPAM error, want false", got)
+	}
+}
+
+func TestSelectableFieldsImplFields(t *testing.T) {
+	na := &network.NetworkAttachment{
+		Status: network.NetworkAttachmentStatus{
+			MACAddress: "de:ad:be:ef:00:01",
+			IfcName:    "veth0",
+			LockUID:    "some-uid",
+		},
+	}
+	fields := SelectableFields(na)
+	if got := fields["status.macAddress"]; got != "de:ad:be:ef:00:01" {
+		t.Errorf("got status.macAddress %q, want de:ad:be:ef:00:01", got)
+	}
+	if got := fields["status.ifcName"]; got != "veth0" {
+		t.Errorf("got status.ifcName %q, want veth0", got)
+	}
+	if got := fields["status.lockUID"]; got != "some-uid" {
+		t.Errorf("got status.lockUID %q, want some-uid", got)
+	}
+}
+
+func TestCheckGracefulDeleteAddsFinalizerOnlyWithPreDeleteExec(t *testing.T) {
+	s := networkattachmentStrategy{}
+
+	plain := &network.NetworkAttachment{}
+	if s.CheckGracefulDelete(context.Background(), plain, &metav1.DeleteOptions{}) {
+		t.Errorf("got graceful delete for a NetworkAttachment with no PreDeleteExec, want false")
+	}
+	if len(plain.Finalizers) != 0 {
+		t.Errorf("got finalizers %v for a NetworkAttachment with no PreDeleteExec, want none", plain.Finalizers)
+	}
+
+	withExec := &network.NetworkAttachment{Spec: network.NetworkAttachmentSpec{PreDeleteExec: []string{"true"}}}
+	options := &metav1.DeleteOptions{}
+	if !s.CheckGracefulDelete(context.Background(), withExec, options) {
+		t.Errorf("got non-graceful delete for a NetworkAttachment with PreDeleteExec, want true")
+	}
+	if options.GracePeriodSeconds == nil {
+		t.Errorf("got nil GracePeriodSeconds after CheckGracefulDelete, want a default")
+	}
+	found := false
+	for _, f := range withExec.Finalizers {
+		if f == network.PreDeleteExecFinalizer {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("got finalizers %v, want %s among them", withExec.Finalizers, network.PreDeleteExecFinalizer)
+	}
+
+	// A second call must not add the finalizer twice.
+	s.CheckGracefulDelete(context.Background(), withExec, options)
+	if len(withExec.Finalizers) != 1 {
+		t.Errorf("got finalizers %v after a second CheckGracefulDelete, want exactly one entry", withExec.Finalizers)
+	}
+}
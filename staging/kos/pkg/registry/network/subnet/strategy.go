@@ -0,0 +1,253 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnet
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/registry/generic"
+	"k8s.io/apiserver/pkg/storage"
+	"k8s.io/apiserver/pkg/storage/names"
+
+	"k8s.io/apiserver/pkg/registry/rest"
+	"k8s.io/examples/staging/kos/pkg/apis/network"
+)
+
+// NewStrategies creates and returns strategy objects for the main resource
+// and its status subresource.
+func NewStrategies(typer runtime.ObjectTyper) (subnetStrategy, subnetStatusStrategy) {
+	s := subnetStrategy{typer, names.SimpleNameGenerator}
+	return s, subnetStatusStrategy{s}
+}
+
+// GetAttrs returns labels.Set, fields.Set, and error in case the given
+// runtime.Object is not a Subnet.
+func GetAttrs(obj runtime.Object) (labels.Set, fields.Set, error) {
+	subnet, ok := obj.(*network.Subnet)
+	if !ok {
+		return nil, nil, fmt.Errorf("given object is not a Subnet")
+	}
+	return labels.Set(subnet.ObjectMeta.Labels), SelectableFields(subnet), nil
+}
+
+// MatchSubnet is the filter used by the generic etcd backend to watch
+// events from etcd to clients of the apiserver only interested in specific
+// labels/fields.
+func MatchSubnet(label labels.Selector, field fields.Selector) storage.SelectionPredicate {
+	return storage.SelectionPredicate{
+		Label:    label,
+		Field:    field,
+		GetAttrs: GetAttrs,
+	}
+}
+
+// SelectableFields returns a field set that represents the object.
+//
+// status.degraded lets a watcher (e.g. an operator dashboard) filter down
+// to Subnets with at least one not-ready Node without listing every
+// Subnet and checking Status.NotReadyNodes client-side.
+func SelectableFields(obj *network.Subnet) fields.Set {
+	return generic.AddObjectMetaFieldsSet(
+		fields.Set{
+			"status.validated": fmt.Sprintf("%t", obj.Status.Validated),
+			"status.degraded":  fmt.Sprintf("%t", obj.Status.NotReadyNodes > 0),
+		},
+		&obj.ObjectMeta, true)
+}
+
+type subnetStrategy struct {
+	runtime.ObjectTyper
+	names.NameGenerator
+}
+
+var _ rest.RESTCreateStrategy = subnetStrategy{}
+var _ rest.RESTUpdateStrategy = subnetStrategy{}
+var _ rest.RESTDeleteStrategy = subnetStrategy{}
+
+func (subnetStrategy) NamespaceScoped() bool {
+	return true
+}
+
+func (subnetStrategy) PrepareForCreate(ctx context.Context, obj runtime.Object) {
+	defer prometheus.NewTimer(prepareForCreateDuration).ObserveDuration()
+	subnet := obj.(*network.Subnet)
+	subnet.ExtendedObjectMeta = network.ExtendedObjectMeta{}
+	subnet.Writes = subnet.Writes.SetWrite(network.SubnetSectionSpec, network.Tick(network.Timestamp{}, selfNodeID()))
+	recordWrite(network.SubnetSectionSpec, updateTypeSpec)
+	subnet.Status = network.SubnetStatus{}
+}
+
+func (subnetStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	defer prometheus.NewTimer(prepareForUpdateDuration.WithLabelValues(updateTypeSpec)).ObserveDuration()
+	oldSubnet := old.(*network.Subnet)
+	newSubnet := obj.(*network.Subnet)
+	newSubnet.Status = oldSubnet.Status
+	newSubnet.ExtendedObjectMeta = oldSubnet.ExtendedObjectMeta
+	if !specEqual(oldSubnet.Spec, newSubnet.Spec) {
+		now := network.Tick(newSubnet.Writes.MaxWrite(), selfNodeID())
+		newSubnet.Writes = newSubnet.Writes.SetWrite(network.SubnetSectionSpec, now)
+		recordWrite(network.SubnetSectionSpec, updateTypeSpec)
+		newSubnet.Generation = oldSubnet.Generation + 1
+	}
+}
+
+// selfNodeID identifies this apiserver process as an HLC writer, so that
+// writes it records compare deterministically against writes recorded by
+// other apiservers with the same physical time and logical counter.
+func selfNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return hostname
+}
+
+func specEqual(x, y network.SubnetSpec) bool {
+	if x.IPv4 != y.IPv4 || x.IPv6 != y.IPv6 || x.VNI != y.VNI || len(x.Ranges) != len(y.Ranges) {
+		return false
+	}
+	for i := range x.Ranges {
+		xr, yr := x.Ranges[i], y.Ranges[i]
+		if xr.Subnet != yr.Subnet || xr.RangeStart != yr.RangeStart || xr.RangeEnd != yr.RangeEnd || xr.Gateway != yr.Gateway || !stringSliceEqual(xr.Exclude, yr.Exclude) {
+			return false
+		}
+	}
+	return true
+}
+
+func (subnetStrategy) Validate(ctx context.Context, obj runtime.Object) field.ErrorList {
+	// Conflict checking (overlapping CIDRs, mismatched namespaces for a
+	// shared VNI) is not a per-object admission concern: it depends on
+	// every other Subnet with the same VNI, which this strategy has no
+	// way to look up. pkg/controllers/subnet.ValidationController does
+	// that check out-of-band and records the result in Status.Errors.
+	return nil
+}
+
+func (subnetStrategy) AllowCreateOnUpdate() bool {
+	return false
+}
+
+func (subnetStrategy) AllowUnconditionalUpdate() bool {
+	return false
+}
+
+func (subnetStrategy) Canonicalize(obj runtime.Object) {
+}
+
+func (subnetStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	var errs field.ErrorList
+	newSubnet, oldSubnet := obj.(*network.Subnet), old.(*network.Subnet)
+	immutableFieldMsg := "attempt to update immutable field"
+	if newSubnet.Spec.IPv4 != oldSubnet.Spec.IPv4 {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "ipv4"), immutableFieldMsg))
+	}
+	if newSubnet.Spec.IPv6 != oldSubnet.Spec.IPv6 {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "ipv6"), immutableFieldMsg))
+	}
+	if newSubnet.Spec.VNI != oldSubnet.Spec.VNI {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "vni"), immutableFieldMsg))
+	}
+	if len(errs) == 0 {
+		validateUpdateTotal.WithLabelValues(resultAccept).Inc()
+	} else {
+		validateUpdateTotal.WithLabelValues(resultImmutableFieldRejected).Inc()
+	}
+	return errs
+}
+
+type subnetStatusStrategy struct {
+	subnetStrategy
+}
+
+var _ rest.RESTUpdateStrategy = subnetStatusStrategy{}
+
+func (subnetStatusStrategy) AllowUnconditionalUpdate() bool {
+	return true
+}
+
+func (subnetStatusStrategy) PrepareForUpdate(ctx context.Context, obj, old runtime.Object) {
+	defer prometheus.NewTimer(prepareForUpdateDuration.WithLabelValues(updateTypeStatus)).ObserveDuration()
+	newSubnet := obj.(*network.Subnet)
+	oldSubnet := old.(*network.Subnet)
+	// update is not allowed to set spec
+	newSubnet.Spec = oldSubnet.Spec
+	newSubnet.ExtendedObjectMeta = oldSubnet.ExtendedObjectMeta
+	if statusChanged(oldSubnet.Status, newSubnet.Status) {
+		now := network.Tick(newSubnet.Writes.MaxWrite(), selfNodeID())
+		newSubnet.Writes = newSubnet.Writes.SetWrite(network.SubnetSectionStatus, now)
+		recordWrite(network.SubnetSectionStatus, updateTypeStatus)
+	}
+}
+
+// statusChanged reports whether new differs from old in any field,
+// including NodeConditions, so that a no-op status update (an informer
+// resync re-PUTting the same Status) does not record a spurious HLC write.
+func statusChanged(old, new network.SubnetStatus) bool {
+	if old.Validated != new.Validated ||
+		old.NextRangeIndex != new.NextRangeIndex ||
+		old.ReadyNodes != new.ReadyNodes ||
+		old.NotReadyNodes != new.NotReadyNodes ||
+		!stringSliceEqual(old.Errors, new.Errors) ||
+		!stringSliceEqual(old.LastAllocatedIPs, new.LastAllocatedIPs) ||
+		len(old.NodeConditions) != len(new.NodeConditions) {
+		return true
+	}
+	for i := range old.NodeConditions {
+		a, b := old.NodeConditions[i], new.NodeConditions[i]
+		if a.Node != b.Node || a.Reason != b.Reason || a.Message != b.Message || a.Ready != b.Ready || !a.LastHeartbeatTime.Equal(&b.LastHeartbeatTime) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringSliceEqual(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i, xi := range x {
+		if xi != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (subnetStatusStrategy) ValidateUpdate(ctx context.Context, obj, old runtime.Object) field.ErrorList {
+	newSubnet := obj.(*network.Subnet)
+	oldSubnet := old.(*network.Subnet)
+	var errs field.ErrorList
+	if newSubnet.Status.ReadyNodes < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("status", "readyNodes"), newSubnet.Status.ReadyNodes, "must not be negative"))
+	}
+	if newSubnet.Status.NotReadyNodes < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("status", "notReadyNodes"), newSubnet.Status.NotReadyNodes, "must not be negative"))
+	}
+	if !specEqual(newSubnet.Spec, oldSubnet.Spec) {
+		errs = append(errs, field.Forbidden(field.NewPath("spec"), "the status subresource cannot modify spec"))
+	}
+	return errs
+}
@@ -28,6 +28,25 @@ import (
 // REST implements rest.Storage and a bunch of other interfaces based
 // on inheritance from the Store, plus CategoriesProvider and
 // ShortNamesProvider --- all based on storing API objects in etcd.
+//
+// REST already satisfies rest.CreaterUpdater by inheritance, the same way
+// it satisfies rest.StandardStorage: *genericregistry.Store provides
+// Create and Update directly. What it does not have is a
+// rest.ResetFieldsStrategy on subnetStrategy/networkAttachmentStrategy
+// (see pkg/registry/network/subnet and pkg/registry/network/networkattachment),
+// which is what the generic apiserver's PATCH handler needs to recognize
+// application/apply-patch+yaml, compute a managed-fields entry per field
+// manager, and reject a conflicting apply instead of silently taking the
+// field. That handshake is apiserver-internal and its exact shape moved
+// around a lot across versions during server-side apply's rollout; this
+// module is pinned to a k8s.io/apiserver commit from before a fixed
+// ResetFieldsStrategy shape settled, so whether this pin's endpoint
+// handler even recognizes ApplyPatchType -- and, if so, under what
+// interface -- isn't something to guess at here. Until that's confirmed
+// against this exact pin, a Subnet or NetworkAttachment apply goes through
+// the field-manager-scoped Patch/ApplyStatus path documented on
+// pkg/controllers/subnet/validator.go's persist method instead of a real
+// conflict-checked PATCH.
 type REST struct {
 	*genericregistry.Store
 	Categorys  []string
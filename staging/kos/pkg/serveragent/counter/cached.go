@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package counter
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// cacheEntry is the memoized CountAgents result for one selector string.
+type cacheEntry struct {
+	count    int
+	computed time.Time
+}
+
+// CachedCounter wraps a LeaseCounter and memoizes CountAgents per selector
+// for ttl, so a controller that checks its shard on every queue item (a
+// high-frequency caller) does not re-scan the Lease cache on every tick.
+// Safe for concurrent use.
+type CachedCounter struct {
+	inner LeaseCounter
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+var _ LeaseCounter = &CachedCounter{}
+
+// NewCachedCounter returns a CachedCounter that delegates to inner and
+// caches each selector's result for ttl. A ttl of zero disables caching:
+// every call goes straight to inner, which is useful for tests that want
+// CountAgents to reflect the informer's cache immediately.
+func NewCachedCounter(inner LeaseCounter, ttl time.Duration) *CachedCounter {
+	return &CachedCounter{
+		inner:   inner,
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *CachedCounter) CountAgents(selector labels.Selector) int {
+	key := selector.String()
+	if c.ttl > 0 {
+		c.mu.Lock()
+		entry, found := c.entries[key]
+		c.mu.Unlock()
+		if found && time.Since(entry.computed) < c.ttl {
+			return entry.count
+		}
+	}
+	count := c.inner.CountAgents(selector)
+	if c.ttl > 0 {
+		c.mu.Lock()
+		c.entries[key] = cacheEntry{count: count, computed: time.Now()}
+		c.mu.Unlock()
+	}
+	return count
+}
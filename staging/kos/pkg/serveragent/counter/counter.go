@@ -0,0 +1,81 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package counter
+
+import (
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// LeaseCounter reports how many Leases matching a label selector are
+// currently healthy, so a controller can shard work across its peers (e.g.
+// hash-mod an object name on the result) instead of every replica
+// processing every event.
+type LeaseCounter interface {
+	// CountAgents returns the number of Leases matching selector whose
+	// RenewTime is recent enough to be considered alive.
+	CountAgents(selector labels.Selector) int
+}
+
+// leaseCounter implements LeaseCounter against a shared Lease informer's
+// indexer. It does no I/O of its own: every call is a scan of whatever the
+// informer already has cached.
+type leaseCounter struct {
+	indexer cache.Indexer
+}
+
+// NewLeaseCounter returns a LeaseCounter backed by informer. informer must
+// already be started (e.g. by the same SharedInformerFactory/Run call that
+// starts a caller's other informers); CountAgents before the informer's
+// first sync simply sees an empty cache and returns 0.
+func NewLeaseCounter(informer cache.SharedIndexInformer) LeaseCounter {
+	return &leaseCounter{indexer: informer.GetIndexer()}
+}
+
+func (c *leaseCounter) CountAgents(selector labels.Selector) int {
+	count := 0
+	for _, obj := range c.indexer.List() {
+		lease, ok := obj.(*coordv1.Lease)
+		if !ok {
+			continue
+		}
+		if !selector.Matches(labels.Set(lease.Labels)) {
+			continue
+		}
+		if leaseAlive(lease) {
+			count++
+		}
+	}
+	return count
+}
+
+// leaseAlive reports whether lease was renewed recently enough to be
+// considered held by a live agent: within twice its own
+// LeaseDurationSeconds, the same margin k8s.io/client-go/tools/
+// leaderelection uses to decide a held Lease has expired. A Lease with no
+// RenewTime or no LeaseDurationSeconds has never been renewed and is never
+// alive.
+func leaseAlive(lease *coordv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	maxAge := time.Duration(*lease.Spec.LeaseDurationSeconds) * 2 * time.Second
+	return time.Since(lease.Spec.RenewTime.Time) < maxAge
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package counter
+
+import (
+	"testing"
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+func leaseIndexer(t *testing.T, leases ...*coordv1.Lease) cache.Indexer {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, lease := range leases {
+		if err := indexer.Add(lease); err != nil {
+			t.Fatalf("failed to add lease %q to indexer: %s", lease.Name, err.Error())
+		}
+	}
+	return indexer
+}
+
+func lease(name string, labels map[string]string, age time.Duration, durationSeconds int32) *coordv1.Lease {
+	renew := metav1.NewMicroTime(time.Now().Add(-age))
+	return &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: coordv1.LeaseSpec{
+			RenewTime:            &renew,
+			LeaseDurationSeconds: &durationSeconds,
+		},
+	}
+}
+
+func TestCountAgentsCountsOnlyFreshLeasesMatchingSelector(t *testing.T) {
+	indexer := leaseIndexer(t,
+		lease("agent-1", map[string]string{"role": "network-agent"}, 1*time.Second, 15),
+		lease("agent-2", map[string]string{"role": "network-agent"}, 40*time.Second, 15),
+		lease("agent-3", map[string]string{"role": "ipam"}, 1*time.Second, 15),
+	)
+	c := &leaseCounter{indexer: indexer}
+	got := c.CountAgents(labels.SelectorFromSet(labels.Set{"role": "network-agent"}))
+	if got != 1 {
+		t.Errorf("got CountAgents()=%d, want 1 (agent-2 is stale, agent-3 doesn't match)", got)
+	}
+}
+
+func TestCountAgentsIgnoresLeaseWithNoRenewTime(t *testing.T) {
+	indexer := leaseIndexer(t, &coordv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: "agent-1"}})
+	c := &leaseCounter{indexer: indexer}
+	if got := c.CountAgents(labels.Everything()); got != 0 {
+		t.Errorf("got CountAgents()=%d for a Lease with no RenewTime, want 0", got)
+	}
+}
+
+type fakeLeaseCounter struct {
+	calls int
+	count int
+}
+
+func (f *fakeLeaseCounter) CountAgents(selector labels.Selector) int {
+	f.calls++
+	return f.count
+}
+
+func TestCachedCounterMemoizesWithinTTL(t *testing.T) {
+	inner := &fakeLeaseCounter{count: 3}
+	c := NewCachedCounter(inner, time.Minute)
+	sel := labels.Everything()
+	if got := c.CountAgents(sel); got != 3 {
+		t.Errorf("got CountAgents()=%d, want 3", got)
+	}
+	inner.count = 5
+	if got := c.CountAgents(sel); got != 3 {
+		t.Errorf("got CountAgents()=%d on the second call within ttl, want the cached 3", got)
+	}
+	if inner.calls != 1 {
+		t.Errorf("got %d calls to the inner counter, want 1 (second call should have hit the cache)", inner.calls)
+	}
+}
+
+func TestCachedCounterRecomputesAfterTTL(t *testing.T) {
+	inner := &fakeLeaseCounter{count: 3}
+	c := NewCachedCounter(inner, time.Nanosecond)
+	sel := labels.Everything()
+	c.CountAgents(sel)
+	time.Sleep(time.Millisecond)
+	inner.count = 7
+	if got := c.CountAgents(sel); got != 7 {
+		t.Errorf("got CountAgents()=%d after ttl elapsed, want the fresh 7", got)
+	}
+}
+
+func TestCachedCounterZeroTTLDisablesCaching(t *testing.T) {
+	inner := &fakeLeaseCounter{count: 3}
+	c := NewCachedCounter(inner, 0)
+	sel := labels.Everything()
+	c.CountAgents(sel)
+	c.CountAgents(sel)
+	if inner.calls != 2 {
+		t.Errorf("got %d calls to the inner counter with ttl=0, want 2 (every call should pass through)", inner.calls)
+	}
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package counter
+
+import "hash/fnv"
+
+// ShardIndex deterministically maps name into [0, shardCount), so that
+// replicas of a controller which all compute the same shardCount (e.g. from
+// a shared LeaseCounter's CountAgents) agree on which one of them owns name
+// without coordinating directly. A shardCount of zero or less always
+// returns 0, the same shard every replica falls back to when peer counting
+// is unavailable.
+func ShardIndex(name string, shardCount int) int {
+	if shardCount <= 0 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return int(h.Sum32() % uint32(shardCount))
+}
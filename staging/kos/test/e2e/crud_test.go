@@ -0,0 +1,111 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	network "k8s.io/examples/staging/kos/pkg/apis/network"
+	clientsetinternalversion "k8s.io/examples/staging/kos/pkg/client/clientset/internalversion"
+	networkinformers "k8s.io/examples/staging/kos/pkg/client/informers/internalversion"
+)
+
+// TestSubnetCRUDAndWatchUnderChurn creates, updates and deletes several
+// Subnets back to back through the real clientset, and checks that a
+// SubnetInformer watching the same namespace eventually reports every one
+// of those Add/Update/Delete events despite the churn, the way a
+// controller's informer cache is expected to converge.
+func TestSubnetCRUDAndWatchUnderChurn(t *testing.T) {
+	config, tearDown := StartTestServer(t)
+	defer tearDown()
+
+	client, err := clientsetinternalversion.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("failed to build clientset: %s", err.Error())
+	}
+
+	const namespace = "e2e-crud"
+
+	factory := networkinformers.NewSharedInformerFactory(client, 30*time.Second)
+	informer := factory.Network().InternalVersion().Subnets(namespace).Informer()
+
+	seen := make(chan string, 64)
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			seen <- "add:" + obj.(*network.Subnet).Name
+		},
+		UpdateFunc: func(old, new interface{}) {
+			seen <- "update:" + new.(*network.Subnet).Name
+		},
+		DeleteFunc: func(obj interface{}) {
+			if subnet, ok := obj.(*network.Subnet); ok {
+				seen <- "delete:" + subnet.Name
+			} else if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				seen <- "delete:" + tombstone.Obj.(*network.Subnet).Name
+			}
+		},
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	const count = 5
+	subnets := client.Network().Subnets(namespace)
+
+	want := map[string]bool{}
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("subnet-%d", i)
+		created, err := subnets.Create(&network.Subnet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       network.SubnetSpec{IPv4: fmt.Sprintf("10.%d.0.0/24", i), VNI: uint32(i + 1)},
+		})
+		if err != nil {
+			t.Fatalf("Create(%s) failed: %s", name, err.Error())
+		}
+		want["add:"+name] = true
+
+		created.Spec.DNS = network.DNSConfig{}
+		if _, err := subnets.Update(created); err != nil {
+			t.Fatalf("Update(%s) failed: %s", name, err.Error())
+		}
+		want["update:"+name] = true
+
+		if err := subnets.Delete(name, &metav1.DeleteOptions{}); err != nil {
+			t.Fatalf("Delete(%s) failed: %s", name, err.Error())
+		}
+		want["delete:"+name] = true
+	}
+
+	deadline := time.After(30 * time.Second)
+	for len(want) > 0 {
+		select {
+		case event := <-seen:
+			delete(want, event)
+		case <-deadline:
+			t.Fatalf("timed out waiting for informer events, still missing: %v", want)
+		}
+	}
+}
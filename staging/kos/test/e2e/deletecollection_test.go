@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +build e2e
+
+package e2e
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	network "k8s.io/examples/staging/kos/pkg/apis/network"
+	clientsetinternalversion "k8s.io/examples/staging/kos/pkg/client/clientset/internalversion"
+)
+
+// TestDeleteCollectionWithLabelSelector confirms that DeleteCollection
+// against the real, etcd-backed server only removes Subnets matching the
+// given label selector, leaving the rest -- the same selector semantics
+// that pkg/client/clientset/versioned/typed/network/v1alpha1/fake's
+// FakeSubnets.DeleteCollection already gives controllers under test (see
+// fake_subnet.go in that package).
+func TestDeleteCollectionWithLabelSelector(t *testing.T) {
+	config, tearDown := StartTestServer(t)
+	defer tearDown()
+
+	client, err := clientsetinternalversion.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("failed to build clientset: %s", err.Error())
+	}
+
+	const namespace = "e2e-deletecollection"
+	subnets := client.Network().Subnets(namespace)
+
+	for i, doomed := range []bool{true, true, false, true, false} {
+		name := fmt.Sprintf("subnet-%d", i)
+		labels := map[string]string{}
+		if doomed {
+			labels["churn"] = "doomed"
+		}
+		if _, err := subnets.Create(&network.Subnet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Spec:       network.SubnetSpec{IPv4: fmt.Sprintf("10.%d.0.0/24", i), VNI: uint32(i + 1)},
+		}); err != nil {
+			t.Fatalf("Create(%s) failed: %s", name, err.Error())
+		}
+	}
+
+	err = subnets.DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{LabelSelector: "churn=doomed"})
+	if err != nil {
+		t.Fatalf("DeleteCollection failed: %s", err.Error())
+	}
+
+	remaining, err := subnets.List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List failed: %s", err.Error())
+	}
+	if len(remaining.Items) != 2 {
+		t.Fatalf("expected 2 Subnets to survive DeleteCollection, got %d", len(remaining.Items))
+	}
+	for _, subnet := range remaining.Items {
+		if subnet.Labels["churn"] == "doomed" {
+			t.Errorf("Subnet %s labeled churn=doomed survived DeleteCollection", subnet.Name)
+		}
+	}
+}
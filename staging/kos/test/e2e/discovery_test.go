@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +build e2e
+
+package e2e
+
+import (
+	"testing"
+
+	"k8s.io/client-go/discovery"
+)
+
+// TestDiscoverShortNamesAndCategories confirms that the ShortNames and
+// Categories registry.REST carries (see pkg/registry/registry.go) surface
+// through the discovery client the same way any other built-in resource's
+// do, so kubectl's short-name and category lookups work against this
+// server.
+func TestDiscoverShortNamesAndCategories(t *testing.T) {
+	config, tearDown := StartTestServer(t)
+	defer tearDown()
+
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		t.Fatalf("failed to build discovery client: %s", err.Error())
+	}
+
+	resources, err := client.ServerResourcesForGroupVersion("network.example.com/v1alpha1")
+	if err != nil {
+		t.Fatalf("ServerResourcesForGroupVersion failed: %s", err.Error())
+	}
+
+	byName := map[string]struct {
+		shortNames []string
+		categories []string
+	}{}
+	for _, resource := range resources.APIResources {
+		byName[resource.Name] = struct {
+			shortNames []string
+			categories []string
+		}{resource.ShortNames, resource.Categories}
+	}
+
+	subnet, ok := byName["subnets"]
+	if !ok {
+		t.Fatalf("discovery did not list the subnets resource")
+	}
+	if len(subnet.shortNames) == 0 {
+		t.Errorf("subnets resource has no ShortNames in discovery")
+	}
+	if len(subnet.categories) == 0 {
+		t.Errorf("subnets resource has no Categories in discovery")
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +build e2e
+
+// Package e2e is meant to boot the real KOS network API server -- the
+// same genericapiserver wired up by pkg/cmd/server, backed by a real,
+// embedded etcd instead of a fake -- and drive it with the real
+// internalversion clientset and informers. It would complement the
+// fake-clientset tests next to
+// pkg/client/clientset/versioned/typed/network/v1alpha1/fake: those catch
+// controller logic bugs cheaply and run in every `go test ./...`, this is
+// meant to catch storage/REST/conversion bugs that only show up against a
+// real etcd-backed implementation, at the cost of needing an etcd binary
+// on PATH.
+//
+// It does not build yet. pkg/cmd/server.NetworkAPIServerOptions.Config
+// returns an *apiserver.Config built from apiserver.Codecs, apiserver.Scheme
+// and apiserver.ExtraConfig, none of which exist in pkg/apiserver in this
+// tree (it currently holds only default_storage_factory_builder.go); that
+// Config's Complete().New() call is also what's missing. This package is
+// written the way that real server will be driven once pkg/apiserver gets
+// that scaffolding, the same as pkg/cmd/server/start.go already assumes it,
+// but until then `go build`/`go test` for this package -- gated behind
+// `+build e2e` precisely so the rest of `go test ./...` isn't blocked on
+// it -- fail at the same missing-package step as the generated
+// clientset/informer/lister packages this tree is also missing. Run it
+// with `make test-e2e`, not as part of the normal test suite, and expect
+// it to fail to build until pkg/apiserver is filled in.
+package e2e
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	restclient "k8s.io/client-go/rest"
+
+	etcd3testing "k8s.io/apiserver/pkg/storage/etcd3/testing"
+
+	"k8s.io/examples/staging/kos/pkg/cmd/server"
+)
+
+// StartTestServer boots a NetworkAPIServer against a freshly created,
+// embedded etcd instance, waits for it to become ready, and returns a
+// loopback client config for it plus a tearDown func the caller must defer
+// to stop both the apiserver and the etcd instance it started. See the
+// package doc comment: this calls through to o.Config(), which cannot
+// build until pkg/apiserver.Config/ExtraConfig/Codecs/Scheme exist.
+func StartTestServer(t *testing.T) (*restclient.Config, func()) {
+	t.Helper()
+
+	_, etcdConfig := etcd3testing.NewUnsecuredEtcd3TestClientServer(t)
+
+	certDir, err := ioutil.TempDir("", "kos-apiserver-e2e")
+	if err != nil {
+		t.Fatalf("failed to create cert directory: %s", err.Error())
+	}
+
+	o := server.NewNetworkAPIServerOptions(os.Stdout, os.Stderr)
+	o.RecommendedOptions.Etcd.StorageConfig = *etcdConfig
+	o.RecommendedOptions.SecureServing.BindAddress = net.ParseIP("127.0.0.1")
+	o.RecommendedOptions.SecureServing.BindPort = 0
+	o.RecommendedOptions.SecureServing.ServerCert.CertDirectory = certDir
+	// CheckSubnetsConflicts is validator-controller territory; leave it on
+	// so the REST layer's own conflict detection gets exercised too.
+
+	if err := o.Complete(); err != nil {
+		os.RemoveAll(certDir)
+		t.Fatalf("failed to complete server options: %s", err.Error())
+	}
+	config, err := o.Config()
+	if err != nil {
+		os.RemoveAll(certDir)
+		t.Fatalf("failed to build server config: %s", err.Error())
+	}
+
+	stopCh := make(chan struct{})
+	serverErrCh := make(chan error, 1)
+	go func() {
+		serverErrCh <- o.RunNetworkAPIServer(stopCh)
+	}()
+
+	loopbackConfig := config.GenericConfig.LoopbackClientConfig
+	if err := waitForServerUp(loopbackConfig, serverErrCh); err != nil {
+		close(stopCh)
+		os.RemoveAll(certDir)
+		t.Fatalf("server never came up: %s", err.Error())
+	}
+
+	tearDown := func() {
+		close(stopCh)
+		os.RemoveAll(certDir)
+	}
+	return loopbackConfig, tearDown
+}
+
+// waitForServerUp polls the server's discovery endpoint until it answers
+// or the server goroutine exits with an error, whichever happens first.
+func waitForServerUp(config *restclient.Config, serverErrCh <-chan error) error {
+	client, err := restclient.UnversionedRESTClientFor(config)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-serverErrCh:
+			return fmt.Errorf("server exited before becoming ready: %s", err.Error())
+		default:
+		}
+		if _, err := client.Get().AbsPath("/healthz").DoRaw(); err == nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("timed out waiting for /healthz")
+}
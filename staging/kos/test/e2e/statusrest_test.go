@@ -0,0 +1,77 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// +build e2e
+
+package e2e
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	network "k8s.io/examples/staging/kos/pkg/apis/network"
+	clientsetinternalversion "k8s.io/examples/staging/kos/pkg/client/clientset/internalversion"
+)
+
+// TestStatusRESTRejectsSpecChanges confirms that a write through the
+// status subresource can change Status but has Spec forced back to
+// whatever is already stored, the same guarantee subnetStatusStrategy's
+// PrepareForUpdate gives the fake clientset (see
+// pkg/registry/network/subnet/strategy.go).
+func TestStatusRESTRejectsSpecChanges(t *testing.T) {
+	config, tearDown := StartTestServer(t)
+	defer tearDown()
+
+	client, err := clientsetinternalversion.NewForConfig(config)
+	if err != nil {
+		t.Fatalf("failed to build clientset: %s", err.Error())
+	}
+
+	const namespace = "e2e-status"
+	subnets := client.Network().Subnets(namespace)
+
+	created, err := subnets.Create(&network.Subnet{
+		ObjectMeta: metav1.ObjectMeta{Name: "subnet1", Namespace: namespace},
+		Spec:       network.SubnetSpec{IPv4: "10.0.0.0/24", VNI: 1},
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %s", err.Error())
+	}
+
+	originalIPv4 := created.Spec.IPv4
+	created.Spec.IPv4 = "10.0.1.0/24"
+	created.Status.Validated = true
+
+	updated, err := subnets.UpdateStatus(created)
+	if err != nil {
+		t.Fatalf("UpdateStatus failed: %s", err.Error())
+	}
+	if !updated.Status.Validated {
+		t.Fatalf("UpdateStatus did not persist Status.Validated")
+	}
+	if updated.Spec.IPv4 != originalIPv4 {
+		t.Fatalf("UpdateStatus let Spec.IPv4 change to %q, want unchanged %q", updated.Spec.IPv4, originalIPv4)
+	}
+
+	got, err := subnets.Get("subnet1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get failed: %s", err.Error())
+	}
+	if got.Spec.IPv4 != originalIPv4 {
+		t.Fatalf("stored Spec.IPv4 is %q, want unchanged %q", got.Spec.IPv4, originalIPv4)
+	}
+}